@@ -0,0 +1,70 @@
+package antivirus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Scanner checks uploaded files for malware before they are stored.
+type Scanner interface {
+	Name() string
+	Scan(r io.Reader) (Result, error)
+}
+
+// Result is the verdict on one file.
+type Result struct {
+	Infected  bool
+	Signature string // name of the detected malware when Infected
+}
+
+var (
+	ErrUnknownScanner = errors.New("unknown virus scanner")
+	ErrScanFailed     = errors.New("virus scan failed")
+)
+
+var (
+	mu       sync.RWMutex
+	scanners = map[string]Scanner{}
+)
+
+// Register makes a scanner available under its name.
+func Register(s Scanner) {
+	mu.Lock()
+	defer mu.Unlock()
+	scanners[s.Name()] = s
+}
+
+// Get returns a registered scanner.
+func Get(name string) (Scanner, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := scanners[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownScanner, name)
+	}
+	return s, nil
+}
+
+// Default returns the scanner selected by VIRUS_SCANNER (clamav when
+// unset). The fake scanner lets everything but test signatures through, so
+// it is only used when VIRUS_SCANNER=fake asks for it.
+func Default() (Scanner, error) {
+	name := os.Getenv("VIRUS_SCANNER")
+	if name == "" {
+		name = "clamav"
+	}
+	return Get(name)
+}
+
+func init() {
+	Register(NewFakeScanner())
+
+	addr := os.Getenv("CLAMAV_ADDR")
+	if addr == "" {
+		addr = "tcp:127.0.0.1:3310"
+	}
+	Register(NewClamdScanner(addr))
+}
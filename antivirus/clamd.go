@@ -0,0 +1,96 @@
+package antivirus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ClamdScanner streams files to a clamd daemon with the INSTREAM command.
+// The address is "unix:/path/to/clamd.sock", "tcp:host:port" or plain
+// "host:port".
+type ClamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// clamdChunk is the INSTREAM chunk size; clamd's StreamMaxLength still caps
+// the total.
+const clamdChunk = 32 * 1024
+
+func NewClamdScanner(addr string) *ClamdScanner {
+	network, address := "tcp", addr
+	if rest, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, address = "unix", rest
+	} else if rest, ok := strings.CutPrefix(addr, "tcp:"); ok {
+		address = rest
+	}
+	return &ClamdScanner{network: network, address: address, timeout: 2 * time.Minute}
+}
+
+func (s *ClamdScanner) Name() string { return "clamav" }
+
+func (s *ClamdScanner) Scan(r io.Reader) (Result, error) {
+	conn, err := net.DialTimeout(s.network, s.address, 5*time.Second)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	// Null-terminated command ("z" prefix), then length-prefixed chunks and
+	// a zero-length chunk to finish
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	chunk := make([]byte, clamdChunk)
+	size := make([]byte, 4)
+	for {
+		n, rerr := r.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return Result{}, fmt.Errorf("%w: %v", ErrScanFailed, err)
+			}
+			if _, err := conn.Write(chunk[:n]); err != nil {
+				// clamd closes the stream when the size limit is exceeded;
+				// its reply says so
+				break
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return Result{}, rerr
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	conn.Write(size)
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Result{}, fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply reads "stream: OK", "stream: <name> FOUND" or
+// "<message> ERROR".
+func parseClamdReply(reply string) (Result, error) {
+	reply = strings.TrimRight(reply, "\x00\r\n ")
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return Result{}, fmt.Errorf("%w: clamd: %s", ErrScanFailed, reply)
+	}
+}
@@ -0,0 +1,36 @@
+package antivirus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseClamdReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  Result
+		err   error
+	}{
+		{"clean", "stream: OK\x00", Result{}, nil},
+		{"clean with newline", "stream: OK\n", Result{}, nil},
+		{"infected", "stream: Eicar-Test-Signature FOUND\x00", Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil},
+		{"signature with spaces", "stream: Win.Test.EICAR_HDB-1 (heuristic) FOUND\n", Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1 (heuristic)"}, nil},
+		{"size limit", "INSTREAM size limit exceeded. ERROR\x00", Result{}, ErrScanFailed},
+		{"stream error", "stream: Can't allocate memory ERROR\x00", Result{}, ErrScanFailed},
+		{"empty", "", Result{}, ErrScanFailed},
+		{"garbage", "PONG", Result{}, ErrScanFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseClamdReply(tt.reply)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("parseClamdReply error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("parseClamdReply = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
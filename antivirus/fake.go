@@ -0,0 +1,60 @@
+package antivirus
+
+import (
+	"bytes"
+	"io"
+)
+
+// EICAR is the industry-standard antivirus test file. Every real scanner
+// reports it, so it can be uploaded to check the rejection path end to end.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// FakeScanner flags only known test signatures. Used in development and
+// tests where no clamd is running.
+type FakeScanner struct {
+	signatures map[string][]byte
+}
+
+func NewFakeScanner() *FakeScanner {
+	return &FakeScanner{signatures: map[string][]byte{
+		"Eicar-Test-Signature": []byte(EICAR),
+	}}
+}
+
+// AddSignature makes the scanner report name for any file containing pattern.
+func (s *FakeScanner) AddSignature(name string, pattern []byte) {
+	s.signatures[name] = pattern
+}
+
+func (s *FakeScanner) Name() string { return "fake" }
+
+func (s *FakeScanner) Scan(r io.Reader) (Result, error) {
+	// Read in overlapping windows so a pattern split between reads is found
+	longest := 0
+	for _, p := range s.signatures {
+		if len(p) > longest {
+			longest = len(p)
+		}
+	}
+
+	buf := make([]byte, 0, 64*1024+longest)
+	chunk := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		for name, p := range s.signatures {
+			if bytes.Contains(buf, p) {
+				return Result{Infected: true, Signature: name}, nil
+			}
+		}
+		if len(buf) > longest {
+			buf = append(buf[:0], buf[len(buf)-longest:]...)
+		}
+		if err == io.EOF {
+			return Result{}, nil
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+}
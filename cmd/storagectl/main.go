@@ -0,0 +1,159 @@
+// Command storagectl manages the portal's file storage.
+//
+//	storagectl migrate [-remove] [dir ...]   copy local files into the configured store
+//	storagectl copy -from local -to s3       copy stored contents to another backend
+//	storagectl verify                        report paths whose contents are missing
+//	storagectl standin [-addr :9000]         run a local S3-compatible server
+//
+// migrate, copy and verify use the same MYSQL* and STORAGE_* / S3_*
+// environment as the portal.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"student-portal/config"
+	"student-portal/filestore"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: storagectl <command> [flags]
+
+commands:
+  migrate [-remove] [dir ...]  copy files written before the store into it (default: uploads storage/signatures)
+  copy -from NAME -to NAME     copy stored contents between backends (`+fmt.Sprint(filestore.Backends())+`)
+  verify                       list stored paths whose contents are missing from the backend
+  standin [-addr :9000]        run a local S3-compatible server for development`)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+	switch cmd {
+	case "migrate":
+		migrate(args)
+	case "copy":
+		copyBackends(args)
+	case "verify":
+		verify(args)
+	case "standin":
+		standin(args)
+	default:
+		usage()
+	}
+}
+
+func openStore() *filestore.Store {
+	config.ConnectDB()
+	config.MigrateDB()
+	if err := filestore.Init(config.DB); err != nil {
+		log.Fatal("❌ Storage: ", err)
+	}
+	store, err := filestore.Default()
+	if err != nil {
+		log.Fatal("❌ Storage: ", err)
+	}
+	return store
+}
+
+func migrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	remove := fs.Bool("remove", false, "delete each local file once it is stored")
+	fs.Parse(args)
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"uploads", "storage/signatures"}
+	}
+	for _, dir := range dirs {
+		// Keys are the paths the database already holds, relative to the
+		// portal's working directory
+		if filepath.IsAbs(dir) {
+			log.Fatalf("❌ %s: run storagectl from the portal directory and pass relative paths", dir)
+		}
+	}
+
+	store := openStore()
+	log.Printf("Migrating %v into the %s backend", dirs, store.Backend().Name())
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: %s does not exist, skipping", dir)
+			continue
+		}
+		res, err := store.ImportDir(dir, *remove, log.Printf)
+		if err != nil {
+			log.Fatalf("❌ %s: %v", dir, err)
+		}
+		log.Printf("✅ %s: %d imported (%d bytes), %d already stored", dir, res.Imported, res.Bytes, res.Skipped)
+	}
+}
+
+func copyBackends(args []string) {
+	fs := flag.NewFlagSet("copy", flag.ExitOnError)
+	from := fs.String("from", "", "source backend")
+	to := fs.String("to", "", "destination backend")
+	fs.Parse(args)
+	if *from == "" || *to == "" || *from == *to {
+		usage()
+	}
+
+	src, err := filestore.NewBackend(*from)
+	if err != nil {
+		log.Fatal("❌ Source: ", err)
+	}
+	dst, err := filestore.NewBackend(*to)
+	if err != nil {
+		log.Fatal("❌ Destination: ", err)
+	}
+	res, err := filestore.CopyBlobs(src, dst, log.Printf)
+	if err != nil {
+		log.Fatal("❌ Copy: ", err)
+	}
+	log.Printf("✅ %d copied (%d bytes), %d already present. Set STORAGE_BACKEND=%s to switch.", res.Imported, res.Bytes, res.Skipped, *to)
+}
+
+func verify(args []string) {
+	store := openStore()
+	missing, err := store.Verify()
+	if err != nil {
+		log.Fatal("❌ Verify: ", err)
+	}
+	for _, p := range missing {
+		fmt.Println(p)
+	}
+	if len(missing) > 0 {
+		log.Fatalf("❌ %d stored paths have no contents in the %s backend", len(missing), store.Backend().Name())
+	}
+	log.Printf("✅ Every stored path has its contents in the %s backend", store.Backend().Name())
+}
+
+func standin(args []string) {
+	fs := flag.NewFlagSet("standin", flag.ExitOnError)
+	addr := fs.String("addr", ":9000", "listen address")
+	dir := fs.String("dir", "./storage/standin", "directory holding the buckets")
+	fs.Parse(args)
+
+	accessKey, secretKey := os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("❌ Set S3_ACCESS_KEY and S3_SECRET_KEY; the portal signs requests with the same pair")
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           filestore.NewStandIn(*dir, accessKey, secretKey, os.Getenv("S3_REGION")),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("S3 stand-in listening on %s, buckets in %s", *addr, *dir)
+	log.Fatal(server.ListenAndServe())
+}
@@ -1,6 +1,9 @@
 package config
 
-import "log"
+import (
+	"fmt"
+	"log"
+)
 
 func MigrateDB() {
 	queries := []string{
@@ -284,6 +287,554 @@ func MigrateDB() {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
 		)`,
+
+		`CREATE TABLE IF NOT EXISTS payment_transactions (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			payment_id INT NOT NULL,
+			student_id INT NOT NULL,
+			amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+			payment_method VARCHAR(100),
+			kind VARCHAR(50) DEFAULT 'payment',
+			status VARCHAR(50) DEFAULT 'pending',
+			submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			posted_at TIMESTAMP NULL,
+			posted_by INT NULL,
+			FOREIGN KEY (payment_id) REFERENCES student_payments(id) ON DELETE CASCADE,
+			FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS or_series (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			series_code VARCHAR(50) UNIQUE NOT NULL,
+			cashier_id INT NOT NULL,
+			prefix VARCHAR(20) DEFAULT '',
+			start_number INT NOT NULL DEFAULT 1,
+			end_number INT NULL,
+			next_number INT NOT NULL DEFAULT 1,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (cashier_id) REFERENCES users(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS official_receipts (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			or_number VARCHAR(50) UNIQUE NOT NULL,
+			series_id INT NOT NULL,
+			sequence_no INT NOT NULL,
+			transaction_id INT NOT NULL UNIQUE,
+			payment_id INT NOT NULL,
+			student_id INT NOT NULL,
+			student_number VARCHAR(100),
+			received_from VARCHAR(255),
+			cashier_id INT NOT NULL,
+			amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+			payment_method VARCHAR(100),
+			file_path VARCHAR(255),
+			reprint_count INT DEFAULT 0,
+			last_reprinted_at TIMESTAMP NULL,
+			issued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uq_series_sequence (series_id, sequence_no),
+			FOREIGN KEY (series_id) REFERENCES or_series(id),
+			FOREIGN KEY (cashier_id) REFERENCES users(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS installment_plans (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(100) UNIQUE NOT NULL,
+			description TEXT,
+			min_downpayment_percent DECIMAL(5,2) DEFAULT 0,
+			is_default BOOLEAN DEFAULT FALSE,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS installment_plan_items (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			plan_id INT NOT NULL,
+			sequence_no INT NOT NULL,
+			term VARCHAR(50) NOT NULL,
+			percentage DECIMAL(5,2) NOT NULL,
+			UNIQUE KEY uq_plan_sequence (plan_id, sequence_no),
+			FOREIGN KEY (plan_id) REFERENCES installment_plans(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS term_calendar (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			school_year VARCHAR(50) NOT NULL,
+			semester VARCHAR(50) NOT NULL,
+			term VARCHAR(50) NOT NULL,
+			start_date DATE NULL,
+			due_date DATE NOT NULL,
+			UNIQUE KEY uq_term (school_year, semester, term)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS installment_allocations (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			transaction_id INT NOT NULL,
+			installment_id INT NOT NULL,
+			amount DECIMAL(12,2) NOT NULL,
+			allocated_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (transaction_id) REFERENCES payment_transactions(id) ON DELETE CASCADE,
+			FOREIGN KEY (installment_id) REFERENCES student_installments(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS penalty_rules (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			calc_type VARCHAR(20) NOT NULL DEFAULT 'fixed',
+			value DECIMAL(10,2) NOT NULL DEFAULT 0,
+			grace_days INT DEFAULT 0,
+			recurrence VARCHAR(20) DEFAULT 'once',
+			max_total DECIMAL(12,2) NULL,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS installment_penalties (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			installment_id INT NOT NULL,
+			rule_id INT NOT NULL,
+			period_no INT NOT NULL DEFAULT 0,
+			amount DECIMAL(12,2) NOT NULL,
+			status VARCHAR(20) DEFAULT 'assessed',
+			waived_by INT NULL,
+			waive_reason TEXT,
+			assessed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			waived_at TIMESTAMP NULL,
+			UNIQUE KEY uq_penalty_period (installment_id, rule_id, period_no),
+			FOREIGN KEY (installment_id) REFERENCES student_installments(id) ON DELETE CASCADE,
+			FOREIGN KEY (rule_id) REFERENCES penalty_rules(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS exam_permit_overrides (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			payment_id INT NOT NULL,
+			term VARCHAR(50) NOT NULL,
+			reason TEXT,
+			valid_until DATE NULL,
+			granted_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uq_permit_override (payment_id, term),
+			FOREIGN KEY (payment_id) REFERENCES student_payments(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS checkout_sessions (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			reference VARCHAR(64) UNIQUE NOT NULL,
+			provider VARCHAR(50) NOT NULL,
+			provider_ref VARCHAR(100) NOT NULL,
+			payment_id INT NOT NULL,
+			student_id INT NOT NULL,
+			amount DECIMAL(12,2) NOT NULL,
+			method VARCHAR(50),
+			status VARCHAR(30) DEFAULT 'open',
+			checkout_url VARCHAR(255),
+			transaction_id INT NULL,
+			settlement_batch_id INT NULL,
+			expires_at TIMESTAMP NULL,
+			completed_at TIMESTAMP NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uq_provider_ref (provider, provider_ref),
+			FOREIGN KEY (payment_id) REFERENCES student_payments(id) ON DELETE CASCADE,
+			FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS gateway_webhook_events (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			provider VARCHAR(50) NOT NULL,
+			event_id VARCHAR(100) NOT NULL,
+			event_type VARCHAR(50),
+			provider_ref VARCHAR(100),
+			payload TEXT,
+			status VARCHAR(20) DEFAULT 'received',
+			error TEXT,
+			received_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uq_provider_event (provider, event_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS settlement_batches (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			provider VARCHAR(50) NOT NULL,
+			file_name VARCHAR(255),
+			line_count INT DEFAULT 0,
+			matched_count INT DEFAULT 0,
+			exception_count INT DEFAULT 0,
+			missing_count INT DEFAULT 0,
+			total_amount DECIMAL(12,2) DEFAULT 0,
+			total_fees DECIMAL(12,2) DEFAULT 0,
+			uploaded_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS settlement_lines (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			batch_id INT NOT NULL,
+			provider_ref VARCHAR(100),
+			reference VARCHAR(64),
+			amount DECIMAL(12,2),
+			fee DECIMAL(12,2),
+			net DECIMAL(12,2),
+			settled_at DATE,
+			status VARCHAR(30),
+			session_id INT NULL,
+			note VARCHAR(255),
+			FOREIGN KEY (batch_id) REFERENCES settlement_batches(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS payment_proofs (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			transaction_id INT NOT NULL UNIQUE,
+			payment_id INT NOT NULL,
+			student_id INT NOT NULL,
+			reference_no VARCHAR(100) NOT NULL,
+			reference_key VARCHAR(100) NOT NULL,
+			bank_name VARCHAR(100),
+			deposit_date DATE NULL,
+			file_name VARCHAR(255),
+			file_path VARCHAR(255) NOT NULL,
+			file_size BIGINT,
+			content_type VARCHAR(100),
+			sha256 CHAR(64) NOT NULL,
+			status VARCHAR(20) DEFAULT 'submitted',
+			review_note TEXT,
+			reviewed_by INT NULL,
+			reviewed_at TIMESTAMP NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_proof_reference (reference_key),
+			INDEX idx_proof_sha256 (sha256),
+			FOREIGN KEY (transaction_id) REFERENCES payment_transactions(id) ON DELETE CASCADE,
+			FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS cashier_shifts (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			cashier_id INT NOT NULL,
+			business_date DATE NOT NULL,
+			opening_cash DECIMAL(12,2) NOT NULL DEFAULT 0,
+			opened_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			closing_cash DECIMAL(12,2) NULL,
+			cash_collected DECIMAL(12,2) NULL,
+			non_cash_collected DECIMAL(12,2) NULL,
+			expected_cash DECIMAL(12,2) NULL,
+			discrepancy DECIMAL(12,2) NULL,
+			receipt_count INT DEFAULT 0,
+			notes TEXT,
+			status VARCHAR(20) DEFAULT 'open',
+			closed_at TIMESTAMP NULL,
+			INDEX idx_shift_date (business_date),
+			FOREIGN KEY (cashier_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS system_settings (
+			setting_key VARCHAR(100) PRIMARY KEY,
+			setting_value VARCHAR(255) NOT NULL,
+			updated_by INT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS document_types (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			code VARCHAR(60) NOT NULL UNIQUE,
+			name VARCHAR(255) NOT NULL,
+			description TEXT,
+			serial_prefix VARCHAR(6) NOT NULL DEFAULT 'DOC',
+			requestable BOOLEAN DEFAULT TRUE,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS document_templates (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			document_type_id INT NOT NULL,
+			version INT NOT NULL,
+			letterhead TEXT NOT NULL,
+			office VARCHAR(255),
+			title VARCHAR(255) NOT NULL,
+			body TEXT NOT NULL,
+			signatories TEXT,
+			footer_note TEXT,
+			status VARCHAR(20) DEFAULT 'draft',
+			created_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			published_by INT NULL,
+			published_at DATETIME NULL,
+			UNIQUE KEY uniq_template_version (document_type_id, version),
+			FOREIGN KEY (document_type_id) REFERENCES document_types(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS document_signatories (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			document_type_id INT NOT NULL,
+			step INT NOT NULL,
+			user_id INT NOT NULL,
+			title VARCHAR(150) NOT NULL,
+			office VARCHAR(255),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uniq_signatory_step (document_type_id, step),
+			FOREIGN KEY (document_type_id) REFERENCES document_types(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS signatory_signatures (
+			user_id INT PRIMARY KEY,
+			file_path VARCHAR(255) NOT NULL,
+			uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS document_approvals (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			request_id INT NOT NULL,
+			step INT NOT NULL,
+			user_id INT NOT NULL,
+			signatory_name VARCHAR(255) NOT NULL,
+			title VARCHAR(150) NOT NULL,
+			office VARCHAR(255),
+			status VARCHAR(20) DEFAULT 'pending',
+			notes TEXT,
+			signature_file VARCHAR(255),
+			acted_at DATETIME NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE KEY uniq_approval_step (request_id, step),
+			INDEX idx_approval_user (user_id, status),
+			FOREIGN KEY (request_id) REFERENCES document_requests(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS document_releases (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			request_id INT NOT NULL,
+			method VARCHAR(20) NOT NULL,
+			claimant_type VARCHAR(20),
+			claimed_by VARCHAR(255),
+			relationship VARCHAR(100),
+			id_presented VARCHAR(255),
+			authorization_letter BOOLEAN DEFAULT FALSE,
+			courier VARCHAR(100),
+			tracking_number VARCHAR(100),
+			mailing_address TEXT,
+			notes TEXT,
+			released_by INT NULL,
+			released_at DATETIME NOT NULL,
+			INDEX idx_release_date (released_at),
+			FOREIGN KEY (request_id) REFERENCES document_requests(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS stored_files (
+			path VARCHAR(255) PRIMARY KEY,
+			sha256 CHAR(64) NOT NULL,
+			size BIGINT NOT NULL,
+			content_type VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_stored_sha (sha256)
+		)`,
+
+		// Chunked uploads in progress; the bytes are kept on local disk
+		`CREATE TABLE IF NOT EXISTS resumable_uploads (
+			id CHAR(32) PRIMARY KEY,
+			owner_role VARCHAR(20) NOT NULL,
+			owner_id VARCHAR(50) NOT NULL,
+			purpose VARCHAR(20) NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			upload_length BIGINT NOT NULL,
+			upload_offset BIGINT NOT NULL DEFAULT 0,
+			sha256 CHAR(64) NOT NULL,
+			status ENUM('uploading','complete','finalizing') DEFAULT 'uploading',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			INDEX idx_resumable_owner (owner_role, owner_id, status),
+			INDEX idx_resumable_expires (expires_at)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS credited_subjects (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			student_id INT NOT NULL,
+			subject_code VARCHAR(50) NOT NULL,
+			subject_name VARCHAR(255) NOT NULL,
+			units DECIMAL(4,1) NOT NULL,
+			grade VARCHAR(10),
+			source_school VARCHAR(255) NOT NULL,
+			school_year VARCHAR(50),
+			semester VARCHAR(50),
+			remarks TEXT,
+			credited_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS document_signing_keys (
+			key_id VARCHAR(32) PRIMARY KEY,
+			public_key VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS issued_documents (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			serial VARCHAR(40) NOT NULL UNIQUE,
+			request_id INT NULL,
+			student_id INT NOT NULL,
+			document_type VARCHAR(255) NOT NULL,
+			file_path VARCHAR(255) NOT NULL,
+			sha256 CHAR(64) NOT NULL,
+			signature VARCHAR(128) NOT NULL,
+			key_id VARCHAR(32) NOT NULL,
+			issued_by INT NULL,
+			issued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			revoked_at DATETIME NULL,
+			revoke_reason TEXT,
+			revoked_by INT NULL,
+			INDEX idx_issued_request (request_id),
+			FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS student_holds (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			student_id INT NOT NULL,
+			hold_type VARCHAR(30) NOT NULL,
+			reason TEXT NOT NULL,
+			source VARCHAR(20) DEFAULT 'manual',
+			blocks_enrollment BOOLEAN DEFAULT TRUE,
+			blocks_documents BOOLEAN DEFAULT TRUE,
+			blocks_grades BOOLEAN DEFAULT TRUE,
+			status VARCHAR(20) DEFAULT 'active',
+			placed_by INT NULL,
+			placed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			lifted_by INT NULL,
+			lifted_at TIMESTAMP NULL,
+			lift_reason TEXT,
+			exempt_until DATE NULL,
+			INDEX idx_hold_student_status (student_id, status),
+			FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS transmutation_tables (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			is_default BOOLEAN DEFAULT FALSE,
+			created_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS transmutation_rows (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			table_id INT NOT NULL,
+			min_percent DECIMAL(5,2) NOT NULL,
+			grade DECIMAL(3,2) NOT NULL,
+			FOREIGN KEY (table_id) REFERENCES transmutation_tables(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS grading_schemes (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			class_id INT NOT NULL UNIQUE,
+			prelim_weight DECIMAL(5,2) DEFAULT 30,
+			midterm_weight DECIMAL(5,2) DEFAULT 30,
+			finals_weight DECIMAL(5,2) DEFAULT 40,
+			transmutation_table_id INT NULL,
+			updated_by INT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (class_id) REFERENCES teacher_subjects(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS grading_components (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			scheme_id INT NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			weight DECIMAL(5,2) NOT NULL,
+			sort_order INT DEFAULT 0,
+			FOREIGN KEY (scheme_id) REFERENCES grading_schemes(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS grade_items (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			class_id INT NOT NULL,
+			component_id INT NOT NULL,
+			term VARCHAR(20) NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			max_score DECIMAL(8,2) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (class_id) REFERENCES teacher_subjects(id) ON DELETE CASCADE,
+			FOREIGN KEY (component_id) REFERENCES grading_components(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS grade_scores (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			item_id INT NOT NULL,
+			student_id INT NOT NULL,
+			score DECIMAL(8,2) NULL,
+			excused BOOLEAN DEFAULT FALSE,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE KEY uq_item_student (item_id, student_id),
+			FOREIGN KEY (item_id) REFERENCES grade_items(id) ON DELETE CASCADE,
+			FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS grading_windows (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			school_year VARCHAR(50) NOT NULL,
+			semester VARCHAR(50) NOT NULL,
+			term VARCHAR(20) NOT NULL,
+			opens_at DATETIME NOT NULL,
+			closes_at DATETIME NOT NULL,
+			updated_by INT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE KEY uq_grading_window (school_year, semester, term)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS grade_change_requests (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			grade_id INT NOT NULL,
+			requested_by INT NOT NULL,
+			old_prelim FLOAT NULL,
+			old_midterm FLOAT NULL,
+			old_finals FLOAT NULL,
+			old_final_grade FLOAT NULL,
+			new_prelim FLOAT NULL,
+			new_midterm FLOAT NULL,
+			new_finals FLOAT NULL,
+			new_final_grade FLOAT NULL,
+			reason TEXT NOT NULL,
+			status VARCHAR(20) DEFAULT 'pending',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			resolved_at DATETIME NULL,
+			FOREIGN KEY (grade_id) REFERENCES grades(id) ON DELETE CASCADE,
+			FOREIGN KEY (requested_by) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS grade_change_actions (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			request_id INT NOT NULL,
+			actor_id INT NOT NULL,
+			actor_role VARCHAR(50) NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			note TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (request_id) REFERENCES grade_change_requests(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS grade_release_batches (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			scope VARCHAR(20) NOT NULL,
+			class_id INT NULL,
+			subject_id INT NULL,
+			course_id INT NULL,
+			school_year VARCHAR(50) NULL,
+			semester VARCHAR(50) NULL,
+			action VARCHAR(10) NOT NULL,
+			release_at DATETIME NULL,
+			status VARCHAR(20) DEFAULT 'scheduled',
+			started_at DATETIME NULL,
+			affected INT DEFAULT 0,
+			error_message TEXT NULL,
+			created_by INT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			executed_at DATETIME NULL,
+			INDEX idx_release_due (status, release_at)
+		)`,
 	}
 
 	for _, query := range queries {
@@ -293,5 +844,234 @@ func MigrateDB() {
 		}
 	}
 
+	// Columns added to tables that already exist on deployed databases
+	addColumnIfMissing("student_payments", "plan_id", "INT NULL")
+	addColumnIfMissing("student_installments", "sequence_no", "INT DEFAULT 0")
+	addColumnIfMissing("student_installments", "due_date", "DATE NULL")
+	addColumnIfMissing("student_installments", "amount_paid", "DECIMAL(12,2) DEFAULT 0")
+	addColumnIfMissing("student_installments", "penalty_amount", "DECIMAL(12,2) DEFAULT 0")
+	addColumnIfMissing("payment_transactions", "claimed_by", "INT NULL")
+	addColumnIfMissing("payment_transactions", "claimed_at", "DATETIME NULL")
+	addColumnIfMissing("student_family", "guardian_email", "VARCHAR(255) NULL")
+	addColumnIfMissing("grades", "class_id", "INT NULL")
+	addColumnIfMissing("grades", "final_grade", "FLOAT NULL")
+	addColumnIfMissing("grades", "final_remark", "VARCHAR(20) NULL")
+	addColumnIfMissing("grades", "mark", "VARCHAR(10) NULL")
+	addColumnIfMissing("grades", "computed_at", "DATETIME NULL")
+	addColumnIfMissing("grade_items", "material_id", "INT NULL")
+	addColumnIfMissing("student_submissions", "score", "DECIMAL(8,2) NULL")
+	addColumnIfMissing("grades", "status", "VARCHAR(20) DEFAULT 'draft'")
+	addColumnIfMissing("grades", "submitted_at", "DATETIME NULL")
+	addColumnIfMissing("grades", "verified_by", "INT NULL")
+	addColumnIfMissing("grades", "verified_at", "DATETIME NULL")
+	addColumnIfMissing("grades", "review_note", "TEXT NULL")
+	addColumnIfMissing("grades", "school_year", "VARCHAR(50) NULL")
+	addColumnIfMissing("grades", "semester", "VARCHAR(50) NULL")
+	addColumnIfMissing("subjects", "units", "DECIMAL(4,1) DEFAULT 3.0")
+	addColumnIfMissing("subjects", "exclude_from_gwa", "BOOLEAN DEFAULT FALSE")
+	addColumnIfMissing("document_requests", "template_id", "INT NULL")
+	addColumnIfMissing("document_types", "fee_per_copy", "INT DEFAULT 0")
+	addColumnIfMissing("document_types", "rush_fee", "INT DEFAULT 0")
+	addColumnIfMissing("document_types", "processing_days", "INT DEFAULT 5")
+	addColumnIfMissing("document_types", "rush_days", "INT DEFAULT 2")
+	addColumnIfMissing("document_types", "max_copies", "INT DEFAULT 10")
+	addColumnIfMissing("document_requests", "rush", "BOOLEAN DEFAULT FALSE")
+	addColumnIfMissing("document_requests", "release_method", "VARCHAR(20) DEFAULT 'pickup'")
+	addColumnIfMissing("document_requests", "mailing_address", "TEXT NULL")
+	addColumnIfMissing("document_requests", "fee_amount", "INT DEFAULT 0")
+	addColumnIfMissing("document_requests", "payment_id", "INT NULL")
+	addColumnIfMissing("document_requests", "fee_transaction_id", "INT NULL")
+	addColumnIfMissing("document_requests", "paid_at", "DATETIME NULL")
+	addColumnIfMissing("document_requests", "claim_stub", "VARCHAR(30) NULL UNIQUE")
+	addColumnIfMissing("document_requests", "pickup_date", "DATE NULL")
+	addColumnIfMissing("document_requests", "ready_at", "DATETIME NULL")
+	addColumnIfMissing("document_requests", "released_at", "DATETIME NULL")
+	addColumnIfMissing("students", "profile_thumbnail", "VARCHAR(255) NULL")
+	addColumnIfMissing("lesson_materials", "page_count", "INT NULL")
+	addColumnIfMissing("lesson_materials", "thumbnail_path", "VARCHAR(255) NULL")
+	addColumnIfMissing("lesson_materials", "preview_text", "TEXT NULL")
+	addColumnIfMissing("student_submissions", "page_count", "INT NULL")
+	addColumnIfMissing("announcements", "image_thumbnail_path", "VARCHAR(255) NULL")
+	addColumnIfMissing("registrar_announcements", "image_thumbnail_path", "VARCHAR(255) NULL")
+	addColumnIfMissing("records_announcements", "image_thumbnail_path", "VARCHAR(255) NULL")
+	addColumnIfMissing("lesson_materials", "video_status", "VARCHAR(20) NULL")
+	addColumnIfMissing("lesson_materials", "video_attempts", "INT NOT NULL DEFAULT 0")
+	addColumnIfMissing("lesson_materials", "video_started_at", "DATETIME NULL")
+	addColumnIfMissing("lesson_materials", "video_error", "TEXT NULL")
+	addColumnIfMissing("lesson_materials", "video_duration", "INT NULL")
+	addColumnIfMissing("lesson_materials", "stream_path", "VARCHAR(255) NULL")
+	addColumnIfMissing("lesson_materials", "hls_path", "VARCHAR(255) NULL")
+	addColumnIfMissing("lesson_materials", "poster_path", "VARCHAR(255) NULL")
+
+	seeds := []string{
+		`INSERT IGNORE INTO system_settings (setting_key, setting_value)
+		 VALUES ('financial_hold_threshold', '0')`,
+
+		// Days a provider may take to settle an online payment
+		`INSERT IGNORE INTO system_settings (setting_key, setting_value)
+		 VALUES ('settlement_window_days', '3')`,
+
+		// Document types that used to be hard-coded generators
+		`INSERT IGNORE INTO document_types (code, name, description, serial_prefix) VALUES
+		 ('transcript_of_records', 'Transcript of Records', 'Complete record of released grades', 'TOR'),
+		 ('certificate_of_enrollment', 'Certificate of Enrollment', 'Proof of current enrollment', 'COE'),
+		 ('good_moral_certificate', 'Good Moral Certificate', 'Certificate of good moral character', 'GMC'),
+		 ('honorable_dismissal', 'Honorable Dismissal', 'Transfer credentials for another school', 'HD')`,
+
+		`INSERT INTO document_templates
+		 (document_type_id, version, letterhead, office, title, body, signatories, footer_note, status, published_at)
+		 SELECT d.id, 1, '{{school_name}}\n{{school_address}}\n{{school_contact}}', t.office, t.title, t.body, t.signatories,
+		        '*** This is an official computer-generated document. ***', 'published', NOW()
+		 FROM document_types d
+		 JOIN (
+			SELECT 'transcript_of_records' AS code,
+			       'OFFICE OF THE UNIVERSITY REGISTRAR' AS office,
+			       'TRANSCRIPT OF RECORDS' AS title,
+			       '{{student_info}}\n\n## ACADEMIC RECORD\n\n{{grades_table}}' AS body,
+			       '[{"name":"","title":"University Registrar","office":"Office of the University Registrar"}]' AS signatories
+			UNION ALL SELECT 'certificate_of_enrollment',
+			       'OFFICE OF THE UNIVERSITY REGISTRAR',
+			       'CERTIFICATE OF ENROLLMENT',
+			       '**TO WHOM IT MAY CONCERN:**\n\nThis is to certify that {{full_name}}, Student Number {{student_number}}, is officially enrolled as a {{year_level}} Year student of the {{course}} program for the {{semester}} semester at {{school_name}}.\n\nThis certification is being issued upon the request of the student for {{purpose_lower}}.\n\nIssued this {{date_issued_long}}.',
+			       '[{"name":"","title":"University Registrar","office":"Office of the University Registrar"}]'
+			UNION ALL SELECT 'good_moral_certificate',
+			       'OFFICE OF STUDENT AFFAIRS AND SERVICES',
+			       'CERTIFICATE OF GOOD MORAL CHARACTER',
+			       '**TO WHOM IT MAY CONCERN:**\n\nThis is to certify that {{full_name}}, Student Number {{student_number}}, a {{year_level}} Year student of the {{course}} program, has demonstrated GOOD MORAL CHARACTER during their enrollment at {{school_name}}.\n\nBased on our records, the above-named student has no pending disciplinary cases and has not violated any university rules, regulations, or policies.\n\nThis certification is being issued upon the request of the student for {{purpose_lower}}.\n\nIssued this {{date_issued_long}}.',
+			       '[{"name":"","title":"Director, Office of Student Affairs and Services","office":"{{school_name}}"}]'
+			UNION ALL SELECT 'honorable_dismissal',
+			       'OFFICE OF THE UNIVERSITY REGISTRAR',
+			       'CERTIFICATE OF HONORABLE DISMISSAL',
+			       '**TO WHOM IT MAY CONCERN:**\n\nThis is to certify that {{full_name}}, bearing Student Number {{student_number}}, was a bona fide student of {{school_name}}, enrolled in the {{course}} program.\n\nThe above-named student is hereby granted HONORABLE DISMISSAL from {{school_name}}. The student has settled all financial obligations, returned all university property, and has no pending accountabilities with the university. The student is eligible for transfer to another institution of higher learning.\n\nThis certificate is issued upon request for {{purpose_lower}}.\n\nIssued this {{date_issued_long}}.',
+			       '[{"name":"","title":"University Registrar","office":"Office of the University Registrar"}]'
+		 ) t ON t.code = d.code
+		 WHERE NOT EXISTS (SELECT 1 FROM document_templates x WHERE x.document_type_id = d.id)`,
+
+		// Full transcript layout replaces the seeded single-table version 1
+		`INSERT INTO document_templates
+		 (document_type_id, version, letterhead, office, title, body, signatories, footer_note, status, published_at)
+		 SELECT t.document_type_id, 2, t.letterhead, t.office, t.title,
+		        '{{student_info}}\n\n{{entrance_data}}\n\n## ACADEMIC RECORD\n\n{{grades_table}}\n\n{{credited_subjects}}\n\n{{end_of_record}}\n\n{{grading_legend}}',
+		        t.signatories, t.footer_note, 'published', NOW()
+		 FROM document_templates t
+		 JOIN document_types d ON d.id = t.document_type_id
+		 WHERE d.code = 'transcript_of_records' AND t.version = 1 AND t.status = 'published'
+		   AND t.body = '{{student_info}}\n\n## ACADEMIC RECORD\n\n{{grades_table}}'
+		   AND NOT EXISTS (SELECT 1 FROM document_templates x WHERE x.document_type_id = t.document_type_id AND x.version = 2)`,
+
+		`UPDATE document_templates t
+		 JOIN document_templates v ON v.document_type_id = t.document_type_id AND v.version = 2 AND v.status = 'published'
+		 JOIN document_types d ON d.id = t.document_type_id
+		 SET t.status = 'archived'
+		 WHERE d.code = 'transcript_of_records' AND t.version = 1 AND t.status = 'published'`,
+
+		// Default transmutation: 75% passing on the 1.0-5.0 scale
+		`INSERT INTO transmutation_tables (name, is_default)
+		 SELECT 'Standard (75% passing)', TRUE
+		 FROM DUAL
+		 WHERE NOT EXISTS (SELECT 1 FROM transmutation_tables)`,
+
+		`INSERT INTO transmutation_rows (table_id, min_percent, grade)
+		 SELECT t.id, r.min_percent, r.grade
+		 FROM transmutation_tables t
+		 JOIN (
+			SELECT 97 AS min_percent, 1.00 AS grade
+			UNION ALL SELECT 94, 1.25
+			UNION ALL SELECT 91, 1.50
+			UNION ALL SELECT 88, 1.75
+			UNION ALL SELECT 85, 2.00
+			UNION ALL SELECT 82, 2.25
+			UNION ALL SELECT 79, 2.50
+			UNION ALL SELECT 76, 2.75
+			UNION ALL SELECT 75, 3.00
+		 ) r
+		 WHERE t.name = 'Standard (75% passing)'
+		   AND NOT EXISTS (SELECT 1 FROM transmutation_rows x WHERE x.table_id = t.id)`,
+
+		// Grades released before the submission workflow existed
+		`UPDATE grades SET status = 'released'
+		 WHERE is_released = TRUE AND (status IS NULL OR status = 'draft')`,
+
+		// Grades recorded before they were stamped with a term get the term
+		// they were entered in: first a grading window they fall inside,
+		// then the school year that was opened last before them. Grades older
+		// than every school year stay NULL and show as unassigned
+		`UPDATE grades g
+		 JOIN grading_windows w ON g.created_at BETWEEN w.opens_at AND w.closes_at
+		 SET g.school_year = w.school_year, g.semester = w.semester
+		 WHERE g.school_year IS NULL`,
+
+		`UPDATE grades g
+		 JOIN school_year sy ON sy.id = (
+			SELECT id FROM school_year
+			WHERE created_at <= g.created_at
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		 )
+		 SET g.school_year = sy.year, g.semester = sy.semester
+		 WHERE g.school_year IS NULL`,
+
+		// Legacy prelim/midterm/finals rows
+		`UPDATE student_installments
+		 SET sequence_no = FIELD(term, 'prelim', 'midterm', 'finals')
+		 WHERE sequence_no = 0`,
+
+		`UPDATE student_installments
+		 SET amount_paid = amount
+		 WHERE status = 'paid' AND amount_paid = 0`,
+
+		// Default plan mirroring the old fixed three-term split
+		`INSERT INTO installment_plans (name, description, is_default)
+		 SELECT 'Standard (Prelim/Midterm/Finals)', 'Balance after downpayment split across the three grading terms', TRUE
+		 FROM DUAL
+		 WHERE NOT EXISTS (SELECT 1 FROM installment_plans)`,
+
+		`INSERT INTO installment_plan_items (plan_id, sequence_no, term, percentage)
+		 SELECT p.id, t.seq, t.term, t.pct
+		 FROM installment_plans p
+		 JOIN (
+			SELECT 1 AS seq, 'prelim' AS term, 33.33 AS pct
+			UNION ALL SELECT 2, 'midterm', 33.33
+			UNION ALL SELECT 3, 'finals', 33.34
+		 ) t
+		 WHERE p.name = 'Standard (Prelim/Midterm/Finals)'
+		   AND NOT EXISTS (SELECT 1 FROM installment_plan_items i WHERE i.plan_id = p.id)`,
+
+		// Videos uploaded before transcoding existed are queued once
+		`UPDATE lesson_materials SET video_status = 'pending'
+		 WHERE type = 'video' AND video_status IS NULL`,
+	}
+
+	for _, query := range seeds {
+		if _, err := DB.Exec(query); err != nil {
+			log.Fatal("Migration error: ", err)
+		}
+	}
+
 	log.Println("✅ Database migrated")
 }
+
+// addColumnIfMissing adds a column to an existing table. CREATE TABLE IF NOT
+// EXISTS never touches tables that are already there, so new columns on old
+// tables have to be added here.
+func addColumnIfMissing(table, column, definition string) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?
+	`, table, column).Scan(&count)
+	if err != nil {
+		log.Fatal("Migration error: ", err)
+	}
+
+	if count > 0 {
+		return
+	}
+
+	if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		log.Fatal("Migration error: ", err)
+	}
+
+	log.Printf("✅ Added column %s.%s", table, column)
+}
@@ -149,6 +149,8 @@ type StudentRegisterRequest struct {
 	MotherContact    string `json:"mother_contact_number"`
 	MotherAddress    string `json:"mother_address"`
 
+	GuardianEmail string `json:"guardian_email"` // receives statements of account
+
 	LastSchool     string   `json:"last_school_attended"`
 	LastSchoolYear string   `json:"last_school_year"`
 	Course         string   `json:"course"`
@@ -239,9 +241,10 @@ func RegisterStudent(c *gin.Context) {
 			father_occupation, father_contact_number, father_address,
 			mother_deceased,
 			mother_first_name, mother_middle_name, mother_last_name,
-			mother_occupation, mother_contact_number, mother_address
+			mother_occupation, mother_contact_number, mother_address,
+			guardian_email
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`,
 		studentDBID,
 		req.FatherDeceased,
@@ -250,6 +253,7 @@ func RegisterStudent(c *gin.Context) {
 		req.MotherDeceased,
 		req.MotherFirstName, req.MotherMiddleName, req.MotherLastName,
 		req.MotherOccupation, req.MotherContact, req.MotherAddress,
+		strings.TrimSpace(req.GuardianEmail),
 	)
 	if err != nil {
 		tx.Rollback()
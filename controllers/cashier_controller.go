@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"student-portal/config"
@@ -81,6 +82,7 @@ func CashierGetPendingPayments(c *gin.Context) {
 			"payment_method": method,
 			"status":         status,
 			"subjects":       subjects,
+			"transactions":   loadPendingTransactionProofs(id),
 		})
 	}
 
@@ -90,10 +92,13 @@ func CashierGetPendingPayments(c *gin.Context) {
 }
 
 // ===================== CASHIER APPROVE PAYMENT =====================
+// Posting, receipts, installment schedule at allocation ay iisang DB transaction
+// para walang kalahating approval kapag may error.
 
 func CashierApprovePayment(c *gin.Context) {
 	var req struct {
-		PaymentID int `json:"payment_id"`
+		PaymentID   int                            `json:"payment_id"`
+		Allocations []installmentAllocationRequest `json:"allocations"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -101,13 +106,21 @@ func CashierApprovePayment(c *gin.Context) {
 		return
 	}
 
-	db := config.DB
+	cashierID := c.GetInt("user_id")
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve payment"})
+		return
+	}
+	defer tx.Rollback()
 
 	var studentID, totalAmount, amountPaid int
-	err := db.QueryRow(`
+	err = tx.QueryRow(`
 		SELECT student_id, total_amount, amount_paid
 		FROM student_payments
 		WHERE id = ? AND status = 'pending'
+		FOR UPDATE
 	`, req.PaymentID).Scan(&studentID, &totalAmount, &amountPaid)
 
 	if err != nil {
@@ -115,252 +128,102 @@ func CashierApprovePayment(c *gin.Context) {
 		return
 	}
 
-	// ✅ FULL PAYMENT
-	if amountPaid >= totalAmount {
-		_, err := db.Exec(`
-			UPDATE student_payments
-			SET status = 'paid'
-			WHERE id = ?
-		`, req.PaymentID)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve payment"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"message": "payment fully approved",
-			"status":  "paid",
-		})
-		return
-	}
-
-	// ✅ PARTIAL PAYMENT — update status to partial
-	_, err = db.Exec(`
-		UPDATE student_payments
-		SET status = 'partial'
-		WHERE id = ?
-	`, req.PaymentID)
-
+	// ✅ Hindi pwedeng i-approve kung naka-claim ng ibang cashier sa queue
+	holder, holderName, err := activeClaimHolder(tx, req.PaymentID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update payment"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve payment"})
 		return
 	}
-
-	remaining := float64(totalAmount - amountPaid)
-
-	// ✅ Check kung may existing installments na bago mag-create
-	// (ibig sabihin, nagbayad na ng isang term ang student bago i-approve ng cashier)
-	var existingInstallmentCount int
-	db.QueryRow(`
-		SELECT COUNT(*) FROM student_installments WHERE payment_id = ?
-	`, req.PaymentID).Scan(&existingInstallmentCount)
-
-	hadExistingInstallments := existingInstallmentCount > 0
-
-	// ✅ Create installment rows para sa missing terms
-	err = CreateInstallmentsAfterDownpayment(req.PaymentID, remaining)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create installments"})
+	if holder != 0 && holder != cashierID {
+		c.JSON(http.StatusConflict, gin.H{"error": "payment is being processed by " + holderName})
 		return
 	}
 
-	// ✅ ONLY mark installments as paid kung may existing installment payment na ang student
-	// BAGO pa i-approve ng cashier ang downpayment.
-	//
-	// BAKIT? Kung bagong downpayment lang (walang installment payments pa),
-	// ang amountPaid ay ang downpayment amount — hindi dapat mag-trigger ng
-	// "prelim paid" kahit malaki ang downpayment kumpara sa per-term amount.
-	if hadExistingInstallments {
-		err = MarkPaidInstallments(req.PaymentID, float64(amountPaid))
-		if err != nil {
-			fmt.Println("⚠️ Warning: could not mark paid installments:", err)
-		}
+	// ✅ Validate explicit allocations bago mag-post ng kahit ano
+	if err := validateInstallmentAllocations(tx, req.PaymentID, req.Allocations); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "payment approved & installments updated",
-		"status":  "partial",
-	})
-}
-
-// CreateInstallmentsAfterDownpayment creates installment rows ONLY for terms
-// that do not already exist in student_installments for this payment.
-// This prevents duplicate entries when a student has already paid a term
-// (e.g. Prelim) before the cashier approves the downpayment.
-func CreateInstallmentsAfterDownpayment(paymentID int, remaining float64) error {
-	db := config.DB
-
-	allTerms := []string{"prelim", "midterm", "finals"}
-
-	// 1️⃣ Check which terms already exist for this payment
-	existingRows, err := db.Query(`
-		SELECT term FROM student_installments
-		WHERE payment_id = ?
-	`, paymentID)
+	// ✅ Post the submitted transactions and issue their official receipts
+	receipts, err := postPendingTransactions(tx, req.PaymentID, cashierID)
 	if err != nil {
-		return err
-	}
-	defer existingRows.Close()
-
-	existingTerms := make(map[string]bool)
-	for existingRows.Next() {
-		var term string
-		existingRows.Scan(&term)
-		existingTerms[term] = true
-	}
-
-	// 2️⃣ Filter to only terms that are missing
-	var missingTerms []string
-	for _, t := range allTerms {
-		if !existingTerms[t] {
-			missingTerms = append(missingTerms, t)
+		if errors.Is(err, errORSeriesExhausted) {
+			c.JSON(http.StatusConflict, gin.H{"error": "no OR numbers left in your active series, register a new one first"})
+			return
 		}
+		fmt.Println("❌ Receipt issuance error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue official receipt"})
+		return
 	}
 
-	// Nothing to create — all terms already recorded
-	if len(missingTerms) == 0 {
-		return nil
-	}
-
-	// 3️⃣ Split remaining balance equally across missing terms only
-	baseAmount := remaining / float64(len(missingTerms))
-	var totalInserted float64
-
-	for i, term := range missingTerms {
-		amount := baseAmount
-
-		// Last term absorbs any floating-point rounding difference
-		if i == len(missingTerms)-1 {
-			amount = remaining - totalInserted
-		}
-
-		_, err := db.Exec(`
-			INSERT INTO student_installments
-			(payment_id, term, amount)
-			VALUES (?, ?, ?)
-		`, paymentID, term, amount)
-
-		if err != nil {
-			return err
-		}
-
-		totalInserted += amount
+	status := "partial"
+	if amountPaid >= totalAmount {
+		status = "paid"
 	}
 
-	return nil
-}
-
-func MarkPaidInstallments(paymentID int, amountPaid float64) error {
-	db := config.DB
-
-	// ✅ I-fetch ang downpayment amount para ma-exclude sa increment calculation
-	// Kung wala kang downpayment_amount column, gamitin ang amount ng unang
-	// payment na ginawa (amountPaid bago pa mag-installment).
-	// Pinakasimple: i-store ang downpayment_amount sa student_payments table.
-	var downpaymentAmount float64
-	err := db.QueryRow(`
-		SELECT downpayment_amount FROM student_payments WHERE id = ?
-	`, paymentID).Scan(&downpaymentAmount)
-	if err != nil {
-		// Kung walang column, fallback sa 0 (pero dapat idagdag ang column)
-		downpaymentAmount = 0
-		fmt.Println("⚠️ Warning: could not fetch downpayment_amount:", err)
-	}
+	_, err = tx.Exec(`
+		UPDATE student_payments
+		SET status = ?
+		WHERE id = ?
+	`, status, req.PaymentID)
 
-	// Fetch all installments in term order
-	rows, err := db.Query(`
-		SELECT id, term, amount, status
-		FROM student_installments
-		WHERE payment_id = ?
-		ORDER BY FIELD(term, 'prelim', 'midterm', 'finals')
-	`, paymentID)
 	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	type installment struct {
-		id     int
-		term   string
-		amount float64
-		status string
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve payment"})
+		return
 	}
 
-	var installments []installment
-	for rows.Next() {
-		var inst installment
-		if err := rows.Scan(&inst.id, &inst.term, &inst.amount, &inst.status); err != nil {
-			return err
+	// ✅ PARTIAL — build the installment schedule from the student's plan
+	if status == "partial" {
+		if err := createInstallmentsFromPlan(tx, req.PaymentID); err != nil {
+			if errors.Is(err, errNoInstallmentPlan) {
+				c.JSON(http.StatusConflict, gin.H{"error": "no active installment plan configured"})
+				return
+			}
+			fmt.Println("❌ Installment creation error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create installments"})
+			return
 		}
-		installments = append(installments, inst)
 	}
 
-	if len(installments) == 0 {
-		return nil
-	}
-
-	// Sum ng installments na paid na
-	var alreadyPaidTotal float64
-	for _, inst := range installments {
-		if inst.status == "paid" {
-			alreadyPaidTotal += inst.amount
-		}
+	allocations, err := allocateToInstallments(tx, req.PaymentID, cashierID, receipts, req.Allocations)
+	if err != nil {
+		fmt.Println("❌ Installment allocation error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to allocate payment to installments"})
+		return
 	}
 
-	// ✅ FIXED: I-strip ang downpayment bago kalkulahin ang increment
-	// increment = (total paid) - (downpayment) - (already paid installments)
-	increment := amountPaid - downpaymentAmount - alreadyPaidTotal
-
-	if increment <= 0 {
-		fmt.Println("ℹ️ MarkPaidInstallments: no new increment to process")
-		return nil
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve payment"})
+		return
 	}
 
-	fmt.Printf("💰 MarkPaidInstallments: amountPaid=%.2f, downpayment=%.2f, alreadyPaid=%.2f, increment=%.2f\n",
-		amountPaid, downpaymentAmount, alreadyPaidTotal, increment)
-
-	const tolerance = 1.0
-
-	// Exact match first
-	for _, inst := range installments {
-		if inst.status == "paid" {
-			continue
-		}
-		diff := increment - inst.amount
-		if diff >= -tolerance && diff <= tolerance {
-			_, err := db.Exec(`
-				UPDATE student_installments
-				SET status = 'paid', paid_at = NOW()
-				WHERE id = ?
-			`, inst.id)
-			if err != nil {
-				return fmt.Errorf("failed to mark %s as paid: %w", inst.term, err)
-			}
-			fmt.Printf("✅ Marked %s (id=%d, amount=%.2f) as paid\n", inst.term, inst.id, inst.amount)
-			return nil
+	issued := []gin.H{}
+	for _, r := range receipts {
+		if _, err := ensureReceiptFile(r); err != nil {
+			fmt.Println("⚠️ Warning: could not generate receipt PDF:", err)
 		}
+		issued = append(issued, gin.H{
+			"or_number":    r.ORNumber,
+			"amount":       r.Amount,
+			"download_url": "/student/payments/receipts/" + r.ORNumber,
+		})
 	}
 
-	// Fallback
-	for _, inst := range installments {
-		if inst.status == "paid" {
-			continue
-		}
-		if increment >= inst.amount-tolerance {
-			_, err := db.Exec(`
-				UPDATE student_installments
-				SET status = 'paid', paid_at = NOW()
-				WHERE id = ?
-			`, inst.id)
-			if err != nil {
-				return fmt.Errorf("failed to mark %s as paid (fallback): %w", inst.term, err)
-			}
-			fmt.Printf("✅ Marked %s (id=%d) as paid via fallback\n", inst.term, inst.id)
-			return nil
-		}
+	if status == "paid" {
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "payment fully approved",
+			"status":      "paid",
+			"receipts":    issued,
+			"allocations": allocations,
+		})
+		return
 	}
 
-	fmt.Printf("ℹ️ MarkPaidInstallments: no installment matched increment ₱%.2f\n", increment)
-	return nil
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "payment approved & installments updated",
+		"status":      "partial",
+		"receipts":    issued,
+		"allocations": allocations,
+	})
 }
@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== CASHIER QUEUE =====================
+//
+// Worklist of every submitted transaction still waiting for approval. A
+// cashier claims a payment before working on it; the claim lapses after
+// claimTTL so an abandoned item goes back to the queue.
+
+const claimTTL = 15 * time.Minute
+
+// Aging buckets, in hours since submission
+const (
+	queueAgingHours  = 24
+	queueOverdueHour = 72
+)
+
+func queueAging(hours int) string {
+	switch {
+	case hours >= queueOverdueHour:
+		return "overdue"
+	case hours >= queueAgingHours:
+		return "aging"
+	default:
+		return "new"
+	}
+}
+
+// activeClaimHolder returns the cashier holding an unexpired claim on any
+// pending transaction of a payment, or 0 when it is free.
+func activeClaimHolder(q dbQuerier, paymentID int) (int, string, error) {
+	var holder int
+	var name string
+	err := q.QueryRow(`
+		SELECT pt.claimed_by, IFNULL(CONCAT(u.first_name, ' ', u.surname), '')
+		FROM payment_transactions pt
+		LEFT JOIN users u ON u.id = pt.claimed_by
+		WHERE pt.payment_id = ? AND pt.status = 'pending'
+		  AND pt.claimed_by IS NOT NULL
+		  AND pt.claimed_at > NOW() - INTERVAL ? SECOND
+		LIMIT 1
+	`, paymentID, int(claimTTL.Seconds())).Scan(&holder, &name)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	return holder, name, err
+}
+
+// GET /cashier/queue
+// Filters: date_from, date_to (YYYY-MM-DD), method, kind, min_amount,
+// max_amount, course (id or code), search (student id / name),
+// claimed (mine | unclaimed), aging (new | aging | overdue), page, per_page
+func CashierGetQueue(c *gin.Context) {
+	cashierID := c.GetInt("user_id")
+	ttl := int(claimTTL.Seconds())
+
+	where := []string{"pt.status = 'pending'"}
+	args := []interface{}{}
+
+	if v := c.Query("date_from"); v != "" {
+		where = append(where, "pt.submitted_at >= ?")
+		args = append(args, v)
+	}
+	if v := c.Query("date_to"); v != "" {
+		where = append(where, "pt.submitted_at < DATE_ADD(?, INTERVAL 1 DAY)")
+		args = append(args, v)
+	}
+	if v := c.Query("method"); v != "" {
+		where = append(where, "pt.payment_method = ?")
+		args = append(args, v)
+	}
+	if v := c.Query("kind"); v != "" {
+		where = append(where, "pt.kind = ?")
+		args = append(args, v)
+	}
+	if v := c.Query("min_amount"); v != "" {
+		amount, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_amount"})
+			return
+		}
+		where = append(where, "pt.amount >= ?")
+		args = append(args, amount)
+	}
+	if v := c.Query("max_amount"); v != "" {
+		amount, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_amount"})
+			return
+		}
+		where = append(where, "pt.amount <= ?")
+		args = append(args, amount)
+	}
+	if v := c.Query("course"); v != "" {
+		where = append(where, "(co.id = ? OR co.code = ?)")
+		args = append(args, v, v)
+	}
+	if v := strings.TrimSpace(c.Query("search")); v != "" {
+		like := "%" + v + "%"
+		where = append(where, "(st.student_id LIKE ? OR CONCAT(st.first_name, ' ', st.last_name) LIKE ?)")
+		args = append(args, like, like)
+	}
+	switch c.Query("claimed") {
+	case "mine":
+		where = append(where, "pt.claimed_by = ? AND pt.claimed_at > NOW() - INTERVAL ? SECOND")
+		args = append(args, cashierID, ttl)
+	case "unclaimed":
+		where = append(where, "(pt.claimed_by IS NULL OR pt.claimed_at <= NOW() - INTERVAL ? SECOND)")
+		args = append(args, ttl)
+	}
+	switch c.Query("aging") {
+	case "new":
+		where = append(where, "TIMESTAMPDIFF(HOUR, pt.submitted_at, NOW()) < ?")
+		args = append(args, queueAgingHours)
+	case "aging":
+		where = append(where, "TIMESTAMPDIFF(HOUR, pt.submitted_at, NOW()) BETWEEN ? AND ?")
+		args = append(args, queueAgingHours, queueOverdueHour-1)
+	case "overdue":
+		where = append(where, "TIMESTAMPDIFF(HOUR, pt.submitted_at, NOW()) >= ?")
+		args = append(args, queueOverdueHour)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	from := `
+		FROM payment_transactions pt
+		INNER JOIN students st ON st.id = pt.student_id
+		LEFT JOIN student_academic sa ON sa.student_id = st.id
+		LEFT JOIN courses co ON co.id = sa.course
+		LEFT JOIN users u ON u.id = pt.claimed_by
+		LEFT JOIN payment_proofs pp ON pp.transaction_id = pt.id
+		WHERE ` + strings.Join(where, " AND ")
+
+	var total int
+	if err := config.DB.QueryRow(`SELECT COUNT(*) `+from, args...).Scan(&total); err != nil {
+		fmt.Println("❌ Cashier queue count error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch queue"})
+		return
+	}
+
+	queryArgs := append([]interface{}{ttl}, args...)
+	queryArgs = append(queryArgs, perPage, (page-1)*perPage)
+
+	rows, err := config.DB.Query(`
+		SELECT pt.id, pt.payment_id, pt.amount, IFNULL(pt.payment_method, ''), pt.kind,
+		       DATE_FORMAT(pt.submitted_at, '%Y-%m-%d %H:%i:%s'),
+		       TIMESTAMPDIFF(HOUR, pt.submitted_at, NOW()),
+		       st.student_id, CONCAT(st.first_name, ' ', st.last_name),
+		       IFNULL(co.code, ''), IFNULL(sa.year_level, ''),
+		       IF(pt.claimed_at > NOW() - INTERVAL ? SECOND, IFNULL(pt.claimed_by, 0), 0),
+		       IFNULL(CONCAT(u.first_name, ' ', u.surname), ''),
+		       IFNULL(DATE_FORMAT(pt.claimed_at, '%Y-%m-%d %H:%i:%s'), ''),
+		       IFNULL(pp.status, '')
+		`+from+`
+		ORDER BY pt.submitted_at ASC, pt.id ASC
+		LIMIT ? OFFSET ?
+	`, queryArgs...)
+	if err != nil {
+		fmt.Println("❌ Cashier queue query error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch queue"})
+		return
+	}
+	defer rows.Close()
+
+	items := []gin.H{}
+	for rows.Next() {
+		var (
+			id, paymentID, ageHours, claimedBy    int
+			amount                                float64
+			method, kind, submittedAt             string
+			studentNo, name, course, yearLevel    string
+			claimedByName, claimedAt, proofStatus string
+		)
+		if err := rows.Scan(&id, &paymentID, &amount, &method, &kind, &submittedAt, &ageHours,
+			&studentNo, &name, &course, &yearLevel, &claimedBy, &claimedByName, &claimedAt, &proofStatus); err != nil {
+			continue
+		}
+
+		item := gin.H{
+			"transaction_id": id,
+			"payment_id":     paymentID,
+			"amount":         amount,
+			"payment_method": method,
+			"kind":           kind,
+			"submitted_at":   submittedAt,
+			"age_hours":      ageHours,
+			"aging":          queueAging(ageHours),
+			"student_id":     studentNo,
+			"student_name":   name,
+			"course":         course,
+			"year_level":     yearLevel,
+			"proof_status":   proofStatus,
+			"claim":          nil,
+		}
+		if claimedBy > 0 {
+			item["claim"] = gin.H{
+				"claimed_by":      claimedBy,
+				"claimed_by_name": claimedByName,
+				"claimed_at":      claimedAt,
+				"mine":            claimedBy == cashierID,
+			}
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":    items,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+		"pages":    int(math.Ceil(float64(total) / float64(perPage))),
+	})
+}
+
+// POST /cashier/queue/:payment_id/claim
+// Claims every pending transaction of the payment for the current cashier.
+// Claiming again refreshes the lock.
+func CashierClaimPayment(c *gin.Context) {
+	paymentID, err := strconv.Atoi(c.Param("payment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment id"})
+		return
+	}
+
+	cashierID := c.GetInt("user_id")
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to claim payment"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the pending rows so two cashiers claiming at once are serialized
+	rows, err := tx.Query(`
+		SELECT id FROM payment_transactions
+		WHERE payment_id = ? AND status = 'pending'
+		FOR UPDATE
+	`, paymentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to claim payment"})
+		return
+	}
+	pending := 0
+	for rows.Next() {
+		pending++
+	}
+	rows.Close()
+
+	if pending == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no pending transactions for this payment"})
+		return
+	}
+
+	holder, holderName, err := activeClaimHolder(tx, paymentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to claim payment"})
+		return
+	}
+	if holder != 0 && holder != cashierID {
+		c.JSON(http.StatusConflict, gin.H{"error": "already claimed by " + holderName})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE payment_transactions
+		SET claimed_by = ?, claimed_at = NOW()
+		WHERE payment_id = ? AND status = 'pending'
+	`, cashierID, paymentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to claim payment"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to claim payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "payment claimed",
+		"payment_id": paymentID,
+		"expires_at": time.Now().Add(claimTTL).Format("2006-01-02 15:04:05"),
+	})
+}
+
+// DELETE /cashier/queue/:payment_id/claim
+func CashierReleasePayment(c *gin.Context) {
+	paymentID, err := strconv.Atoi(c.Param("payment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment id"})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		UPDATE payment_transactions
+		SET claimed_by = NULL, claimed_at = NULL
+		WHERE payment_id = ? AND status = 'pending' AND claimed_by = ?
+	`, paymentID, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to release payment"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "you have no claim on this payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "payment released"})
+}
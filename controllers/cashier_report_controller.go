@@ -0,0 +1,707 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ===================== CASHIER SHIFTS =====================
+//
+// A cashier opens a shift with the cash in the drawer, collects during the
+// day and closes with a physical count. Expected cash is the opening amount
+// plus every cash receipt issued by that cashier during the shift; the
+// difference is recorded as the discrepancy.
+
+func isCashMethod(method string) bool {
+	return strings.EqualFold(strings.TrimSpace(method), "cash")
+}
+
+type collectionLine struct {
+	Label  string
+	Count  int
+	Amount float64
+}
+
+func (l collectionLine) toJSON() gin.H {
+	return gin.H{"label": l.Label, "count": l.Count, "amount": roundCents(l.Amount)}
+}
+
+func collectionsJSON(lines []collectionLine) []gin.H {
+	result := []gin.H{}
+	for _, l := range lines {
+		result = append(result, l.toJSON())
+	}
+	return result
+}
+
+// shiftCollections groups the receipts a cashier issued during a shift by
+// payment method. An open shift counts up to now.
+func shiftCollections(q dbQuerier, shiftID int) ([]collectionLine, error) {
+	rows, err := q.Query(`
+		SELECT IFNULL(r.payment_method, ''), COUNT(*), IFNULL(SUM(r.amount), 0)
+		FROM cashier_shifts s
+		INNER JOIN official_receipts r
+			ON r.cashier_id = s.cashier_id
+			AND r.issued_at >= s.opened_at
+			AND r.issued_at <= IFNULL(s.closed_at, NOW())
+		WHERE s.id = ?
+		GROUP BY r.payment_method
+		ORDER BY r.payment_method
+	`, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []collectionLine
+	for rows.Next() {
+		var l collectionLine
+		if err := rows.Scan(&l.Label, &l.Count, &l.Amount); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+func splitCash(lines []collectionLine) (cash, nonCash float64, count int) {
+	for _, l := range lines {
+		if isCashMethod(l.Label) {
+			cash += l.Amount
+		} else {
+			nonCash += l.Amount
+		}
+		count += l.Count
+	}
+	return roundCents(cash), roundCents(nonCash), count
+}
+
+// POST /cashier/shifts/open
+func CashierOpenShift(c *gin.Context) {
+	var req struct {
+		OpeningCash float64 `json:"opening_cash"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.OpeningCash < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "opening_cash must be zero or more"})
+		return
+	}
+
+	cashierID := c.GetInt("user_id")
+
+	var openID int
+	err := config.DB.QueryRow(`
+		SELECT id FROM cashier_shifts WHERE cashier_id = ? AND status = 'open' LIMIT 1
+	`, cashierID).Scan(&openID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "you already have an open shift", "shift_id": openID})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		INSERT INTO cashier_shifts (cashier_id, business_date, opening_cash, status)
+		VALUES (?, CURDATE(), ?, 'open')
+	`, cashierID, req.OpeningCash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open shift"})
+		return
+	}
+
+	shiftID, _ := res.LastInsertId()
+	fmt.Printf("✅ Cashier %d opened shift %d\n", cashierID, shiftID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shift opened", "shift_id": shiftID})
+}
+
+// GET /cashier/shifts/current
+func CashierGetCurrentShift(c *gin.Context) {
+	var (
+		shiftID           int
+		openingCash       float64
+		openedAt, bizDate string
+	)
+	err := config.DB.QueryRow(`
+		SELECT id, opening_cash, DATE_FORMAT(opened_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(business_date, '%Y-%m-%d')
+		FROM cashier_shifts
+		WHERE cashier_id = ? AND status = 'open'
+		LIMIT 1
+	`, c.GetInt("user_id")).Scan(&shiftID, &openingCash, &openedAt, &bizDate)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"shift": nil})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shift"})
+		return
+	}
+
+	lines, err := shiftCollections(config.DB, shiftID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute collections"})
+		return
+	}
+	cash, nonCash, count := splitCash(lines)
+
+	c.JSON(http.StatusOK, gin.H{
+		"shift": gin.H{
+			"shift_id":           shiftID,
+			"business_date":      bizDate,
+			"opened_at":          openedAt,
+			"opening_cash":       openingCash,
+			"cash_collected":     cash,
+			"non_cash_collected": nonCash,
+			"expected_cash":      roundCents(openingCash + cash),
+			"receipt_count":      count,
+			"by_method":          collectionsJSON(lines),
+		},
+	})
+}
+
+// POST /cashier/shifts/close
+func CashierCloseShift(c *gin.Context) {
+	var req struct {
+		ClosingCash *float64 `json:"closing_cash"`
+		Notes       string   `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ClosingCash == nil || *req.ClosingCash < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "closing_cash is required"})
+		return
+	}
+
+	cashierID := c.GetInt("user_id")
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to close shift"})
+		return
+	}
+	defer tx.Rollback()
+
+	var shiftID int
+	var openingCash float64
+	err = tx.QueryRow(`
+		SELECT id, opening_cash FROM cashier_shifts WHERE cashier_id = ? AND status = 'open' LIMIT 1
+		FOR UPDATE
+	`, cashierID).Scan(&shiftID, &openingCash)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no open shift"})
+		return
+	}
+
+	// Receipts are numbered under a lock on the cashier's OR series. Taking
+	// it here too means a payment being posted right now is either counted
+	// in these totals or issued after the close, never lost in between
+	series, err := tx.Query(`SELECT id FROM or_series WHERE cashier_id = ? FOR UPDATE`, cashierID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to close shift"})
+		return
+	}
+	series.Close()
+
+	// Freeze the end of the shift first so the totals match what is stored
+	if _, err := tx.Exec(`UPDATE cashier_shifts SET closed_at = NOW() WHERE id = ?`, shiftID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to close shift"})
+		return
+	}
+
+	lines, err := shiftCollections(tx, shiftID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute collections"})
+		return
+	}
+	cash, nonCash, count := splitCash(lines)
+	expected := roundCents(openingCash + cash)
+	discrepancy := roundCents(*req.ClosingCash - expected)
+
+	_, err = tx.Exec(`
+		UPDATE cashier_shifts
+		SET closing_cash = ?, cash_collected = ?, non_cash_collected = ?, expected_cash = ?,
+		    discrepancy = ?, receipt_count = ?, notes = ?, status = 'closed'
+		WHERE id = ?
+	`, *req.ClosingCash, cash, nonCash, expected, discrepancy, count, req.Notes, shiftID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to close shift"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to close shift"})
+		return
+	}
+
+	if discrepancy != 0 {
+		fmt.Printf("⚠️ Shift %d closed with discrepancy %.2f\n", shiftID, discrepancy)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Shift closed",
+		"shift_id":           shiftID,
+		"opening_cash":       openingCash,
+		"cash_collected":     cash,
+		"non_cash_collected": nonCash,
+		"expected_cash":      expected,
+		"closing_cash":       *req.ClosingCash,
+		"discrepancy":        discrepancy,
+		"receipt_count":      count,
+		"by_method":          collectionsJSON(lines),
+	})
+}
+
+type shiftSummary struct {
+	ID          int
+	Cashier     string
+	Status      string
+	OpenedAt    string
+	ClosedAt    string
+	OpeningCash float64
+	ClosingCash sql.NullFloat64
+	Expected    sql.NullFloat64
+	Discrepancy sql.NullFloat64
+	Notes       string
+}
+
+func nullableAmount(v sql.NullFloat64) interface{} {
+	if !v.Valid {
+		return nil
+	}
+	return v.Float64
+}
+
+func (s shiftSummary) toJSON() gin.H {
+	return gin.H{
+		"shift_id":      s.ID,
+		"cashier":       s.Cashier,
+		"status":        s.Status,
+		"opened_at":     s.OpenedAt,
+		"closed_at":     s.ClosedAt,
+		"opening_cash":  s.OpeningCash,
+		"closing_cash":  nullableAmount(s.ClosingCash),
+		"expected_cash": nullableAmount(s.Expected),
+		"discrepancy":   nullableAmount(s.Discrepancy),
+		"notes":         s.Notes,
+	}
+}
+
+func loadShifts(date string, cashierID int) ([]shiftSummary, error) {
+	rows, err := config.DB.Query(`
+		SELECT s.id, IFNULL(CONCAT(u.first_name, ' ', u.surname), u.username), s.status,
+		       DATE_FORMAT(s.opened_at, '%Y-%m-%d %H:%i:%s'),
+		       IFNULL(DATE_FORMAT(s.closed_at, '%Y-%m-%d %H:%i:%s'), ''),
+		       s.opening_cash, s.closing_cash, s.expected_cash, s.discrepancy, IFNULL(s.notes, '')
+		FROM cashier_shifts s
+		INNER JOIN users u ON u.id = s.cashier_id
+		WHERE s.business_date = ? AND (? = 0 OR s.cashier_id = ?)
+		ORDER BY s.opened_at
+	`, date, cashierID, cashierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shifts []shiftSummary
+	for rows.Next() {
+		var s shiftSummary
+		if err := rows.Scan(&s.ID, &s.Cashier, &s.Status, &s.OpenedAt, &s.ClosedAt, &s.OpeningCash,
+			&s.ClosingCash, &s.Expected, &s.Discrepancy, &s.Notes); err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, s)
+	}
+	return shifts, nil
+}
+
+func reportDate(c *gin.Context) (string, bool) {
+	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return "", false
+	}
+	return date, true
+}
+
+// GET /cashier/shifts?date=&cashier_id=
+func CashierGetShifts(c *gin.Context) {
+	date, ok := reportDate(c)
+	if !ok {
+		return
+	}
+	cashierID, _ := strconv.Atoi(c.Query("cashier_id"))
+
+	shifts, err := loadShifts(date, cashierID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shifts"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, s := range shifts {
+		result = append(result, s.toJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"date": date, "shifts": result})
+}
+
+// ===================== DAILY COLLECTION REPORT =====================
+
+type dailyCollectionReport struct {
+	Date      string
+	ByMethod  []collectionLine
+	ByCashier []collectionLine
+	Shifts    []shiftSummary
+	Total     float64
+	Count     int
+}
+
+func groupDailyCollections(date, groupBy string) ([]collectionLine, error) {
+	rows, err := config.DB.Query(`
+		SELECT `+groupBy+`, COUNT(*), IFNULL(SUM(r.amount), 0)
+		FROM official_receipts r
+		LEFT JOIN users u ON u.id = r.cashier_id
+		WHERE r.issued_at >= ? AND r.issued_at < DATE_ADD(?, INTERVAL 1 DAY)
+		GROUP BY 1
+		ORDER BY 1
+	`, date, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []collectionLine
+	for rows.Next() {
+		var l collectionLine
+		if err := rows.Scan(&l.Label, &l.Count, &l.Amount); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+func loadDailyCollectionReport(date string) (dailyCollectionReport, error) {
+	report := dailyCollectionReport{Date: date}
+
+	var err error
+	report.ByMethod, err = groupDailyCollections(date, "IFNULL(r.payment_method, '')")
+	if err != nil {
+		return report, err
+	}
+	report.ByCashier, err = groupDailyCollections(date, "IFNULL(CONCAT(u.first_name, ' ', u.surname), 'Unknown')")
+	if err != nil {
+		return report, err
+	}
+	report.Shifts, err = loadShifts(date, 0)
+	if err != nil {
+		return report, err
+	}
+
+	for _, l := range report.ByMethod {
+		report.Total += l.Amount
+		report.Count += l.Count
+	}
+	report.Total = roundCents(report.Total)
+
+	return report, nil
+}
+
+// GET /cashier/reports/daily?date=YYYY-MM-DD&format=json|csv|pdf
+func CashierDailyCollectionReport(c *gin.Context) {
+	date, ok := reportDate(c)
+	if !ok {
+		return
+	}
+
+	report, err := loadDailyCollectionReport(date)
+	if err != nil {
+		fmt.Println("❌ Daily report error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=collections_%s.csv", date))
+		writeDailyCollectionCSV(c, report)
+	case "pdf":
+		c.Header("Content-Type", "application/pdf")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=collections_%s.pdf", date))
+		if err := generateDailyCollectionPDF(report).Output(c.Writer); err != nil {
+			fmt.Println("❌ Daily report PDF error:", err)
+		}
+	default:
+		shifts := []gin.H{}
+		for _, s := range report.Shifts {
+			shifts = append(shifts, s.toJSON())
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"date":          report.Date,
+			"total":         report.Total,
+			"receipt_count": report.Count,
+			"by_method":     collectionsJSON(report.ByMethod),
+			"by_cashier":    collectionsJSON(report.ByCashier),
+			"shifts":        shifts,
+		})
+	}
+}
+
+func csvAmount(v sql.NullFloat64) string {
+	if !v.Valid {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", v.Float64)
+}
+
+func writeDailyCollectionCSV(c *gin.Context, report dailyCollectionReport) {
+	w := csv.NewWriter(c.Writer)
+
+	w.Write([]string{"Daily Collection Report", report.Date})
+	w.Write(nil)
+	w.Write([]string{"Payment Method", "Receipts", "Amount"})
+	for _, l := range report.ByMethod {
+		w.Write([]string{l.Label, strconv.Itoa(l.Count), fmt.Sprintf("%.2f", l.Amount)})
+	}
+	w.Write([]string{"TOTAL", strconv.Itoa(report.Count), fmt.Sprintf("%.2f", report.Total)})
+	w.Write(nil)
+	w.Write([]string{"Cashier", "Receipts", "Amount"})
+	for _, l := range report.ByCashier {
+		w.Write([]string{l.Label, strconv.Itoa(l.Count), fmt.Sprintf("%.2f", l.Amount)})
+	}
+	w.Write(nil)
+	w.Write([]string{"Shift", "Cashier", "Status", "Opened", "Closed", "Opening Cash", "Expected Cash", "Closing Cash", "Discrepancy", "Notes"})
+	for _, s := range report.Shifts {
+		w.Write([]string{strconv.Itoa(s.ID), s.Cashier, s.Status, s.OpenedAt, s.ClosedAt,
+			fmt.Sprintf("%.2f", s.OpeningCash), csvAmount(s.Expected), csvAmount(s.ClosingCash),
+			csvAmount(s.Discrepancy), s.Notes})
+	}
+
+	w.Flush()
+}
+
+// reportTable writes a bordered table. The first textCols columns are left
+// aligned, the remaining (amount) columns right aligned.
+func reportTable(pdf *gofpdf.Fpdf, widths []float64, header []string, rows [][]string, textCols int) {
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(230, 240, 235)
+	for i, h := range header {
+		pdf.CellFormat(widths[i], 7, h, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Arial", "", 9)
+	for _, row := range rows {
+		for i, v := range row {
+			align := "R"
+			if i < textCols {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 6, v, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(5)
+}
+
+func reportSection(pdf *gofpdf.Fpdf, title string) {
+	pdf.SetFont("Arial", "B", 11)
+	pdf.Cell(0, 7, title)
+	pdf.Ln(8)
+}
+
+func pdfAmount(v sql.NullFloat64) string {
+	if !v.Valid {
+		return "-"
+	}
+	return formatPeso(v.Float64)
+}
+
+func generateDailyCollectionPDF(report dailyCollectionReport) *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+
+	writeUniversityHeader(pdf)
+
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(0, 8, "DAILY COLLECTION REPORT", "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(0, 6, "Business date: "+report.Date, "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	reportSection(pdf, "Collections by Payment Method")
+	var rows [][]string
+	for _, l := range report.ByMethod {
+		rows = append(rows, []string{l.Label, strconv.Itoa(l.Count), formatPeso(l.Amount)})
+	}
+	rows = append(rows, []string{"TOTAL", strconv.Itoa(report.Count), formatPeso(report.Total)})
+	reportTable(pdf, []float64{90, 30, 50}, []string{"Method", "Receipts", "Amount"}, rows, 1)
+
+	reportSection(pdf, "Collections by Cashier")
+	rows = nil
+	for _, l := range report.ByCashier {
+		rows = append(rows, []string{l.Label, strconv.Itoa(l.Count), formatPeso(l.Amount)})
+	}
+	reportTable(pdf, []float64{90, 30, 50}, []string{"Cashier", "Receipts", "Amount"}, rows, 1)
+
+	reportSection(pdf, "Shift Close-out")
+	rows = nil
+	for _, s := range report.Shifts {
+		rows = append(rows, []string{s.Cashier, s.Status, formatPeso(s.OpeningCash),
+			pdfAmount(s.Expected), pdfAmount(s.ClosingCash), pdfAmount(s.Discrepancy)})
+	}
+	reportTable(pdf, []float64{50, 20, 25, 25, 25, 25},
+		[]string{"Cashier", "Status", "Opening", "Expected", "Counted", "Over/Short"}, rows, 2)
+
+	pdf.SetFont("Arial", "I", 8)
+	pdf.SetTextColor(100, 100, 100)
+	pdf.Cell(0, 4, "Generated on "+time.Now().Format("January 02, 2006 at 3:04 PM"))
+
+	return pdf
+}
+
+// ===================== TERM COLLECTION REPORT =====================
+
+type termReportLine struct {
+	Course      string
+	YearLevel   string
+	Students    int
+	Assessed    float64
+	Collected   float64
+	Outstanding float64
+}
+
+func loadTermCollectionReport(schoolYear, semester string) ([]termReportLine, error) {
+	// Payments approved before transactions were recorded have no posted rows;
+	// their amount_paid is taken as collected.
+	rows, err := config.DB.Query(`
+		SELECT IFNULL(co.code, 'UNASSIGNED'), IFNULL(sa.year_level, ''),
+		       COUNT(DISTINCT sp.student_id),
+		       IFNULL(SUM(sp.total_amount), 0),
+		       IFNULL(SUM(IFNULL(posted.amount,
+		           CASE WHEN sp.status IN ('paid', 'partial') THEN sp.amount_paid ELSE 0 END)), 0)
+		FROM student_payments sp
+		LEFT JOIN student_academic sa ON sa.student_id = sp.student_id
+		LEFT JOIN courses co ON co.id = sa.course
+		LEFT JOIN (
+			SELECT payment_id, SUM(amount) AS amount
+			FROM payment_transactions
+			WHERE status = 'posted' AND IFNULL(kind, 'payment') <> 'document_fee'
+			GROUP BY payment_id
+		) posted ON posted.payment_id = sp.id
+		WHERE (? = '' OR sp.school_year = ?) AND (? = '' OR sp.semester = ?)
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, schoolYear, schoolYear, semester, semester)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []termReportLine
+	for rows.Next() {
+		var l termReportLine
+		if err := rows.Scan(&l.Course, &l.YearLevel, &l.Students, &l.Assessed, &l.Collected); err != nil {
+			return nil, err
+		}
+		l.Collected = roundCents(l.Collected)
+		l.Outstanding = roundCents(l.Assessed - l.Collected)
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// GET /cashier/reports/term?school_year=&semester=&format=json|csv|pdf
+func CashierTermCollectionReport(c *gin.Context) {
+	schoolYear := c.Query("school_year")
+	semester := c.Query("semester")
+
+	lines, err := loadTermCollectionReport(schoolYear, semester)
+	if err != nil {
+		fmt.Println("❌ Term report error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build report"})
+		return
+	}
+
+	var total termReportLine
+	total.Course = "TOTAL"
+	for _, l := range lines {
+		total.Students += l.Students
+		total.Assessed += l.Assessed
+		total.Collected += l.Collected
+		total.Outstanding += l.Outstanding
+	}
+	total.Assessed = roundCents(total.Assessed)
+	total.Collected = roundCents(total.Collected)
+	total.Outstanding = roundCents(total.Outstanding)
+
+	label := strings.TrimSpace(strings.Join([]string{semester, schoolYear}, " "))
+	if label == "" {
+		label = "All terms"
+	}
+	fileLabel := strings.NewReplacer(" ", "_", "/", "-").Replace(label)
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", "attachment; filename=term_collections_"+fileLabel+".csv")
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"Course", "Year Level", "Students", "Assessed", "Collected", "Outstanding"})
+		for _, l := range append(lines, total) {
+			w.Write([]string{l.Course, l.YearLevel, strconv.Itoa(l.Students), fmt.Sprintf("%.2f", l.Assessed),
+				fmt.Sprintf("%.2f", l.Collected), fmt.Sprintf("%.2f", l.Outstanding)})
+		}
+		w.Flush()
+	case "pdf":
+		c.Header("Content-Type", "application/pdf")
+		c.Header("Content-Disposition", "attachment; filename=term_collections_"+fileLabel+".pdf")
+
+		pdf := gofpdf.New("P", "mm", "A4", "")
+		pdf.AddPage()
+		writeUniversityHeader(pdf)
+		pdf.SetFont("Arial", "B", 14)
+		pdf.CellFormat(0, 8, "ASSESSMENT AND COLLECTION REPORT", "", 1, "C", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		pdf.CellFormat(0, 6, label, "", 1, "C", false, 0, "")
+		pdf.Ln(6)
+
+		var rows [][]string
+		for _, l := range append(lines, total) {
+			rows = append(rows, []string{l.Course, l.YearLevel, strconv.Itoa(l.Students),
+				formatPeso(l.Assessed), formatPeso(l.Collected), formatPeso(l.Outstanding)})
+		}
+		reportTable(pdf, []float64{35, 20, 20, 35, 35, 35},
+			[]string{"Course", "Year", "Students", "Assessed", "Collected", "Outstanding"}, rows, 2)
+
+		if err := pdf.Output(c.Writer); err != nil {
+			fmt.Println("❌ Term report PDF error:", err)
+		}
+	default:
+		result := []gin.H{}
+		for _, l := range lines {
+			result = append(result, gin.H{
+				"course":      l.Course,
+				"year_level":  l.YearLevel,
+				"students":    l.Students,
+				"assessed":    l.Assessed,
+				"collected":   l.Collected,
+				"outstanding": l.Outstanding,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"school_year": schoolYear,
+			"semester":    semester,
+			"lines":       result,
+			"totals": gin.H{
+				"students":    total.Students,
+				"assessed":    total.Assessed,
+				"collected":   total.Collected,
+				"outstanding": total.Outstanding,
+			},
+		})
+	}
+}
@@ -0,0 +1,750 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/filestore"
+	"student-portal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== DOCUMENT SIGNATORIES & APPROVAL CHAIN =====================
+//
+// A document type may have an ordered chain of signatories (e.g. the
+// registrar, then the dean). When records approves a request for such a type
+// the request goes "for_signature" and each signatory approves it in turn.
+// The PDF is generated only after the last approval, with every signatory's
+// uploaded signature image and approval time stamped on it. Types without
+// signatories are generated as soon as records approves, as before.
+
+const (
+	requestForSignature = "for_signature"
+
+	approvalPending  = "pending"
+	approvalApproved = "approved"
+	approvalRejected = "rejected"
+	approvalSkipped  = "skipped"
+)
+
+// Signature images are stored outside uploads/ so they are never served
+// through /uploads.
+const signatureDir = "signatures"
+
+// signatoryRoles are the accounts that may sign documents.
+var signatoryRoles = []string{"admin", "registrar", "records", "faculty", "teacher"}
+
+type chainSignatory struct {
+	Step         int    `json:"step"`
+	UserID       int    `json:"user_id"`
+	Name         string `json:"name"`
+	Title        string `json:"title"`
+	Office       string `json:"office"`
+	HasSignature bool   `json:"has_signature"`
+}
+
+type documentApproval struct {
+	ID            int        `json:"id"`
+	RequestID     int        `json:"request_id"`
+	Step          int        `json:"step"`
+	UserID        int        `json:"user_id"`
+	Name          string     `json:"name"`
+	Title         string     `json:"title"`
+	Office        string     `json:"office"`
+	Status        string     `json:"status"`
+	Notes         string     `json:"notes"`
+	SignatureFile string     `json:"-"`
+	ActedAt       *time.Time `json:"acted_at"`
+}
+
+const signatoryNameExpr = `COALESCE(NULLIF(TRIM(CONCAT_WS(' ', u.first_name, u.surname)), ''), u.username)`
+
+// documentSignatoryChain returns a document type's signatories in signing order.
+func documentSignatoryChain(documentTypeID int) ([]chainSignatory, error) {
+	rows, err := config.DB.Query(`
+		SELECT ds.step, ds.user_id, `+signatoryNameExpr+`, ds.title, IFNULL(ds.office, ''),
+		       EXISTS(SELECT 1 FROM signatory_signatures ss WHERE ss.user_id = ds.user_id)
+		FROM document_signatories ds
+		INNER JOIN users u ON u.id = ds.user_id
+		WHERE ds.document_type_id = ?
+		ORDER BY ds.step
+	`, documentTypeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chain := []chainSignatory{}
+	for rows.Next() {
+		var s chainSignatory
+		if err := rows.Scan(&s.Step, &s.UserID, &s.Name, &s.Title, &s.Office, &s.HasSignature); err != nil {
+			return nil, err
+		}
+		chain = append(chain, s)
+	}
+	return chain, rows.Err()
+}
+
+const documentApprovalColumns = `id, request_id, step, user_id, signatory_name, title, IFNULL(office, ''),
+	status, IFNULL(notes, ''), IFNULL(signature_file, ''), acted_at`
+
+func scanDocumentApproval(row interface{ Scan(...interface{}) error }) (*documentApproval, error) {
+	var a documentApproval
+	if err := row.Scan(&a.ID, &a.RequestID, &a.Step, &a.UserID, &a.Name, &a.Title, &a.Office,
+		&a.Status, &a.Notes, &a.SignatureFile, &a.ActedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func requestApprovals(requestID int) ([]documentApproval, error) {
+	rows, err := config.DB.Query(`
+		SELECT `+documentApprovalColumns+` FROM document_approvals
+		WHERE request_id = ? ORDER BY step
+	`, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvals := []documentApproval{}
+	for rows.Next() {
+		a, err := scanDocumentApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, *a)
+	}
+	return approvals, rows.Err()
+}
+
+// approvalSignatories turns a finished chain into the signatories printed on
+// the document, with their signature images and approval times.
+func approvalSignatories(approvals []documentApproval) []documentSignatory {
+	list := make([]documentSignatory, 0, len(approvals))
+	for _, a := range approvals {
+		list = append(list, documentSignatory{
+			Name:          a.Name,
+			Title:         a.Title,
+			Office:        a.Office,
+			SignatureFile: a.SignatureFile,
+			SignedAt:      a.ActedAt,
+		})
+	}
+	return list
+}
+
+// startApprovalChain snapshots the type's signatories onto the request and
+// moves it to for_signature within tx. Later changes to the chain do not
+// affect it.
+func startApprovalChain(tx *sql.Tx, requestID, templateID int, chain []chainSignatory, notes string) error {
+	res, err := tx.Exec(`
+		UPDATE document_requests
+		SET status = ?, notes = ?, template_id = ?
+		WHERE id = ? AND status IN ('pending', 'paid')
+	`, requestForSignature, notes, templateID, requestID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return fmt.Errorf("request %d is no longer awaiting processing", requestID)
+	}
+
+	for _, s := range chain {
+		if _, err := tx.Exec(`
+			INSERT INTO document_approvals (request_id, step, user_id, signatory_name, title, office, status)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, requestID, s.Step, s.UserID, s.Name, s.Title, s.Office, approvalPending); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// currentSignature returns the stored signature image of a user, or "".
+func currentSignature(userID int) (string, error) {
+	var path string
+	err := config.DB.QueryRow(`SELECT file_path FROM signatory_signatures WHERE user_id = ?`, userID).Scan(&path)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return path, err
+}
+
+// notifySignatory emails a signatory that a document is waiting for them.
+// Runs in the background; failures are only logged.
+func notifySignatory(userID int, documentType, studentName string) {
+	var email, name string
+	err := config.DB.QueryRow(`
+		SELECT IFNULL(u.email, ''), `+signatoryNameExpr+` FROM users u WHERE u.id = ?
+	`, userID).Scan(&email, &name)
+	if err != nil || email == "" {
+		return
+	}
+
+	go func() {
+		body := fmt.Sprintf(`
+<div style="font-family:'Segoe UI',Arial,sans-serif;color:#333;max-width:600px;">
+	<div style="background:#1b4332;color:#ffffff;padding:20px 24px;border-radius:8px 8px 0 0;">
+		<h2 style="margin:0;">Document Awaiting Your Signature</h2>
+	</div>
+	<div style="padding:20px 24px;border:1px solid #e5e7eb;border-top:none;border-radius:0 0 8px 8px;">
+		<p>Hi %s,</p>
+		<p>A <strong>%s</strong> for <strong>%s</strong> is waiting for your approval.</p>
+		<p style="font-size:12px;color:#6b7280;">Log in to the portal to review and sign it.</p>
+	</div>
+</div>`, html.EscapeString(name), html.EscapeString(documentType), html.EscapeString(studentName))
+
+		if err := utils.SendEmail(email, "Document awaiting your signature - University of Manila", body); err != nil {
+			fmt.Println("⚠️ Warning: could not send signatory notice to", email, ":", err)
+		}
+	}()
+}
+
+// ===================== RECORDS: SIGNATORY CHAINS =====================
+
+// GET /records/document-types/:id/signatories
+func RecordsGetDocumentSignatories(c *gin.Context) {
+	typeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid document type id"})
+		return
+	}
+
+	chain, err := documentSignatoryChain(typeID)
+	if err != nil {
+		fmt.Println("❌ Signatory chain error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load signatories"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"document_type_id": typeID, "signatories": chain})
+}
+
+// PUT /records/document-types/:id/signatories
+// Replaces the chain; the order of the list is the signing order. An empty
+// list removes the chain. Requests already for signature keep their chain.
+func RecordsSetDocumentSignatories(c *gin.Context) {
+	typeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid document type id"})
+		return
+	}
+
+	var req struct {
+		Signatories []struct {
+			UserID int    `json:"user_id"`
+			Title  string `json:"title"`
+			Office string `json:"office"`
+		} `json:"signatories"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	var exists bool
+	config.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM document_types WHERE id = ?)`, typeID).Scan(&exists)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document type not found"})
+		return
+	}
+
+	seen := map[int]bool{}
+	for i, s := range req.Signatories {
+		if strings.TrimSpace(s.Title) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("signatory %d needs a title", i+1)})
+			return
+		}
+		if seen[s.UserID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("user %d is listed more than once", s.UserID)})
+			return
+		}
+		seen[s.UserID] = true
+
+		var role, status string
+		err := config.DB.QueryRow(`SELECT role, IFNULL(status, 'active') FROM users WHERE id = ?`, s.UserID).Scan(&role, &status)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("user %d not found", s.UserID)})
+			return
+		}
+		allowed := false
+		for _, r := range signatoryRoles {
+			if strings.EqualFold(role, r) {
+				allowed = true
+			}
+		}
+		if !allowed || status != "active" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("user %d cannot be a signatory", s.UserID)})
+			return
+		}
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save signatories"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM document_signatories WHERE document_type_id = ?`, typeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save signatories"})
+		return
+	}
+	for i, s := range req.Signatories {
+		if _, err := tx.Exec(`
+			INSERT INTO document_signatories (document_type_id, step, user_id, title, office)
+			VALUES (?, ?, ?, ?, ?)
+		`, typeID, i+1, s.UserID, strings.TrimSpace(s.Title), strings.TrimSpace(s.Office)); err != nil {
+			fmt.Println("❌ Signatory insert error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save signatories"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save signatories"})
+		return
+	}
+
+	chain, _ := documentSignatoryChain(typeID)
+	c.JSON(http.StatusOK, gin.H{"message": "signatories saved", "signatories": chain})
+}
+
+// GET /records/document-requests/:id/approvals
+func RecordsGetDocumentApprovals(c *gin.Context) {
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request_id"})
+		return
+	}
+
+	approvals, err := requestApprovals(requestID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load approvals"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"request_id": requestID, "approvals": approvals})
+}
+
+// ===================== SIGNATORY: SIGNATURE IMAGE =====================
+
+// GET /signatory/signature
+func SignatoryGetSignature(c *gin.Context) {
+	var uploadedAt time.Time
+	err := config.DB.QueryRow(`SELECT uploaded_at FROM signatory_signatures WHERE user_id = ?`, c.GetInt("user_id")).Scan(&uploadedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"has_signature": false})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load signature"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"has_signature": true, "uploaded_at": uploadedAt})
+}
+
+// GET /signatory/signature/image
+func SignatoryGetSignatureImage(c *gin.Context) {
+	path, err := currentSignature(c.GetInt("user_id"))
+	if err != nil || path == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no signature uploaded"})
+		return
+	}
+	sendStoredFile(c, path, "signature.png", cachePrivate, false)
+}
+
+// POST /signatory/signature (multipart "signature", PNG or JPEG)
+// The image is re-encoded as an 8-bit PNG, which strips metadata and gives
+// the PDF library a format it can always embed. Each upload gets a new file
+// so documents already approved keep the signature they were signed with.
+func SignatoryUploadSignature(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	file, err := c.FormFile("signature")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
+		return
+	}
+	if file.Size > 2*1024*1024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file too large (max 2MB)"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	defer f.Close()
+
+	buffer := make([]byte, 512)
+	n, err := f.Read(buffer)
+	if err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file content"})
+		return
+	}
+	if ct := http.DetectContentType(buffer[:n]); ct != "image/png" && ct != "image/jpeg" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file type (only png, jpg allowed)"})
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file content"})
+		return
+	}
+
+	// The size comes from the header, so an image that only claims a huge
+	// canvas is refused before any pixels are allocated
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not decode image"})
+		return
+	}
+	if cfg.Width > 2000 || cfg.Height > 2000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image too large (max 2000x2000 pixels)"})
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file content"})
+		return
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not decode image"})
+		return
+	}
+	bounds := img.Bounds()
+	flat := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Src)
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, flat); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save signature"})
+		return
+	}
+	path := fmt.Sprintf("%s/signature_%d_%d.png", signatureDir, userID, time.Now().UnixNano())
+	if _, err := filestore.SaveBytes(path, encoded.Bytes(), "image/png"); err != nil {
+		fmt.Println("❌ Signature save error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save signature"})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		INSERT INTO signatory_signatures (user_id, file_path, uploaded_at)
+		VALUES (?, ?, NOW())
+		ON DUPLICATE KEY UPDATE file_path = VALUES(file_path), uploaded_at = NOW()
+	`, userID, path)
+	if err != nil {
+		filestore.Remove(path)
+		fmt.Println("❌ Signature save error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save signature"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "signature uploaded"})
+}
+
+// ===================== SIGNATORY: APPROVALS =====================
+
+// GET /signatory/approvals?all=1
+// Lists requests waiting on the signatory (every earlier step approved);
+// all=1 lists everything they were ever asked to sign.
+func SignatoryGetApprovals(c *gin.Context) {
+	where := `a.user_id = ? AND a.status = 'pending' AND dr.status = 'for_signature'
+		AND NOT EXISTS (
+			SELECT 1 FROM document_approvals p
+			WHERE p.request_id = a.request_id AND p.step < a.step AND p.status <> 'approved'
+		)`
+	if c.Query("all") == "1" {
+		where = `a.user_id = ?`
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT a.id, a.request_id, a.step, a.user_id, a.signatory_name, a.title, IFNULL(a.office, ''),
+		       a.status, IFNULL(a.notes, ''), IFNULL(a.signature_file, ''), a.acted_at,
+		       dr.document_type, IFNULL(dr.purpose, ''), dr.status, dr.requested_at,
+		       s.student_id, CONCAT(s.first_name, ' ', s.last_name)
+		FROM document_approvals a
+		INNER JOIN document_requests dr ON dr.id = a.request_id
+		INNER JOIN students s ON s.id = dr.student_id
+		WHERE `+where+`
+		ORDER BY dr.requested_at, a.id
+	`, c.GetInt("user_id"))
+	if err != nil {
+		fmt.Println("❌ Approvals query error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load approvals"})
+		return
+	}
+	defer rows.Close()
+
+	list := []gin.H{}
+	for rows.Next() {
+		var a documentApproval
+		var docType, purpose, requestStatus, studentNumber, studentName string
+		var requestedAt time.Time
+		if err := rows.Scan(&a.ID, &a.RequestID, &a.Step, &a.UserID, &a.Name, &a.Title, &a.Office,
+			&a.Status, &a.Notes, &a.SignatureFile, &a.ActedAt,
+			&docType, &purpose, &requestStatus, &requestedAt, &studentNumber, &studentName); err != nil {
+			continue
+		}
+		list = append(list, gin.H{
+			"approval":       a,
+			"document_type":  docType,
+			"purpose":        purpose,
+			"request_status": requestStatus,
+			"requested_at":   requestedAt,
+			"student_number": studentNumber,
+			"student_name":   studentName,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"approvals": list, "count": len(list)})
+}
+
+// POST /signatory/approvals/:id
+// Body: {"status": "approved" | "rejected", "notes": "..."}. A rejection
+// ends the chain and rejects the request; the last approval generates,
+// stamps and signs the document, unless the student is on hold by then.
+func SignatoryActOnApproval(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	approvalID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid approval id"})
+		return
+	}
+
+	var req struct {
+		Status string `json:"status" binding:"required"`
+		Notes  string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Status != approvalApproved && req.Status != approvalRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be 'approved' or 'rejected'"})
+		return
+	}
+	if req.Status == approvalRejected && strings.TrimSpace(req.Notes) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "notes are required when rejecting"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the approval so a double submit cannot sign twice
+	approval, err := scanDocumentApproval(tx.QueryRow(`
+		SELECT `+documentApprovalColumns+` FROM document_approvals WHERE id = ? FOR UPDATE
+	`, approvalID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "approval not found"})
+		return
+	}
+	if approval.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this approval is assigned to another signatory"})
+		return
+	}
+	if approval.Status != approvalPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "approval already " + approval.Status})
+		return
+	}
+
+	var (
+		requestStatus, documentType, purpose string
+		studentDBID                          int
+		templateID                           sql.NullInt64
+	)
+	err = tx.QueryRow(`
+		SELECT status, document_type, IFNULL(purpose, ''), student_id, template_id
+		FROM document_requests WHERE id = ? FOR UPDATE
+	`, approval.RequestID).Scan(&requestStatus, &documentType, &purpose, &studentDBID, &templateID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	}
+	if requestStatus != requestForSignature {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request is not awaiting signatures"})
+		return
+	}
+
+	approvals, err := requestApprovals(approval.RequestID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load approvals"})
+		return
+	}
+	var next *documentApproval
+	for i, a := range approvals {
+		if a.Step < approval.Step && a.Status != approvalApproved {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("waiting for %s (%s) to approve first", a.Name, a.Title),
+			})
+			return
+		}
+		if a.Step > approval.Step && next == nil {
+			next = &approvals[i]
+		}
+	}
+
+	now := time.Now()
+
+	if req.Status == approvalRejected {
+		if _, err := tx.Exec(`
+			UPDATE document_approvals SET status = ?, notes = ?, acted_at = ? WHERE id = ?
+		`, approvalRejected, req.Notes, now, approval.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+			return
+		}
+		if _, err := tx.Exec(`
+			UPDATE document_approvals SET status = ? WHERE request_id = ? AND status = 'pending'
+		`, approvalSkipped, approval.RequestID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+			return
+		}
+		if _, err := tx.Exec(`
+			UPDATE document_requests SET status = ?, processed_at = NOW(), notes = ? WHERE id = ?
+		`, requestRejected, fmt.Sprintf("Rejected by %s (%s): %s", approval.Name, approval.Title, req.Notes), approval.RequestID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "request rejected",
+			"request_id":     approval.RequestID,
+			"request_status": requestRejected,
+		})
+		return
+	}
+
+	signature, err := currentSignature(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load signature"})
+		return
+	}
+	if signature == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "upload your signature before approving"})
+		return
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE document_approvals SET status = ?, notes = ?, signature_file = ?, acted_at = ? WHERE id = ?
+	`, approvalApproved, req.Notes, signature, now, approval.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+		return
+	}
+
+	// Not the last signatory: hand over to the next one
+	if next != nil {
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+			return
+		}
+		var studentName string
+		config.DB.QueryRow(`SELECT CONCAT(first_name, ' ', last_name) FROM students WHERE id = ?`, studentDBID).Scan(&studentName)
+		notifySignatory(next.UserID, documentType, studentName)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "approved, forwarded to the next signatory",
+			"request_id":     approval.RequestID,
+			"request_status": requestForSignature,
+			"next":           gin.H{"name": next.Name, "title": next.Title},
+		})
+		return
+	}
+
+	// Last signatory: holds placed while the request waited on the chain
+	// still stop the release. The financial hold is refreshed first; the
+	// holds query is the transaction's first plain read, so it sees that
+	if err := refreshFinancialHold(studentDBID); err != nil {
+		fmt.Println("⚠️ Warning: could not refresh financial hold:", err)
+	}
+	holds, err := activeHolds(tx, studentDBID, holdScopeDocuments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check student holds"})
+		return
+	}
+	if len(holds) > 0 {
+		list := []gin.H{}
+		for _, h := range holds {
+			list = append(list, h.toJSON())
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "student has an active hold, the document cannot be released",
+			"holds": list,
+		})
+		return
+	}
+
+	// Generate the stamped document from the template version records
+	// approved. It is recorded in the transaction, so only the file has to
+	// be removed if the approval does not commit
+	for i := range approvals {
+		if approvals[i].ID == approval.ID {
+			approvals[i].Status = approvalApproved
+			approvals[i].SignatureFile = signature
+			approvals[i].ActedAt = &now
+		}
+	}
+
+	docType, err := findDocumentType(documentType)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%q is not a registered document type", documentType)})
+		return
+	}
+	if !templateID.Valid {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "request has no template"})
+		return
+	}
+	tpl, err := loadDocumentTemplate(int(templateID.Int64))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "request template not found"})
+		return
+	}
+	tpl.Signatories = approvalSignatories(approvals)
+
+	documentPath, serial, err := generateRequestDocument(tx, approval.RequestID, studentDBID, documentType, purpose, docType, tpl, userID)
+	if err != nil {
+		fmt.Println("❌ Document generation error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate document"})
+		return
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE document_requests SET status = ?, processed_at = NOW(), document_file = ? WHERE id = ?
+	`, requestProcessing, documentPath, approval.RequestID); err != nil {
+		filestore.Remove(documentPath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		filestore.Remove(documentPath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process approval"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "approved, document generated",
+		"request_id":     approval.RequestID,
+		"request_status": requestProcessing,
+		"document_path":  documentPath,
+		"serial":         serial,
+	})
+}
@@ -0,0 +1,648 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ===================== DOCUMENT FEES, STAGES & RELEASE =====================
+//
+// A request is billed when it is filed: copies x the type's fee per copy,
+// plus the rush fee. The fee is kept on the request itself and only linked
+// to the student's latest account so its receipt lands there; it never
+// touches the tuition total, downpayment or installments. The cashier posts
+// the fee with an official receipt, which moves the request to "paid" and
+// gives it a claim stub and pickup date. Free requests get their stub right
+// away. From there:
+//
+//	pending -> paid -> [for_signature] -> processing -> ready_for_pickup -> released
+//	                                                 \-> mailed
+//
+// "processing" means the document is generated and being printed; every
+// hand-over is logged in document_releases with who claimed it.
+
+const (
+	requestPending        = "pending"
+	requestPaid           = "paid"
+	requestProcessing     = "processing"
+	requestReadyForPickup = "ready_for_pickup"
+	requestReleased       = "released"
+	requestMailed         = "mailed"
+	requestRejected       = "rejected"
+
+	releasePickup = "pickup"
+	releaseMail   = "mail"
+)
+
+var errNoBillingAccount = errors.New("student has no account to bill")
+
+// documentFee is what a request costs: every copy plus the rush fee.
+func documentFee(t *documentType, copies int, rush bool) int {
+	fee := t.FeePerCopy * copies
+	if rush {
+		fee += t.RushFee
+	}
+	return fee
+}
+
+// addBusinessDays skips Saturdays and Sundays.
+func addBusinessDays(from time.Time, days int) time.Time {
+	d := from
+	for days > 0 {
+		d = d.AddDate(0, 0, 1)
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			days--
+		}
+	}
+	return d
+}
+
+// billDocumentRequest links the request's fee to the student's latest
+// account. The fee stays on the request, apart from the tuition balance.
+func billDocumentRequest(tx *sql.Tx, requestID, studentDBID int) error {
+	var paymentID int
+	err := tx.QueryRow(`
+		SELECT id FROM student_payments
+		WHERE student_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, studentDBID).Scan(&paymentID)
+	if err == sql.ErrNoRows {
+		return errNoBillingAccount
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE document_requests SET payment_id = ? WHERE id = ?`, paymentID, requestID)
+	return err
+}
+
+// assignClaimStub gives a settled request its claim stub number and a pickup
+// date counted in business days from now.
+func assignClaimStub(exec interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, requestID, processingDays int) (string, time.Time, error) {
+	now := time.Now()
+	stub := fmt.Sprintf("CS-%d-%06d", now.Year(), requestID)
+	pickup := addBusinessDays(now, processingDays)
+	_, err := exec.Exec(`
+		UPDATE document_requests SET claim_stub = ?, pickup_date = ? WHERE id = ?
+	`, stub, pickup.Format("2006-01-02"), requestID)
+	return stub, pickup, err
+}
+
+// loadRequestFulfillment returns the fee, stage and release details shown
+// with a request.
+func loadRequestFulfillment(requestID int) gin.H {
+	var (
+		rush                                 bool
+		feeAmount                            int
+		releaseMethod, mailingAddress, stub  string
+		pickupDate, paidAt, readyAt, release *string
+		orNumber                             string
+	)
+	err := config.DB.QueryRow(`
+		SELECT IFNULL(dr.rush, FALSE), IFNULL(dr.fee_amount, 0), IFNULL(dr.release_method, 'pickup'),
+		       IFNULL(dr.mailing_address, ''), IFNULL(dr.claim_stub, ''),
+		       DATE_FORMAT(dr.pickup_date, '%Y-%m-%d'),
+		       DATE_FORMAT(dr.paid_at, '%Y-%m-%d %H:%i:%s'),
+		       DATE_FORMAT(dr.ready_at, '%Y-%m-%d %H:%i:%s'),
+		       DATE_FORMAT(dr.released_at, '%Y-%m-%d %H:%i:%s'),
+		       IFNULL(r.or_number, '')
+		FROM document_requests dr
+		LEFT JOIN official_receipts r ON r.transaction_id = dr.fee_transaction_id
+		WHERE dr.id = ?
+	`, requestID).Scan(&rush, &feeAmount, &releaseMethod, &mailingAddress, &stub,
+		&pickupDate, &paidAt, &readyAt, &release, &orNumber)
+	if err != nil {
+		return gin.H{}
+	}
+
+	info := gin.H{
+		"rush":            rush,
+		"fee_amount":      feeAmount,
+		"release_method":  releaseMethod,
+		"mailing_address": mailingAddress,
+		"claim_stub":      stub,
+		"pickup_date":     pickupDate,
+		"paid_at":         paidAt,
+		"or_number":       orNumber,
+		"ready_at":        readyAt,
+		"released_at":     release,
+	}
+
+	var (
+		method, claimantType, claimedBy, relationship, courier, tracking string
+		releasedAt                                                       time.Time
+	)
+	err = config.DB.QueryRow(`
+		SELECT method, IFNULL(claimant_type, ''), IFNULL(claimed_by, ''), IFNULL(relationship, ''),
+		       IFNULL(courier, ''), IFNULL(tracking_number, ''), released_at
+		FROM document_releases WHERE request_id = ? ORDER BY id DESC LIMIT 1
+	`, requestID).Scan(&method, &claimantType, &claimedBy, &relationship, &courier, &tracking, &releasedAt)
+	if err == nil {
+		info["release"] = gin.H{
+			"method":          method,
+			"claimant_type":   claimantType,
+			"claimed_by":      claimedBy,
+			"relationship":    relationship,
+			"courier":         courier,
+			"tracking_number": tracking,
+			"released_at":     releasedAt,
+		}
+	}
+	return info
+}
+
+// notifyDocumentReady emails the student that the document can be claimed.
+// Runs in the background; failures are only logged.
+func notifyDocumentReady(requestID int) {
+	var email, firstName, docType, stub, pickupDate string
+	err := config.DB.QueryRow(`
+		SELECT IFNULL(s.email, ''), s.first_name, dr.document_type, IFNULL(dr.claim_stub, ''),
+		       IFNULL(DATE_FORMAT(dr.pickup_date, '%M %e, %Y'), '')
+		FROM document_requests dr
+		INNER JOIN students s ON s.id = dr.student_id
+		WHERE dr.id = ?
+	`, requestID).Scan(&email, &firstName, &docType, &stub, &pickupDate)
+	if err != nil || email == "" {
+		return
+	}
+
+	go func() {
+		body := fmt.Sprintf(`
+<div style="font-family:'Segoe UI',Arial,sans-serif;color:#333;max-width:600px;">
+	<div style="background:#1b4332;color:#ffffff;padding:20px 24px;border-radius:8px 8px 0 0;">
+		<h2 style="margin:0;">Document Ready for Pickup</h2>
+	</div>
+	<div style="padding:20px 24px;border:1px solid #e5e7eb;border-top:none;border-radius:0 0 8px 8px;">
+		<p>Hi %s,</p>
+		<p>Your <strong>%s</strong> is ready at the Records Office.</p>
+		<p>Claim stub: <strong>%s</strong><br>Pickup from: <strong>%s</strong></p>
+		<p style="font-size:12px;color:#6b7280;">Bring your claim stub and a valid ID. A representative needs
+		an authorization letter and valid IDs of both of you.</p>
+	</div>
+</div>`, html.EscapeString(firstName), html.EscapeString(docType), html.EscapeString(stub), html.EscapeString(pickupDate))
+
+		if err := utils.SendEmail(email, "Your document is ready for pickup - University of Manila", body); err != nil {
+			fmt.Println("⚠️ Warning: could not send pickup notice to", email, ":", err)
+		}
+	}()
+}
+
+// ===================== CASHIER: DOCUMENT FEES =====================
+
+// GET /cashier/document-requests
+// Requests billed but not yet paid.
+func CashierGetUnpaidDocumentRequests(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT dr.id, dr.document_type, dr.copies, IFNULL(dr.rush, FALSE), dr.fee_amount,
+		       DATE_FORMAT(dr.requested_at, '%Y-%m-%d %H:%i:%s'),
+		       s.student_id, CONCAT(s.first_name, ' ', s.last_name)
+		FROM document_requests dr
+		INNER JOIN students s ON s.id = dr.student_id
+		WHERE dr.status = 'pending' AND dr.fee_amount > 0
+		ORDER BY dr.requested_at
+	`)
+	if err != nil {
+		fmt.Println("❌ Unpaid document requests query error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load document requests"})
+		return
+	}
+	defer rows.Close()
+
+	list := []gin.H{}
+	for rows.Next() {
+		var id, copies, fee int
+		var rush bool
+		var docType, requestedAt, studentNumber, studentName string
+		if err := rows.Scan(&id, &docType, &copies, &rush, &fee, &requestedAt, &studentNumber, &studentName); err != nil {
+			continue
+		}
+		list = append(list, gin.H{
+			"request_id":     id,
+			"document_type":  docType,
+			"copies":         copies,
+			"rush":           rush,
+			"fee_amount":     fee,
+			"requested_at":   requestedAt,
+			"student_number": studentNumber,
+			"student_name":   studentName,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": list, "count": len(list)})
+}
+
+// POST /cashier/document-requests/:id/pay
+// Posts the full fee and issues its receipt under the billed account. The
+// tuition balance of the account is left as it is.
+func CashierPayDocumentRequest(c *gin.Context) {
+	cashierID := c.GetInt("user_id")
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request_id"})
+		return
+	}
+
+	var req struct {
+		PaymentMethod string `json:"payment_method"`
+	}
+	c.ShouldBindJSON(&req)
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = "cash"
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to post payment"})
+		return
+	}
+	defer tx.Rollback()
+
+	var (
+		status                           string
+		studentDBID, fee, processingDays int
+		paymentID                        sql.NullInt64
+		rush                             bool
+		documentTypeName                 string
+	)
+	err = tx.QueryRow(`
+		SELECT status, student_id, fee_amount, payment_id, IFNULL(rush, FALSE), document_type
+		FROM document_requests WHERE id = ? FOR UPDATE
+	`, requestID).Scan(&status, &studentDBID, &fee, &paymentID, &rush, &documentTypeName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	}
+	if status != requestPending || fee <= 0 || !paymentID.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request has no unpaid fee"})
+		return
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO payment_transactions
+		(payment_id, student_id, amount, payment_method, kind, status, submitted_at)
+		VALUES (?, ?, ?, ?, 'document_fee', 'pending', NOW())
+	`, paymentID.Int64, studentDBID, fee, req.PaymentMethod)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to post payment"})
+		return
+	}
+	transactionID, _ := res.LastInsertId()
+
+	receipt, err := postTransaction(tx, cashierID, int(paymentID.Int64), pendingTransaction{
+		ID:            int(transactionID),
+		StudentID:     studentDBID,
+		Amount:        float64(fee),
+		PaymentMethod: req.PaymentMethod,
+		Kind:          "document_fee",
+	})
+	if err != nil {
+		if errors.Is(err, errORSeriesExhausted) {
+			c.JSON(http.StatusConflict, gin.H{"error": "no OR numbers left in your active series, register a new one first"})
+			return
+		}
+		fmt.Println("❌ Receipt issuance error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue official receipt"})
+		return
+	}
+
+	processingDays = 5
+	if t, err := findDocumentType(documentTypeName); err == nil {
+		processingDays = t.ProcessingDays
+		if rush {
+			processingDays = t.RushDays
+		}
+	}
+	if _, err := tx.Exec(`
+		UPDATE document_requests SET status = ?, paid_at = NOW(), fee_transaction_id = ? WHERE id = ?
+	`, requestPaid, transactionID, requestID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to post payment"})
+		return
+	}
+	stub, pickup, err := assignClaimStub(tx, requestID, processingDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to post payment"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to post payment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "document fee posted",
+		"request_id":  requestID,
+		"status":      requestPaid,
+		"or_number":   receipt.ORNumber,
+		"amount":      fee,
+		"claim_stub":  stub,
+		"pickup_date": pickup.Format("2006-01-02"),
+	})
+}
+
+// ===================== RECORDS: READY & RELEASE =====================
+
+// POST /records/document-requests/:id/ready
+// The printed document is at the release window; the student is notified.
+func RecordsMarkDocumentReady(c *gin.Context) {
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request_id"})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		UPDATE document_requests
+		SET status = ?, ready_at = NOW(), pickup_date = GREATEST(IFNULL(pickup_date, CURDATE()), CURDATE())
+		WHERE id = ? AND status = ? AND IFNULL(release_method, 'pickup') = 'pickup'
+	`, requestReadyForPickup, requestID, requestProcessing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update request"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only pickup requests in processing can be marked ready"})
+		return
+	}
+
+	notifyDocumentReady(requestID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "document ready for pickup",
+		"request_id":  requestID,
+		"status":      requestReadyForPickup,
+		"fulfillment": loadRequestFulfillment(requestID),
+	})
+}
+
+// POST /records/document-requests/:id/release
+// Logs the hand-over. Pickups need the claim stub and the claimant's name;
+// a representative also needs their relationship, ID and an authorization
+// letter. Mailed documents need the courier and tracking number.
+func RecordsReleaseDocument(c *gin.Context) {
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request_id"})
+		return
+	}
+
+	var req struct {
+		ClaimStub           string `json:"claim_stub"`
+		ClaimantType        string `json:"claimant_type"` // student or representative
+		ClaimedBy           string `json:"claimed_by"`
+		Relationship        string `json:"relationship"`
+		IDPresented         string `json:"id_presented"`
+		AuthorizationLetter bool   `json:"authorization_letter"`
+		Courier             string `json:"courier"`
+		TrackingNumber      string `json:"tracking_number"`
+		Notes               string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to release document"})
+		return
+	}
+	defer tx.Rollback()
+
+	var status, method, stub, mailingAddress, firstName, middleName, lastName string
+	err = tx.QueryRow(`
+		SELECT dr.status, IFNULL(dr.release_method, 'pickup'), IFNULL(dr.claim_stub, ''), IFNULL(dr.mailing_address, ''),
+		       s.first_name, IFNULL(s.middle_name, ''), s.last_name
+		FROM document_requests dr
+		INNER JOIN students s ON s.id = dr.student_id
+		WHERE dr.id = ? FOR UPDATE
+	`, requestID).Scan(&status, &method, &stub, &mailingAddress, &firstName, &middleName, &lastName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	}
+
+	newStatus := requestReleased
+	switch method {
+	case releaseMail:
+		if status != requestProcessing {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only generated documents can be mailed"})
+			return
+		}
+		if strings.TrimSpace(req.Courier) == "" || strings.TrimSpace(req.TrackingNumber) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "courier and tracking_number are required for mailed documents"})
+			return
+		}
+		req.ClaimantType, req.ClaimedBy = "", ""
+		newStatus = requestMailed
+	default:
+		// "approved" is how generated documents were marked before release stages
+		if status != requestReadyForPickup && status != "approved" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "document is not ready for pickup"})
+			return
+		}
+		if stub != "" && !strings.EqualFold(strings.TrimSpace(req.ClaimStub), stub) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "claim stub does not match"})
+			return
+		}
+		if req.ClaimantType == "" {
+			req.ClaimantType = "student"
+		}
+		switch req.ClaimantType {
+		case "student":
+			if strings.TrimSpace(req.ClaimedBy) == "" {
+				req.ClaimedBy = strings.Join(strings.Fields(firstName+" "+middleName+" "+lastName), " ")
+			}
+		case "representative":
+			if strings.TrimSpace(req.ClaimedBy) == "" || strings.TrimSpace(req.Relationship) == "" || strings.TrimSpace(req.IDPresented) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "claimed_by, relationship and id_presented are required for a representative"})
+				return
+			}
+			if !req.AuthorizationLetter {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a representative must present an authorization letter"})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "claimant_type must be 'student' or 'representative'"})
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO document_releases
+		(request_id, method, claimant_type, claimed_by, relationship, id_presented, authorization_letter,
+		 courier, tracking_number, mailing_address, notes, released_by, released_at)
+		VALUES (?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?, NOW())
+	`, requestID, method, req.ClaimantType, strings.TrimSpace(req.ClaimedBy), strings.TrimSpace(req.Relationship),
+		strings.TrimSpace(req.IDPresented), req.AuthorizationLetter, strings.TrimSpace(req.Courier),
+		strings.TrimSpace(req.TrackingNumber), mailingAddress, req.Notes, c.GetInt("user_id"))
+	if err != nil {
+		fmt.Println("❌ Release log error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to release document"})
+		return
+	}
+	if _, err := tx.Exec(`
+		UPDATE document_requests SET status = ?, released_at = NOW() WHERE id = ?
+	`, newStatus, requestID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to release document"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to release document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "document " + newStatus,
+		"request_id":  requestID,
+		"status":      newStatus,
+		"fulfillment": loadRequestFulfillment(requestID),
+	})
+}
+
+// GET /records/document-releases?from=2026-01-01&to=2026-01-31
+// The release logbook.
+func RecordsGetDocumentReleases(c *gin.Context) {
+	from := c.DefaultQuery("from", time.Now().AddDate(0, 0, -30).Format("2006-01-02"))
+	to := c.DefaultQuery("to", time.Now().Format("2006-01-02"))
+
+	rows, err := config.DB.Query(`
+		SELECT r.id, r.request_id, dr.document_type, s.student_id, CONCAT(s.first_name, ' ', s.last_name),
+		       r.method, IFNULL(r.claimant_type, ''), IFNULL(r.claimed_by, ''), IFNULL(r.relationship, ''),
+		       IFNULL(r.id_presented, ''), r.authorization_letter,
+		       IFNULL(r.courier, ''), IFNULL(r.tracking_number, ''), IFNULL(r.notes, ''),
+		       IFNULL(u.username, ''), r.released_at
+		FROM document_releases r
+		INNER JOIN document_requests dr ON dr.id = r.request_id
+		INNER JOIN students s ON s.id = dr.student_id
+		LEFT JOIN users u ON u.id = r.released_by
+		WHERE DATE(r.released_at) BETWEEN ? AND ?
+		ORDER BY r.released_at DESC
+	`, from, to)
+	if err != nil {
+		fmt.Println("❌ Release log query error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load releases"})
+		return
+	}
+	defer rows.Close()
+
+	list := []gin.H{}
+	for rows.Next() {
+		var id, requestID int
+		var authorization bool
+		var docType, studentNumber, studentName, method, claimantType, claimedBy, relationship string
+		var idPresented, courier, tracking, notes, releasedBy string
+		var releasedAt time.Time
+		if err := rows.Scan(&id, &requestID, &docType, &studentNumber, &studentName, &method, &claimantType,
+			&claimedBy, &relationship, &idPresented, &authorization, &courier, &tracking, &notes,
+			&releasedBy, &releasedAt); err != nil {
+			continue
+		}
+		list = append(list, gin.H{
+			"id":                   id,
+			"request_id":           requestID,
+			"document_type":        docType,
+			"student_number":       studentNumber,
+			"student_name":         studentName,
+			"method":               method,
+			"claimant_type":        claimantType,
+			"claimed_by":           claimedBy,
+			"relationship":         relationship,
+			"id_presented":         idPresented,
+			"authorization_letter": authorization,
+			"courier":              courier,
+			"tracking_number":      tracking,
+			"notes":                notes,
+			"released_by":          releasedBy,
+			"released_at":          releasedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "releases": list, "count": len(list)})
+}
+
+// ===================== STUDENT: CLAIM STUB =====================
+
+// GET /student/documents/requests/:id/claim-stub
+func StudentDownloadClaimStub(c *gin.Context) {
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request_id"})
+		return
+	}
+
+	var (
+		docType, purpose, stub, status, method string
+		studentNumber, firstName, lastName     string
+		copies, fee                            int
+		rush                                   bool
+		pickupDate, requestedAt                time.Time
+	)
+	err = config.DB.QueryRow(`
+		SELECT dr.document_type, IFNULL(dr.purpose, ''), IFNULL(dr.claim_stub, ''), dr.status,
+		       IFNULL(dr.release_method, 'pickup'), s.student_id, s.first_name, s.last_name,
+		       dr.copies, IFNULL(dr.fee_amount, 0), IFNULL(dr.rush, FALSE),
+		       IFNULL(dr.pickup_date, CURDATE()), dr.requested_at
+		FROM document_requests dr
+		INNER JOIN students s ON s.id = dr.student_id
+		WHERE dr.id = ? AND s.student_id = ?
+	`, requestID, c.GetString("student_id")).Scan(&docType, &purpose, &stub, &status, &method,
+		&studentNumber, &firstName, &lastName, &copies, &fee, &rush, &pickupDate, &requestedAt)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	}
+	if stub == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "the claim stub is issued once the fee is paid"})
+		return
+	}
+
+	pdf := gofpdf.New("L", "mm", "A6", "")
+	pdf.AddPage()
+	pdf.SetFont("Arial", "B", 13)
+	pdf.CellFormat(0, 7, "DOCUMENT CLAIM STUB", "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(0, 9, stub, "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	rushLabel := ""
+	if rush {
+		rushLabel = " (RUSH)"
+	}
+	release := "Pickup on or after " + pickupDate.Format("January 2, 2006")
+	if method == releaseMail {
+		release = "By mail, dispatched on or after " + pickupDate.Format("January 2, 2006")
+	}
+	writeDocumentFields(pdf, [][2]string{
+		{"Student", fmt.Sprintf("%s, %s (%s)", lastName, firstName, studentNumber)},
+		{"Document", fmt.Sprintf("%s x%d%s", docType, copies, rushLabel)},
+		{"Purpose", purpose},
+		{"Fee", "PHP " + formatPeso(float64(fee))},
+		{"Requested", requestedAt.Format("January 2, 2006")},
+		{"Release", release},
+	})
+
+	pdf.SetFont("Arial", "I", 7)
+	pdf.MultiCell(0, 3.5, "Present this stub and a valid ID at the Records Office. A representative must bring "+
+		"an authorization letter and valid IDs of both the student and the representative.", "", "L", false)
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=claim_stub_%s.pdf", stub))
+	if err := pdf.Output(c.Writer); err != nil {
+		fmt.Println("❌ Claim stub error:", err)
+	}
+}
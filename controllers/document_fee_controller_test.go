@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"student-portal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runHandler calls h with a JSON body and the given context keys and returns
+// the status and decoded response.
+func runHandler(t *testing.T, h gin.HandlerFunc, body string, params gin.Params, keys gin.H) (int, gin.H) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	for k, v := range keys {
+		c.Set(k, v)
+	}
+	h(c)
+
+	var resp gin.H
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+// A paid document fee is its own charge: it must not count as the first
+// tuition payment nor raise the total the downpayment is measured against.
+func TestDocumentFeeThenDownpayment(t *testing.T) {
+	const (
+		studentDBID = 7
+		paymentID   = 11
+		requestID   = 21
+	)
+	var (
+		totalAmount, amountPaid int64 = 30000, 0
+		requestStatus                 = "pending"
+		requestFee              int64
+	)
+
+	fake, db := newFakeDB(
+		fakeRule{match: "SELECT id FROM students WHERE student_id = ?", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{int64(studentDBID)}}
+		}},
+		fakeRule{match: "FROM document_types", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{int64(3), "tor", "Transcript of Records", "", "TOR", true, true,
+				int64(150), int64(0), int64(5), int64(2), int64(10)}}
+		}},
+		fakeRule{match: "FROM document_templates", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{int64(4), int64(3), int64(1), "", "", "Transcript of Records", "",
+				"[]", "", "published", time.Now(), time.Now()}}
+		}},
+		fakeRule{match: "INSERT INTO document_requests", exec: func(args []driver.Value) {
+			requestFee = args[7].(int64)
+		}},
+		fakeRule{match: "SELECT id FROM student_payments WHERE student_id = ?", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{int64(paymentID)}}
+		}},
+		fakeRule{match: "FROM document_requests WHERE id = ? FOR UPDATE", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{requestStatus, int64(studentDBID), requestFee, int64(paymentID), false, "Transcript of Records"}}
+		}},
+		fakeRule{match: "FROM students WHERE id = ?", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{"2024-0001", "Juan", "", "Cruz"}}
+		}},
+		fakeRule{match: "UPDATE document_requests SET status = ?, paid_at", exec: func(args []driver.Value) {
+			requestStatus = args[0].(string)
+		}},
+		// The tuition ledger, as the account would see it
+		fakeRule{match: "SET total_amount = total_amount + ?", exec: func(args []driver.Value) {
+			totalAmount += args[0].(int64)
+		}},
+		fakeRule{match: "SET amount_paid = amount_paid + ?", exec: func(args []driver.Value) {
+			amountPaid += args[0].(int64)
+		}},
+		fakeRule{match: "SELECT total_amount, amount_paid FROM student_payments", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{totalAmount, amountPaid}}
+		}},
+		fakeRule{match: "SELECT plan_id FROM student_payments WHERE id = ?),", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{int64(1)}}
+		}},
+		fakeRule{match: "FROM installment_plans WHERE id = ?", query: func([]driver.Value) [][]driver.Value {
+			return [][]driver.Value{{int64(1), "Standard", "", float64(20), true, true}}
+		}},
+		fakeRule{match: "UPDATE student_payments SET amount_paid = ?, status = 'pending'", exec: func(args []driver.Value) {
+			amountPaid = args[0].(int64)
+		}},
+	)
+	defer db.Close()
+	saved := config.DB
+	config.DB = db
+	defer func() { config.DB = saved }()
+
+	student := gin.H{"student_id": "2024-0001"}
+
+	code, resp := runHandler(t, StudentRequestDocument,
+		`{"document_type": "tor", "purpose": "Employment", "copies": 2}`, nil, student)
+	if code != http.StatusOK {
+		t.Fatalf("request document: %d %v", code, resp)
+	}
+	if requestFee != 300 {
+		t.Fatalf("fee = %d, want 300", requestFee)
+	}
+
+	code, resp = runHandler(t, CashierPayDocumentRequest, `{"payment_method": "cash"}`,
+		gin.Params{{Key: "id", Value: "21"}}, gin.H{"user_id": 5, "role": "cashier"})
+	if code != http.StatusOK {
+		t.Fatalf("pay document fee: %d %v", code, resp)
+	}
+	if requestStatus != requestPaid {
+		t.Fatalf("request status = %q, want %q", requestStatus, requestPaid)
+	}
+	if fake.ran("INSERT INTO payment_fees") {
+		t.Error("document fee was added to the tuition assessment")
+	}
+	if totalAmount != 30000 || amountPaid != 0 {
+		t.Fatalf("tuition account changed to total %d, paid %d", totalAmount, amountPaid)
+	}
+
+	code, resp = runHandler(t, StudentDownPayment,
+		`{"payment_id": 11, "down_payment": 6000, "payment_method": "cash"}`, nil, student)
+	if code != http.StatusOK {
+		t.Fatalf("downpayment: %d %v", code, resp)
+	}
+	if amountPaid != 6000 {
+		t.Errorf("amount_paid = %d, want 6000", amountPaid)
+	}
+	if resp["remaining"] != float64(24000) {
+		t.Errorf("remaining = %v, want 24000", resp["remaining"])
+	}
+}
@@ -0,0 +1,1047 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"student-portal/config"
+	"student-portal/filestore"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ===================== DOCUMENT TYPES & TEMPLATES =====================
+//
+// Records staff manage the document types students may request and a
+// versioned template for each. A template has a letterhead, office and title
+// lines, a body with {{placeholders}}, signatories and a footer note. Body
+// paragraphs are separated by blank lines; a paragraph that is only a block
+// placeholder such as {{grades_table}} is replaced by that block, "## X" is a
+// section heading and "**X**" a bold paragraph. Only one version per type is
+// published at a time; requests record the version they were generated from.
+
+type documentType struct {
+	ID           int    `json:"id"`
+	Code         string `json:"code"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	SerialPrefix string `json:"serial_prefix"`
+	Requestable  bool   `json:"requestable"`
+	IsActive     bool   `json:"is_active"`
+
+	// Fees are whole pesos, like the rest of the student account
+	FeePerCopy     int `json:"fee_per_copy"`
+	RushFee        int `json:"rush_fee"`
+	ProcessingDays int `json:"processing_days"`
+	RushDays       int `json:"rush_days"`
+	MaxCopies      int `json:"max_copies"`
+}
+
+type documentSignatory struct {
+	Name   string `json:"name"`
+	Title  string `json:"title"`
+	Office string `json:"office"`
+
+	// Set only from a finished approval chain, never from a template
+	SignatureFile string     `json:"-"`
+	SignedAt      *time.Time `json:"-"`
+}
+
+type documentTemplate struct {
+	ID             int                 `json:"id"`
+	DocumentTypeID int                 `json:"document_type_id"`
+	Version        int                 `json:"version"`
+	Letterhead     string              `json:"letterhead"`
+	Office         string              `json:"office"`
+	Title          string              `json:"title"`
+	Body           string              `json:"body"`
+	Signatories    []documentSignatory `json:"signatories"`
+	FooterNote     string              `json:"footer_note"`
+	Status         string              `json:"status"`
+	CreatedAt      time.Time           `json:"created_at"`
+	PublishedAt    *time.Time          `json:"published_at"`
+}
+
+const (
+	templateDraft     = "draft"
+	templatePublished = "published"
+	templateArchived  = "archived"
+)
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// documentPlaceholderNames lists every placeholder a template may use.
+var documentPlaceholderNames = []string{
+	"school_name", "school_address", "school_contact",
+	"full_name", "first_name", "middle_name", "last_name",
+	"student_number", "course", "year_level", "semester", "email", "address",
+	"scholarship_status", "purpose", "purpose_lower",
+	"date_issued", "date_issued_long", "gwa", "latin_honors",
+	"student_info", "entrance_data", "grades_table", "credited_subjects",
+	"grading_legend", "end_of_record",
+}
+
+const documentTypeColumns = `id, code, name, IFNULL(description, ''), serial_prefix, requestable, is_active,
+	fee_per_copy, rush_fee, processing_days, rush_days, max_copies`
+
+func scanDocumentType(row interface{ Scan(...interface{}) error }) (*documentType, error) {
+	var t documentType
+	if err := row.Scan(&t.ID, &t.Code, &t.Name, &t.Description, &t.SerialPrefix, &t.Requestable, &t.IsActive,
+		&t.FeePerCopy, &t.RushFee, &t.ProcessingDays, &t.RushDays, &t.MaxCopies); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// documentTypeCode turns "Good Moral Certificate" into "good_moral_certificate".
+func documentTypeCode(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "_")
+}
+
+// findDocumentType resolves a requested document type by code or name, so
+// both "tor" and "Transcript of Records" work.
+func findDocumentType(name string) (*documentType, error) {
+	return scanDocumentType(config.DB.QueryRow(`
+		SELECT `+documentTypeColumns+` FROM document_types
+		WHERE code = ? OR LOWER(name) = ?
+		ORDER BY code = ? DESC
+		LIMIT 1
+	`, documentTypeCode(name), strings.ToLower(strings.TrimSpace(name)), documentTypeCode(name)))
+}
+
+const documentTemplateColumns = `id, document_type_id, version, letterhead, IFNULL(office, ''), title, body,
+	IFNULL(signatories, '[]'), IFNULL(footer_note, ''), status, created_at, published_at`
+
+func scanDocumentTemplate(row interface{ Scan(...interface{}) error }) (*documentTemplate, error) {
+	var t documentTemplate
+	var signatories string
+	if err := row.Scan(&t.ID, &t.DocumentTypeID, &t.Version, &t.Letterhead, &t.Office, &t.Title, &t.Body,
+		&signatories, &t.FooterNote, &t.Status, &t.CreatedAt, &t.PublishedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(signatories), &t.Signatories); err != nil {
+		return nil, fmt.Errorf("template %d has invalid signatories: %w", t.ID, err)
+	}
+	return &t, nil
+}
+
+func loadDocumentTemplate(id int) (*documentTemplate, error) {
+	return scanDocumentTemplate(config.DB.QueryRow(`SELECT `+documentTemplateColumns+` FROM document_templates WHERE id = ?`, id))
+}
+
+func publishedDocumentTemplate(documentTypeID int) (*documentTemplate, error) {
+	return scanDocumentTemplate(config.DB.QueryRow(`
+		SELECT `+documentTemplateColumns+` FROM document_templates
+		WHERE document_type_id = ? AND status = 'published'
+		ORDER BY version DESC LIMIT 1
+	`, documentTypeID))
+}
+
+// unknownPlaceholders returns placeholders in text that the renderer does
+// not know, so typos are caught when the template is saved.
+func unknownPlaceholders(texts ...string) []string {
+	known := map[string]bool{}
+	for _, n := range documentPlaceholderNames {
+		known[n] = true
+	}
+	seen := map[string]bool{}
+	var unknown []string
+	for _, text := range texts {
+		for _, m := range templatePlaceholder.FindAllStringSubmatch(text, -1) {
+			if !known[m[1]] && !seen[m[1]] {
+				seen[m[1]] = true
+				unknown = append(unknown, m[1])
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// ordinalDay formats 1 -> "1st", 22 -> "22nd", 13 -> "13th".
+func ordinalDay(d int) string {
+	suffix := "th"
+	if d%100 < 11 || d%100 > 13 {
+		switch d % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(d) + suffix
+}
+
+func schoolSettings() (name, address, contact string) {
+	return getSetting("school_name", "THE UNIVERSITY OF MANILA"),
+		getSetting("school_address", "Sampaloc District in Manila, Philippines."),
+		getSetting("school_contact", "Tel: 287355085 | https://www.facebook.com/UMOFFICIAL1913/")
+}
+
+// documentValues fills the text placeholders for a student. GWA and honors
+// are only computed when the template uses them.
+func documentValues(data StudentDocumentData, texts ...string) map[string]string {
+	name, address, contact := schoolSettings()
+	now := time.Now()
+	v := map[string]string{
+		"school_name":        name,
+		"school_address":     address,
+		"school_contact":     contact,
+		"full_name":          strings.Join(strings.Fields(data.FirstName+" "+data.MiddleName+" "+data.LastName), " "),
+		"first_name":         data.FirstName,
+		"middle_name":        data.MiddleName,
+		"last_name":          data.LastName,
+		"student_number":     data.StudentNumber,
+		"course":             data.Course,
+		"year_level":         data.YearLevel,
+		"semester":           data.Semester,
+		"email":              data.Email,
+		"address":            data.Address,
+		"scholarship_status": data.ScholarshipStatus,
+		"purpose":            data.Purpose,
+		"purpose_lower":      strings.ToLower(data.Purpose),
+		"date_issued":        now.Format("January 2, 2006"),
+		"date_issued_long":   ordinalDay(now.Day()) + " day of " + now.Format("January, 2006"),
+		"gwa":                "",
+		"latin_honors":       "",
+	}
+
+	all := strings.Join(texts, "\n")
+	if data.StudentDBID > 0 && (strings.Contains(all, "gwa") || strings.Contains(all, "latin_honors")) {
+		if standing, err := computeAcademicStanding(data.StudentDBID); err == nil {
+			if standing.GWA != nil {
+				v["gwa"] = fmt.Sprintf("%.2f", *standing.GWA)
+			}
+			v["latin_honors"] = standing.LatinHonors
+		}
+	}
+	return v
+}
+
+func fillPlaceholders(text string, values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		name := templatePlaceholder.FindStringSubmatch(m)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// documentContext is what block renderers get: the PDF being built, the
+// student and how many record entries were printed on each page.
+type documentContext struct {
+	pdf     *gofpdf.Fpdf
+	data    StudentDocumentData
+	values  map[string]string
+	entries map[int]int
+
+	standing *academicStanding
+}
+
+// academicStanding loads the student's grades once per document.
+func (d *documentContext) academicStanding() (*academicStanding, error) {
+	if d.standing != nil {
+		return d.standing, nil
+	}
+	if d.data.StudentDBID == 0 {
+		d.standing = &academicStanding{}
+		return d.standing, nil
+	}
+	standing, err := computeAcademicStanding(d.data.StudentDBID)
+	if err != nil {
+		return nil, err
+	}
+	d.standing = standing
+	return standing, nil
+}
+
+// documentBlocks are the placeholders that stand alone as a paragraph and
+// expand to a table or section rather than text.
+var documentBlocks = map[string]func(*documentContext) error{
+	"student_info":      writeStudentInfo,
+	"entrance_data":     writeEntranceData,
+	"grades_table":      writeGradesTable,
+	"credited_subjects": writeCreditedSubjects,
+	"grading_legend":    writeGradingLegend,
+	"end_of_record":     writeEndOfRecord,
+}
+
+// renderDocument lays out a template for one student. A non-empty banner is
+// printed in red at the top of every page (used for previews). Every page
+// carries "Page X of Y" and, when record entries were printed on it, their
+// count, so pages cannot be swapped or padded unnoticed.
+func renderDocument(tpl *documentTemplate, data StudentDocumentData, banner string) (*gofpdf.Fpdf, error) {
+	values := documentValues(data, tpl.Letterhead, tpl.Body, tpl.FooterNote)
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	ctx := &documentContext{pdf: pdf, data: data, values: values, entries: map[int]int{}}
+
+	if banner != "" {
+		pdf.SetHeaderFunc(func() {
+			pdf.SetFont("Arial", "B", 9)
+			pdf.SetTextColor(185, 28, 28)
+			pdf.SetXY(10, 4)
+			pdf.Cell(0, 4, banner)
+			pdf.SetTextColor(0, 0, 0)
+			pdf.SetY(10)
+		})
+	}
+
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Arial", "", 8)
+		pdf.SetTextColor(100, 100, 100)
+		left := "Student No. " + data.StudentNumber
+		if data.Serial != "" {
+			left += "  |  Serial No. " + data.Serial
+		}
+		if n := ctx.entries[pdf.PageNo()]; n > 0 {
+			left += fmt.Sprintf("  |  Entries on this page: %d", n)
+		}
+		pdf.CellFormat(140, 5, left, "T", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "T", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+	pdf.AddPage()
+
+	writeLetterhead(pdf, fillPlaceholders(tpl.Letterhead, values))
+
+	if tpl.Office != "" {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(0, 6, fillPlaceholders(tpl.Office, values))
+		pdf.Ln(12)
+	}
+
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(0, 10, fillPlaceholders(tpl.Title, values))
+	pdf.Ln(15)
+
+	for _, para := range strings.Split(strings.ReplaceAll(tpl.Body, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if m := templatePlaceholder.FindStringSubmatch(para); m != nil && m[0] == para {
+			if block, ok := documentBlocks[m[1]]; ok {
+				if err := block(ctx); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		switch {
+		case para == "":
+			continue
+		case strings.HasPrefix(para, "## "):
+			writeDocumentSection(pdf, fillPlaceholders(strings.TrimPrefix(para, "## "), values))
+		case strings.HasPrefix(para, "**") && strings.HasSuffix(para, "**") && len(para) > 4:
+			pdf.SetFont("Arial", "B", 11)
+			pdf.MultiCell(0, 6, fillPlaceholders(strings.Trim(para, "*"), values), "", "L", false)
+			pdf.Ln(8)
+		default:
+			pdf.SetFont("Arial", "", 11)
+			pdf.MultiCell(0, 7, fillPlaceholders(para, values), "", "J", false)
+			pdf.Ln(8)
+		}
+	}
+
+	writeSignatories(pdf, tpl.Signatories, values)
+
+	if tpl.FooterNote != "" {
+		pdf.Ln(4)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.SetTextColor(100, 100, 100)
+		pdf.MultiCell(0, 4, fillPlaceholders(tpl.FooterNote, values), "", "L", false)
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	if data.Serial != "" {
+		pdf.Ln(4)
+		writeVerificationFooter(pdf, data.Serial)
+	}
+
+	return pdf, pdf.Error()
+}
+
+// writeLetterhead prints the first line large and the rest small, with the
+// green rule used on every university document.
+func writeLetterhead(pdf *gofpdf.Fpdf, letterhead string) {
+	for i, line := range strings.Split(strings.TrimSpace(letterhead), "\n") {
+		switch i {
+		case 0:
+			pdf.SetFont("Arial", "B", 18)
+			pdf.Cell(0, 8, strings.TrimSpace(line))
+			pdf.Ln(7)
+		case 1:
+			pdf.SetFont("Arial", "", 10)
+			pdf.Cell(0, 5, strings.TrimSpace(line))
+			pdf.Ln(4)
+		default:
+			pdf.SetFont("Arial", "", 9)
+			pdf.Cell(0, 4, strings.TrimSpace(line))
+			pdf.Ln(4)
+		}
+	}
+	pdf.Ln(-2)
+	pdf.SetDrawColor(40, 145, 108)
+	pdf.SetLineWidth(0.5)
+	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
+	pdf.Ln(8)
+}
+
+func writeDocumentSection(pdf *gofpdf.Fpdf, title string) {
+	pdf.SetFont("Arial", "B", 10)
+	pdf.Cell(0, 6, title)
+	pdf.Ln(1)
+	pdf.SetDrawColor(200, 200, 200)
+	pdf.SetLineWidth(0.3)
+	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
+	pdf.Ln(5)
+}
+
+func writeStudentInfo(ctx *documentContext) error {
+	data := ctx.data
+	writeDocumentSection(ctx.pdf, "STUDENT INFORMATION")
+	writeDocumentFields(ctx.pdf, [][2]string{
+		{"Student Number:", data.StudentNumber},
+		{"Name:", fmt.Sprintf("%s %s %s", data.FirstName, data.MiddleName, data.LastName)},
+		{"Course/Program:", data.Course},
+		{"Year Level:", data.YearLevel},
+	})
+	return nil
+}
+
+// writeDocumentFields prints label/value pairs, one per line.
+func writeDocumentFields(pdf *gofpdf.Fpdf, fields [][2]string) {
+	for _, f := range fields {
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(45, 6, f[0])
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(0, 6, f[1])
+		pdf.Ln(5)
+	}
+	pdf.Ln(5)
+}
+
+// writeSignatories prints a signature line per signatory, two per row.
+// Signatories from an approval chain get their signature image stamped
+// above their name and the approval time under their title.
+func writeSignatories(pdf *gofpdf.Fpdf, signatories []documentSignatory, values map[string]string) {
+	if len(signatories) == 0 {
+		return
+	}
+	pdf.Ln(6)
+	left, _, _, _ := pdf.GetMargins()
+	const (
+		width     = 85.0
+		sigHeight = 14.0
+	)
+
+	for i := 0; i < len(signatories); i += 2 {
+		row := signatories[i:minInt(i+2, len(signatories))]
+		rowHeight := 24.0
+		stamped := false
+		for _, s := range row {
+			if s.SignatureFile != "" {
+				stamped = true
+			}
+		}
+		if stamped {
+			rowHeight += sigHeight + 6
+		}
+		ensureRoom(pdf, rowHeight)
+
+		y := pdf.GetY()
+		for j, s := range row {
+			x := left + float64(j)*(width+10)
+			nameY := y
+			if stamped {
+				nameY += sigHeight + 2
+			}
+			if s.SignatureFile != "" {
+				opts := gofpdf.ImageOptions{ImageType: "PNG", ReadDpi: false}
+				var info *gofpdf.ImageInfoType
+				if data, err := filestore.ReadAll(s.SignatureFile); err == nil {
+					info = pdf.RegisterImageOptionsReader(s.SignatureFile, opts, bytes.NewReader(data))
+				} else {
+					fmt.Println("⚠️ Warning: signature image unavailable:", err)
+				}
+				if info != nil {
+					w, h := info.Width()*sigHeight/info.Height(), sigHeight
+					if w > width-10 {
+						w, h = width-10, info.Height()*(width-10)/info.Width()
+					}
+					pdf.ImageOptions(s.SignatureFile, x+5, y+sigHeight-h, w, h, false, opts, 0, "")
+				}
+			}
+			pdf.SetXY(x, nameY)
+			pdf.SetFont("Arial", "B", 10)
+			pdf.CellFormat(width, 5, strings.ToUpper(fillPlaceholders(s.Name, values)), "", 2, "L", false, 0, "")
+			pdf.SetFont("Arial", "", 10)
+			pdf.CellFormat(width, 5, "_________________________________________", "", 2, "L", false, 0, "")
+			pdf.SetFont("Arial", "B", 10)
+			pdf.CellFormat(width, 5, fillPlaceholders(s.Title, values), "", 2, "L", false, 0, "")
+			if s.Office != "" {
+				pdf.SetFont("Arial", "", 9)
+				pdf.CellFormat(width, 4, fillPlaceholders(s.Office, values), "", 2, "L", false, 0, "")
+			}
+			if s.SignedAt != nil {
+				pdf.SetFont("Arial", "I", 7)
+				pdf.SetTextColor(100, 100, 100)
+				pdf.CellFormat(width, 4, "Digitally approved "+s.SignedAt.Format("January 2, 2006 3:04 PM"), "", 2, "L", false, 0, "")
+				pdf.SetTextColor(0, 0, 0)
+			}
+		}
+		pdf.SetXY(left, y+rowHeight)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// sampleDocumentData is used to preview a template without a real student.
+func sampleDocumentData() StudentDocumentData {
+	return StudentDocumentData{
+		StudentNumber:     "2026-00001",
+		FirstName:         "Juan",
+		MiddleName:        "Santos",
+		LastName:          "Dela Cruz",
+		Course:            "Bachelor of Science in Information Technology",
+		YearLevel:         "3rd",
+		Email:             "juan.delacruz@example.com",
+		Address:           "Sampaloc, Manila",
+		Semester:          "1st",
+		ScholarshipStatus: "non-scholar",
+		Purpose:           "Employment",
+		DateRequested:     time.Now().Format("2006-01-02"),
+	}
+}
+
+// studentDocumentData loads the fields documents are filled from.
+func studentDocumentData(studentDBID int, purpose string) (StudentDocumentData, error) {
+	d := StudentDocumentData{StudentDBID: studentDBID, Purpose: purpose, DateRequested: time.Now().Format("2006-01-02")}
+	err := config.DB.QueryRow(`
+		SELECT
+			s.student_id, s.first_name, IFNULL(s.middle_name, ''), s.last_name,
+			IFNULL(s.email, ''), IFNULL(s.address, ''),
+			IFNULL(c.course_name, 'N/A'),
+			IFNULL(sa.year_level, '1'),
+			IFNULL(sa.semester, '1st'),
+			IFNULL(sa.scholarship_status, 'non-scholar')
+		FROM students s
+		LEFT JOIN student_academic sa ON s.id = sa.student_id
+		LEFT JOIN courses c ON sa.course = c.id
+		WHERE s.id = ?
+	`, studentDBID).Scan(&d.StudentNumber, &d.FirstName, &d.MiddleName, &d.LastName,
+		&d.Email, &d.Address, &d.Course, &d.YearLevel, &d.Semester, &d.ScholarshipStatus)
+	return d, err
+}
+
+// ===================== DOCUMENT TYPE REGISTRY =====================
+
+// GET /records/document-types
+func RecordsGetDocumentTypes(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT ` + documentTypeColumns + `,
+		       (SELECT MAX(version) FROM document_templates t WHERE t.document_type_id = document_types.id AND t.status = 'published')
+		FROM document_types ORDER BY name
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load document types"})
+		return
+	}
+	defer rows.Close()
+
+	types := []gin.H{}
+	for rows.Next() {
+		var t documentType
+		var published *int
+		if err := rows.Scan(&t.ID, &t.Code, &t.Name, &t.Description, &t.SerialPrefix, &t.Requestable, &t.IsActive,
+			&t.FeePerCopy, &t.RushFee, &t.ProcessingDays, &t.RushDays, &t.MaxCopies, &published); err != nil {
+			continue
+		}
+		types = append(types, gin.H{
+			"id":                t.ID,
+			"code":              t.Code,
+			"name":              t.Name,
+			"description":       t.Description,
+			"serial_prefix":     t.SerialPrefix,
+			"requestable":       t.Requestable,
+			"is_active":         t.IsActive,
+			"fee_per_copy":      t.FeePerCopy,
+			"rush_fee":          t.RushFee,
+			"processing_days":   t.ProcessingDays,
+			"rush_days":         t.RushDays,
+			"max_copies":        t.MaxCopies,
+			"published_version": published,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"document_types": types})
+}
+
+// GET /student/document-types
+// What students can request right now: active, requestable and with a
+// published template.
+func StudentGetDocumentTypes(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT ` + documentTypeColumns + ` FROM document_types d
+		WHERE requestable = TRUE AND is_active = TRUE
+		  AND EXISTS (SELECT 1 FROM document_templates t WHERE t.document_type_id = d.id AND t.status = 'published')
+		ORDER BY name
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load document types"})
+		return
+	}
+	defer rows.Close()
+
+	types := []gin.H{}
+	for rows.Next() {
+		t, err := scanDocumentType(rows)
+		if err != nil {
+			continue
+		}
+		types = append(types, gin.H{
+			"code":            t.Code,
+			"name":            t.Name,
+			"description":     t.Description,
+			"fee_per_copy":    t.FeePerCopy,
+			"rush_fee":        t.RushFee,
+			"processing_days": t.ProcessingDays,
+			"rush_days":       t.RushDays,
+			"max_copies":      t.MaxCopies,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"document_types": types})
+}
+
+type documentTypeRequest struct {
+	Code         string `json:"code"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	SerialPrefix string `json:"serial_prefix"`
+	Requestable  *bool  `json:"requestable"`
+	IsActive     *bool  `json:"is_active"`
+
+	FeePerCopy     *int `json:"fee_per_copy"`
+	RushFee        *int `json:"rush_fee"`
+	ProcessingDays *int `json:"processing_days"`
+	RushDays       *int `json:"rush_days"`
+	MaxCopies      *int `json:"max_copies"`
+}
+
+// applyFees copies the fee settings that were sent onto t.
+func (r documentTypeRequest) applyFees(t *documentType) error {
+	for _, f := range []struct {
+		in  *int
+		out *int
+		min int
+	}{
+		{r.FeePerCopy, &t.FeePerCopy, 0},
+		{r.RushFee, &t.RushFee, 0},
+		{r.ProcessingDays, &t.ProcessingDays, 0},
+		{r.RushDays, &t.RushDays, 0},
+		{r.MaxCopies, &t.MaxCopies, 1},
+	} {
+		if f.in == nil {
+			continue
+		}
+		if *f.in < f.min {
+			return fmt.Errorf("fees, days and copies cannot be negative and max_copies must be at least 1")
+		}
+		*f.out = *f.in
+	}
+	if t.RushDays > t.ProcessingDays {
+		return fmt.Errorf("rush_days cannot be longer than processing_days")
+	}
+	return nil
+}
+
+var serialPrefixPattern = regexp.MustCompile(`^[A-Z]{2,6}$`)
+
+// POST /records/document-types
+func RecordsCreateDocumentType(c *gin.Context) {
+	var req documentTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if req.Code == "" {
+		req.Code = documentTypeCode(req.Name)
+	}
+	req.Code = documentTypeCode(req.Code)
+	req.SerialPrefix = strings.ToUpper(strings.TrimSpace(req.SerialPrefix))
+	if req.SerialPrefix == "" {
+		req.SerialPrefix = "DOC"
+	}
+	if !serialPrefixPattern.MatchString(req.SerialPrefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "serial_prefix must be 2-6 letters"})
+		return
+	}
+	requestable := req.Requestable == nil || *req.Requestable
+	fees := documentType{ProcessingDays: 5, RushDays: 2, MaxCopies: 10}
+	if err := req.applyFees(&fees); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		INSERT INTO document_types (code, name, description, serial_prefix, requestable, is_active,
+			fee_per_copy, rush_fee, processing_days, rush_days, max_copies)
+		VALUES (?, ?, ?, ?, ?, TRUE, ?, ?, ?, ?, ?)
+	`, req.Code, strings.TrimSpace(req.Name), req.Description, req.SerialPrefix, requestable,
+		fees.FeePerCopy, fees.RushFee, fees.ProcessingDays, fees.RushDays, fees.MaxCopies)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "a document type with this code already exists"})
+		return
+	}
+
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Document type created; add and publish a template before students can request it",
+		"id":      id,
+		"code":    req.Code,
+	})
+}
+
+// PUT /records/document-types/:id
+func RecordsUpdateDocumentType(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	t, err := scanDocumentType(config.DB.QueryRow(`SELECT `+documentTypeColumns+` FROM document_types WHERE id = ?`, id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document type not found"})
+		return
+	}
+
+	var req documentTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Name) != "" {
+		t.Name = strings.TrimSpace(req.Name)
+	}
+	if req.Description != "" {
+		t.Description = req.Description
+	}
+	if req.SerialPrefix != "" {
+		t.SerialPrefix = strings.ToUpper(strings.TrimSpace(req.SerialPrefix))
+		if !serialPrefixPattern.MatchString(t.SerialPrefix) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "serial_prefix must be 2-6 letters"})
+			return
+		}
+	}
+	if req.Requestable != nil {
+		t.Requestable = *req.Requestable
+	}
+	if req.IsActive != nil {
+		t.IsActive = *req.IsActive
+	}
+	if err := req.applyFees(t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		UPDATE document_types SET name = ?, description = ?, serial_prefix = ?, requestable = ?, is_active = ?,
+			fee_per_copy = ?, rush_fee = ?, processing_days = ?, rush_days = ?, max_copies = ?
+		WHERE id = ?
+	`, t.Name, t.Description, t.SerialPrefix, t.Requestable, t.IsActive,
+		t.FeePerCopy, t.RushFee, t.ProcessingDays, t.RushDays, t.MaxCopies, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update document type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document type updated", "document_type": t})
+}
+
+// ===================== TEMPLATE VERSIONS =====================
+
+// GET /records/document-types/:id/templates
+func RecordsGetDocumentTemplates(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT `+documentTemplateColumns+` FROM document_templates
+		WHERE document_type_id = ? ORDER BY version DESC
+	`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load templates"})
+		return
+	}
+	defer rows.Close()
+
+	templates := []*documentTemplate{}
+	for rows.Next() {
+		t, err := scanDocumentTemplate(rows)
+		if err != nil {
+			fmt.Println("❌ Template scan error:", err)
+			continue
+		}
+		templates = append(templates, t)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates, "placeholders": documentPlaceholderNames})
+}
+
+type documentTemplateRequest struct {
+	Letterhead  *string              `json:"letterhead"`
+	Office      *string              `json:"office"`
+	Title       *string              `json:"title"`
+	Body        *string              `json:"body"`
+	Signatories *[]documentSignatory `json:"signatories"`
+	FooterNote  *string              `json:"footer_note"`
+}
+
+// apply copies the fields present in the request onto t and validates the
+// result.
+func (r documentTemplateRequest) apply(t *documentTemplate) error {
+	if r.Letterhead != nil {
+		t.Letterhead = *r.Letterhead
+	}
+	if r.Office != nil {
+		t.Office = *r.Office
+	}
+	if r.Title != nil {
+		t.Title = *r.Title
+	}
+	if r.Body != nil {
+		t.Body = *r.Body
+	}
+	if r.Signatories != nil {
+		t.Signatories = *r.Signatories
+	}
+	if r.FooterNote != nil {
+		t.FooterNote = *r.FooterNote
+	}
+	if t.Signatories == nil {
+		t.Signatories = []documentSignatory{}
+	}
+
+	if strings.TrimSpace(t.Title) == "" || strings.TrimSpace(t.Body) == "" || strings.TrimSpace(t.Letterhead) == "" {
+		return fmt.Errorf("letterhead, title and body are required")
+	}
+	for _, s := range t.Signatories {
+		if strings.TrimSpace(s.Title) == "" {
+			return fmt.Errorf("every signatory needs a title")
+		}
+	}
+	texts := []string{t.Letterhead, t.Office, t.Title, t.Body, t.FooterNote}
+	for _, s := range t.Signatories {
+		texts = append(texts, s.Name, s.Title, s.Office)
+	}
+	if unknown := unknownPlaceholders(texts...); len(unknown) > 0 {
+		return fmt.Errorf("unknown placeholders: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// POST /records/document-types/:id/templates
+// Creates the next draft version. Fields left out are copied from the latest
+// version.
+func RecordsCreateDocumentTemplate(c *gin.Context) {
+	typeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var exists int
+	config.DB.QueryRow(`SELECT COUNT(*) FROM document_types WHERE id = ?`, typeID).Scan(&exists)
+	if exists == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document type not found"})
+		return
+	}
+
+	var req documentTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tpl, err := scanDocumentTemplate(config.DB.QueryRow(`
+		SELECT `+documentTemplateColumns+` FROM document_templates
+		WHERE document_type_id = ? ORDER BY version DESC LIMIT 1
+	`, typeID))
+	if err == sql.ErrNoRows {
+		tpl = &documentTemplate{Letterhead: "{{school_name}}\n{{school_address}}\n{{school_contact}}"}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load latest template"})
+		return
+	}
+	version := tpl.Version + 1
+
+	if err := req.apply(tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	signatories, _ := json.Marshal(tpl.Signatories)
+
+	res, err := config.DB.Exec(`
+		INSERT INTO document_templates
+		(document_type_id, version, letterhead, office, title, body, signatories, footer_note, status, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'draft', ?)
+	`, typeID, version, tpl.Letterhead, tpl.Office, tpl.Title, tpl.Body, string(signatories), tpl.FooterNote, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save template"})
+		return
+	}
+
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"message": "Draft template saved", "id": id, "version": version})
+}
+
+// PUT /records/document-templates/:id
+// Only drafts can be edited; published versions stay as they were issued.
+func RecordsUpdateDocumentTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	tpl, err := loadDocumentTemplate(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if tpl.Status != templateDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "only draft templates can be edited; create a new version instead"})
+		return
+	}
+
+	var req documentTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.apply(tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	signatories, _ := json.Marshal(tpl.Signatories)
+
+	_, err = config.DB.Exec(`
+		UPDATE document_templates
+		SET letterhead = ?, office = ?, title = ?, body = ?, signatories = ?, footer_note = ?
+		WHERE id = ? AND status = 'draft'
+	`, tpl.Letterhead, tpl.Office, tpl.Title, tpl.Body, string(signatories), tpl.FooterNote, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Draft template updated", "template": tpl})
+}
+
+// POST /records/document-templates/:id/publish
+// Publishes a draft and archives the version it replaces.
+func RecordsPublishDocumentTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	tpl, err := loadDocumentTemplate(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	if tpl.Status != templateDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "template is already " + tpl.Status})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to publish template"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE document_templates SET status = 'archived'
+		WHERE document_type_id = ? AND status = 'published'
+	`, tpl.DocumentTypeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to publish template"})
+		return
+	}
+	if _, err := tx.Exec(`
+		UPDATE document_templates SET status = 'published', published_at = NOW(), published_by = ?
+		WHERE id = ?
+	`, c.GetInt("user_id"), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to publish template"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to publish template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Version %d published", tpl.Version), "id": id})
+}
+
+// GET /records/document-templates/:id/preview?student_id=
+// Renders the template as a PDF, for a real student when student_id (the
+// students.id) is given and with sample data otherwise. Previews carry no
+// serial and are not recorded.
+func RecordsPreviewDocumentTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	tpl, err := loadDocumentTemplate(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+
+	data := sampleDocumentData()
+	if sid := c.Query("student_id"); sid != "" {
+		studentDBID, err := strconv.Atoi(sid)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student_id"})
+			return
+		}
+		if data, err = studentDocumentData(studentDBID, "Preview"); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+			return
+		}
+	}
+
+	// The banner keeps a preview from being mistaken for an issued document
+	banner := fmt.Sprintf("PREVIEW - template version %d (%s) - not a valid document", tpl.Version, tpl.Status)
+	pdf, err := renderDocument(tpl, data, banner)
+	if err != nil {
+		fmt.Println("❌ Template preview error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render template"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render template"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="template_%d_v%d_preview.pdf"`, tpl.DocumentTypeID, tpl.Version))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
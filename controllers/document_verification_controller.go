@@ -0,0 +1,429 @@
+package controllers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"student-portal/config"
+	"student-portal/filestore"
+	"student-portal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ===================== DOCUMENT VERIFICATION =====================
+//
+// Every generated document gets a serial and a QR code linking to the public
+// /verify/:serial page. After the PDF is written its SHA-256 is signed with
+// the server's Ed25519 key, so a holder of the file can check it online
+// (upload it to /verify/:serial) or offline against /verify/public-key.
+
+// maxSerialPrefix keeps serials within issued_documents.serial.
+const maxSerialPrefix = 6
+
+// newDocumentSerial returns e.g. TOR-2026-K3M9QX2WFD. The random part is 50
+// bits so serials cannot be guessed to enumerate other students' documents.
+// The prefix is a short code; anything else in it (spaces from a document
+// type name) is dropped so the serial fits its column and the QR link.
+func newDocumentSerial(prefix string) (string, error) {
+	prefix = strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToUpper(prefix))
+	if len(prefix) > maxSerialPrefix {
+		prefix = prefix[:maxSerialPrefix]
+	}
+	if prefix == "" {
+		prefix = "DOC"
+	}
+
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)[:10]
+	return fmt.Sprintf("%s-%d-%s", prefix, time.Now().Year(), code), nil
+}
+
+func verificationURL(serial string) string {
+	base := strings.TrimRight(os.Getenv("APP_BASE_URL"), "/")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return base + "/verify/" + serial
+}
+
+// writeVerificationFooter prints the serial, verification link and QR code
+// below the current content, starting a new page when there is no room.
+func writeVerificationFooter(pdf *gofpdf.Fpdf, serial string) {
+	const qrSize = 28.0
+	pageW, pageH := pdf.GetPageSize()
+	left, top, right, bottom := pdf.GetMargins()
+
+	y := pdf.GetY() + 4
+	if y+qrSize > pageH-bottom {
+		pdf.AddPage()
+		y = top
+	}
+	url := verificationURL(serial)
+
+	if modules, err := utils.EncodeQR(url); err == nil {
+		drawQRCode(pdf, modules, pageW-right-qrSize, y, qrSize)
+	} else {
+		fmt.Println("⚠️ QR code error:", err)
+	}
+
+	pdf.SetTextColor(100, 100, 100)
+	pdf.SetFont("Arial", "B", 8)
+	pdf.SetXY(left, y+6)
+	pdf.Cell(0, 4, "Document Serial No.: "+serial)
+	pdf.SetFont("Arial", "", 7)
+	pdf.SetXY(left, y+11)
+	pdf.Cell(0, 4, "Verify this document by scanning the QR code or visiting:")
+	pdf.SetXY(left, y+15)
+	pdf.Cell(0, 4, url)
+	pdf.SetXY(left, y+19)
+	pdf.Cell(0, 4, "This file is digitally signed (Ed25519). Any alteration invalidates the signature.")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetY(y + qrSize)
+}
+
+// drawQRCode draws the modules with a 4-module quiet zone in a size x size
+// square at (x, y).
+func drawQRCode(pdf *gofpdf.Fpdf, modules [][]bool, x, y, size float64) {
+	cell := size / float64(len(modules)+8)
+	pdf.SetFillColor(255, 255, 255)
+	pdf.Rect(x, y, size, size, "F")
+	pdf.SetFillColor(0, 0, 0)
+	for r, row := range modules {
+		for col, dark := range row {
+			if dark {
+				pdf.Rect(x+float64(col+4)*cell, y+float64(r+4)*cell, cell, cell, "F")
+			}
+		}
+	}
+}
+
+// issueDocument hashes and signs a generated PDF and records it under its
+// serial.
+func issueDocument(exec interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, serial string, requestID, studentID int, documentType, path string, issuedBy int) error {
+	f, err := filestore.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	digest := h.Sum(nil)
+
+	signature, keyID, err := utils.SignDocumentHash(digest)
+	if err != nil {
+		return err
+	}
+	if err := recordSigningKey(); err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`
+		INSERT INTO issued_documents
+		(serial, request_id, student_id, document_type, file_path, sha256, signature, key_id, issued_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, serial, requestID, studentID, documentType, path, hex.EncodeToString(digest),
+		base64.StdEncoding.EncodeToString(signature), keyID, issuedBy)
+	return err
+}
+
+type issuedDocument struct {
+	Serial        string
+	DocumentType  string
+	StudentName   string
+	StudentNumber string
+	Course        string
+	SHA256        string
+	Signature     string
+	KeyID         string
+	IssuedAt      time.Time
+	RevokedAt     *time.Time
+	RevokeReason  string
+}
+
+func loadIssuedDocument(serial string) (*issuedDocument, error) {
+	var d issuedDocument
+	err := config.DB.QueryRow(`
+		SELECT d.serial, d.document_type,
+		       CONCAT(s.first_name, ' ', s.last_name), s.student_id,
+		       IFNULL(c.course_name, ''),
+		       d.sha256, d.signature, d.key_id, d.issued_at, d.revoked_at, IFNULL(d.revoke_reason, '')
+		FROM issued_documents d
+		INNER JOIN students s ON s.id = d.student_id
+		LEFT JOIN student_academic sa ON sa.student_id = s.id
+		LEFT JOIN courses c ON c.id = sa.course
+		WHERE d.serial = ?
+	`, strings.ToUpper(strings.TrimSpace(serial))).Scan(&d.Serial, &d.DocumentType, &d.StudentName, &d.StudentNumber,
+		&d.Course, &d.SHA256, &d.Signature, &d.KeyID, &d.IssuedAt, &d.RevokedAt, &d.RevokeReason)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (d *issuedDocument) status() string {
+	if d.RevokedAt != nil {
+		return "revoked"
+	}
+	return "valid"
+}
+
+// recordSigningKey keeps the public half of the current signing key, so
+// documents it signed can still be checked after the key is replaced.
+func recordSigningKey() error {
+	pub, keyID, err := utils.SigningPublicKey()
+	if err != nil {
+		return err
+	}
+	_, err = config.DB.Exec(`INSERT IGNORE INTO document_signing_keys (key_id, public_key) VALUES (?, ?)`,
+		keyID, base64.StdEncoding.EncodeToString(pub))
+	return err
+}
+
+// documentPublicKey finds the key a document was signed with: the current
+// one, or an earlier one kept by recordSigningKey.
+func documentPublicKey(keyID string) (ed25519.PublicKey, error) {
+	if pub, current, err := utils.SigningPublicKey(); err == nil && current == keyID {
+		return pub, nil
+	}
+	var encoded string
+	err := config.DB.QueryRow(`SELECT public_key FROM document_signing_keys WHERE key_id = ?`, keyID).Scan(&encoded)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(pub) != ed25519.PublicKeySize || utils.SigningKeyID(pub) != keyID {
+		return nil, fmt.Errorf("signing key %s is corrupt", keyID)
+	}
+	return ed25519.PublicKey(pub), nil
+}
+
+// signatureValid re-checks the stored signature against the key that made
+// it, so a tampered database row shows up on the verification page.
+func (d *issuedDocument) signatureValid() bool {
+	pub, err := documentPublicKey(d.KeyID)
+	if err != nil {
+		return false
+	}
+	digest, err := hex.DecodeString(d.SHA256)
+	if err != nil {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(d.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, digest, sig)
+}
+
+func (d *issuedDocument) toJSON() gin.H {
+	out := gin.H{
+		"serial":          d.Serial,
+		"status":          d.status(),
+		"document_type":   d.DocumentType,
+		"student_name":    d.StudentName,
+		"student_number":  d.StudentNumber,
+		"course":          d.Course,
+		"issued_at":       d.IssuedAt.Format("2006-01-02 15:04:05"),
+		"issuer":          "Office of the University Registrar, The University of Manila",
+		"sha256":          d.SHA256,
+		"signature":       d.Signature,
+		"signature_alg":   "Ed25519 over the SHA-256 digest of the PDF",
+		"key_id":          d.KeyID,
+		"signature_valid": d.signatureValid(),
+	}
+	if d.RevokedAt != nil {
+		out["revoked_at"] = d.RevokedAt.Format("2006-01-02 15:04:05")
+		out["revoke_reason"] = d.RevokeReason
+	}
+	return out
+}
+
+// GET /verify/:serial  (public)
+// Browsers (e.g. a phone scanning the QR code) get a simple page, API
+// clients get JSON.
+func VerifyDocument(c *gin.Context) {
+	d, err := loadIssuedDocument(c.Param("serial"))
+	if err == sql.ErrNoRows {
+		if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+			c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte(verificationPage(nil)))
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "no document was issued with this serial", "status": "not_found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up document"})
+		return
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(verificationPage(d)))
+		return
+	}
+	c.JSON(http.StatusOK, d.toJSON())
+}
+
+// POST /verify/:serial  (public, multipart "file")
+// Checks an uploaded PDF against the issued document's hash.
+func VerifyDocumentFile(c *gin.Context) {
+	d, err := loadIssuedDocument(c.Param("serial"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no document was issued with this serial", "status": "not_found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up document"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "attach the PDF as 'file'"})
+		return
+	}
+	if file.Size > 20<<20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is too large"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	uploaded := hex.EncodeToString(h.Sum(nil))
+
+	out := d.toJSON()
+	out["uploaded_sha256"] = uploaded
+	out["matches"] = uploaded == d.SHA256
+	out["authentic"] = uploaded == d.SHA256 && d.status() == "valid" && d.signatureValid()
+	c.JSON(http.StatusOK, out)
+}
+
+// GET /verify/public-key[?key_id=...]  (public)
+// For offline checks: verify the signature from the verification page over
+// sha256(pdf) with this key. key_id picks the key of an older document.
+func GetDocumentSigningKey(c *gin.Context) {
+	var pub ed25519.PublicKey
+	var err error
+	keyID := c.Query("key_id")
+	if keyID != "" {
+		pub, err = documentPublicKey(keyID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown key_id"})
+			return
+		}
+	} else {
+		pub, keyID, err = utils.SigningPublicKey()
+	}
+	if err != nil {
+		fmt.Println("❌ Signing key error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "signing key unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "Ed25519",
+		"key_id":     keyID,
+		"public_key": base64.StdEncoding.EncodeToString(pub),
+		"signs":      "SHA-256 digest of the PDF file",
+	})
+}
+
+// POST /records/issued-documents/:serial/revoke  { "reason": "..." }
+func RecordsRevokeIssuedDocument(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		UPDATE issued_documents
+		SET revoked_at = NOW(), revoke_reason = ?, revoked_by = ?
+		WHERE serial = ? AND revoked_at IS NULL
+	`, req.Reason, c.GetInt("user_id"), strings.ToUpper(c.Param("serial")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke document"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found or already revoked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document revoked", "serial": strings.ToUpper(c.Param("serial"))})
+}
+
+func verificationPage(d *issuedDocument) string {
+	var body string
+	if d == nil {
+		body = `<h2 style="color:#b91c1c;">Not found</h2>
+			<p>No document was issued with this serial number. The document may be forged.</p>`
+	} else {
+		status, color := "VALID", "#1b4332"
+		if d.status() == "revoked" {
+			status, color = "REVOKED", "#b91c1c"
+		} else if !d.signatureValid() {
+			status, color = "SIGNATURE INVALID", "#b91c1c"
+		}
+		row := func(label, value string) string {
+			return fmt.Sprintf(`<tr><td style="padding:4px 12px 4px 0;color:#555;">%s</td><td style="padding:4px 0;"><b>%s</b></td></tr>`,
+				label, html.EscapeString(value))
+		}
+		rows := row("Serial No.", d.Serial) +
+			row("Document", d.DocumentType) +
+			row("Issued to", d.StudentName+" ("+d.StudentNumber+")") +
+			row("Program", d.Course) +
+			row("Issued on", d.IssuedAt.Format("January 2, 2006 3:04 PM"))
+		if d.RevokedAt != nil {
+			rows += row("Revoked on", d.RevokedAt.Format("January 2, 2006")) + row("Reason", d.RevokeReason)
+		}
+		body = fmt.Sprintf(`<h2 style="color:%s;">%s</h2>
+			<table>%s</table>
+			<p style="font-size:12px;color:#555;word-break:break-all;">SHA-256: %s<br>Signature (Ed25519, key %s): %s</p>
+			<p style="font-size:12px;color:#555;">Compare the SHA-256 above with the hash of the PDF you received, or upload the file to this address (POST) to check it.</p>`,
+			color, status, rows, d.SHA256, d.KeyID, d.Signature)
+	}
+
+	return `<!DOCTYPE html><html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width,initial-scale=1">
+<title>Document Verification</title></head>
+<body style="font-family:Arial,sans-serif;margin:0;background:#f5f5f5;">
+<div style="max-width:600px;margin:0 auto;background:#fff;">
+<div style="background:#1b4332;color:#fff;padding:16px 24px;"><b>The University of Manila</b><br>Document Verification</div>
+<div style="padding:16px 24px;">` + body + `</div></div></body></html>`
+}
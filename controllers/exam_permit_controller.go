@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== EXAM PERMITS =====================
+//
+// A student may take the exams of a term once every installment due up to and
+// including that term is fully paid (penalties included). The cashier can
+// grant a promissory override for a single term.
+
+type examPermit struct {
+	Term       string
+	DueDate    string
+	Status     string
+	BalanceDue float64
+	Reason     string
+}
+
+func (p examPermit) toJSON() gin.H {
+	item := gin.H{
+		"term":        p.Term,
+		"due_date":    nil,
+		"status":      p.Status,
+		"cleared":     p.Status != "hold",
+		"balance_due": p.BalanceDue,
+		"reason":      p.Reason,
+	}
+	if p.DueDate != "" {
+		item["due_date"] = p.DueDate
+	}
+	return item
+}
+
+// latestStudentPayment returns the newest billing record of a student.
+func latestStudentPayment(studentDBID int) (int, string, error) {
+	var paymentID int
+	var status string
+	err := config.DB.QueryRow(`
+		SELECT id, IFNULL(status, 'unpaid')
+		FROM student_payments
+		WHERE student_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, studentDBID).Scan(&paymentID, &status)
+	return paymentID, status, err
+}
+
+// computeExamPermits returns the permit status of every installment term of
+// a payment, in schedule order.
+func computeExamPermits(paymentID int, paymentStatus string) ([]examPermit, error) {
+	overrides := map[string]string{}
+	oRows, err := config.DB.Query(`
+		SELECT term, IFNULL(reason, '')
+		FROM exam_permit_overrides
+		WHERE payment_id = ? AND (valid_until IS NULL OR valid_until >= CURDATE())
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	for oRows.Next() {
+		var term, reason string
+		oRows.Scan(&term, &reason)
+		overrides[term] = reason
+	}
+	oRows.Close()
+
+	rows, err := config.DB.Query(`
+		SELECT term,
+		       amount + IFNULL(penalty_amount, 0) - IFNULL(amount_paid, 0),
+		       IFNULL(DATE_FORMAT(due_date, '%Y-%m-%d'), '')
+		FROM student_installments
+		WHERE payment_id = ?
+		ORDER BY sequence_no, id
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var permits []examPermit
+	var cumulative float64
+	for rows.Next() {
+		var p examPermit
+		var balance float64
+		if err := rows.Scan(&p.Term, &balance, &p.DueDate); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if balance > 0 {
+			cumulative += balance
+		}
+		p.BalanceDue = roundCents(cumulative)
+
+		switch {
+		case p.BalanceDue <= 0 || paymentStatus == "paid":
+			p.Status = "cleared"
+			p.BalanceDue = 0
+		case overrides[p.Term] != "":
+			p.Status = "promissory"
+			p.Reason = overrides[p.Term]
+		default:
+			p.Status = "hold"
+			p.Reason = "unpaid installment balance"
+		}
+
+		permits = append(permits, p)
+	}
+	rows.Close()
+
+	if len(permits) > 0 {
+		return permits, nil
+	}
+
+	// No schedule yet: either fully paid upfront or downpayment not approved
+	plan, err := resolvePaymentPlan(config.DB, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range plan.Items {
+		p := examPermit{Term: item.Term, Status: "cleared"}
+		if paymentStatus != "paid" {
+			if overrides[item.Term] != "" {
+				p.Status = "promissory"
+				p.Reason = overrides[item.Term]
+			} else {
+				p.Status = "hold"
+				p.Reason = "downpayment not yet approved"
+			}
+		}
+		permits = append(permits, p)
+	}
+
+	return permits, nil
+}
+
+// GET /student/exam-permits
+func StudentGetExamPermits(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	var studentDBID int
+	err := config.DB.QueryRow(`SELECT id FROM students WHERE student_id = ?`, studentStrID).Scan(&studentDBID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+		return
+	}
+
+	paymentID, paymentStatus, err := latestStudentPayment(studentDBID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"permits": []gin.H{}, "message": "no billing record yet"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch payment"})
+		return
+	}
+
+	permits, err := computeExamPermits(paymentID, paymentStatus)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute exam permits"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, p := range permits {
+		result = append(result, p.toJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payment_id": paymentID,
+		"permits":    result,
+	})
+}
+
+// ===== TEACHER: EXAM PERMITS =====
+// GET /teacher/exam-permits?class_id=&term=
+func TeacherGetExamPermits(c *gin.Context) {
+	role := c.GetString("role")
+	teacherID := c.GetInt("user_id")
+	classID := c.Query("class_id")
+	term := strings.ToLower(strings.TrimSpace(c.Query("term")))
+
+	if role != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if classID == "" || term == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "class_id and term are required"})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT DISTINCT st.id, st.student_id, st.first_name, st.last_name
+		FROM teacher_subjects ts
+		INNER JOIN student_academic sa
+			ON FIND_IN_SET(ts.subject_id, sa.subjects) > 0
+		INNER JOIN students st
+			ON st.id = sa.student_id
+		WHERE ts.teacher_id = ? AND ts.id = ?
+		AND st.status = 'approved'
+		ORDER BY st.last_name, st.first_name
+	`, teacherID, classID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type classStudent struct {
+		id                     int
+		studentNo, first, last string
+	}
+
+	var students []classStudent
+	for rows.Next() {
+		var s classStudent
+		if err := rows.Scan(&s.id, &s.studentNo, &s.first, &s.last); err != nil {
+			continue
+		}
+		students = append(students, s)
+	}
+	rows.Close()
+
+	result := []gin.H{}
+	onHold := 0
+	for _, s := range students {
+		// Teachers only see the status, never the amounts
+		item := gin.H{
+			"student_id":     s.id,
+			"student_number": s.studentNo,
+			"full_name":      s.first + " " + s.last,
+			"term":           term,
+			"status":         "hold",
+			"cleared":        false,
+		}
+
+		paymentID, paymentStatus, err := latestStudentPayment(s.id)
+		if err == nil {
+			permits, err := computeExamPermits(paymentID, paymentStatus)
+			if err == nil {
+				for _, p := range permits {
+					if p.Term == term {
+						item["status"] = p.Status
+						item["cleared"] = p.Status != "hold"
+					}
+				}
+			}
+		}
+
+		if item["status"] == "hold" {
+			onHold++
+		}
+		result = append(result, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"students": result,
+		"total":    len(result),
+		"on_hold":  onHold,
+	})
+}
+
+// ===================== CASHIER EXAM PERMIT OVERRIDES =====================
+
+// POST /cashier/exam-permits/override
+// Grants a promissory permit for one term of a payment.
+func CashierOverrideExamPermit(c *gin.Context) {
+	var req struct {
+		PaymentID  int    `json:"payment_id" binding:"required"`
+		Term       string `json:"term" binding:"required"`
+		Reason     string `json:"reason" binding:"required"`
+		ValidUntil string `json:"valid_until"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment_id, term and reason are required"})
+		return
+	}
+
+	var exists bool
+	config.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM student_payments WHERE id = ?)`, req.PaymentID).Scan(&exists)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+		return
+	}
+
+	var validUntil interface{}
+	if req.ValidUntil != "" {
+		validUntil = req.ValidUntil
+	}
+
+	_, err := config.DB.Exec(`
+		INSERT INTO exam_permit_overrides (payment_id, term, reason, valid_until, granted_by)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE reason = VALUES(reason), valid_until = VALUES(valid_until),
+			granted_by = VALUES(granted_by), created_at = NOW()
+	`, req.PaymentID, strings.ToLower(strings.TrimSpace(req.Term)), req.Reason, validUntil, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to save override, check valid_until (YYYY-MM-DD)"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exam permit override granted"})
+}
+
+// DELETE /cashier/exam-permits/override?payment_id=&term=
+func CashierRevokeExamPermitOverride(c *gin.Context) {
+	paymentID, err := strconv.Atoi(c.Query("payment_id"))
+	term := strings.ToLower(strings.TrimSpace(c.Query("term")))
+	if err != nil || term == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment_id and term are required"})
+		return
+	}
+
+	res, err := config.DB.Exec(`DELETE FROM exam_permit_overrides WHERE payment_id = ? AND term = ?`, paymentID, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke override"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "override not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exam permit override revoked"})
+}
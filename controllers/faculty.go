@@ -68,11 +68,14 @@ func FacultyGetCourses(c *gin.Context) {
 // ===== SUBJECTS =====
 func FacultyCreateSubject(c *gin.Context) {
 	var req struct {
-		SubjectName string `json:"subject_name" binding:"required"`
-		Code        string `json:"code" binding:"required"`
-		CourseID    int    `json:"course_id" binding:"required"`
-		YearLevel   int    `json:"year_level" binding:"required"`
-		Semester    string `json:"semester" binding:"required"`
+		SubjectName string   `json:"subject_name" binding:"required"`
+		Code        string   `json:"code" binding:"required"`
+		CourseID    int      `json:"course_id" binding:"required"`
+		YearLevel   int      `json:"year_level" binding:"required"`
+		Semester    string   `json:"semester" binding:"required"`
+		Units       *float64 `json:"units"`
+		// NSTP/PE and other non-credit subjects
+		ExcludeFromGWA bool `json:"exclude_from_gwa"`
 	}
 
 	if err := c.BindJSON(&req); err != nil {
@@ -80,9 +83,18 @@ func FacultyCreateSubject(c *gin.Context) {
 		return
 	}
 
+	units := 3.0
+	if req.Units != nil {
+		units = *req.Units
+	}
+	if units <= 0 || units > 10 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "units must be between 0 and 10"})
+		return
+	}
+
 	res, err := config.DB.Exec(
-		"INSERT INTO subjects (subject_name, code, course_id, year_level, semester) VALUES (?,?,?,?,?)",
-		req.SubjectName, req.Code, req.CourseID, req.YearLevel, req.Semester,
+		"INSERT INTO subjects (subject_name, code, course_id, year_level, semester, units, exclude_from_gwa) VALUES (?,?,?,?,?,?,?)",
+		req.SubjectName, req.Code, req.CourseID, req.YearLevel, req.Semester, units, req.ExcludeFromGWA,
 	)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -98,7 +110,7 @@ func FacultyGetSubjects(c *gin.Context) {
 	yearLevel := c.Query("year_level")
 	semester := c.Query("semester")
 
-	query := "SELECT id, subject_name, code, course_id, year_level, COALESCE(semester, '') FROM subjects"
+	query := "SELECT id, subject_name, code, course_id, year_level, COALESCE(semester, ''), IFNULL(units, 3), IFNULL(exclude_from_gwa, FALSE) FROM subjects"
 	args := []interface{}{}
 	conditions := []string{}
 
@@ -133,14 +145,18 @@ func FacultyGetSubjects(c *gin.Context) {
 	for rows.Next() {
 		var id, cID, year int
 		var name, code, sem string
-		rows.Scan(&id, &name, &code, &cID, &year, &sem)
+		var units float64
+		var excluded bool
+		rows.Scan(&id, &name, &code, &cID, &year, &sem, &units, &excluded)
 		subjects = append(subjects, map[string]interface{}{
-			"id":           id,
-			"subject_name": name,
-			"code":         code,
-			"course_id":    cID,
-			"year_level":   year,
-			"semester":     sem,
+			"id":               id,
+			"subject_name":     name,
+			"code":             code,
+			"course_id":        cID,
+			"year_level":       year,
+			"semester":         sem,
+			"units":            units,
+			"exclude_from_gwa": excluded,
 		})
 	}
 	c.JSON(http.StatusOK, subjects)
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is a scripted database/sql driver for handler tests. Each statement
+// is matched against the rules in order by a whitespace-insensitive
+// substring; unmatched queries return no rows and unmatched statements affect
+// one row. Every statement run is kept in log.
+type fakeDB struct {
+	mu     sync.Mutex
+	rules  []fakeRule
+	log    []string
+	lastID int64
+}
+
+type fakeRule struct {
+	match string
+	// query returns the rows of a SELECT
+	query func(args []driver.Value) [][]driver.Value
+	// exec applies an INSERT/UPDATE/DELETE to the test's state
+	exec func(args []driver.Value)
+}
+
+func newFakeDB(rules ...fakeRule) (*fakeDB, *sql.DB) {
+	f := &fakeDB{rules: rules, lastID: 100}
+	return f, sql.OpenDB(f)
+}
+
+func compactSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func (f *fakeDB) rule(query string) *fakeRule {
+	q := compactSQL(query)
+	f.log = append(f.log, q)
+	for i := range f.rules {
+		if strings.Contains(q, compactSQL(f.rules[i].match)) {
+			return &f.rules[i]
+		}
+	}
+	return nil
+}
+
+// ran reports whether a statement containing fragment was run.
+func (f *fakeDB) ran(fragment string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, q := range f.log {
+		if strings.Contains(q, compactSQL(fragment)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ db *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d.db}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if r := s.db.rule(s.query); r != nil && r.exec != nil {
+		r.exec(args)
+	}
+	s.db.lastID++
+	return fakeResult{s.db.lastID}, nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	rows := &fakeRows{}
+	if r := s.db.rule(s.query); r != nil && r.query != nil {
+		rows.rows = r.query(args)
+	}
+	return rows, nil
+}
+
+type fakeResult struct{ id int64 }
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
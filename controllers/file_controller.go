@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/filestore"
+	"student-portal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== UPLOADED FILES =====================
+//
+// Uploads are not served statically. GET /uploads/... needs a login and
+// checks, per folder, that the caller may read the file: students only their
+// own documents, receipts, proofs and submissions, staff by role. A caller
+// who may read a file can mint a short-lived signed link to it
+// (POST /uploads/sign) that works without logging in, under /files/....
+// Contents are read through the filestore package, so it makes no difference
+// whether they live on local disk or in an object store.
+
+const (
+	cachePrivate = "private, no-store"
+	cacheShared  = "private, max-age=3600"
+
+	defaultLinkTTL = 15 * time.Minute
+	maxLinkTTL     = 24 * time.Hour
+)
+
+// fileViewer is who is asking for a file.
+type fileViewer struct {
+	role      string
+	userID    int
+	studentID string
+}
+
+func viewerFromContext(c *gin.Context) fileViewer {
+	return fileViewer{role: c.GetString("role"), userID: c.GetInt("user_id"), studentID: c.GetString("student_id")}
+}
+
+func (v fileViewer) is(roles ...string) bool {
+	for _, r := range roles {
+		if v.role == r {
+			return true
+		}
+	}
+	return false
+}
+
+type uploadCategory struct {
+	cache   string
+	canRead func(v fileViewer, stored string) bool
+}
+
+// storedPathMatches compares a stored file path column with "uploads/x/y",
+// however it was saved ("./uploads/...", Windows separators).
+func storedPathMatches(column string) string {
+	return fmt.Sprintf(`TRIM(LEADING './' FROM REPLACE(%s, '\\', '/')) = ?`, column)
+}
+
+func existsQuery(query string, args ...interface{}) bool {
+	var ok bool
+	if err := config.DB.QueryRow(`SELECT EXISTS(`+query+`)`, args...).Scan(&ok); err != nil {
+		fmt.Println("❌ File access check error:", err)
+		return false
+	}
+	return ok
+}
+
+func anyViewer(v fileViewer, stored string) bool { return v.role != "" }
+
+var uploadCategories = map[string]uploadCategory{
+	"documents": {cachePrivate, func(v fileViewer, stored string) bool {
+		if v.is("records", "registrar", "admin") {
+			return true
+		}
+		return v.is("student") && existsQuery(`
+			SELECT 1 FROM document_requests dr
+			INNER JOIN students s ON s.id = dr.student_id
+			WHERE s.student_id = ? AND `+storedPathMatches("dr.document_file"), v.studentID, stored)
+	}},
+	"receipts": {cachePrivate, func(v fileViewer, stored string) bool {
+		if v.is("cashier", "admin") {
+			return true
+		}
+		return v.is("student") && existsQuery(`
+			SELECT 1 FROM official_receipts r
+			INNER JOIN students s ON s.id = r.student_id
+			WHERE s.student_id = ? AND `+storedPathMatches("r.file_path"), v.studentID, stored)
+	}},
+	"payment_proofs": {cachePrivate, func(v fileViewer, stored string) bool {
+		if v.is("cashier", "admin") {
+			return true
+		}
+		return v.is("student") && existsQuery(`
+			SELECT 1 FROM payment_proofs p
+			INNER JOIN students s ON s.id = p.student_id
+			WHERE s.student_id = ? AND `+storedPathMatches("p.file_path"), v.studentID, stored)
+	}},
+	"student_submissions": {cachePrivate, func(v fileViewer, stored string) bool {
+		switch {
+		case v.is("admin"):
+			return true
+		case v.is("student"):
+			return existsQuery(`
+				SELECT 1 FROM student_submissions ss
+				INNER JOIN students s ON s.id = ss.student_id
+				WHERE s.student_id = ? AND `+storedPathMatches("ss.file_path"), v.studentID, stored)
+		case v.is("teacher", "faculty"):
+			return existsQuery(`
+				SELECT 1 FROM student_submissions ss
+				INNER JOIN lesson_materials lm ON lm.id = ss.material_id
+				WHERE lm.teacher_id = ? AND `+storedPathMatches("ss.file_path"), v.userID, stored)
+		}
+		return false
+	}},
+	"lessons": {cacheShared, func(v fileViewer, stored string) bool {
+		if v.is("teacher", "faculty", "admin") {
+			return true
+		}
+		return v.is("student") && existsQuery(`
+			SELECT 1 FROM lesson_materials lm
+			INNER JOIN student_academic sa ON FIND_IN_SET(lm.subject_id, sa.subjects) > 0
+			INNER JOIN students s ON s.id = sa.student_id
+			WHERE s.student_id = ? AND (`+storedPathMatches("lm.file_path")+` OR `+storedPathMatches("lm.thumbnail_path")+`)`,
+			v.studentID, stored, stored)
+	}},
+	"profile_pictures":        {cacheShared, anyViewer},
+	"announcements":           {cacheShared, anyViewer},
+	"records_announcements":   {cacheShared, anyViewer},
+	"registrar-announcements": {cacheShared, anyViewer},
+}
+
+// resolveUpload turns a request path into its category and the
+// "uploads/..." form stored in the database.
+func resolveUpload(raw string) (category uploadCategory, stored string, ok bool) {
+	clean := strings.TrimPrefix(path.Clean("/"+raw), "/")
+	clean = strings.TrimPrefix(clean, "uploads/")
+	parts := strings.SplitN(clean, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return uploadCategory{}, "", false
+	}
+	category, ok = uploadCategories[parts[0]]
+	if !ok {
+		return uploadCategory{}, "", false
+	}
+	stored = "uploads/" + clean
+	if _, err := filestore.Stat(stored); err != nil {
+		if !errors.Is(err, filestore.ErrNotExist) {
+			fmt.Println("❌ File lookup error:", err)
+		}
+		return uploadCategory{}, "", false
+	}
+	return category, stored, true
+}
+
+// inlineTypes are shown in the browser; anything else is downloaded so an
+// uploaded HTML or SVG file never runs in the portal's origin.
+var inlineTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+	"video/mp2t":      true,
+
+	"application/vnd.apple.mpegurl": true,
+}
+
+// sendStoredFile streams a stored file, answering Range requests. name is
+// the download file name; attachment forces a download even for types the
+// browser could show.
+func sendStoredFile(c *gin.Context, stored, name, cache string, attachment bool) {
+	obj, err := filestore.Open(stored)
+	if err != nil {
+		if !errors.Is(err, filestore.ErrNotExist) {
+			fmt.Println("❌ File open error:", err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	defer obj.Close()
+
+	info := obj.Info()
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(strings.ToLower(path.Ext(stored)))
+	}
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	disposition := "attachment"
+	if inlineTypes[contentType] && !attachment {
+		disposition = "inline"
+	}
+
+	c.Header("Content-Type", contentType)
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": name}); header != "" {
+		c.Header("Content-Disposition", header)
+	} else {
+		c.Header("Content-Disposition", disposition)
+	}
+	c.Header("Cache-Control", cache)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime, obj)
+}
+
+func serveUploadFile(c *gin.Context, stored, cache string) {
+	sendStoredFile(c, stored, path.Base(stored), cache, c.Query("download") == "1")
+}
+
+// GET /uploads/*filepath
+func ServeUpload(c *gin.Context) {
+	category, stored, ok := resolveUpload(c.Param("filepath"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	if !category.canRead(viewerFromContext(c), stored) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot access this file"})
+		return
+	}
+	serveUploadFile(c, stored, category.cache)
+}
+
+// POST /uploads/sign
+// Body: {"path": "uploads/documents/x.pdf", "expires_in": 30} (minutes,
+// default 15, max 1440). The caller must be able to read the file.
+func SignUploadURL(c *gin.Context) {
+	var req struct {
+		Path      string `json:"path" binding:"required"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	category, stored, ok := resolveUpload(req.Path)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	if !category.canRead(viewerFromContext(c), stored) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot access this file"})
+		return
+	}
+
+	ttl := defaultLinkTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Minute
+	}
+	if ttl > maxLinkTTL {
+		ttl = maxLinkTTL
+	}
+	expires := time.Now().Add(ttl)
+
+	sig, err := utils.SignFilePath(stored, expires)
+	if err != nil {
+		fmt.Println("❌ File URL signing error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign link"})
+		return
+	}
+
+	link := fmt.Sprintf("/files/%s?expires=%d&sig=%s", stored, expires.Unix(), sig)
+	c.JSON(http.StatusOK, gin.H{
+		"url":        link,
+		"expires_at": expires,
+	})
+}
+
+// GET /files/*filepath?expires=...&sig=...
+// Serves a signed link without a login.
+func ServeSignedUpload(c *gin.Context) {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid link"})
+		return
+	}
+
+	category, stored, ok := resolveUpload(c.Param("filepath"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	if !utils.VerifyFilePath(stored, expires, c.Query("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "link is invalid or has expired"})
+		return
+	}
+
+	// Cached copies must not outlive the link
+	cache := category.cache
+	if cache != cachePrivate {
+		remaining := expires - time.Now().Unix()
+		cache = fmt.Sprintf("private, max-age=%d", remaining)
+	}
+	serveUploadFile(c, stored, cache)
+}
@@ -0,0 +1,458 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===== GRADE SHEET IMPORT / EXPORT =====
+// Teachers download the class list from GET /teacher/grades?format=csv|xlsx,
+// fill in the grades offline and upload the sheet back. The import validates
+// every row first; nothing is written unless the whole sheet is clean.
+
+var gradeSheetHeader = []string{"student_id", "student_number", "last_name", "first_name", "prelim", "midterm", "finals", "remarks"}
+
+const maxGradeSheetSize = 5 * 1024 * 1024
+
+func formatSheetGrade(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 2, 64)
+}
+
+func gradeSheetRow(id int, studentNo, last, first string, prelim, midterm, finals *float64, remarks string) []string {
+	return []string{
+		strconv.Itoa(id), studentNo, last, first,
+		formatSheetGrade(prelim), formatSheetGrade(midterm), formatSheetGrade(finals), remarks,
+	}
+}
+
+func writeGradeSheet(c *gin.Context, format, filename string, rows [][]string) {
+	if format == "xlsx" {
+		data, err := utils.WriteXLSX("Grades", rows)
+		if err != nil {
+			fmt.Println("❌ Grade sheet export error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build grade sheet"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.xlsx", filename))
+		c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", data)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", filename))
+	w := csv.NewWriter(c.Writer)
+	w.WriteAll(rows)
+}
+
+// readGradeSheet accepts .csv and .xlsx uploads.
+func readGradeSheet(filename string, data []byte) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx":
+		return utils.ReadXLSX(data)
+	case ".csv":
+		r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		return r.ReadAll()
+	default:
+		return nil, fmt.Errorf("unsupported file type; upload a .csv or .xlsx grade sheet")
+	}
+}
+
+type gradeSheetChange struct {
+	Row           int      `json:"row"`
+	StudentID     int      `json:"student_id"`
+	StudentNumber string   `json:"student_number"`
+	Name          string   `json:"name"`
+	Action        string   `json:"action"` // insert, update, unchanged
+	Prelim        *float64 `json:"prelim"`
+	Midterm       *float64 `json:"midterm"`
+	Finals        *float64 `json:"finals"`
+	Remarks       string   `json:"remarks"`
+	Previous      gin.H    `json:"previous,omitempty"`
+
+	gradeID int
+}
+
+type gradeSheetError struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+func sameGrade(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return math.Abs(*a-*b) < 0.001
+}
+
+// ===== IMPORT GRADES =====
+// POST /teacher/grades/import?class_id=1&dry_run=true   (multipart "file")
+// Columns are matched by header name. Only the grade columns present in the
+// sheet are changed; a blank cell clears that grade.
+func TeacherImportGrades(c *gin.Context) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Teacher role required"})
+		return
+	}
+	teacherID := c.GetInt("user_id")
+
+	classID, err := strconv.Atoi(c.DefaultQuery("class_id", c.PostForm("class_id")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "class_id is required"})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true" || c.PostForm("dry_run") == "true"
+
+	subjectID, err := teacherClassSubject(classID, teacherID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Class not found or not assigned to you"})
+		return
+	}
+
+	var hasScheme bool
+	config.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM grading_schemes WHERE class_id = ?)`, classID).Scan(&hasScheme)
+	if hasScheme {
+		c.JSON(http.StatusConflict, gin.H{"error": "This class uses a grading scheme; enter raw scores and grades are computed automatically"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if file.Size > maxGradeSheetSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Grade sheet too large. Max size: 5MB"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	sheet, err := readGradeSheet(file.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(sheet) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Grade sheet has no data rows"})
+		return
+	}
+
+	columns := map[string]int{}
+	for i, h := range sheet[0] {
+		key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(h)), " ", "_")
+		if _, dup := columns[key]; !dup && key != "" {
+			columns[key] = i
+		}
+	}
+	_, hasID := columns["student_id"]
+	_, hasNumber := columns["student_number"]
+	if !hasID && !hasNumber {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Grade sheet needs a student_id or student_number column"})
+		return
+	}
+	gradeCols := []string{}
+	for _, col := range []string{"prelim", "midterm", "finals", "remarks"} {
+		if _, ok := columns[col]; ok {
+			gradeCols = append(gradeCols, col)
+		}
+	}
+	if len(gradeCols) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Grade sheet has no prelim, midterm, finals or remarks column"})
+		return
+	}
+
+	// Class roster (approved + enrolled) with the teacher's current grades
+	type rosterEntry struct {
+		id                      int
+		studentNo, last, first  string
+		gradeID                 int
+		prelim, midterm, finals *float64
+		remarks, status         string
+	}
+	byID := map[int]*rosterEntry{}
+	byNumber := map[string]*rosterEntry{}
+
+	rows, err := config.DB.Query(`
+		SELECT DISTINCT st.id, IFNULL(st.student_id, ''), st.last_name, st.first_name,
+		       IFNULL(g.id, 0), g.prelim, g.midterm, g.finals, IFNULL(g.remarks, ''), IFNULL(g.status, 'draft')
+		FROM student_academic sa
+		INNER JOIN students st ON st.id = sa.student_id
+		LEFT JOIN grades g ON g.student_id = st.id AND g.subject_id = ? AND g.teacher_id = ?
+		WHERE FIND_IN_SET(?, sa.subjects) > 0
+		AND st.status = 'approved'
+	`, subjectID, teacherID, subjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load class list"})
+		return
+	}
+	for rows.Next() {
+		e := &rosterEntry{}
+		if err := rows.Scan(&e.id, &e.studentNo, &e.last, &e.first, &e.gradeID,
+			&e.prelim, &e.midterm, &e.finals, &e.remarks, &e.status); err != nil {
+			continue
+		}
+		byID[e.id] = e
+		if e.studentNo != "" {
+			byNumber[strings.ToUpper(e.studentNo)] = e
+		}
+	}
+	rows.Close()
+
+	cell := func(row []string, col string) (string, bool) {
+		i, ok := columns[col]
+		if !ok {
+			return "", false
+		}
+		if i >= len(row) {
+			return "", true
+		}
+		return strings.TrimSpace(row[i]), true
+	}
+
+	var changes []gradeSheetChange
+	var rowErrors []gradeSheetError
+	seen := map[int]int{}
+
+	windowErrors := map[string]error{}
+	for _, term := range gradingTerms {
+		windowErrors[term] = checkGradingWindow(term)
+	}
+
+	for i, row := range sheet[1:] {
+		rowNum := i + 2
+
+		blank := true
+		for _, v := range row {
+			if strings.TrimSpace(v) != "" {
+				blank = false
+				break
+			}
+		}
+		if blank {
+			continue
+		}
+
+		var errs []string
+		var entry *rosterEntry
+
+		idText, _ := cell(row, "student_id")
+		number, _ := cell(row, "student_number")
+
+		if idText != "" {
+			id, err := strconv.Atoi(idText)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid student_id %q", idText))
+			} else if entry = byID[id]; entry == nil {
+				errs = append(errs, fmt.Sprintf("student %d is not enrolled in this class or not yet approved", id))
+			}
+		}
+		if number != "" {
+			byNo := byNumber[strings.ToUpper(number)]
+			switch {
+			case byNo == nil:
+				errs = append(errs, fmt.Sprintf("student number %s is not enrolled in this class or not yet approved", number))
+				entry = nil
+			case entry != nil && entry != byNo:
+				errs = append(errs, fmt.Sprintf("student_id %s and student number %s belong to different students", idText, number))
+				entry = nil
+			default:
+				entry = byNo
+			}
+		}
+		if idText == "" && number == "" {
+			errs = append(errs, "student_id or student_number is required")
+		}
+
+		if entry != nil {
+			if last, ok := cell(row, "last_name"); ok && last != "" && !strings.EqualFold(last, entry.last) {
+				errs = append(errs, fmt.Sprintf("last name %q does not match the student record (%s)", last, entry.last))
+			}
+			if first, ok := cell(row, "first_name"); ok && first != "" && !strings.EqualFold(first, entry.first) {
+				errs = append(errs, fmt.Sprintf("first name %q does not match the student record (%s)", first, entry.first))
+			}
+			if prev, dup := seen[entry.id]; dup {
+				errs = append(errs, fmt.Sprintf("student already appears on row %d", prev))
+			}
+			if entry.gradeID != 0 && !gradeEditable(entry.status) {
+				errs = append(errs, gradeLockedMessage(entry.status))
+			}
+		}
+
+		change := gradeSheetChange{Row: rowNum}
+		if entry != nil {
+			change.Prelim, change.Midterm, change.Finals, change.Remarks = entry.prelim, entry.midterm, entry.finals, entry.remarks
+		}
+
+		for _, col := range []string{"prelim", "midterm", "finals"} {
+			text, ok := cell(row, col)
+			if !ok {
+				continue
+			}
+			var value *float64
+			if text != "" {
+				v, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("%s %q is not a number", col, text))
+					continue
+				}
+				if v < 1.0 || v > 5.0 {
+					errs = append(errs, fmt.Sprintf("%s grade must be between 1.0 and 5.0 (Filipino GWA scale)", col))
+					continue
+				}
+				value = &v
+			}
+			switch col {
+			case "prelim":
+				change.Prelim = value
+			case "midterm":
+				change.Midterm = value
+			case "finals":
+				change.Finals = value
+			}
+		}
+		if remarks, ok := cell(row, "remarks"); ok {
+			change.Remarks = remarks
+		}
+
+		if entry != nil {
+			values := map[string][2]*float64{
+				"prelim":  {change.Prelim, entry.prelim},
+				"midterm": {change.Midterm, entry.midterm},
+				"finals":  {change.Finals, entry.finals},
+			}
+			for _, term := range gradingTerms {
+				if sameGrade(values[term][0], values[term][1]) {
+					continue
+				}
+				if err := windowErrors[term]; err != nil {
+					errs = append(errs, "grading window closed: "+err.Error())
+				}
+			}
+		}
+
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, gradeSheetError{Row: rowNum, Errors: errs})
+			continue
+		}
+
+		seen[entry.id] = rowNum
+		change.StudentID = entry.id
+		change.StudentNumber = entry.studentNo
+		change.Name = entry.first + " " + entry.last
+		change.gradeID = entry.gradeID
+
+		switch {
+		case entry.gradeID == 0:
+			change.Action = "insert"
+		case sameGrade(change.Prelim, entry.prelim) && sameGrade(change.Midterm, entry.midterm) &&
+			sameGrade(change.Finals, entry.finals) && change.Remarks == entry.remarks:
+			change.Action = "unchanged"
+		default:
+			change.Action = "update"
+			change.Previous = gin.H{
+				"prelim":  entry.prelim,
+				"midterm": entry.midterm,
+				"finals":  entry.finals,
+				"remarks": entry.remarks,
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	summary := gin.H{"insert": 0, "update": 0, "unchanged": 0, "errors": len(rowErrors)}
+	for _, ch := range changes {
+		summary[ch.Action] = summary[ch.Action].(int) + 1
+	}
+	if changes == nil {
+		changes = []gradeSheetChange{}
+	}
+
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Grade sheet has errors; no grades were saved",
+			"errors":  rowErrors,
+			"summary": summary,
+			"dry_run": dryRun,
+		})
+		return
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Dry run: no grades were saved",
+			"dry_run": true,
+			"changes": changes,
+			"summary": summary,
+		})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import grades"})
+		return
+	}
+	defer tx.Rollback()
+
+	schoolYear, semester := gradeTermArgs()
+	for _, ch := range changes {
+		switch ch.Action {
+		case "insert":
+			_, err = tx.Exec(`
+				INSERT INTO grades (student_id, subject_id, teacher_id, prelim, midterm, finals, remarks, school_year, semester, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+			`, ch.StudentID, subjectID, teacherID, ch.Prelim, ch.Midterm, ch.Finals, ch.Remarks, schoolYear, semester)
+		case "update":
+			_, err = tx.Exec(`
+				UPDATE grades
+				SET prelim = ?, midterm = ?, finals = ?, remarks = ?, updated_at = NOW()
+				WHERE id = ?
+			`, ch.Prelim, ch.Midterm, ch.Finals, ch.Remarks, ch.gradeID)
+		}
+		if err != nil {
+			fmt.Printf("❌ Grade import failed on row %d: %v\n", ch.Row, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to save row %d; no grades were saved", ch.Row)})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import grades"})
+		return
+	}
+
+	fmt.Printf("✅ Grade sheet imported for class %d: %v\n", classID, summary)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Grades imported successfully",
+		"dry_run": false,
+		"changes": changes,
+		"summary": summary,
+	})
+}
@@ -0,0 +1,607 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== BATCH GRADE RELEASE =====================
+// Records officers release (or hold) every verified grade of a class section,
+// subject, course or the whole term at once, either right away or at a
+// scheduled date/time picked up by StartGradeReleaseJob. Students get an
+// email when grades become visible to them.
+
+type gradeBatchScope struct {
+	Scope     string `json:"scope"` // class, subject, course, term
+	ClassID   int    `json:"class_id"`
+	SubjectID int    `json:"subject_id"`
+	CourseID  int    `json:"course_id"`
+
+	// The term a "term" batch covers, fixed when the batch is created
+	SchoolYear string `json:"-"`
+	Semester   string `json:"-"`
+}
+
+// staleGradeBatch is how long a batch may stay 'running' before it is taken
+// to have died with the server and is run again.
+const staleGradeBatch = 15 * time.Minute
+
+// pinTerm fixes a "term" batch to the active term, so a batch scheduled
+// before a term rollover still releases the term it was made for.
+func (s *gradeBatchScope) pinTerm() error {
+	if s.Scope != "term" || s.SchoolYear != "" {
+		return nil
+	}
+	year, semester, ok := activeSchoolTerm()
+	if !ok {
+		return fmt.Errorf("no active school year")
+	}
+	s.SchoolYear, s.Semester = year, semester
+	return nil
+}
+
+// filter returns the WHERE fragment (on alias g) selecting the scope's grades.
+// "term" means every grade of the batch's term (the active one if unpinned).
+func (s gradeBatchScope) filter() (string, []interface{}, error) {
+	switch s.Scope {
+	case "class":
+		var teacherID, subjectID int
+		err := config.DB.QueryRow(`SELECT teacher_id, subject_id FROM teacher_subjects WHERE id = ?`, s.ClassID).
+			Scan(&teacherID, &subjectID)
+		if err != nil {
+			return "", nil, fmt.Errorf("class not found")
+		}
+		return "g.teacher_id = ? AND g.subject_id = ?", []interface{}{teacherID, subjectID}, nil
+	case "subject":
+		if s.SubjectID == 0 {
+			return "", nil, fmt.Errorf("subject_id is required")
+		}
+		return "g.subject_id = ?", []interface{}{s.SubjectID}, nil
+	case "course":
+		if s.CourseID == 0 {
+			return "", nil, fmt.Errorf("course_id is required")
+		}
+		return "g.student_id IN (SELECT student_id FROM student_academic WHERE course = ?)", []interface{}{s.CourseID}, nil
+	case "term":
+		// The term a grade belongs to, not when it was entered: a late
+		// correction to last term's grade stays out of this term's batch
+		if err := s.pinTerm(); err != nil {
+			return "", nil, err
+		}
+		return "g.school_year = ? AND g.semester = ?", []interface{}{s.SchoolYear, s.Semester}, nil
+	default:
+		return "", nil, fmt.Errorf("scope must be class, subject, course or term")
+	}
+}
+
+func (s gradeBatchScope) nullableIDs() (interface{}, interface{}, interface{}) {
+	nullable := func(id int) interface{} {
+		if id == 0 {
+			return nil
+		}
+		return id
+	}
+	return nullable(s.ClassID), nullable(s.SubjectID), nullable(s.CourseID)
+}
+
+// nullableTerm is the pinned term of a "term" batch, NULL for other scopes.
+func (s gradeBatchScope) nullableTerm() (interface{}, interface{}) {
+	if s.SchoolYear == "" {
+		return nil, nil
+	}
+	return s.SchoolYear, s.Semester
+}
+
+// notifyGradesReleased emails each student the subjects that just became
+// visible. Runs in the background; failures are only logged.
+func notifyGradesReleased(gradeIDs []int) {
+	if len(gradeIDs) == 0 {
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(gradeIDs)), ",")
+	args := make([]interface{}, len(gradeIDs))
+	for i, id := range gradeIDs {
+		args[i] = id
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT st.id, IFNULL(st.email, ''), st.first_name, s.subject_name, s.code
+		FROM grades g
+		INNER JOIN students st ON st.id = g.student_id
+		INNER JOIN subjects s ON s.id = g.subject_id
+		WHERE g.id IN (`+placeholders+`)
+		ORDER BY st.id, s.subject_name
+	`, args...)
+	if err != nil {
+		fmt.Println("⚠️ Warning: could not load released grades for notification:", err)
+		return
+	}
+
+	type notice struct {
+		email, firstName string
+		subjects         []string
+	}
+	var order []int
+	notices := map[int]*notice{}
+	for rows.Next() {
+		var studentID int
+		var email, firstName, subject, code string
+		if err := rows.Scan(&studentID, &email, &firstName, &subject, &code); err != nil {
+			continue
+		}
+		n, ok := notices[studentID]
+		if !ok {
+			n = &notice{email: email, firstName: firstName}
+			notices[studentID] = n
+			order = append(order, studentID)
+		}
+		n.subjects = append(n.subjects, fmt.Sprintf("%s (%s)", subject, code))
+	}
+	rows.Close()
+
+	go func() {
+		for _, id := range order {
+			n := notices[id]
+			if n.email == "" {
+				continue
+			}
+
+			var items strings.Builder
+			for _, s := range n.subjects {
+				items.WriteString("<li>" + html.EscapeString(s) + "</li>")
+			}
+
+			body := fmt.Sprintf(`
+<div style="font-family:'Segoe UI',Arial,sans-serif;color:#333;max-width:600px;">
+	<div style="background:#1b4332;color:#ffffff;padding:20px 24px;border-radius:8px 8px 0 0;">
+		<h2 style="margin:0;">Grades Released</h2>
+	</div>
+	<div style="padding:20px 24px;border:1px solid #e5e7eb;border-top:none;border-radius:0 0 8px 8px;">
+		<p>Hi %s,</p>
+		<p>Your grades for the following subjects are now available in the Student Portal:</p>
+		<ul>%s</ul>
+		<p style="font-size:12px;color:#6b7280;">Log in to the portal to view your grades.
+		For concerns about a grade, please coordinate with your instructor.</p>
+	</div>
+</div>`, html.EscapeString(n.firstName), items.String())
+
+			if err := utils.SendEmail(n.email, "Your grades are now available - University of Manila", body); err != nil {
+				fmt.Println("⚠️ Warning: could not send grade release email to", n.email, ":", err)
+			}
+		}
+	}()
+}
+
+// executeGradeBatch releases or holds the scope's grades. Only verified grades
+// are released and only released ones are put on hold.
+func executeGradeBatch(scope gradeBatchScope, action string) (int, error) {
+	where, args, err := scope.filter()
+	if err != nil {
+		return 0, err
+	}
+
+	from := gradeStatusVerified
+	if action == "hold" {
+		from = gradeStatusReleased
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT g.id FROM grades g WHERE `+where+` AND g.status = ? FOR UPDATE`,
+		append(args, from)...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if action == "release" {
+			_, err = tx.Exec(`
+				UPDATE grades SET is_released = TRUE, released_at = NOW(), status = 'released' WHERE id = ?
+			`, id)
+		} else {
+			_, err = tx.Exec(`
+				UPDATE grades SET is_released = FALSE, released_at = NULL, status = 'verified' WHERE id = ?
+			`, id)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if action == "release" {
+		notifyGradesReleased(ids)
+	}
+
+	return len(ids), nil
+}
+
+// RunDueGradeReleases executes scheduled batches whose release time has come.
+func RunDueGradeReleases() (int, error) {
+	// A run that never finished died with the server; schedule it again.
+	// Running a batch twice is harmless: it only moves grades still in the
+	// status the action starts from
+	if _, err := config.DB.Exec(`
+		UPDATE grade_release_batches SET status = 'scheduled'
+		WHERE status = 'running' AND started_at < ?
+	`, time.Now().Add(-staleGradeBatch)); err != nil {
+		return 0, err
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT id, scope, IFNULL(class_id, 0), IFNULL(subject_id, 0), IFNULL(course_id, 0),
+		       IFNULL(school_year, ''), IFNULL(semester, ''), action
+		FROM grade_release_batches
+		WHERE status = 'scheduled' AND release_at <= NOW()
+		ORDER BY release_at, id
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	type batch struct {
+		id     int
+		scope  gradeBatchScope
+		action string
+	}
+	var due []batch
+	for rows.Next() {
+		var b batch
+		if err := rows.Scan(&b.id, &b.scope.Scope, &b.scope.ClassID, &b.scope.SubjectID, &b.scope.CourseID,
+			&b.scope.SchoolYear, &b.scope.Semester, &b.action); err == nil {
+			due = append(due, b)
+		}
+	}
+	rows.Close()
+
+	total := 0
+	for _, b := range due {
+		// Claim the batch first so two instances never run it twice
+		res, err := config.DB.Exec(`
+			UPDATE grade_release_batches SET status = 'running', started_at = ? WHERE id = ? AND status = 'scheduled'
+		`, time.Now(), b.id)
+		if err != nil {
+			return total, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+
+		n, runErr := executeGradeBatch(b.scope, b.action)
+		if runErr != nil {
+			config.DB.Exec(`
+				UPDATE grade_release_batches SET status = 'failed', error_message = ?, executed_at = NOW() WHERE id = ?
+			`, runErr.Error(), b.id)
+			fmt.Printf("❌ Scheduled grade release #%d failed: %v\n", b.id, runErr)
+			continue
+		}
+
+		config.DB.Exec(`
+			UPDATE grade_release_batches SET status = 'done', affected = ?, executed_at = NOW() WHERE id = ?
+		`, n, b.id)
+		total += n
+	}
+
+	return total, nil
+}
+
+// StartGradeReleaseJob runs scheduled grade releases in the background.
+func StartGradeReleaseJob(interval time.Duration) {
+	for {
+		n, err := RunDueGradeReleases()
+		if err != nil {
+			fmt.Println("❌ Grade release job error:", err)
+		} else if n > 0 {
+			fmt.Printf("✅ Grade release job: %d grades released/held\n", n)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// ===================== PREVIEW BATCH RELEASE =====================
+// GET /records/grade-releases/preview?scope=class&class_id=3&action=release
+
+func RecordsPreviewGradeRelease(c *gin.Context) {
+	scope := gradeBatchScope{Scope: c.Query("scope")}
+	scope.ClassID, _ = strconv.Atoi(c.Query("class_id"))
+	scope.SubjectID, _ = strconv.Atoi(c.Query("subject_id"))
+	scope.CourseID, _ = strconv.Atoi(c.Query("course_id"))
+	action := c.DefaultQuery("action", "release")
+
+	where, args, err := scope.filter()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total, verified, released, missingTerms int
+	byStatus := gin.H{}
+	rows, err := config.DB.Query(`
+		SELECT IFNULL(g.status, 'draft'), COUNT(*),
+		       SUM(CASE WHEN (g.prelim IS NULL OR g.midterm IS NULL OR g.finals IS NULL)
+		                 AND IFNULL(g.mark, '') = '' THEN 1 ELSE 0 END)
+		FROM grades g
+		WHERE `+where+`
+		GROUP BY IFNULL(g.status, 'draft')
+	`, args...)
+	if err != nil {
+		fmt.Println("❌ Release preview error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build preview"})
+		return
+	}
+	for rows.Next() {
+		var status string
+		var count, missing int
+		if err := rows.Scan(&status, &count, &missing); err != nil {
+			continue
+		}
+		byStatus[status] = count
+		total += count
+		missingTerms += missing
+		switch status {
+		case gradeStatusVerified:
+			verified = count
+		case gradeStatusReleased:
+			released = count
+		}
+	}
+	rows.Close()
+
+	// Classes still holding things up
+	pending := []gin.H{}
+	classRows, err := config.DB.Query(`
+		SELECT s.subject_name, s.code, u.username, IFNULL(g.status, 'draft'), COUNT(*)
+		FROM grades g
+		INNER JOIN subjects s ON s.id = g.subject_id
+		INNER JOIN users u ON u.id = g.teacher_id
+		WHERE `+where+` AND IFNULL(g.status, 'draft') NOT IN ('verified', 'released')
+		GROUP BY s.subject_name, s.code, u.username, IFNULL(g.status, 'draft')
+		ORDER BY s.subject_name
+		LIMIT 100
+	`, args...)
+	if err == nil {
+		for classRows.Next() {
+			var subject, code, teacher, status string
+			var count int
+			if err := classRows.Scan(&subject, &code, &teacher, &status, &count); err == nil {
+				pending = append(pending, gin.H{
+					"subject":      subject,
+					"subject_code": code,
+					"teacher_name": teacher,
+					"status":       status,
+					"grades":       count,
+				})
+			}
+		}
+		classRows.Close()
+	}
+
+	willChange, alreadyDone := verified, released
+	if action == "hold" {
+		willChange, alreadyDone = released, verified
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scope":           scope,
+		"action":          action,
+		"total":           total,
+		"will_change":     willChange,
+		"already_done":    alreadyDone,
+		"not_ready":       total - verified - released,
+		"missing_terms":   missingTerms,
+		"by_status":       byStatus,
+		"pending_classes": pending,
+	})
+}
+
+// ===================== RUN / SCHEDULE BATCH RELEASE =====================
+// POST /records/grade-releases
+// { "scope": "subject", "subject_id": 4, "action": "release", "release_at": "2026-01-15 08:00" }
+// Without release_at the batch runs immediately.
+
+func RecordsBatchReleaseGrades(c *gin.Context) {
+	var req struct {
+		gradeBatchScope
+		Action    string `json:"action"`
+		ReleaseAt string `json:"release_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Action == "" {
+		req.Action = "release"
+	}
+	if req.Action != "release" && req.Action != "hold" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be 'release' or 'hold'"})
+		return
+	}
+	if err := req.gradeBatchScope.pinTerm(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, _, err := req.gradeBatchScope.filter(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	classID, subjectID, courseID := req.gradeBatchScope.nullableIDs()
+	schoolYear, semester := req.gradeBatchScope.nullableTerm()
+	recordsID := c.GetInt("user_id")
+
+	if req.ReleaseAt != "" {
+		var releaseAt time.Time
+		var err error
+		for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"} {
+			if releaseAt, err = time.ParseInLocation(layout, req.ReleaseAt, time.Local); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid release_at, use YYYY-MM-DD HH:MM"})
+			return
+		}
+
+		var future bool
+		config.DB.QueryRow(`SELECT ? > NOW()`, releaseAt.Format("2006-01-02 15:04:05")).Scan(&future)
+		if !future {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "release_at must be in the future"})
+			return
+		}
+
+		res, err := config.DB.Exec(`
+			INSERT INTO grade_release_batches
+			(scope, class_id, subject_id, course_id, school_year, semester, action, release_at, status, created_by)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'scheduled', ?)
+		`, req.Scope, classID, subjectID, courseID, schoolYear, semester, req.Action,
+			releaseAt.Format("2006-01-02 15:04:05"), recordsID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to schedule release"})
+			return
+		}
+		batchID, _ := res.LastInsertId()
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    "Grade release scheduled",
+			"batch_id":   batchID,
+			"release_at": releaseAt.Format("2006-01-02 15:04:05"),
+		})
+		return
+	}
+
+	n, err := executeGradeBatch(req.gradeBatchScope, req.Action)
+	if err != nil {
+		fmt.Println("❌ Batch release error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update grades"})
+		return
+	}
+
+	res, _ := config.DB.Exec(`
+		INSERT INTO grade_release_batches
+		(scope, class_id, subject_id, course_id, school_year, semester, action, status, affected, created_by, executed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 'done', ?, ?, NOW())
+	`, req.Scope, classID, subjectID, courseID, schoolYear, semester, req.Action, n, recordsID)
+	var batchID int64
+	if res != nil {
+		batchID, _ = res.LastInsertId()
+	}
+
+	message := fmt.Sprintf("%d grades released", n)
+	if req.Action == "hold" {
+		message = fmt.Sprintf("%d grades put on hold", n)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "affected": n, "batch_id": batchID})
+}
+
+// ===================== LIST / CANCEL BATCHES =====================
+
+// GET /records/grade-releases?status=scheduled
+func RecordsGetGradeReleaseBatches(c *gin.Context) {
+	query := `
+		SELECT b.id, b.scope, b.class_id, b.subject_id, b.course_id,
+		       IFNULL(b.school_year, ''), IFNULL(b.semester, ''), b.action,
+		       DATE_FORMAT(b.release_at, '%Y-%m-%d %H:%i:%s'), b.status, b.affected,
+		       IFNULL(b.error_message, ''), IFNULL(u.username, ''),
+		       DATE_FORMAT(b.created_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(b.executed_at, '%Y-%m-%d %H:%i:%s')
+		FROM grade_release_batches b
+		LEFT JOIN users u ON u.id = b.created_by
+		WHERE 1=1`
+	args := []interface{}{}
+	if status := c.Query("status"); status != "" {
+		query += " AND b.status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY b.created_at DESC LIMIT 200"
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch release batches"})
+		return
+	}
+	defer rows.Close()
+
+	batches := []gin.H{}
+	for rows.Next() {
+		var (
+			id, affected                           int
+			scope, action, status, errMsg, creator string
+			schoolYear, semester                   string
+			classID, subjectID, courseID           sql.NullInt64
+			releaseAt, executedAt                  *string
+			createdAt                              string
+		)
+		if err := rows.Scan(&id, &scope, &classID, &subjectID, &courseID, &schoolYear, &semester, &action, &releaseAt, &status,
+			&affected, &errMsg, &creator, &createdAt, &executedAt); err != nil {
+			continue
+		}
+		batches = append(batches, gin.H{
+			"id":          id,
+			"scope":       scope,
+			"class_id":    classID.Int64,
+			"subject_id":  subjectID.Int64,
+			"course_id":   courseID.Int64,
+			"school_year": schoolYear,
+			"semester":    semester,
+			"action":      action,
+			"release_at":  releaseAt,
+			"status":      status,
+			"affected":    affected,
+			"error":       errMsg,
+			"created_by":  creator,
+			"created_at":  createdAt,
+			"executed_at": executedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"batches": batches})
+}
+
+// DELETE /records/grade-releases/:id
+func RecordsCancelGradeRelease(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		UPDATE grade_release_batches SET status = 'cancelled' WHERE id = ? AND status = 'scheduled'
+	`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel release"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "only scheduled releases can be cancelled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled release cancelled"})
+}
@@ -0,0 +1,722 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===== GRADE SUBMISSION WORKFLOW =====
+// draft -> submitted (teacher) -> verified (faculty / records) -> released
+// (records). A submitted class can be returned to the teacher for fixes.
+// Teachers can only edit draft or returned grades, and only while the term's
+// grading window is open; anything after that goes through a grade change
+// request: pending -> endorsed (faculty) -> approved (records, applied).
+
+const (
+	gradeStatusDraft     = "draft"
+	gradeStatusSubmitted = "submitted"
+	gradeStatusVerified  = "verified"
+	gradeStatusReturned  = "returned"
+	gradeStatusReleased  = "released"
+)
+
+func gradeEditable(status string) bool {
+	return status == "" || status == gradeStatusDraft || status == gradeStatusReturned
+}
+
+func gradeLockedMessage(status string) string {
+	return fmt.Sprintf("Grade is already %s and locked; file a grade change request instead", status)
+}
+
+// activeSchoolTerm returns the school year / semester set by the faculty.
+func activeSchoolTerm() (string, string, bool) {
+	var year, semester string
+	err := config.DB.QueryRow(`
+		SELECT year, semester FROM school_year WHERE is_active = TRUE ORDER BY id DESC LIMIT 1
+	`).Scan(&year, &semester)
+	return year, semester, err == nil
+}
+
+// checkGradingWindow returns an error when the term has a grading window for
+// the active semester and now falls outside it. Terms without a window are
+// open.
+func checkGradingWindow(term string) error {
+	year, semester, ok := activeSchoolTerm()
+	if !ok {
+		return nil
+	}
+
+	var opensAt, closesAt time.Time
+	var open bool
+	err := config.DB.QueryRow(`
+		SELECT opens_at, closes_at, NOW() BETWEEN opens_at AND closes_at
+		FROM grading_windows
+		WHERE school_year = ? AND semester = ? AND term = ?
+	`, year, semester, term).Scan(&opensAt, &closesAt, &open)
+	if err != nil || open {
+		return nil
+	}
+
+	return fmt.Errorf("the %s grading window is %s to %s", term,
+		opensAt.Format("Jan 2, 2006 3:04 PM"), closesAt.Format("Jan 2, 2006 3:04 PM"))
+}
+
+// ===== GRADING WINDOWS =====
+// GET /admin/grading-windows, /records/grading-windows, /teacher/grading-windows
+// Defaults to the active school year and semester.
+func GetGradingWindows(c *gin.Context) {
+	year, semester, _ := activeSchoolTerm()
+	year = c.DefaultQuery("school_year", year)
+	semester = c.DefaultQuery("semester", semester)
+
+	rows, err := config.DB.Query(`
+		SELECT id, term, DATE_FORMAT(opens_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(closes_at, '%Y-%m-%d %H:%i:%s'),
+		       NOW() BETWEEN opens_at AND closes_at
+		FROM grading_windows
+		WHERE school_year = ? AND semester = ?
+		ORDER BY FIELD(term, 'prelim', 'midterm', 'finals')
+	`, year, semester)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch grading windows"})
+		return
+	}
+	defer rows.Close()
+
+	windows := []gin.H{}
+	for rows.Next() {
+		var id int
+		var term, opensAt, closesAt string
+		var open bool
+		if err := rows.Scan(&id, &term, &opensAt, &closesAt, &open); err != nil {
+			continue
+		}
+		windows = append(windows, gin.H{
+			"id":        id,
+			"term":      term,
+			"opens_at":  opensAt,
+			"closes_at": closesAt,
+			"is_open":   open,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"school_year": year,
+		"semester":    semester,
+		"windows":     windows,
+	})
+}
+
+// POST /admin/grading-windows, /records/grading-windows
+func SaveGradingWindow(c *gin.Context) {
+	var req struct {
+		SchoolYear string `json:"school_year"`
+		Semester   string `json:"semester"`
+		Term       string `json:"term" binding:"required"`
+		OpensAt    string `json:"opens_at" binding:"required"`
+		ClosesAt   string `json:"closes_at" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "term, opens_at and closes_at are required"})
+		return
+	}
+
+	req.Term = strings.ToLower(strings.TrimSpace(req.Term))
+	if !isGradingTerm(req.Term) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "term must be prelim, midterm or finals"})
+		return
+	}
+
+	if req.SchoolYear == "" || req.Semester == "" {
+		year, semester, ok := activeSchoolTerm()
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No active school year; pass school_year and semester"})
+			return
+		}
+		req.SchoolYear, req.Semester = year, semester
+	}
+
+	parse := func(s string) (time.Time, error) {
+		for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid date")
+	}
+	opensAt, err1 := parse(req.OpensAt)
+	closesAt, err2 := parse(req.ClosesAt)
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD or YYYY-MM-DD HH:MM:SS"})
+		return
+	}
+	// A date-only closing day stays open until the end of that day
+	if len(strings.TrimSpace(req.ClosesAt)) == len("2006-01-02") {
+		closesAt = closesAt.Add(24*time.Hour - time.Second)
+	}
+	if !closesAt.After(opensAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "closes_at must be after opens_at"})
+		return
+	}
+
+	_, err := config.DB.Exec(`
+		INSERT INTO grading_windows (school_year, semester, term, opens_at, closes_at, updated_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE opens_at = VALUES(opens_at), closes_at = VALUES(closes_at), updated_by = VALUES(updated_by)
+	`, req.SchoolYear, req.Semester, req.Term,
+		opensAt.Format("2006-01-02 15:04:05"), closesAt.Format("2006-01-02 15:04:05"), c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save grading window"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Grading window saved",
+		"school_year": req.SchoolYear,
+		"semester":    req.Semester,
+		"term":        req.Term,
+		"opens_at":    opensAt.Format("2006-01-02 15:04:05"),
+		"closes_at":   closesAt.Format("2006-01-02 15:04:05"),
+	})
+}
+
+// ===== SUBMIT GRADES FOR VERIFICATION =====
+// POST /teacher/classes/:id/submit-grades
+// Every enrolled student needs complete grades (or an INC / DRP mark).
+func TeacherSubmitGradesForVerification(c *gin.Context) {
+	classID, subjectID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+	teacherID := c.GetInt("user_id")
+
+	rows, err := config.DB.Query(`
+		SELECT DISTINCT st.id, CONCAT(st.first_name, ' ', st.last_name),
+		       IFNULL(g.id, 0), IFNULL(g.status, 'draft'),
+		       g.prelim IS NOT NULL AND g.midterm IS NOT NULL AND g.finals IS NOT NULL,
+		       IFNULL(g.mark, '')
+		FROM student_academic sa
+		INNER JOIN students st ON st.id = sa.student_id
+		LEFT JOIN grades g ON g.student_id = st.id AND g.subject_id = ? AND g.teacher_id = ?
+		WHERE FIND_IN_SET(?, sa.subjects) > 0
+		AND st.status = 'approved'
+	`, subjectID, teacherID, subjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load class grades"})
+		return
+	}
+
+	var incomplete []gin.H
+	var toSubmit []int
+	for rows.Next() {
+		var studentID, gradeID int
+		var name, status, mark string
+		var complete bool
+		if err := rows.Scan(&studentID, &name, &gradeID, &status, &complete, &mark); err != nil {
+			continue
+		}
+		if gradeID == 0 || (!complete && mark == "") {
+			incomplete = append(incomplete, gin.H{"student_id": studentID, "name": name})
+			continue
+		}
+		if gradeEditable(status) {
+			toSubmit = append(toSubmit, gradeID)
+		}
+	}
+	rows.Close()
+
+	if len(incomplete) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Some students have incomplete grades; enter all terms or mark them INC / DRP",
+			"students": incomplete,
+		})
+		return
+	}
+	if len(toSubmit) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "No draft grades to submit for this class"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit grades"})
+		return
+	}
+	defer tx.Rollback()
+
+	for _, id := range toSubmit {
+		if _, err := tx.Exec(`
+			UPDATE grades SET status = 'submitted', submitted_at = NOW(), review_note = NULL WHERE id = ?
+		`, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit grades"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit grades"})
+		return
+	}
+
+	fmt.Printf("✅ Class %d grades submitted for verification (%d)\n", classID, len(toSubmit))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Grades submitted for verification",
+		"submitted": len(toSubmit),
+	})
+}
+
+// ===================== GRADE SUBMISSIONS (FACULTY / RECORDS) =====================
+
+// GET /faculty/grade-submissions?status=submitted, /records/grade-submissions
+func StaffGetGradeSubmissions(c *gin.Context) {
+	status := c.DefaultQuery("status", gradeStatusSubmitted)
+
+	rows, err := config.DB.Query(`
+		SELECT (SELECT MIN(ts.id) FROM teacher_subjects ts WHERE ts.teacher_id = g.teacher_id AND ts.subject_id = g.subject_id),
+		       s.subject_name, s.code, u.username, COUNT(*),
+		       DATE_FORMAT(MAX(g.submitted_at), '%Y-%m-%d %H:%i:%s')
+		FROM grades g
+		INNER JOIN subjects s ON s.id = g.subject_id
+		INNER JOIN users u ON u.id = g.teacher_id
+		WHERE g.status = ?
+		GROUP BY g.teacher_id, g.subject_id, s.subject_name, s.code, u.username
+		ORDER BY MAX(g.submitted_at)
+	`, status)
+	if err != nil {
+		fmt.Println("❌ Grade submissions query error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch grade submissions"})
+		return
+	}
+	defer rows.Close()
+
+	submissions := []gin.H{}
+	for rows.Next() {
+		var classID sql.NullInt64
+		var subject, code, teacher string
+		var count int
+		var submittedAt *string
+		if err := rows.Scan(&classID, &subject, &code, &teacher, &count, &submittedAt); err != nil {
+			continue
+		}
+		submissions = append(submissions, gin.H{
+			"class_id":     classID.Int64,
+			"subject":      subject,
+			"subject_code": code,
+			"teacher_name": teacher,
+			"grades":       count,
+			"submitted_at": submittedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"submissions": submissions, "status": status})
+}
+
+// POST /faculty/grade-submissions/:class_id/review, /records/grade-submissions/:class_id/review
+// { "action": "verify" | "return", "note": "..." }
+func StaffReviewGradeSubmission(c *gin.Context) {
+	classID, err := strconv.Atoi(c.Param("class_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid class_id"})
+		return
+	}
+
+	var req struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Action != "verify" && req.Action != "return") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be 'verify' or 'return'"})
+		return
+	}
+	if req.Action == "return" && strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note is required when returning grades to the teacher"})
+		return
+	}
+
+	var teacherID, subjectID int
+	err = config.DB.QueryRow(`SELECT teacher_id, subject_id FROM teacher_subjects WHERE id = ?`, classID).
+		Scan(&teacherID, &subjectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "class not found"})
+		return
+	}
+
+	newStatus := gradeStatusVerified
+	if req.Action == "return" {
+		newStatus = gradeStatusReturned
+	}
+
+	res, err := config.DB.Exec(`
+		UPDATE grades
+		SET status = ?, verified_by = ?, verified_at = NOW(), review_note = ?
+		WHERE teacher_id = ? AND subject_id = ? AND status = 'submitted'
+	`, newStatus, c.GetInt("user_id"), req.Note, teacherID, subjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review grades"})
+		return
+	}
+
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "no submitted grades for this class"})
+		return
+	}
+
+	message := "Grades verified and ready for release"
+	if newStatus == gradeStatusReturned {
+		message = "Grades returned to the teacher"
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message, "grades": n, "status": newStatus})
+}
+
+// ===== GRADE CHANGE REQUESTS =====
+
+// POST /teacher/grades/:grade_id/change-requests
+// { "prelim": 2.0, "midterm": null, "finals": 1.75, "final_grade": null, "reason": "..." }
+// Only the fields sent are changed.
+func TeacherRequestGradeChange(c *gin.Context) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	teacherID := c.GetInt("user_id")
+
+	gradeID, err := strconv.Atoi(c.Param("grade_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid grade_id"})
+		return
+	}
+
+	var req struct {
+		Prelim     *float64 `json:"prelim"`
+		Midterm    *float64 `json:"midterm"`
+		Finals     *float64 `json:"finals"`
+		FinalGrade *float64 `json:"final_grade"`
+		Reason     string   `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+	if req.Prelim == nil && req.Midterm == nil && req.Finals == nil && req.FinalGrade == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to change"})
+		return
+	}
+	for name, v := range map[string]*float64{"prelim": req.Prelim, "midterm": req.Midterm, "finals": req.Finals, "final_grade": req.FinalGrade} {
+		if v != nil && (*v < 1.0 || *v > 5.0) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be between 1.0 and 5.0 (Filipino GWA scale)"})
+			return
+		}
+	}
+
+	var status string
+	var prelim, midterm, finals, final *float64
+	err = config.DB.QueryRow(`
+		SELECT IFNULL(status, 'draft'), prelim, midterm, finals, final_grade
+		FROM grades WHERE id = ? AND teacher_id = ?
+	`, gradeID, teacherID).Scan(&status, &prelim, &midterm, &finals, &final)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grade not found"})
+		return
+	}
+	if gradeEditable(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Grade is still a draft; edit it directly"})
+		return
+	}
+
+	var open bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM grade_change_requests WHERE grade_id = ? AND status IN ('pending', 'endorsed'))
+	`, gradeID).Scan(&open)
+	if open {
+		c.JSON(http.StatusConflict, gin.H{"error": "This grade already has an open change request"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file change request"})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO grade_change_requests
+		(grade_id, requested_by, old_prelim, old_midterm, old_finals, old_final_grade,
+		 new_prelim, new_midterm, new_finals, new_final_grade, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, gradeID, teacherID, prelim, midterm, finals, final,
+		req.Prelim, req.Midterm, req.Finals, req.FinalGrade, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file change request"})
+		return
+	}
+	requestID, _ := res.LastInsertId()
+
+	if _, err := tx.Exec(`
+		INSERT INTO grade_change_actions (request_id, actor_id, actor_role, action, note)
+		VALUES (?, ?, 'teacher', 'requested', ?)
+	`, requestID, teacherID, req.Reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file change request"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file change request"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Grade change request filed", "request_id": requestID})
+}
+
+// loadGradeChangeRequests lists requests, optionally for one teacher.
+func loadGradeChangeRequests(status string, teacherID int) ([]gin.H, error) {
+	query := `
+		SELECT r.id, r.grade_id, r.status, r.reason,
+		       r.old_prelim, r.old_midterm, r.old_finals, r.old_final_grade,
+		       r.new_prelim, r.new_midterm, r.new_finals, r.new_final_grade,
+		       CONCAT(st.first_name, ' ', st.last_name), IFNULL(st.student_id, ''),
+		       s.subject_name, s.code, u.username,
+		       DATE_FORMAT(r.created_at, '%Y-%m-%d %H:%i:%s'),
+		       DATE_FORMAT(r.resolved_at, '%Y-%m-%d %H:%i:%s')
+		FROM grade_change_requests r
+		INNER JOIN grades g ON g.id = r.grade_id
+		INNER JOIN students st ON st.id = g.student_id
+		INNER JOIN subjects s ON s.id = g.subject_id
+		INNER JOIN users u ON u.id = r.requested_by
+		WHERE 1=1`
+	args := []interface{}{}
+	if status != "" {
+		query += " AND r.status = ?"
+		args = append(args, status)
+	}
+	if teacherID > 0 {
+		query += " AND r.requested_by = ?"
+		args = append(args, teacherID)
+	}
+	query += " ORDER BY r.created_at DESC"
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []gin.H{}
+	var ids []int
+	for rows.Next() {
+		var (
+			id, gradeID                                int
+			reqStatus, reason                          string
+			oldP, oldM, oldF, oldFinal                 *float64
+			newP, newM, newF, newFinal                 *float64
+			student, studentNo, subject, code, teacher string
+			createdAt                                  string
+			resolvedAt                                 *string
+		)
+		if err := rows.Scan(&id, &gradeID, &reqStatus, &reason, &oldP, &oldM, &oldF, &oldFinal,
+			&newP, &newM, &newF, &newFinal, &student, &studentNo, &subject, &code, &teacher,
+			&createdAt, &resolvedAt); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		requests = append(requests, gin.H{
+			"id":             id,
+			"grade_id":       gradeID,
+			"status":         reqStatus,
+			"reason":         reason,
+			"student_name":   student,
+			"student_number": studentNo,
+			"subject":        subject,
+			"subject_code":   code,
+			"teacher_name":   teacher,
+			"old":            gin.H{"prelim": oldP, "midterm": oldM, "finals": oldF, "final_grade": oldFinal},
+			"new":            gin.H{"prelim": newP, "midterm": newM, "finals": newF, "final_grade": newFinal},
+			"created_at":     createdAt,
+			"resolved_at":    resolvedAt,
+		})
+	}
+	rows.Close()
+
+	// Approval trail
+	for i, id := range ids {
+		trail := []gin.H{}
+		actionRows, err := config.DB.Query(`
+			SELECT a.actor_role, IFNULL(u.username, ''), a.action, IFNULL(a.note, ''),
+			       DATE_FORMAT(a.created_at, '%Y-%m-%d %H:%i:%s')
+			FROM grade_change_actions a
+			LEFT JOIN users u ON u.id = a.actor_id
+			WHERE a.request_id = ?
+			ORDER BY a.created_at, a.id
+		`, id)
+		if err != nil {
+			return nil, err
+		}
+		for actionRows.Next() {
+			var role, actor, action, note, at string
+			if err := actionRows.Scan(&role, &actor, &action, &note, &at); err == nil {
+				trail = append(trail, gin.H{"role": role, "actor": actor, "action": action, "note": note, "at": at})
+			}
+		}
+		actionRows.Close()
+		requests[i]["trail"] = trail
+	}
+
+	return requests, nil
+}
+
+// GET /teacher/grade-change-requests
+func TeacherGetGradeChangeRequests(c *gin.Context) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	requests, err := loadGradeChangeRequests(c.Query("status"), c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch change requests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// GET /faculty/grade-change-requests, /records/grade-change-requests
+// Defaults to what the caller has to act on.
+func StaffGetGradeChangeRequests(c *gin.Context) {
+	status := "pending"
+	if c.GetString("role") == "records" {
+		status = "endorsed"
+	}
+	status = c.DefaultQuery("status", status)
+	if status == "all" {
+		status = ""
+	}
+
+	requests, err := loadGradeChangeRequests(status, 0)
+	if err != nil {
+		fmt.Println("❌ Grade change requests query error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch change requests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// POST /faculty/grade-change-requests/:id/review   { "action": "endorse" | "reject", "note": "" }
+// POST /records/grade-change-requests/:id/review   { "action": "approve" | "reject", "note": "" }
+// Records approval applies the new values to the grade.
+func StaffReviewGradeChangeRequest(c *gin.Context) {
+	role := c.GetString("role")
+	actorID := c.GetInt("user_id")
+
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request id"})
+		return
+	}
+
+	var req struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action is required"})
+		return
+	}
+
+	// Which step this role handles
+	expected, advance := "pending", "endorse"
+	if role == "records" {
+		expected, advance = "endorsed", "approve"
+	}
+	if req.Action != advance && req.Action != "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("action must be '%s' or 'reject'", advance)})
+		return
+	}
+	if req.Action == "reject" && strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note is required when rejecting"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review request"})
+		return
+	}
+	defer tx.Rollback()
+
+	var gradeID int
+	var status string
+	var newP, newM, newF, newFinal *float64
+	err = tx.QueryRow(`
+		SELECT grade_id, status, new_prelim, new_midterm, new_finals, new_final_grade
+		FROM grade_change_requests WHERE id = ? FOR UPDATE
+	`, requestID).Scan(&gradeID, &status, &newP, &newM, &newF, &newFinal)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "change request not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review request"})
+		return
+	}
+	if status != expected {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("request is %s; %s can only act on %s requests", status, role, expected)})
+		return
+	}
+
+	newStatus := map[string]string{"endorse": "endorsed", "approve": "approved", "reject": "rejected"}[req.Action]
+
+	if newStatus == "approved" {
+		var mark string
+		var final *float64
+		tx.QueryRow(`SELECT IFNULL(mark, ''), final_grade FROM grades WHERE id = ?`, gradeID).Scan(&mark, &final)
+		if newFinal != nil {
+			final = newFinal
+		}
+
+		_, err = tx.Exec(`
+			UPDATE grades
+			SET prelim = COALESCE(?, prelim), midterm = COALESCE(?, midterm), finals = COALESCE(?, finals),
+			    final_grade = COALESCE(?, final_grade),
+			    final_remark = CASE WHEN final_grade IS NULL THEN final_remark ELSE ? END,
+			    updated_at = NOW()
+			WHERE id = ?
+		`, newP, newM, newF, newFinal, gradeRemark(mark, final), gradeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply grade change"})
+			return
+		}
+	}
+
+	resolved := "NULL"
+	if newStatus != "endorsed" {
+		resolved = "NOW()"
+	}
+	if _, err := tx.Exec(`UPDATE grade_change_requests SET status = ?, resolved_at = `+resolved+` WHERE id = ?`,
+		newStatus, requestID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review request"})
+		return
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO grade_change_actions (request_id, actor_id, actor_role, action, note)
+		VALUES (?, ?, ?, ?, ?)
+	`, requestID, actorID, role, newStatus, req.Note); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review request"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Change request " + newStatus, "status": newStatus})
+}
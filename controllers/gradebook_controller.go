@@ -0,0 +1,427 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===== GRADEBOOK =====
+// Lessons and activities become gradebook columns by linking them to a grade
+// item (component + term + max points). Reviewing a submission of a linked
+// lesson writes the score straight into grade_scores, so it is aggregated
+// into the class's weighted components like any other score.
+
+// syncSubmissionScore sets the student's score on the lesson's linked grade
+// item, if any, from their latest accepted, scored submission, the same one
+// TeacherLinkLessonGradeItem carries over. The score is cleared only when no
+// such submission is left. Returns the class/subject to recompute, or
+// ok=false when the lesson is not linked.
+func syncSubmissionScore(materialID, studentID int) (classID, subjectID int, ok bool, err error) {
+	var itemID int
+	err = config.DB.QueryRow(`
+		SELECT gi.id, gi.class_id, ts.subject_id
+		FROM grade_items gi
+		INNER JOIN teacher_subjects ts ON ts.id = gi.class_id
+		WHERE gi.material_id = ?
+	`, materialID).Scan(&itemID, &classID, &subjectID)
+	if err == sql.ErrNoRows {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	var score sql.NullFloat64
+	err = config.DB.QueryRow(`
+		SELECT score FROM student_submissions
+		WHERE material_id = ? AND student_id = ? AND status = 'accepted' AND score IS NOT NULL
+		ORDER BY id DESC
+		LIMIT 1
+	`, materialID, studentID).Scan(&score)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, 0, false, err
+	}
+
+	_, err = config.DB.Exec(`
+		INSERT INTO grade_scores (item_id, student_id, score, excused)
+		VALUES (?, ?, ?, FALSE)
+		ON DUPLICATE KEY UPDATE score = VALUES(score), excused = FALSE
+	`, itemID, studentID, score)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	return classID, subjectID, true, nil
+}
+
+// ===== LINK LESSON TO GRADEBOOK =====
+// POST /teacher/lessons/:id/grade-item  { "component_id": 3, "term": "midterm", "max_score": 50 }
+// Creates (or updates) the lesson's gradebook column and carries over the
+// scores of submissions that were already accepted.
+func TeacherLinkLessonGradeItem(c *gin.Context) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	teacherID := c.GetInt("user_id")
+
+	materialID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lesson id"})
+		return
+	}
+
+	var req struct {
+		ComponentID int     `json:"component_id" binding:"required"`
+		Term        string  `json:"term" binding:"required"`
+		MaxScore    float64 `json:"max_score" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "component_id, term and max_score are required"})
+		return
+	}
+
+	req.Term = strings.ToLower(strings.TrimSpace(req.Term))
+	if !isGradingTerm(req.Term) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "term must be prelim, midterm or finals"})
+		return
+	}
+	if req.MaxScore <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_score must be positive"})
+		return
+	}
+
+	var classID, subjectID int
+	var title string
+	err = config.DB.QueryRow(`
+		SELECT lm.class_id, lm.subject_id, lm.title
+		FROM lesson_materials lm
+		INNER JOIN teacher_subjects ts ON ts.id = lm.class_id AND ts.teacher_id = lm.teacher_id
+		WHERE lm.id = ? AND lm.teacher_id = ?
+	`, materialID, teacherID).Scan(&classID, &subjectID, &title)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found or not owned by you"})
+		return
+	}
+
+	var belongs bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM grading_components gc
+			INNER JOIN grading_schemes gs ON gs.id = gc.scheme_id
+			WHERE gc.id = ? AND gs.class_id = ?
+		)
+	`, req.ComponentID, classID).Scan(&belongs)
+	if !belongs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Component not found in this class's grading scheme"})
+		return
+	}
+
+	// Already-recorded scores must still fit under the new max
+	var highest sql.NullFloat64
+	config.DB.QueryRow(`
+		SELECT MAX(score) FROM student_submissions WHERE material_id = ? AND status = 'accepted'
+	`, materialID).Scan(&highest)
+	if highest.Valid && highest.Float64 > req.MaxScore {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An accepted submission already scored %.2f; max_score cannot be lower", highest.Float64)})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link lesson"})
+		return
+	}
+	defer tx.Rollback()
+
+	var itemID int64
+	err = tx.QueryRow(`SELECT id FROM grade_items WHERE material_id = ?`, materialID).Scan(&itemID)
+	switch {
+	case err == sql.ErrNoRows:
+		res, insertErr := tx.Exec(`
+			INSERT INTO grade_items (class_id, component_id, term, title, max_score, material_id)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, classID, req.ComponentID, req.Term, title, req.MaxScore, materialID)
+		if insertErr == nil {
+			itemID, _ = res.LastInsertId()
+		}
+		err = insertErr
+	case err == nil:
+		_, err = tx.Exec(`
+			UPDATE grade_items SET component_id = ?, term = ?, title = ?, max_score = ? WHERE id = ?
+		`, req.ComponentID, req.Term, title, req.MaxScore, itemID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link lesson"})
+		return
+	}
+
+	// Latest accepted, scored submission per student
+	_, err = tx.Exec(`
+		INSERT INTO grade_scores (item_id, student_id, score, excused)
+		SELECT ?, ss.student_id, ss.score, FALSE
+		FROM student_submissions ss
+		WHERE ss.material_id = ? AND ss.status = 'accepted' AND ss.score IS NOT NULL
+		AND ss.id = (
+			SELECT MAX(ss2.id) FROM student_submissions ss2
+			WHERE ss2.material_id = ss.material_id AND ss2.student_id = ss.student_id
+			AND ss2.status = 'accepted' AND ss2.score IS NOT NULL
+		)
+		ON DUPLICATE KEY UPDATE score = VALUES(score), excused = FALSE
+	`, itemID, materialID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to carry over submission scores"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link lesson"})
+		return
+	}
+
+	recomputeAfterChange(classID, teacherID, subjectID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lesson linked to gradebook", "grade_item_id": itemID})
+}
+
+// ===== TEACHER GRADEBOOK MATRIX =====
+// GET /teacher/classes/:id/gradebook
+// Students as rows, grade items as columns, with the computed term and final
+// grades (not saved; use compute-grades for that).
+func TeacherGetGradebook(c *gin.Context) {
+	classID, subjectID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	scheme, err := loadGradingScheme(classID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This class has no grading scheme yet"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch grading scheme"})
+		return
+	}
+
+	itemRows, err := config.DB.Query(`
+		SELECT gi.id, gi.term, gi.component_id, gc.name, gi.title, gi.max_score, gi.material_id
+		FROM grade_items gi
+		INNER JOIN grading_components gc ON gc.id = gi.component_id
+		WHERE gi.class_id = ?
+		ORDER BY FIELD(gi.term, 'prelim', 'midterm', 'finals'), gc.sort_order, gi.created_at, gi.id
+	`, classID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch grade items"})
+		return
+	}
+	items := []gin.H{}
+	for itemRows.Next() {
+		var (
+			id, componentID        int
+			term, component, title string
+			maxScore               float64
+			materialID             *int
+		)
+		if err := itemRows.Scan(&id, &term, &componentID, &component, &title, &maxScore, &materialID); err != nil {
+			continue
+		}
+		items = append(items, gin.H{
+			"id":           id,
+			"term":         term,
+			"component_id": componentID,
+			"component":    component,
+			"title":        title,
+			"max_score":    maxScore,
+			"lesson_id":    materialID,
+		})
+	}
+	itemRows.Close()
+
+	scores := map[int]gin.H{}
+	scoreRows, err := config.DB.Query(`
+		SELECT gs.student_id, gs.item_id, gs.score, gs.excused
+		FROM grade_scores gs
+		INNER JOIN grade_items gi ON gi.id = gs.item_id
+		WHERE gi.class_id = ?
+	`, classID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scores"})
+		return
+	}
+	for scoreRows.Next() {
+		var studentID, itemID int
+		var score *float64
+		var excused bool
+		if err := scoreRows.Scan(&studentID, &itemID, &score, &excused); err != nil {
+			continue
+		}
+		if scores[studentID] == nil {
+			scores[studentID] = gin.H{}
+		}
+		scores[studentID][strconv.Itoa(itemID)] = gin.H{"score": score, "excused": excused}
+	}
+	scoreRows.Close()
+
+	computed, err := computeClassGrades(classID, c.GetInt("user_id"), subjectID, false)
+	if err != nil {
+		fmt.Println("❌ Gradebook computation error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute grades"})
+		return
+	}
+	byStudent := map[int]computedGrade{}
+	for _, g := range computed {
+		byStudent[g.StudentID] = g
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT DISTINCT st.id, st.student_id, st.first_name, st.last_name
+		FROM student_academic sa
+		INNER JOIN students st ON st.id = sa.student_id
+		WHERE FIND_IN_SET(?, sa.subjects) > 0
+		AND st.status = 'approved'
+		ORDER BY st.last_name, st.first_name
+	`, subjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch students"})
+		return
+	}
+	defer rows.Close()
+
+	students := []gin.H{}
+	for rows.Next() {
+		var id int
+		var studentNo, first, last string
+		if err := rows.Scan(&id, &studentNo, &first, &last); err != nil {
+			continue
+		}
+
+		row := byStudent[id].toJSON()
+		row["student_id"] = id
+		row["student_number"] = studentNo
+		row["full_name"] = first + " " + last
+		row["scores"] = scores[id]
+		if scores[id] == nil {
+			row["scores"] = gin.H{}
+		}
+		students = append(students, row)
+	}
+
+	components := scheme.Components
+	if components == nil {
+		components = []gradingComponent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"class_id":     classID,
+		"term_weights": scheme.TermWeights,
+		"components":   components,
+		"items":        items,
+		"students":     students,
+	})
+}
+
+// ===================== STUDENT GRADEBOOK =====================
+// GET /student/gradebook
+// The student's own raw scores per class. Computed grades stay hidden until
+// the Records Office releases them through /student/grades.
+
+func StudentGetGradebook(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	var studentDBID int
+	var subjectIDs string
+	err := config.DB.QueryRow(`
+		SELECT st.id, IFNULL(sa.subjects, '')
+		FROM students st
+		LEFT JOIN student_academic sa ON sa.student_id = st.id
+		WHERE st.student_id = ?
+		ORDER BY sa.id DESC
+		LIMIT 1
+	`, studentStrID).Scan(&studentDBID, &subjectIDs)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+		return
+	}
+
+	if blockedByHolds(c, studentDBID, holdScopeGrades, "view your grades") {
+		return
+	}
+
+	if subjectIDs == "" {
+		c.JSON(http.StatusOK, gin.H{"classes": []gin.H{}, "message": "no subjects enrolled"})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT gi.class_id, s.subject_name, s.code, gi.id, gi.term, gc.name, gi.title, gi.max_score,
+		       gs.score, IFNULL(gs.excused, FALSE), gi.material_id
+		FROM grade_items gi
+		INNER JOIN teacher_subjects ts ON ts.id = gi.class_id
+		INNER JOIN subjects s ON s.id = ts.subject_id
+		INNER JOIN grading_components gc ON gc.id = gi.component_id
+		LEFT JOIN grade_scores gs ON gs.item_id = gi.id AND gs.student_id = ?
+		WHERE FIND_IN_SET(ts.subject_id, ?)
+		ORDER BY s.subject_name, FIELD(gi.term, 'prelim', 'midterm', 'finals'), gc.sort_order, gi.created_at, gi.id
+	`, studentDBID, subjectIDs)
+	if err != nil {
+		fmt.Println("❌ Error fetching gradebook:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch gradebook"})
+		return
+	}
+	defer rows.Close()
+
+	classes := []gin.H{}
+	index := map[int]int{}
+
+	for rows.Next() {
+		var (
+			classID, itemID                             int
+			subjectName, subjectCode, term, comp, title string
+			maxScore                                    float64
+			score                                       *float64
+			excused                                     bool
+			materialID                                  *int
+		)
+		if err := rows.Scan(&classID, &subjectName, &subjectCode, &itemID, &term, &comp, &title, &maxScore,
+			&score, &excused, &materialID); err != nil {
+			fmt.Println("❌ Row scan error:", err)
+			continue
+		}
+
+		i, seen := index[classID]
+		if !seen {
+			i = len(classes)
+			index[classID] = i
+			classes = append(classes, gin.H{
+				"class_id":     classID,
+				"subject":      subjectName,
+				"subject_code": subjectCode,
+				"items":        []gin.H{},
+			})
+		}
+
+		classes[i]["items"] = append(classes[i]["items"].([]gin.H), gin.H{
+			"item_id":   itemID,
+			"term":      term,
+			"component": comp,
+			"title":     title,
+			"max_score": maxScore,
+			"score":     score,
+			"excused":   excused,
+			"lesson_id": materialID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"classes": classes})
+}
@@ -0,0 +1,1081 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"student-portal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===== GRADING SCHEMES =====
+// A class with a grading scheme computes its grades from raw scores. Each term
+// grade is the weighted percentage of the scheme's components (quizzes,
+// exams, projects...) transmuted to the 1.0-5.0 scale; the final grade weighs
+// the three term percentages. Classes without a scheme keep the manually
+// entered prelim/midterm/finals from TeacherSubmitGrade.
+
+var gradingTerms = []string{"prelim", "midterm", "finals"}
+
+const (
+	passingGrade = 3.0
+	failingGrade = 5.0
+)
+
+type gradingComponent struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
+type gradingScheme struct {
+	ID                   int
+	ClassID              int
+	TermWeights          map[string]float64
+	TransmutationTableID int
+	Components           []gradingComponent
+}
+
+type transmutationRow struct {
+	MinPercent float64 `json:"min_percent"`
+	Grade      float64 `json:"grade"`
+}
+
+func isGradingTerm(term string) bool {
+	for _, t := range gradingTerms {
+		if t == term {
+			return true
+		}
+	}
+	return false
+}
+
+// teacherClassSubject returns the subject of a class owned by the teacher.
+func teacherClassSubject(classID, teacherID int) (int, error) {
+	var subjectID int
+	err := config.DB.QueryRow(`
+		SELECT subject_id FROM teacher_subjects WHERE id = ? AND teacher_id = ?
+	`, classID, teacherID).Scan(&subjectID)
+	return subjectID, err
+}
+
+// classStudentIDs lists the approved students enrolled in a subject.
+func classStudentIDs(subjectID int) ([]int, error) {
+	rows, err := config.DB.Query(`
+		SELECT DISTINCT st.id
+		FROM student_academic sa
+		INNER JOIN students st ON st.id = sa.student_id
+		WHERE FIND_IN_SET(?, sa.subjects) > 0
+		AND st.status = 'approved'
+		ORDER BY st.id
+	`, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// loadGradingScheme returns sql.ErrNoRows when the class has no scheme.
+func loadGradingScheme(classID int) (gradingScheme, error) {
+	s := gradingScheme{ClassID: classID, TermWeights: map[string]float64{}}
+
+	var prelim, midterm, finals float64
+	err := config.DB.QueryRow(`
+		SELECT id, prelim_weight, midterm_weight, finals_weight, IFNULL(transmutation_table_id, 0)
+		FROM grading_schemes
+		WHERE class_id = ?
+	`, classID).Scan(&s.ID, &prelim, &midterm, &finals, &s.TransmutationTableID)
+	if err != nil {
+		return s, err
+	}
+	s.TermWeights["prelim"] = prelim
+	s.TermWeights["midterm"] = midterm
+	s.TermWeights["finals"] = finals
+
+	rows, err := config.DB.Query(`
+		SELECT id, name, weight FROM grading_components WHERE scheme_id = ? ORDER BY sort_order, id
+	`, s.ID)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comp gradingComponent
+		if err := rows.Scan(&comp.ID, &comp.Name, &comp.Weight); err != nil {
+			return s, err
+		}
+		s.Components = append(s.Components, comp)
+	}
+
+	return s, nil
+}
+
+// loadTransmutationRows loads a table, or the default one when tableID is 0.
+// Rows come back from the highest cut-off down.
+func loadTransmutationRows(tableID int) ([]transmutationRow, error) {
+	if tableID == 0 {
+		err := config.DB.QueryRow(`
+			SELECT id FROM transmutation_tables WHERE is_default = TRUE ORDER BY id LIMIT 1
+		`).Scan(&tableID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT min_percent, grade FROM transmutation_rows WHERE table_id = ? ORDER BY min_percent DESC
+	`, tableID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []transmutationRow
+	for rows.Next() {
+		var r transmutationRow
+		if err := rows.Scan(&r.MinPercent, &r.Grade); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// transmute converts a percentage to the 1.0-5.0 scale. Anything below the
+// lowest cut-off is a failing 5.0.
+func transmute(table []transmutationRow, percent float64) float64 {
+	for _, r := range table {
+		if percent >= r.MinPercent {
+			return r.Grade
+		}
+	}
+	return failingGrade
+}
+
+type termGrade struct {
+	Percent *float64
+	Grade   *float64
+}
+
+type computedGrade struct {
+	StudentID    int
+	Terms        map[string]termGrade
+	FinalPercent *float64
+	FinalGrade   *float64
+	Mark         string
+	Remark       string
+	Status       string
+}
+
+func (g computedGrade) toJSON() gin.H {
+	terms := gin.H{}
+	for _, t := range gradingTerms {
+		terms[t] = gin.H{"percent": g.Terms[t].Percent, "grade": g.Terms[t].Grade}
+	}
+	return gin.H{
+		"student_id":    g.StudentID,
+		"terms":         terms,
+		"final_percent": g.FinalPercent,
+		"final_grade":   g.FinalGrade,
+		"mark":          g.Mark,
+		"remark":        g.Remark,
+		"status":        g.Status,
+	}
+}
+
+// gradeRemark derives PASSED / FAILED / INC / DRP. INC and DRP set by the
+// teacher take precedence over any computed grade.
+func gradeRemark(mark string, finalGrade *float64) string {
+	switch {
+	case mark == "DRP" || mark == "INC":
+		return mark
+	case finalGrade == nil:
+		return ""
+	case *finalGrade <= passingGrade:
+		return "PASSED"
+	default:
+		return "FAILED"
+	}
+}
+
+// computeClassGrades computes every enrolled student's term and final grades
+// from the raw scores. Missing scores count as zero; excused items are left
+// out. With persist, the results are written to the grades table except for
+// grades already submitted for verification or released.
+func computeClassGrades(classID, teacherID, subjectID int, persist bool) ([]computedGrade, error) {
+	scheme, err := loadGradingScheme(classID)
+	if err != nil {
+		return nil, err
+	}
+	table, err := loadTransmutationRows(scheme.TransmutationTableID)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := map[int]float64{}
+	for _, comp := range scheme.Components {
+		weights[comp.ID] = comp.Weight
+	}
+
+	type item struct {
+		id, componentID int
+		term            string
+		maxScore        float64
+	}
+	var items []item
+	itemRows, err := config.DB.Query(`
+		SELECT id, component_id, term, max_score FROM grade_items WHERE class_id = ?
+	`, classID)
+	if err != nil {
+		return nil, err
+	}
+	for itemRows.Next() {
+		var it item
+		if err := itemRows.Scan(&it.id, &it.componentID, &it.term, &it.maxScore); err != nil {
+			itemRows.Close()
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	itemRows.Close()
+
+	type scoreKey struct{ item, student int }
+	type score struct {
+		value   sql.NullFloat64
+		excused bool
+	}
+	scores := map[scoreKey]score{}
+	scoreRows, err := config.DB.Query(`
+		SELECT gs.item_id, gs.student_id, gs.score, gs.excused
+		FROM grade_scores gs
+		INNER JOIN grade_items gi ON gi.id = gs.item_id
+		WHERE gi.class_id = ?
+	`, classID)
+	if err != nil {
+		return nil, err
+	}
+	for scoreRows.Next() {
+		var k scoreKey
+		var s score
+		if err := scoreRows.Scan(&k.item, &k.student, &s.value, &s.excused); err != nil {
+			scoreRows.Close()
+			return nil, err
+		}
+		scores[k] = s
+	}
+	scoreRows.Close()
+
+	type existingGrade struct {
+		id     int
+		mark   string
+		status string
+	}
+	existing := map[int]existingGrade{}
+	gradeRows, err := config.DB.Query(`
+		SELECT id, student_id, IFNULL(mark, ''), IFNULL(status, 'draft')
+		FROM grades
+		WHERE subject_id = ? AND teacher_id = ?
+	`, subjectID, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	for gradeRows.Next() {
+		var e existingGrade
+		var studentID int
+		if err := gradeRows.Scan(&e.id, &studentID, &e.mark, &e.status); err != nil {
+			gradeRows.Close()
+			return nil, err
+		}
+		existing[studentID] = e
+	}
+	gradeRows.Close()
+
+	students, err := classStudentIDs(subjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []computedGrade
+	for _, studentID := range students {
+		g := computedGrade{
+			StudentID: studentID,
+			Terms:     map[string]termGrade{},
+			Mark:      existing[studentID].mark,
+			Status:    existing[studentID].status,
+		}
+		if g.Status == "" {
+			g.Status = gradeStatusDraft
+		}
+
+		for _, term := range gradingTerms {
+			earned := map[int]float64{}
+			possible := map[int]float64{}
+			for _, it := range items {
+				if it.term != term {
+					continue
+				}
+				s := scores[scoreKey{it.id, studentID}]
+				if s.excused {
+					continue
+				}
+				possible[it.componentID] += it.maxScore
+				if s.value.Valid {
+					earned[it.componentID] += s.value.Float64
+				}
+			}
+
+			var weighted, usedWeight float64
+			for compID, max := range possible {
+				if max <= 0 {
+					continue
+				}
+				weighted += earned[compID] / max * 100 * weights[compID]
+				usedWeight += weights[compID]
+			}
+			if usedWeight == 0 {
+				continue
+			}
+
+			percent := roundCents(weighted / usedWeight)
+			grade := transmute(table, percent)
+			g.Terms[term] = termGrade{Percent: &percent, Grade: &grade}
+		}
+
+		complete := true
+		var finalPercent float64
+		for _, term := range gradingTerms {
+			if g.Terms[term].Percent == nil {
+				complete = false
+				break
+			}
+			finalPercent += *g.Terms[term].Percent * scheme.TermWeights[term] / 100
+		}
+		if complete && g.Mark == "" {
+			fp := roundCents(finalPercent)
+			fg := transmute(table, fp)
+			g.FinalPercent = &fp
+			g.FinalGrade = &fg
+		}
+		g.Remark = gradeRemark(g.Mark, g.FinalGrade)
+
+		results = append(results, g)
+
+		if !persist || !gradeEditable(g.Status) {
+			continue
+		}
+
+		if e, ok := existing[studentID]; ok {
+			_, err = config.DB.Exec(`
+				UPDATE grades
+				SET prelim = ?, midterm = ?, finals = ?, final_grade = ?, final_remark = ?,
+				    class_id = ?, computed_at = NOW(), updated_at = NOW()
+				WHERE id = ? AND IFNULL(status, 'draft') IN ('draft', 'returned')
+			`, g.Terms["prelim"].Grade, g.Terms["midterm"].Grade, g.Terms["finals"].Grade,
+				g.FinalGrade, g.Remark, classID, e.id)
+		} else {
+			schoolYear, semester := gradeTermArgs()
+			_, err = config.DB.Exec(`
+				INSERT INTO grades
+				(student_id, subject_id, teacher_id, class_id, prelim, midterm, finals,
+				 final_grade, final_remark, school_year, semester, computed_at, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW(), NOW())
+			`, studentID, subjectID, teacherID, classID, g.Terms["prelim"].Grade, g.Terms["midterm"].Grade,
+				g.Terms["finals"].Grade, g.FinalGrade, g.Remark, schoolYear, semester)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// recomputeAfterChange keeps stored grades in sync after scores or the scheme
+// change. Failures are logged; the teacher can recompute explicitly.
+func recomputeAfterChange(classID, teacherID, subjectID int) {
+	if _, err := computeClassGrades(classID, teacherID, subjectID, true); err != nil && err != sql.ErrNoRows {
+		fmt.Println("⚠️ Warning: could not recompute class grades:", err)
+	}
+}
+
+func classIDParam(c *gin.Context) (int, int, bool) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return 0, 0, false
+	}
+
+	classID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid class id"})
+		return 0, 0, false
+	}
+
+	subjectID, err := teacherClassSubject(classID, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Class not found or not assigned to you"})
+		return 0, 0, false
+	}
+
+	return classID, subjectID, true
+}
+
+// ===== GET GRADING SCHEME =====
+// GET /teacher/classes/:id/grading-scheme
+func TeacherGetGradingScheme(c *gin.Context) {
+	classID, _, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	scheme, err := loadGradingScheme(classID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"scheme": nil})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch grading scheme"})
+		return
+	}
+
+	table, err := loadTransmutationRows(scheme.TransmutationTableID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transmutation table"})
+		return
+	}
+
+	components := scheme.Components
+	if components == nil {
+		components = []gradingComponent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scheme": gin.H{
+			"scheme_id":              scheme.ID,
+			"class_id":               classID,
+			"term_weights":           scheme.TermWeights,
+			"transmutation_table_id": scheme.TransmutationTableID,
+			"transmutation":          table,
+			"components":             components,
+		},
+	})
+}
+
+// ===== SAVE GRADING SCHEME =====
+// PUT /teacher/classes/:id/grading-scheme
+// Components without an id are created; existing ones missing from the list
+// are removed unless they already have grade items.
+func TeacherSaveGradingScheme(c *gin.Context) {
+	classID, subjectID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+	teacherID := c.GetInt("user_id")
+
+	var req struct {
+		PrelimWeight         float64            `json:"prelim_weight"`
+		MidtermWeight        float64            `json:"midterm_weight"`
+		FinalsWeight         float64            `json:"finals_weight"`
+		TransmutationTableID int                `json:"transmutation_table_id"`
+		Components           []gradingComponent `json:"components"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if req.PrelimWeight < 0 || req.MidtermWeight < 0 || req.FinalsWeight < 0 ||
+		math.Abs(req.PrelimWeight+req.MidtermWeight+req.FinalsWeight-100) > 0.01 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Prelim, midterm and finals weights must add up to 100"})
+		return
+	}
+
+	if len(req.Components) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one grading component is required"})
+		return
+	}
+	var componentTotal float64
+	for _, comp := range req.Components {
+		if strings.TrimSpace(comp.Name) == "" || comp.Weight <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each component needs a name and a positive weight"})
+			return
+		}
+		componentTotal += comp.Weight
+	}
+	if math.Abs(componentTotal-100) > 0.01 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Component weights must add up to 100"})
+		return
+	}
+
+	var tableID interface{}
+	if req.TransmutationTableID > 0 {
+		var exists bool
+		config.DB.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM transmutation_tables WHERE id = ? AND (is_default = TRUE OR created_by = ?))
+		`, req.TransmutationTableID, teacherID).Scan(&exists)
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Transmutation table not found"})
+			return
+		}
+		tableID = req.TransmutationTableID
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save grading scheme"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO grading_schemes (class_id, prelim_weight, midterm_weight, finals_weight, transmutation_table_id, updated_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE prelim_weight = VALUES(prelim_weight), midterm_weight = VALUES(midterm_weight),
+			finals_weight = VALUES(finals_weight), transmutation_table_id = VALUES(transmutation_table_id),
+			updated_by = VALUES(updated_by)
+	`, classID, req.PrelimWeight, req.MidtermWeight, req.FinalsWeight, tableID, teacherID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save grading scheme"})
+		return
+	}
+
+	var schemeID int
+	if err := tx.QueryRow(`SELECT id FROM grading_schemes WHERE class_id = ?`, classID).Scan(&schemeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save grading scheme"})
+		return
+	}
+
+	keep := map[int]bool{}
+	for i, comp := range req.Components {
+		if comp.ID > 0 {
+			res, err := tx.Exec(`
+				UPDATE grading_components SET name = ?, weight = ?, sort_order = ? WHERE id = ? AND scheme_id = ?
+			`, comp.Name, comp.Weight, i, comp.ID, schemeID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save components"})
+				return
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				var exists bool
+				tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM grading_components WHERE id = ? AND scheme_id = ?)`,
+					comp.ID, schemeID).Scan(&exists)
+				if !exists {
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Component %d does not belong to this class", comp.ID)})
+					return
+				}
+			}
+			keep[comp.ID] = true
+			continue
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO grading_components (scheme_id, name, weight, sort_order) VALUES (?, ?, ?, ?)
+		`, schemeID, comp.Name, comp.Weight, i)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save components"})
+			return
+		}
+		newID, _ := res.LastInsertId()
+		keep[int(newID)] = true
+	}
+
+	rows, err := tx.Query(`
+		SELECT gc.id, gc.name, COUNT(gi.id)
+		FROM grading_components gc
+		LEFT JOIN grade_items gi ON gi.component_id = gc.id
+		WHERE gc.scheme_id = ?
+		GROUP BY gc.id, gc.name
+	`, schemeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save components"})
+		return
+	}
+	type existingComponent struct {
+		id, items int
+		name      string
+	}
+	var toDelete []existingComponent
+	for rows.Next() {
+		var e existingComponent
+		rows.Scan(&e.id, &e.name, &e.items)
+		if !keep[e.id] {
+			toDelete = append(toDelete, e)
+		}
+	}
+	rows.Close()
+
+	for _, e := range toDelete {
+		if e.items > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Component %q already has grade items and cannot be removed", e.name)})
+			return
+		}
+		if _, err := tx.Exec(`DELETE FROM grading_components WHERE id = ?`, e.id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save components"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save grading scheme"})
+		return
+	}
+
+	recomputeAfterChange(classID, teacherID, subjectID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Grading scheme saved", "scheme_id": schemeID})
+}
+
+// ===== TRANSMUTATION TABLES =====
+// GET /teacher/transmutation-tables
+func TeacherGetTransmutationTables(c *gin.Context) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT id, name, is_default FROM transmutation_tables
+		WHERE is_default = TRUE OR created_by = ?
+		ORDER BY is_default DESC, name
+	`, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transmutation tables"})
+		return
+	}
+
+	type tableInfo struct {
+		id        int
+		name      string
+		isDefault bool
+	}
+	var list []tableInfo
+	for rows.Next() {
+		var t tableInfo
+		if err := rows.Scan(&t.id, &t.name, &t.isDefault); err == nil {
+			list = append(list, t)
+		}
+	}
+	rows.Close()
+
+	tables := []gin.H{}
+	for _, t := range list {
+		tableRows, err := loadTransmutationRows(t.id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transmutation tables"})
+			return
+		}
+		tables = append(tables, gin.H{
+			"id":         t.id,
+			"name":       t.name,
+			"is_default": t.isDefault,
+			"rows":       tableRows,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tables": tables})
+}
+
+// POST /teacher/transmutation-tables
+func TeacherCreateTransmutationTable(c *gin.Context) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var req struct {
+		Name string             `json:"name" binding:"required"`
+		Rows []transmutationRow `json:"rows" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and rows are required"})
+		return
+	}
+
+	sort.Slice(req.Rows, func(i, j int) bool { return req.Rows[i].MinPercent > req.Rows[j].MinPercent })
+	for i, r := range req.Rows {
+		if r.MinPercent < 0 || r.MinPercent > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_percent must be between 0 and 100"})
+			return
+		}
+		if r.Grade < 1.0 || r.Grade > 5.0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grade must be between 1.0 and 5.0 (Filipino GWA scale)"})
+			return
+		}
+		if i > 0 && (r.MinPercent == req.Rows[i-1].MinPercent || r.Grade < req.Rows[i-1].Grade) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cut-offs must be unique and lower percentages cannot get better grades"})
+			return
+		}
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transmutation table"})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO transmutation_tables (name, is_default, created_by) VALUES (?, FALSE, ?)
+	`, req.Name, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transmutation table"})
+		return
+	}
+	tableID, _ := res.LastInsertId()
+
+	for _, r := range req.Rows {
+		if _, err := tx.Exec(`
+			INSERT INTO transmutation_rows (table_id, min_percent, grade) VALUES (?, ?, ?)
+		`, tableID, r.MinPercent, r.Grade); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transmutation table"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transmutation table"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Transmutation table created", "id": tableID})
+}
+
+// ===== GRADE ITEMS =====
+// GET /teacher/classes/:id/grade-items?term=
+func TeacherGetGradeItems(c *gin.Context) {
+	classID, _, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	term := c.Query("term")
+	rows, err := config.DB.Query(`
+		SELECT gi.id, gi.component_id, gc.name, gi.term, gi.title, gi.max_score,
+		       COUNT(gs.id), DATE_FORMAT(gi.created_at, '%Y-%m-%d %H:%i:%s')
+		FROM grade_items gi
+		INNER JOIN grading_components gc ON gc.id = gi.component_id
+		LEFT JOIN grade_scores gs ON gs.item_id = gi.id AND (gs.score IS NOT NULL OR gs.excused = TRUE)
+		WHERE gi.class_id = ? AND (? = '' OR gi.term = ?)
+		GROUP BY gi.id, gi.component_id, gc.name, gi.term, gi.title, gi.max_score, gi.created_at
+		ORDER BY FIELD(gi.term, 'prelim', 'midterm', 'finals'), gi.created_at, gi.id
+	`, classID, term, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch grade items"})
+		return
+	}
+	defer rows.Close()
+
+	items := []gin.H{}
+	for rows.Next() {
+		var (
+			id, componentID, scored    int
+			component, itemTerm, title string
+			maxScore                   float64
+			createdAt                  string
+		)
+		if err := rows.Scan(&id, &componentID, &component, &itemTerm, &title, &maxScore, &scored, &createdAt); err != nil {
+			continue
+		}
+		items = append(items, gin.H{
+			"id":           id,
+			"component_id": componentID,
+			"component":    component,
+			"term":         itemTerm,
+			"title":        title,
+			"max_score":    maxScore,
+			"scored":       scored,
+			"created_at":   createdAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// POST /teacher/classes/:id/grade-items
+func TeacherCreateGradeItem(c *gin.Context) {
+	classID, _, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ComponentID int     `json:"component_id" binding:"required"`
+		Term        string  `json:"term" binding:"required"`
+		Title       string  `json:"title" binding:"required"`
+		MaxScore    float64 `json:"max_score" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "component_id, term, title and max_score are required"})
+		return
+	}
+
+	req.Term = strings.ToLower(strings.TrimSpace(req.Term))
+	if !isGradingTerm(req.Term) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "term must be prelim, midterm or finals"})
+		return
+	}
+	if req.MaxScore <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_score must be positive"})
+		return
+	}
+
+	var belongs bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM grading_components gc
+			INNER JOIN grading_schemes gs ON gs.id = gc.scheme_id
+			WHERE gc.id = ? AND gs.class_id = ?
+		)
+	`, req.ComponentID, classID).Scan(&belongs)
+	if !belongs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Component not found in this class's grading scheme"})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		INSERT INTO grade_items (class_id, component_id, term, title, max_score) VALUES (?, ?, ?, ?, ?)
+	`, classID, req.ComponentID, req.Term, req.Title, req.MaxScore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create grade item"})
+		return
+	}
+
+	itemID, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"message": "Grade item created", "id": itemID})
+}
+
+// loadTeacherGradeItem returns the class and subject of an item owned by the teacher.
+func loadTeacherGradeItem(itemID, teacherID int) (int, int, float64, error) {
+	var classID, subjectID int
+	var maxScore float64
+	err := config.DB.QueryRow(`
+		SELECT gi.class_id, ts.subject_id, gi.max_score
+		FROM grade_items gi
+		INNER JOIN teacher_subjects ts ON ts.id = gi.class_id
+		WHERE gi.id = ? AND ts.teacher_id = ?
+	`, itemID, teacherID).Scan(&classID, &subjectID, &maxScore)
+	return classID, subjectID, maxScore, err
+}
+
+// DELETE /teacher/grade-items/:id
+func TeacherDeleteGradeItem(c *gin.Context) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	teacherID := c.GetInt("user_id")
+
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item id"})
+		return
+	}
+
+	classID, subjectID, _, err := loadTeacherGradeItem(itemID, teacherID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grade item not found"})
+		return
+	}
+
+	if _, err := config.DB.Exec(`DELETE FROM grade_items WHERE id = ?`, itemID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete grade item"})
+		return
+	}
+
+	recomputeAfterChange(classID, teacherID, subjectID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Grade item deleted"})
+}
+
+// ===== RAW SCORE ENTRY =====
+// POST /teacher/grade-items/:id/scores
+// { "scores": [ { "student_id": 1, "score": 18 }, { "student_id": 2, "excused": true } ] }
+// A null score clears the entry.
+func TeacherSaveScores(c *gin.Context) {
+	if c.GetString("role") != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	teacherID := c.GetInt("user_id")
+
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item id"})
+		return
+	}
+
+	var req struct {
+		Scores []struct {
+			StudentID int      `json:"student_id"`
+			Score     *float64 `json:"score"`
+			Excused   bool     `json:"excused"`
+		} `json:"scores" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scores are required"})
+		return
+	}
+
+	classID, subjectID, maxScore, err := loadTeacherGradeItem(itemID, teacherID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grade item not found"})
+		return
+	}
+
+	var term string
+	config.DB.QueryRow(`SELECT term FROM grade_items WHERE id = ?`, itemID).Scan(&term)
+	if err := checkGradingWindow(term); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Grading window closed: " + err.Error()})
+		return
+	}
+
+	enrolled := map[int]bool{}
+	ids, err := classStudentIDs(subjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load class list"})
+		return
+	}
+	for _, id := range ids {
+		enrolled[id] = true
+	}
+
+	for _, s := range req.Scores {
+		if !enrolled[s.StudentID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Student %d is not enrolled in this class", s.StudentID)})
+			return
+		}
+		if s.Score != nil && (*s.Score < 0 || *s.Score > maxScore) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Score for student %d must be between 0 and %.2f", s.StudentID, maxScore)})
+			return
+		}
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save scores"})
+		return
+	}
+	defer tx.Rollback()
+
+	for _, s := range req.Scores {
+		_, err := tx.Exec(`
+			INSERT INTO grade_scores (item_id, student_id, score, excused)
+			VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE score = VALUES(score), excused = VALUES(excused)
+		`, itemID, s.StudentID, s.Score, s.Excused)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save scores"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save scores"})
+		return
+	}
+
+	recomputeAfterChange(classID, teacherID, subjectID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scores saved", "saved": len(req.Scores)})
+}
+
+// ===== INC / DRP =====
+// POST /teacher/classes/:id/grade-marks  { "student_id": 1, "mark": "INC" | "DRP" | "" }
+func TeacherSetGradeMark(c *gin.Context) {
+	classID, subjectID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+	teacherID := c.GetInt("user_id")
+
+	var req struct {
+		StudentID int    `json:"student_id" binding:"required"`
+		Mark      string `json:"mark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "student_id is required"})
+		return
+	}
+
+	req.Mark = strings.ToUpper(strings.TrimSpace(req.Mark))
+	if req.Mark != "" && req.Mark != "INC" && req.Mark != "DRP" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mark must be INC, DRP or empty"})
+		return
+	}
+
+	var mark interface{}
+	if req.Mark != "" {
+		mark = req.Mark
+	}
+
+	var gradeID int
+	var status string
+	err := config.DB.QueryRow(`
+		SELECT id, IFNULL(status, 'draft') FROM grades WHERE student_id = ? AND subject_id = ? AND teacher_id = ?
+	`, req.StudentID, subjectID, teacherID).Scan(&gradeID, &status)
+
+	switch {
+	case err == sql.ErrNoRows:
+		schoolYear, semester := gradeTermArgs()
+		_, err = config.DB.Exec(`
+			INSERT INTO grades (student_id, subject_id, teacher_id, class_id, mark, final_remark, school_year, semester, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+		`, req.StudentID, subjectID, teacherID, classID, mark, req.Mark, schoolYear, semester)
+	case err != nil:
+	case !gradeEditable(status):
+		c.JSON(http.StatusConflict, gin.H{"error": gradeLockedMessage(status)})
+		return
+	default:
+		_, err = config.DB.Exec(`UPDATE grades SET mark = ?, updated_at = NOW() WHERE id = ?`, mark, gradeID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save mark"})
+		return
+	}
+
+	recomputeAfterChange(classID, teacherID, subjectID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Mark saved", "mark": req.Mark})
+}
+
+// ===== COMPUTE GRADES =====
+// POST /teacher/classes/:id/compute-grades
+func TeacherComputeGrades(c *gin.Context) {
+	classID, subjectID, ok := classIDParam(c)
+	if !ok {
+		return
+	}
+
+	results, err := computeClassGrades(classID, c.GetInt("user_id"), subjectID, true)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This class has no grading scheme yet"})
+		return
+	}
+	if err != nil {
+		fmt.Println("❌ Grade computation error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute grades"})
+		return
+	}
+
+	grades := []gin.H{}
+	for _, g := range results {
+		grades = append(grades, g.toJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grades": grades, "total": len(grades)})
+}
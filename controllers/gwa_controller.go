@@ -0,0 +1,425 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"student-portal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===== GWA / ACADEMIC HONORS =====
+//
+// The general weighted average (GWA) is sum(grade x units) / sum(units) over
+// the released grades that count toward it. NSTP/PE subjects, INC and dropped
+// subjects and subjects with no final grade are listed but left out. GWAs are
+// rounded to two decimals before they are compared with any threshold, so
+// the figure a student sees is the figure that was evaluated.
+
+// honorsSettings are the dean's list and latin honors rules, kept in
+// system_settings so the registrar can adjust them without a deploy.
+type honorsSettings struct {
+	ExcludedPrefixes  []string `json:"excluded_prefixes"`
+	DeansListMaxGWA   float64  `json:"deans_list_max_gwa"`
+	DeansListMinGrade float64  `json:"deans_list_min_grade"`
+	DeansListMinUnits float64  `json:"deans_list_min_units"`
+	SummaCumLaudeGWA  float64  `json:"summa_cum_laude_gwa"`
+	MagnaCumLaudeGWA  float64  `json:"magna_cum_laude_gwa"`
+	CumLaudeGWA       float64  `json:"cum_laude_gwa"`
+	HonorsMinGrade    float64  `json:"honors_min_grade"`
+}
+
+// honorsSettingKeys maps each numeric rule to its system_settings key and
+// default value.
+var honorsSettingKeys = []struct {
+	key      string
+	fallback float64
+	field    func(*honorsSettings) *float64
+}{
+	{"deans_list_max_gwa", 1.75, func(s *honorsSettings) *float64 { return &s.DeansListMaxGWA }},
+	{"deans_list_min_grade", 2.50, func(s *honorsSettings) *float64 { return &s.DeansListMinGrade }},
+	{"deans_list_min_units", 15, func(s *honorsSettings) *float64 { return &s.DeansListMinUnits }},
+	{"summa_cum_laude_gwa", 1.20, func(s *honorsSettings) *float64 { return &s.SummaCumLaudeGWA }},
+	{"magna_cum_laude_gwa", 1.45, func(s *honorsSettings) *float64 { return &s.MagnaCumLaudeGWA }},
+	{"cum_laude_gwa", 1.75, func(s *honorsSettings) *float64 { return &s.CumLaudeGWA }},
+	{"honors_min_grade", 2.50, func(s *honorsSettings) *float64 { return &s.HonorsMinGrade }},
+}
+
+const defaultGWAExcludedPrefixes = "NSTP,PE,PATHFIT"
+
+func loadHonorsSettings() honorsSettings {
+	var s honorsSettings
+	for _, k := range honorsSettingKeys {
+		v, err := strconv.ParseFloat(getSetting(k.key, ""), 64)
+		if err != nil {
+			v = k.fallback
+		}
+		*k.field(&s) = v
+	}
+	for _, p := range strings.Split(getSetting("gwa_excluded_prefixes", defaultGWAExcludedPrefixes), ",") {
+		if p = strings.ToUpper(strings.TrimSpace(p)); p != "" {
+			s.ExcludedPrefixes = append(s.ExcludedPrefixes, p)
+		}
+	}
+	return s
+}
+
+// excludedByCode reports whether a subject code starts with one of the
+// excluded prefixes as a whole word: "PE 1" and "PE101" match "PE", "PED101"
+// does not.
+func (s honorsSettings) excludedByCode(code string) bool {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, p := range s.ExcludedPrefixes {
+		if !strings.HasPrefix(code, p) {
+			continue
+		}
+		rest := code[len(p):]
+		if rest == "" || !unicode.IsLetter(rune(rest[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+type gwaSubject struct {
+	GradeID     int      `json:"grade_id"`
+	SubjectName string   `json:"subject"`
+	SubjectCode string   `json:"subject_code"`
+	Units       float64  `json:"units"`
+	Grade       *float64 `json:"grade"`
+	Mark        string   `json:"mark,omitempty"` // DRP, INC... as recorded
+	Remark      string   `json:"remark"`
+	Counted     bool     `json:"counted"`
+	Excluded    string   `json:"excluded_reason,omitempty"`
+}
+
+type gwaTerm struct {
+	SchoolYear     string       `json:"school_year"`
+	Semester       string       `json:"semester"`
+	Subjects       []gwaSubject `json:"subjects"`
+	UnitsEarned    float64      `json:"units_earned"`
+	UnitsCounted   float64      `json:"units_counted"`
+	GWA            *float64     `json:"gwa"`
+	DeansList      bool         `json:"deans_list"`
+	DeansListNotes []string     `json:"deans_list_notes"`
+
+	weighted float64
+	blocked  bool
+}
+
+type academicStanding struct {
+	StudentID      int        `json:"student_id"`
+	Terms          []*gwaTerm `json:"terms"`
+	UnitsEarned    float64    `json:"units_earned"`
+	UnitsCounted   float64    `json:"units_counted"`
+	CreditedUnits  float64    `json:"credited_units"`
+	GWA            *float64   `json:"gwa"`
+	LatinHonors    string     `json:"latin_honors"`
+	HonorsEligible bool       `json:"honors_eligible"`
+	HonorsNotes    []string   `json:"honors_notes"`
+}
+
+// subjectStatus classifies a released grade as counted or excluded, and says
+// whether it is an unresolved INC/DRP/failure that rules out honors.
+func subjectStatus(s honorsSettings, code string, flagged bool, grade *float64, mark, remark string) (reason string, blocking bool) {
+	mark = strings.ToUpper(strings.TrimSpace(mark))
+	remark = strings.ToUpper(strings.TrimSpace(remark))
+
+	switch {
+	case mark == "DRP" || remark == "DRP" || remark == "DROPPED":
+		return "dropped", true
+	case mark == "INC" || remark == "INC" || remark == "INCOMPLETE":
+		return "incomplete", true
+	case grade == nil:
+		return "no final grade", true
+	case *grade > passingGrade:
+		blocking = true
+	}
+
+	if flagged || s.excludedByCode(code) {
+		return "not credited toward GWA (NSTP/PE)", blocking
+	}
+	return "", blocking
+}
+
+// unassignedTerm groups grades whose term is not recorded. They count
+// toward the cumulative GWA but are never a dean's list term.
+const unassignedTerm = "Unassigned"
+
+func (t *gwaTerm) unassigned() bool { return t.SchoolYear == unassignedTerm }
+
+// computeAcademicStanding groups a student's released grades by term and
+// evaluates the term GWAs, dean's list, cumulative GWA and latin honors.
+func computeAcademicStanding(studentDBID int) (*academicStanding, error) {
+	settings := loadHonorsSettings()
+
+	// Grades are stamped with the term they were given in; grades whose
+	// term could not be worked out stay unassigned rather than guessed.
+	rows, err := config.DB.Query(`
+		SELECT
+			g.id,
+			s.subject_name,
+			IFNULL(s.code, ''),
+			IFNULL(s.units, 3),
+			IFNULL(s.exclude_from_gwa, FALSE),
+			COALESCE(g.final_grade, CASE
+				WHEN g.prelim IS NOT NULL AND g.midterm IS NOT NULL AND g.finals IS NOT NULL
+				THEN ROUND((g.prelim + g.midterm + g.finals) / 3, 2)
+				ELSE NULL
+			END),
+			IFNULL(g.mark, ''),
+			COALESCE(NULLIF(g.final_remark, ''), g.remarks, ''),
+			IFNULL(g.school_year, ?),
+			IFNULL(g.semester, ?)
+		FROM grades g
+		INNER JOIN subjects s ON s.id = g.subject_id
+		WHERE g.student_id = ? AND g.is_released = TRUE
+		ORDER BY g.school_year IS NULL, 9, 10, s.subject_name
+	`, unassignedTerm, unassignedTerm, studentDBID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standing := &academicStanding{StudentID: studentDBID, Terms: []*gwaTerm{}, HonorsNotes: []string{}}
+	var term *gwaTerm
+	var weighted float64
+	var worst float64
+
+	for rows.Next() {
+		var subj gwaSubject
+		var flagged bool
+		var mark, remark, schoolYear, semester string
+		if err := rows.Scan(&subj.GradeID, &subj.SubjectName, &subj.SubjectCode, &subj.Units, &flagged,
+			&subj.Grade, &mark, &remark, &schoolYear, &semester); err != nil {
+			return nil, err
+		}
+
+		if term == nil || term.SchoolYear != schoolYear || term.Semester != semester {
+			term = &gwaTerm{SchoolYear: schoolYear, Semester: semester, Subjects: []gwaSubject{}, DeansListNotes: []string{}}
+			standing.Terms = append(standing.Terms, term)
+		}
+
+		reason, blocking := subjectStatus(settings, subj.SubjectCode, flagged, subj.Grade, mark, remark)
+		subj.Mark = strings.ToUpper(strings.TrimSpace(mark))
+		subj.Remark = gradeRemark(subj.Mark, subj.Grade)
+		if subj.Remark == "" {
+			subj.Remark = strings.ToUpper(remark)
+		}
+		subj.Counted = reason == ""
+		subj.Excluded = reason
+
+		if blocking {
+			term.blocked = true
+			note := fmt.Sprintf("%s: %s", subj.SubjectCode, strings.ToLower(subj.Remark))
+			if reason != "" && reason != "not credited toward GWA (NSTP/PE)" {
+				note = fmt.Sprintf("%s: %s", subj.SubjectCode, reason)
+			}
+			standing.HonorsNotes = append(standing.HonorsNotes, note)
+		}
+		if subj.Grade != nil && *subj.Grade <= passingGrade {
+			term.UnitsEarned += subj.Units
+		}
+		if subj.Counted {
+			term.UnitsCounted += subj.Units
+			term.weighted += *subj.Grade * subj.Units
+			if *subj.Grade > worst {
+				worst = *subj.Grade
+			}
+			if *subj.Grade > settings.DeansListMinGrade {
+				term.DeansListNotes = append(term.DeansListNotes,
+					fmt.Sprintf("%s grade %.2f is below the %.2f cutoff", subj.SubjectCode, *subj.Grade, settings.DeansListMinGrade))
+			}
+		}
+		term.Subjects = append(term.Subjects, subj)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, t := range standing.Terms {
+		if t.UnitsCounted > 0 {
+			gwa := roundCents(t.weighted / t.UnitsCounted)
+			t.GWA = &gwa
+		}
+		standing.UnitsEarned += t.UnitsEarned
+		standing.UnitsCounted += t.UnitsCounted
+		weighted += t.weighted
+
+		if t.unassigned() {
+			t.DeansListNotes = append(t.DeansListNotes, "term of these grades is not recorded")
+		}
+		if t.blocked {
+			t.DeansListNotes = append(t.DeansListNotes, "has an INC, dropped or failed subject")
+		}
+		if t.UnitsCounted < settings.DeansListMinUnits {
+			t.DeansListNotes = append(t.DeansListNotes,
+				fmt.Sprintf("carried %.1f units, %.1f required", t.UnitsCounted, settings.DeansListMinUnits))
+		}
+		if t.GWA == nil || *t.GWA > settings.DeansListMaxGWA {
+			t.DeansListNotes = append(t.DeansListNotes,
+				fmt.Sprintf("term GWA must be %.2f or better", settings.DeansListMaxGWA))
+		}
+		t.DeansList = len(t.DeansListNotes) == 0
+	}
+
+	// Subjects credited from other schools add units but not to the GWA
+	if err := config.DB.QueryRow(`
+		SELECT IFNULL(SUM(units), 0) FROM credited_subjects WHERE student_id = ?
+	`, studentDBID).Scan(&standing.CreditedUnits); err != nil {
+		return nil, err
+	}
+
+	if standing.UnitsCounted > 0 {
+		gwa := roundCents(weighted / standing.UnitsCounted)
+		standing.GWA = &gwa
+	}
+
+	switch {
+	case standing.GWA == nil:
+		standing.HonorsNotes = append(standing.HonorsNotes, "no grades counted toward GWA yet")
+	case worst > settings.HonorsMinGrade:
+		standing.HonorsNotes = append(standing.HonorsNotes,
+			fmt.Sprintf("has a grade of %.2f, below the %.2f cutoff", worst, settings.HonorsMinGrade))
+	}
+	standing.HonorsEligible = len(standing.HonorsNotes) == 0
+
+	if standing.HonorsEligible {
+		switch gwa := *standing.GWA; {
+		case gwa <= settings.SummaCumLaudeGWA:
+			standing.LatinHonors = "Summa Cum Laude"
+		case gwa <= settings.MagnaCumLaudeGWA:
+			standing.LatinHonors = "Magna Cum Laude"
+		case gwa <= settings.CumLaudeGWA:
+			standing.LatinHonors = "Cum Laude"
+		default:
+			standing.HonorsEligible = false
+			standing.HonorsNotes = append(standing.HonorsNotes,
+				fmt.Sprintf("cumulative GWA must be %.2f or better", settings.CumLaudeGWA))
+		}
+	}
+
+	return standing, nil
+}
+
+// GET /student/gwa
+func StudentGetGWA(c *gin.Context) {
+	var studentDBID int
+	if err := config.DB.QueryRow(`SELECT id FROM students WHERE student_id = ?`, c.GetString("student_id")).Scan(&studentDBID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+		return
+	}
+
+	if blockedByHolds(c, studentDBID, holdScopeGrades, "view your grades") {
+		return
+	}
+
+	standing, err := computeAcademicStanding(studentDBID)
+	if err != nil {
+		fmt.Println("❌ GWA error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute GWA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, standing)
+}
+
+// GET /records/grades/student/:student_id/gwa
+func RecordsGetStudentGWA(c *gin.Context) {
+	studentID, err := strconv.Atoi(c.Param("student_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student_id"})
+		return
+	}
+
+	var exists int
+	if err := config.DB.QueryRow(`SELECT COUNT(*) FROM students WHERE id = ?`, studentID).Scan(&exists); err != nil || exists == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+		return
+	}
+
+	standing, err := computeAcademicStanding(studentID)
+	if err != nil {
+		fmt.Println("❌ GWA error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute GWA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, standing)
+}
+
+// GET /admin/settings/honors
+func AdminGetHonorsSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, loadHonorsSettings())
+}
+
+// PUT /admin/settings/honors
+// Only the fields present are changed, e.g. { "cum_laude_gwa": 1.75 }
+func AdminUpdateHonorsSettings(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	values := map[string]string{}
+	for _, k := range honorsSettingKeys {
+		raw, ok := req[k.key]
+		if !ok {
+			continue
+		}
+		v, ok := raw.(float64)
+		if !ok || v < 0 || (k.key != "deans_list_min_units" && (v < 1 || v > 5)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": k.key + " is out of range"})
+			return
+		}
+		values[k.key] = strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	if raw, ok := req["excluded_prefixes"]; ok {
+		list, ok := raw.([]interface{})
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "excluded_prefixes must be a list of subject code prefixes"})
+			return
+		}
+		var prefixes []string
+		for _, p := range list {
+			if s, ok := p.(string); ok && strings.TrimSpace(s) != "" {
+				prefixes = append(prefixes, strings.ToUpper(strings.TrimSpace(s)))
+			}
+		}
+		values["gwa_excluded_prefixes"] = strings.Join(prefixes, ",")
+	}
+
+	if len(values) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to update"})
+		return
+	}
+
+	for key, value := range values {
+		_, err := config.DB.Exec(`
+			INSERT INTO system_settings (setting_key, setting_value, updated_by)
+			VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE setting_value = VALUES(setting_value), updated_by = VALUES(updated_by)
+		`, key, value, c.GetInt("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save settings"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Honors settings saved", "settings": loadHonorsSettings()})
+}
+
+// gradeTermArgs returns the active school year and semester to stamp on a new
+// grade row, or NULLs when no term is active.
+func gradeTermArgs() (interface{}, interface{}) {
+	year, semester, ok := activeSchoolTerm()
+	if !ok {
+		return nil, nil
+	}
+	return year, semester
+}
@@ -0,0 +1,550 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== STUDENT HOLDS =====================
+//
+// A hold stops a student from enrolling, requesting documents and/or viewing
+// grades until the office that placed it lifts it. Financial holds are also
+// placed and lifted automatically from the past-due balance.
+
+const (
+	holdScopeEnrollment = "enrollment"
+	holdScopeDocuments  = "documents"
+	holdScopeGrades     = "grades"
+)
+
+// Office the student has to settle each kind of hold with
+var holdOffices = map[string]string{
+	"financial":    "Cashier's Office",
+	"library":      "Library",
+	"disciplinary": "Office of Student Affairs",
+	"registrar":    "Registrar's Office",
+}
+
+// Hold types each staff role may place and lift
+var holdTypesByRole = map[string][]string{
+	"admin":     {"financial", "library", "disciplinary", "registrar"},
+	"cashier":   {"financial"},
+	"registrar": {"registrar", "disciplinary"},
+	"records":   {"registrar", "library"},
+}
+
+func canManageHold(role, holdType string) bool {
+	for _, t := range holdTypesByRole[role] {
+		if t == holdType {
+			return true
+		}
+	}
+	return false
+}
+
+type studentHold struct {
+	ID               int
+	HoldType         string
+	Reason           string
+	Source           string
+	BlocksEnrollment bool
+	BlocksDocuments  bool
+	BlocksGrades     bool
+	PlacedAt         string
+}
+
+func (h studentHold) toJSON() gin.H {
+	var blocks []string
+	if h.BlocksEnrollment {
+		blocks = append(blocks, holdScopeEnrollment)
+	}
+	if h.BlocksDocuments {
+		blocks = append(blocks, holdScopeDocuments)
+	}
+	if h.BlocksGrades {
+		blocks = append(blocks, holdScopeGrades)
+	}
+	return gin.H{
+		"hold_id":   h.ID,
+		"hold_type": h.HoldType,
+		"reason":    h.Reason,
+		"source":    h.Source,
+		"office":    holdOffices[h.HoldType],
+		"blocks":    blocks,
+		"placed_at": h.PlacedAt,
+	}
+}
+
+// ----- settings -----
+
+func getSetting(key, fallback string) string {
+	var value string
+	if err := config.DB.QueryRow(`SELECT setting_value FROM system_settings WHERE setting_key = ?`, key).Scan(&value); err != nil {
+		return fallback
+	}
+	return value
+}
+
+func financialHoldThreshold() float64 {
+	v, err := strconv.ParseFloat(getSetting("financial_hold_threshold", "0"), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// pastDueBalance is what the student owes right now: the remaining balance of
+// every earlier term plus overdue installments of the current one.
+func pastDueBalance(studentDBID int) (float64, error) {
+	var balance float64
+	err := config.DB.QueryRow(`
+		SELECT
+			IFNULL((
+				SELECT SUM(GREATEST(sp.total_amount - IFNULL(sp.amount_paid, 0), 0))
+				FROM student_payments sp
+				WHERE sp.student_id = ?
+				  AND sp.id < (SELECT MAX(id) FROM student_payments WHERE student_id = ?)
+			), 0)
+			+
+			IFNULL((
+				SELECT SUM(GREATEST(si.amount + IFNULL(si.penalty_amount, 0) - IFNULL(si.amount_paid, 0), 0))
+				FROM student_installments si
+				WHERE si.payment_id = (SELECT MAX(id) FROM student_payments WHERE student_id = ?)
+				  AND si.due_date < CURDATE()
+			), 0)
+	`, studentDBID, studentDBID, studentDBID).Scan(&balance)
+	return roundCents(balance), err
+}
+
+// refreshFinancialHold places, updates or lifts the automatic financial hold
+// of a student. A staff exemption on a lifted automatic hold is respected
+// until it expires.
+func refreshFinancialHold(studentDBID int) error {
+	balance, err := pastDueBalance(studentDBID)
+	if err != nil {
+		return err
+	}
+	threshold := financialHoldThreshold()
+
+	var holdID int
+	err = config.DB.QueryRow(`
+		SELECT id FROM student_holds
+		WHERE student_id = ? AND hold_type = 'financial' AND source = 'auto' AND status = 'active'
+		LIMIT 1
+	`, studentDBID).Scan(&holdID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if balance <= threshold {
+		if holdID > 0 {
+			_, err = config.DB.Exec(`
+				UPDATE student_holds
+				SET status = 'lifted', lifted_at = NOW(), lift_reason = 'Past-due balance settled'
+				WHERE id = ?
+			`, holdID)
+		}
+		return err
+	}
+
+	reason := fmt.Sprintf("Past-due balance of PHP %s", formatPeso(balance))
+	if holdID > 0 {
+		_, err = config.DB.Exec(`UPDATE student_holds SET reason = ? WHERE id = ?`, reason, holdID)
+		return err
+	}
+
+	var exempt bool
+	config.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM student_holds
+			WHERE student_id = ? AND hold_type = 'financial' AND source = 'auto'
+			  AND status = 'lifted' AND exempt_until >= CURDATE()
+		)
+	`, studentDBID).Scan(&exempt)
+	if exempt {
+		return nil
+	}
+
+	_, err = config.DB.Exec(`
+		INSERT INTO student_holds (student_id, hold_type, reason, source)
+		VALUES (?, 'financial', ?, 'auto')
+	`, studentDBID, reason)
+	return err
+}
+
+// RefreshFinancialHolds re-evaluates every student with a billing record.
+func RefreshFinancialHolds() (int, error) {
+	rows, err := config.DB.Query(`SELECT DISTINCT student_id FROM student_payments`)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := refreshFinancialHold(id); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+func StartHoldJob(interval time.Duration) {
+	for {
+		if _, err := RefreshFinancialHolds(); err != nil {
+			fmt.Println("❌ Hold job error:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// activeHolds returns the holds blocking the given scope ("" for all).
+func activeHolds(q dbQuerier, studentDBID int, scope string) ([]studentHold, error) {
+	query := `
+		SELECT id, hold_type, reason, source, blocks_enrollment, blocks_documents, blocks_grades,
+		       DATE_FORMAT(placed_at, '%Y-%m-%d %H:%i:%s')
+		FROM student_holds
+		WHERE student_id = ? AND status = 'active'`
+	switch scope {
+	case holdScopeEnrollment:
+		query += ` AND blocks_enrollment = TRUE`
+	case holdScopeDocuments:
+		query += ` AND blocks_documents = TRUE`
+	case holdScopeGrades:
+		query += ` AND blocks_grades = TRUE`
+	}
+	query += ` ORDER BY placed_at`
+
+	rows, err := q.Query(query, studentDBID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []studentHold
+	for rows.Next() {
+		var h studentHold
+		if err := rows.Scan(&h.ID, &h.HoldType, &h.Reason, &h.Source, &h.BlocksEnrollment,
+			&h.BlocksDocuments, &h.BlocksGrades, &h.PlacedAt); err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, nil
+}
+
+// blockedByHolds writes a 403 and returns true when the student has a hold on
+// the given scope. The financial hold is refreshed first so a student who has
+// just paid is not blocked by a stale hold.
+func blockedByHolds(c *gin.Context, studentDBID int, scope, action string) bool {
+	if err := refreshFinancialHold(studentDBID); err != nil {
+		fmt.Println("⚠️ Warning: could not refresh financial hold:", err)
+	}
+
+	holds, err := activeHolds(config.DB, studentDBID, scope)
+	if err != nil {
+		fmt.Println("❌ Hold check error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check account holds"})
+		return true
+	}
+	if len(holds) == 0 {
+		return false
+	}
+
+	offices := []string{}
+	seen := map[string]bool{}
+	list := []gin.H{}
+	for _, h := range holds {
+		if office := holdOffices[h.HoldType]; !seen[office] {
+			seen[office] = true
+			offices = append(offices, office)
+		}
+		list = append(list, h.toJSON())
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": fmt.Sprintf("You cannot %s while your account is on hold. Please settle it with the %s.",
+			action, strings.Join(offices, " and ")),
+		"holds": list,
+	})
+	return true
+}
+
+// ===================== STUDENT: HOLDS =====================
+
+// GET /student/holds
+func StudentGetHolds(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	var studentDBID int
+	if err := config.DB.QueryRow(`SELECT id FROM students WHERE student_id = ?`, studentStrID).Scan(&studentDBID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+		return
+	}
+
+	if err := refreshFinancialHold(studentDBID); err != nil {
+		fmt.Println("⚠️ Warning: could not refresh financial hold:", err)
+	}
+
+	holds, err := activeHolds(config.DB, studentDBID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch holds"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, h := range holds {
+		result = append(result, h.toJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"holds": result, "on_hold": len(result) > 0})
+}
+
+// ===================== STAFF: HOLDS =====================
+// Shared by the admin, registrar, cashier and records groups. Each role only
+// manages the hold types listed in holdTypesByRole.
+
+// GET /<role>/holds?student_id=&status=active|lifted|all&type=
+func StaffGetHolds(c *gin.Context) {
+	status := c.DefaultQuery("status", "active")
+
+	where := []string{"1 = 1"}
+	args := []interface{}{}
+	if v := c.Query("student_id"); v != "" {
+		where = append(where, "st.student_id = ?")
+		args = append(args, v)
+	}
+	if status != "all" {
+		where = append(where, "h.status = ?")
+		args = append(args, status)
+	}
+	if v := c.Query("type"); v != "" {
+		where = append(where, "h.hold_type = ?")
+		args = append(args, v)
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT h.id, st.student_id, CONCAT(st.first_name, ' ', st.last_name), h.hold_type, h.reason,
+		       h.source, h.status, h.blocks_enrollment, h.blocks_documents, h.blocks_grades,
+		       DATE_FORMAT(h.placed_at, '%Y-%m-%d %H:%i:%s'),
+		       IFNULL(CONCAT(pu.first_name, ' ', pu.surname), 'System'),
+		       IFNULL(DATE_FORMAT(h.lifted_at, '%Y-%m-%d %H:%i:%s'), ''),
+		       IFNULL(h.lift_reason, ''),
+		       IFNULL(DATE_FORMAT(h.exempt_until, '%Y-%m-%d'), '')
+		FROM student_holds h
+		INNER JOIN students st ON st.id = h.student_id
+		LEFT JOIN users pu ON pu.id = h.placed_by
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY h.placed_at DESC
+		LIMIT 500
+	`, args...)
+	if err != nil {
+		fmt.Println("❌ Holds query error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch holds"})
+		return
+	}
+	defer rows.Close()
+
+	holds := []gin.H{}
+	for rows.Next() {
+		var (
+			h                                     studentHold
+			studentNo, name, holdStatus, placedBy string
+			liftedAt, liftReason, exemptUntil     string
+		)
+		if err := rows.Scan(&h.ID, &studentNo, &name, &h.HoldType, &h.Reason, &h.Source, &holdStatus,
+			&h.BlocksEnrollment, &h.BlocksDocuments, &h.BlocksGrades, &h.PlacedAt, &placedBy,
+			&liftedAt, &liftReason, &exemptUntil); err != nil {
+			continue
+		}
+
+		item := h.toJSON()
+		item["student_id"] = studentNo
+		item["student_name"] = name
+		item["status"] = holdStatus
+		item["placed_by"] = placedBy
+		item["lifted_at"] = liftedAt
+		item["lift_reason"] = liftReason
+		item["exempt_until"] = exemptUntil
+		holds = append(holds, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"holds": holds})
+}
+
+// POST /<role>/holds
+func StaffPlaceHold(c *gin.Context) {
+	var req struct {
+		StudentID string   `json:"student_id" binding:"required"`
+		HoldType  string   `json:"hold_type" binding:"required"`
+		Reason    string   `json:"reason" binding:"required"`
+		Blocks    []string `json:"blocks"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "student_id, hold_type and reason are required"})
+		return
+	}
+
+	req.HoldType = strings.ToLower(strings.TrimSpace(req.HoldType))
+	if _, ok := holdOffices[req.HoldType]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hold_type must be financial, library, disciplinary or registrar"})
+		return
+	}
+	if !canManageHold(c.GetString("role"), req.HoldType) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot place a " + req.HoldType + " hold"})
+		return
+	}
+
+	// Default: block everything
+	blocks := map[string]bool{holdScopeEnrollment: true, holdScopeDocuments: true, holdScopeGrades: true}
+	if len(req.Blocks) > 0 {
+		blocks = map[string]bool{}
+		for _, b := range req.Blocks {
+			switch b {
+			case holdScopeEnrollment, holdScopeDocuments, holdScopeGrades:
+				blocks[b] = true
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "blocks may only contain enrollment, documents, grades"})
+				return
+			}
+		}
+	}
+
+	var studentDBID int
+	if err := config.DB.QueryRow(`SELECT id FROM students WHERE student_id = ?`, req.StudentID).Scan(&studentDBID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		INSERT INTO student_holds
+		(student_id, hold_type, reason, source, blocks_enrollment, blocks_documents, blocks_grades, placed_by)
+		VALUES (?, ?, ?, 'manual', ?, ?, ?, ?)
+	`, studentDBID, req.HoldType, req.Reason, blocks[holdScopeEnrollment], blocks[holdScopeDocuments],
+		blocks[holdScopeGrades], c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to place hold"})
+		return
+	}
+
+	holdID, _ := res.LastInsertId()
+	fmt.Printf("✅ %s hold placed on %s by user %d\n", req.HoldType, req.StudentID, c.GetInt("user_id"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hold placed", "hold_id": holdID})
+}
+
+// POST /<role>/holds/:id/lift
+// Lifting an automatic financial hold exempts the student from it until
+// exempt_until (default 7 days), otherwise the next refresh would put it back.
+func StaffLiftHold(c *gin.Context) {
+	holdID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hold id"})
+		return
+	}
+
+	var req struct {
+		Reason      string `json:"reason" binding:"required"`
+		ExemptUntil string `json:"exempt_until"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	var holdType, source, status string
+	err = config.DB.QueryRow(`SELECT hold_type, source, status FROM student_holds WHERE id = ?`, holdID).
+		Scan(&holdType, &source, &status)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "hold not found"})
+		return
+	}
+	if status != "active" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hold is already lifted"})
+		return
+	}
+	if !canManageHold(c.GetString("role"), holdType) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot lift a " + holdType + " hold"})
+		return
+	}
+
+	var exemptUntil interface{}
+	if source == "auto" {
+		until := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+		if req.ExemptUntil != "" {
+			if _, err := time.Parse("2006-01-02", req.ExemptUntil); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "exempt_until must be YYYY-MM-DD"})
+				return
+			}
+			until = req.ExemptUntil
+		}
+		exemptUntil = until
+	}
+
+	_, err = config.DB.Exec(`
+		UPDATE student_holds
+		SET status = 'lifted', lifted_by = ?, lifted_at = NOW(), lift_reason = ?, exempt_until = ?
+		WHERE id = ?
+	`, c.GetInt("user_id"), req.Reason, exemptUntil, holdID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to lift hold"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hold lifted", "exempt_until": exemptUntil})
+}
+
+// ===== ADMIN: HOLD SETTINGS =====
+
+// GET /admin/settings/holds
+func AdminGetHoldSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"financial_hold_threshold": financialHoldThreshold()})
+}
+
+// PUT /admin/settings/holds
+func AdminUpdateHoldSettings(c *gin.Context) {
+	var req struct {
+		FinancialHoldThreshold *float64 `json:"financial_hold_threshold"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.FinancialHoldThreshold == nil || *req.FinancialHoldThreshold < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "financial_hold_threshold must be zero or more"})
+		return
+	}
+
+	_, err := config.DB.Exec(`
+		INSERT INTO system_settings (setting_key, setting_value, updated_by)
+		VALUES ('financial_hold_threshold', ?, ?)
+		ON DUPLICATE KEY UPDATE setting_value = VALUES(setting_value), updated_by = VALUES(updated_by)
+	`, strconv.FormatFloat(*req.FinancialHoldThreshold, 'f', 2, 64), c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save settings"})
+		return
+	}
+
+	// Apply the new threshold right away
+	n, err := RefreshFinancialHolds()
+	if err != nil {
+		fmt.Println("❌ Hold refresh error:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hold settings saved", "students_checked": n})
+}
@@ -0,0 +1,909 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== INSTALLMENT PLANS =====================
+//
+// A plan splits the balance left after the downpayment into ordered
+// installments. Each item carries a percentage and a term key; the due date of
+// an installment is taken from the term calendar for the payment's semester.
+
+var errNoInstallmentPlan = errors.New("no installment plan configured")
+
+type installmentPlanItem struct {
+	SequenceNo int     `json:"sequence_no"`
+	Term       string  `json:"term"`
+	Percentage float64 `json:"percentage"`
+}
+
+type installmentPlan struct {
+	ID                    int
+	Name                  string
+	Description           string
+	MinDownpaymentPercent float64
+	IsDefault             bool
+	IsActive              bool
+	Items                 []installmentPlanItem
+}
+
+type installmentAllocationRequest struct {
+	InstallmentID int     `json:"installment_id"`
+	Amount        float64 `json:"amount"`
+}
+
+// dbQuerier is satisfied by both *sql.DB and *sql.Tx.
+type dbQuerier interface {
+	QueryRow(string, ...interface{}) *sql.Row
+	Query(string, ...interface{}) (*sql.Rows, error)
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func loadInstallmentPlan(q dbQuerier, planID int) (installmentPlan, error) {
+	var p installmentPlan
+	err := q.QueryRow(`
+		SELECT id, name, IFNULL(description, ''), IFNULL(min_downpayment_percent, 0), is_default, is_active
+		FROM installment_plans
+		WHERE id = ?
+	`, planID).Scan(&p.ID, &p.Name, &p.Description, &p.MinDownpaymentPercent, &p.IsDefault, &p.IsActive)
+	if err != nil {
+		return p, err
+	}
+
+	rows, err := q.Query(`
+		SELECT sequence_no, term, percentage
+		FROM installment_plan_items
+		WHERE plan_id = ?
+		ORDER BY sequence_no
+	`, planID)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item installmentPlanItem
+		if err := rows.Scan(&item.SequenceNo, &item.Term, &item.Percentage); err != nil {
+			return p, err
+		}
+		p.Items = append(p.Items, item)
+	}
+
+	return p, nil
+}
+
+// resolvePaymentPlan returns the plan attached to a payment, or the default
+// plan when the student has not picked one.
+func resolvePaymentPlan(q dbQuerier, paymentID int) (installmentPlan, error) {
+	var planID sql.NullInt64
+	err := q.QueryRow(`SELECT plan_id FROM student_payments WHERE id = ?`, paymentID).Scan(&planID)
+	if err != nil {
+		return installmentPlan{}, err
+	}
+
+	if !planID.Valid {
+		err := q.QueryRow(`
+			SELECT id FROM installment_plans
+			WHERE is_default = TRUE AND is_active = TRUE
+			ORDER BY id
+			LIMIT 1
+		`).Scan(&planID)
+		if err == sql.ErrNoRows {
+			return installmentPlan{}, errNoInstallmentPlan
+		}
+		if err != nil {
+			return installmentPlan{}, err
+		}
+	}
+
+	plan, err := loadInstallmentPlan(q, int(planID.Int64))
+	if err != nil {
+		return plan, err
+	}
+	if len(plan.Items) == 0 {
+		return plan, errNoInstallmentPlan
+	}
+
+	return plan, nil
+}
+
+// createInstallmentsFromPlan creates the installment schedule of a payment.
+// It does nothing when the schedule already exists.
+func createInstallmentsFromPlan(tx *sql.Tx, paymentID int) error {
+	var existing int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM student_installments WHERE payment_id = ?`, paymentID).Scan(&existing); err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	var (
+		totalAmount, downpayment float64
+		semester, schoolYear     string
+	)
+	err := tx.QueryRow(`
+		SELECT total_amount, IFNULL(downpayment_amount, 0), IFNULL(semester, ''), IFNULL(school_year, '')
+		FROM student_payments
+		WHERE id = ?
+	`, paymentID).Scan(&totalAmount, &downpayment, &semester, &schoolYear)
+	if err != nil {
+		return err
+	}
+
+	plan, err := resolvePaymentPlan(tx, paymentID)
+	if err != nil {
+		return err
+	}
+
+	base := roundCents(totalAmount - downpayment)
+	if base <= 0 {
+		return nil
+	}
+
+	var totalInserted float64
+	for i, item := range plan.Items {
+		amount := roundCents(base * item.Percentage / 100)
+
+		// Last installment absorbs any rounding difference
+		if i == len(plan.Items)-1 {
+			amount = roundCents(base - totalInserted)
+		}
+
+		var dueDate sql.NullString
+		err := tx.QueryRow(`
+			SELECT DATE_FORMAT(due_date, '%Y-%m-%d')
+			FROM term_calendar
+			WHERE school_year = ? AND semester = ? AND term = ?
+		`, schoolYear, semester, item.Term).Scan(&dueDate)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO student_installments
+			(payment_id, term, sequence_no, amount, due_date, amount_paid, status)
+			VALUES (?, ?, ?, ?, ?, 0, 'unpaid')
+		`, paymentID, item.Term, item.SequenceNo, amount, dueDate)
+		if err != nil {
+			return err
+		}
+
+		totalInserted += amount
+	}
+
+	_, err = tx.Exec(`UPDATE student_payments SET plan_id = ? WHERE id = ? AND plan_id IS NULL`, plan.ID, paymentID)
+	return err
+}
+
+type openInstallment struct {
+	ID         int
+	Term       string
+	Amount     float64
+	AmountPaid float64
+}
+
+func (i openInstallment) balance() float64 {
+	return roundCents(i.Amount - i.AmountPaid)
+}
+
+// validateInstallmentAllocations checks cashier-chosen allocations against the
+// payment's schedule before anything is posted.
+func validateInstallmentAllocations(tx *sql.Tx, paymentID int, allocations []installmentAllocationRequest) error {
+	if len(allocations) == 0 {
+		return nil
+	}
+
+	var pendingTotal float64
+	err := tx.QueryRow(`
+		SELECT IFNULL(SUM(amount), 0)
+		FROM payment_transactions
+		WHERE payment_id = ? AND status = 'pending' AND kind = 'payment'
+	`, paymentID).Scan(&pendingTotal)
+	if err != nil {
+		return err
+	}
+
+	requested := map[int]float64{}
+	var total float64
+	for _, a := range allocations {
+		if a.Amount <= 0 {
+			return fmt.Errorf("allocation amount must be positive")
+		}
+
+		var amount, amountPaid float64
+		err := tx.QueryRow(`
+			SELECT amount + IFNULL(penalty_amount, 0), IFNULL(amount_paid, 0)
+			FROM student_installments
+			WHERE id = ? AND payment_id = ?
+		`, a.InstallmentID, paymentID).Scan(&amount, &amountPaid)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("installment %d does not belong to this payment", a.InstallmentID)
+		}
+		if err != nil {
+			return err
+		}
+
+		requested[a.InstallmentID] += a.Amount
+		if roundCents(requested[a.InstallmentID]) > roundCents(amount-amountPaid) {
+			return fmt.Errorf("allocation exceeds the balance of installment %d", a.InstallmentID)
+		}
+		total += a.Amount
+	}
+
+	if roundCents(total) > roundCents(pendingTotal) {
+		return fmt.Errorf("allocations exceed the submitted payment amount")
+	}
+
+	return nil
+}
+
+// allocateToInstallments applies the posted payment transactions to the
+// installment schedule: explicit cashier allocations first, then whatever is
+// left goes to the earliest-due open installments.
+func allocateToInstallments(tx *sql.Tx, paymentID, cashierID int, receipts []officialReceipt, explicit []installmentAllocationRequest) ([]gin.H, error) {
+	type source struct {
+		transactionID int
+		remaining     float64
+	}
+
+	var sources []*source
+	for _, r := range receipts {
+		if r.Kind == "downpayment" {
+			continue
+		}
+		sources = append(sources, &source{transactionID: r.TransactionID, remaining: roundCents(r.Amount)})
+	}
+	if len(sources) == 0 {
+		return []gin.H{}, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, term, amount + IFNULL(penalty_amount, 0), IFNULL(amount_paid, 0)
+		FROM student_installments
+		WHERE payment_id = ?
+		ORDER BY (due_date IS NULL), due_date, sequence_no, id
+		FOR UPDATE
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var installments []*openInstallment
+	byID := map[int]*openInstallment{}
+	for rows.Next() {
+		inst := &openInstallment{}
+		if err := rows.Scan(&inst.ID, &inst.Term, &inst.Amount, &inst.AmountPaid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		installments = append(installments, inst)
+		byID[inst.ID] = inst
+	}
+	rows.Close()
+
+	applied := []gin.H{}
+
+	apply := func(inst *openInstallment, amount float64) error {
+		for _, src := range sources {
+			if amount <= 0 {
+				break
+			}
+			if src.remaining <= 0 {
+				continue
+			}
+
+			portion := math.Min(src.remaining, amount)
+			_, err := tx.Exec(`
+				INSERT INTO installment_allocations (transaction_id, installment_id, amount, allocated_by)
+				VALUES (?, ?, ?, ?)
+			`, src.transactionID, inst.ID, portion, cashierID)
+			if err != nil {
+				return err
+			}
+
+			src.remaining = roundCents(src.remaining - portion)
+			inst.AmountPaid = roundCents(inst.AmountPaid + portion)
+			amount = roundCents(amount - portion)
+
+			applied = append(applied, gin.H{
+				"transaction_id": src.transactionID,
+				"installment_id": inst.ID,
+				"term":           inst.Term,
+				"amount":         portion,
+			})
+		}
+		return nil
+	}
+
+	for _, a := range explicit {
+		inst, ok := byID[a.InstallmentID]
+		if !ok {
+			return nil, fmt.Errorf("installment %d does not belong to this payment", a.InstallmentID)
+		}
+		if err := apply(inst, math.Min(a.Amount, inst.balance())); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, inst := range installments {
+		if inst.balance() <= 0 {
+			continue
+		}
+		if err := apply(inst, inst.balance()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, inst := range installments {
+		status := "unpaid"
+		if inst.balance() <= 0 {
+			status = "paid"
+		} else if inst.AmountPaid > 0 {
+			status = "partial"
+		}
+
+		_, err := tx.Exec(`
+			UPDATE student_installments
+			SET amount_paid = ?,
+				status = ?,
+				paid_at = IF(? = 'paid', IFNULL(paid_at, NOW()), NULL)
+			WHERE id = ?
+		`, inst.AmountPaid, status, status, inst.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return applied, nil
+}
+
+// loadInstallmentSchedule returns a payment's installments with balance and
+// overdue flags for display.
+func loadInstallmentSchedule(paymentID int) ([]gin.H, error) {
+	rows, err := config.DB.Query(`
+		SELECT
+			id,
+			IFNULL(sequence_no, 0),
+			term,
+			amount,
+			IFNULL(penalty_amount, 0),
+			IFNULL(amount_paid, 0),
+			status,
+			IFNULL(DATE_FORMAT(due_date, '%Y-%m-%d'), ''),
+			(status <> 'paid' AND due_date IS NOT NULL AND due_date < CURDATE()),
+			IFNULL(DATEDIFF(CURDATE(), due_date), 0),
+			paid_at
+		FROM student_installments
+		WHERE payment_id = ?
+		ORDER BY sequence_no, id
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	installments := []gin.H{}
+	for rows.Next() {
+		var (
+			id, sequenceNo, daysPastDue int
+			term, status, dueDate       string
+			amount, penalty, amountPaid float64
+			isOverdue                   bool
+			paidAt                      *string
+		)
+		if err := rows.Scan(&id, &sequenceNo, &term, &amount, &penalty, &amountPaid, &status, &dueDate, &isOverdue, &daysPastDue, &paidAt); err != nil {
+			return nil, err
+		}
+
+		item := gin.H{
+			"installment_id": id,
+			"sequence_no":    sequenceNo,
+			"term":           term,
+			"amount":         amount,
+			"penalty":        penalty,
+			"amount_paid":    amountPaid,
+			"balance":        roundCents(amount + penalty - amountPaid),
+			"status":         status,
+			"due_date":       nil,
+			"is_overdue":     isOverdue,
+			"days_overdue":   0,
+			"paid_at":        paidAt,
+		}
+		if dueDate != "" {
+			item["due_date"] = dueDate
+		}
+		if isOverdue {
+			item["days_overdue"] = daysPastDue
+		}
+		installments = append(installments, item)
+	}
+
+	return installments, nil
+}
+
+func planToJSON(p installmentPlan) gin.H {
+	items := p.Items
+	if items == nil {
+		items = []installmentPlanItem{}
+	}
+	return gin.H{
+		"plan_id":                 p.ID,
+		"name":                    p.Name,
+		"description":             p.Description,
+		"min_downpayment_percent": p.MinDownpaymentPercent,
+		"installment_count":       len(items),
+		"is_default":              p.IsDefault,
+		"is_active":               p.IsActive,
+		"items":                   items,
+	}
+}
+
+func listInstallmentPlans(activeOnly bool) ([]gin.H, error) {
+	query := `SELECT id FROM installment_plans`
+	if activeOnly {
+		query += ` WHERE is_active = TRUE`
+	}
+	query += ` ORDER BY is_default DESC, name`
+
+	rows, err := config.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	plans := []gin.H{}
+	for _, id := range ids {
+		p, err := loadInstallmentPlan(config.DB, id)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, planToJSON(p))
+	}
+
+	return plans, nil
+}
+
+// ===== ADMIN: INSTALLMENT PLANS =====
+
+type installmentPlanRequest struct {
+	Name                  string                `json:"name" binding:"required"`
+	Description           string                `json:"description"`
+	MinDownpaymentPercent float64               `json:"min_downpayment_percent"`
+	IsDefault             bool                  `json:"is_default"`
+	IsActive              *bool                 `json:"is_active"`
+	Items                 []installmentPlanItem `json:"items" binding:"required"`
+}
+
+func (req *installmentPlanRequest) validate() error {
+	if req.MinDownpaymentPercent < 0 || req.MinDownpaymentPercent >= 100 {
+		return fmt.Errorf("min_downpayment_percent must be between 0 and 100")
+	}
+	if len(req.Items) == 0 {
+		return fmt.Errorf("a plan needs at least one installment")
+	}
+
+	var total float64
+	seen := map[string]bool{}
+	for i := range req.Items {
+		item := &req.Items[i]
+		item.SequenceNo = i + 1
+		item.Term = strings.ToLower(strings.TrimSpace(item.Term))
+
+		if item.Term == "" {
+			return fmt.Errorf("installment %d needs a term", i+1)
+		}
+		if seen[item.Term] {
+			return fmt.Errorf("term %q appears more than once", item.Term)
+		}
+		if item.Percentage <= 0 {
+			return fmt.Errorf("installment %d needs a positive percentage", i+1)
+		}
+		seen[item.Term] = true
+		total += item.Percentage
+	}
+
+	if math.Abs(total-100) > 0.01 {
+		return fmt.Errorf("installment percentages must add up to 100 (got %.2f)", total)
+	}
+
+	return nil
+}
+
+func saveInstallmentPlanItems(tx *sql.Tx, planID int, items []installmentPlanItem) error {
+	if _, err := tx.Exec(`DELETE FROM installment_plan_items WHERE plan_id = ?`, planID); err != nil {
+		return err
+	}
+	for _, item := range items {
+		_, err := tx.Exec(`
+			INSERT INTO installment_plan_items (plan_id, sequence_no, term, percentage)
+			VALUES (?, ?, ?, ?)
+		`, planID, item.SequenceNo, item.Term, item.Percentage)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GET /admin/installment-plans
+func AdminGetInstallmentPlans(c *gin.Context) {
+	plans, err := listInstallmentPlans(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch installment plans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// POST /admin/installment-plans
+func AdminCreateInstallmentPlan(c *gin.Context) {
+	var req installmentPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	if req.IsDefault {
+		if _, err := tx.Exec(`UPDATE installment_plans SET is_default = FALSE`); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create installment plan"})
+			return
+		}
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO installment_plans (name, description, min_downpayment_percent, is_default, is_active, created_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, req.Name, req.Description, req.MinDownpaymentPercent, req.IsDefault, isActive, c.GetInt("user_id"))
+	if err != nil {
+		if dup, _ := handleDuplicateEntryError(err); dup {
+			c.JSON(http.StatusConflict, gin.H{"error": "a plan with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create installment plan"})
+		return
+	}
+
+	planID, _ := res.LastInsertId()
+	if err := saveInstallmentPlanItems(tx, int(planID), req.Items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save plan installments"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Installment plan created", "id": planID})
+}
+
+// PUT /admin/installment-plans/:id
+// The installment breakdown can only change while no payment uses the plan,
+// so existing schedules always match the plan they were built from.
+func AdminUpdateInstallmentPlan(c *gin.Context) {
+	planID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid plan id"})
+		return
+	}
+
+	var req installmentPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := loadInstallmentPlan(tx, planID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "installment plan not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	isActive := current.IsActive
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	if req.IsDefault && !isActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the default plan must be active"})
+		return
+	}
+
+	itemsChanged := len(current.Items) != len(req.Items)
+	for i := 0; !itemsChanged && i < len(req.Items); i++ {
+		itemsChanged = current.Items[i].Term != req.Items[i].Term ||
+			math.Abs(current.Items[i].Percentage-req.Items[i].Percentage) > 0.001
+	}
+
+	if itemsChanged {
+		var inUse int
+		tx.QueryRow(`SELECT COUNT(*) FROM student_payments WHERE plan_id = ?`, planID).Scan(&inUse)
+		if inUse > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "plan is already used by student payments; create a new plan instead"})
+			return
+		}
+		if err := saveInstallmentPlanItems(tx, planID, req.Items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save plan installments"})
+			return
+		}
+	}
+
+	if req.IsDefault {
+		if _, err := tx.Exec(`UPDATE installment_plans SET is_default = FALSE WHERE id <> ?`, planID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update installment plan"})
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE installment_plans
+		SET name = ?, description = ?, min_downpayment_percent = ?, is_default = ?, is_active = ?
+		WHERE id = ?
+	`, req.Name, req.Description, req.MinDownpaymentPercent, req.IsDefault, isActive, planID)
+	if err != nil {
+		if dup, _ := handleDuplicateEntryError(err); dup {
+			c.JSON(http.StatusConflict, gin.H{"error": "a plan with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update installment plan"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Installment plan updated"})
+}
+
+// ===== ADMIN: TERM CALENDAR =====
+
+// GET /admin/term-calendar?school_year=&semester=
+func AdminGetTermCalendar(c *gin.Context) {
+	query := `
+		SELECT id, school_year, semester, term,
+		       IFNULL(DATE_FORMAT(start_date, '%Y-%m-%d'), ''),
+		       DATE_FORMAT(due_date, '%Y-%m-%d')
+		FROM term_calendar
+		WHERE 1=1`
+	args := []interface{}{}
+
+	if sy := c.Query("school_year"); sy != "" {
+		query += " AND school_year = ?"
+		args = append(args, sy)
+	}
+	if sem := c.Query("semester"); sem != "" {
+		query += " AND semester = ?"
+		args = append(args, sem)
+	}
+	query += " ORDER BY school_year DESC, semester, due_date"
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch term calendar"})
+		return
+	}
+	defer rows.Close()
+
+	terms := []gin.H{}
+	for rows.Next() {
+		var id int
+		var schoolYear, semester, term, startDate, dueDate string
+		if err := rows.Scan(&id, &schoolYear, &semester, &term, &startDate, &dueDate); err != nil {
+			continue
+		}
+		terms = append(terms, gin.H{
+			"id":          id,
+			"school_year": schoolYear,
+			"semester":    semester,
+			"term":        term,
+			"start_date":  startDate,
+			"due_date":    dueDate,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"terms": terms})
+}
+
+// POST /admin/term-calendar
+// Creates or updates the dates of one term. Installments created afterwards
+// pick the due date up automatically; existing unpaid installments of the same
+// term are moved to the new date as well.
+func AdminSaveTermCalendar(c *gin.Context) {
+	var req struct {
+		SchoolYear string `json:"school_year" binding:"required"`
+		Semester   string `json:"semester" binding:"required"`
+		Term       string `json:"term" binding:"required"`
+		StartDate  string `json:"start_date"`
+		DueDate    string `json:"due_date" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	term := strings.ToLower(strings.TrimSpace(req.Term))
+	var startDate interface{}
+	if req.StartDate != "" {
+		startDate = req.StartDate
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO term_calendar (school_year, semester, term, start_date, due_date)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE start_date = VALUES(start_date), due_date = VALUES(due_date)
+	`, req.SchoolYear, req.Semester, term, startDate, req.DueDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dates, use YYYY-MM-DD"})
+		return
+	}
+
+	res, err := tx.Exec(`
+		UPDATE student_installments si
+		INNER JOIN student_payments sp ON sp.id = si.payment_id
+		SET si.due_date = ?
+		WHERE sp.school_year = ? AND sp.semester = ? AND si.term = ? AND si.status <> 'paid'
+	`, req.DueDate, req.SchoolYear, req.Semester, term)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update installment due dates"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, _ := res.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{
+		"message":              "Term calendar saved",
+		"installments_updated": updated,
+	})
+}
+
+// ===================== STUDENT INSTALLMENT PLANS =====================
+
+// GET /student/installment-plans
+func StudentGetInstallmentPlans(c *gin.Context) {
+	plans, err := listInstallmentPlans(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch installment plans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// ===================== CASHIER OVERDUE INSTALLMENTS =====================
+
+// GET /cashier/overdue-installments?school_year=&semester=
+func CashierGetOverdueInstallments(c *gin.Context) {
+	query := `
+		SELECT
+			si.id, si.payment_id, si.term, si.amount + IFNULL(si.penalty_amount, 0), IFNULL(si.amount_paid, 0),
+			DATE_FORMAT(si.due_date, '%Y-%m-%d'), DATEDIFF(CURDATE(), si.due_date),
+			st.student_id, CONCAT(st.first_name, ' ', st.last_name),
+			IFNULL(sp.semester, ''), IFNULL(sp.school_year, '')
+		FROM student_installments si
+		INNER JOIN student_payments sp ON sp.id = si.payment_id
+		INNER JOIN students st ON st.id = sp.student_id
+		WHERE si.status <> 'paid'
+		  AND si.due_date IS NOT NULL
+		  AND si.due_date < CURDATE()`
+	args := []interface{}{}
+
+	if sy := c.Query("school_year"); sy != "" {
+		query += " AND sp.school_year = ?"
+		args = append(args, sy)
+	}
+	if sem := c.Query("semester"); sem != "" {
+		query += " AND sp.semester = ?"
+		args = append(args, sem)
+	}
+	query += " ORDER BY si.due_date, st.last_name"
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch overdue installments"})
+		return
+	}
+	defer rows.Close()
+
+	overdue := []gin.H{}
+	var totalOverdue float64
+	for rows.Next() {
+		var (
+			id, paymentID, daysOverdue                           int
+			term, dueDate, studentID, name, semester, schoolYear string
+			amount, amountPaid                                   float64
+		)
+		if err := rows.Scan(&id, &paymentID, &term, &amount, &amountPaid, &dueDate, &daysOverdue,
+			&studentID, &name, &semester, &schoolYear); err != nil {
+			continue
+		}
+
+		balance := roundCents(amount - amountPaid)
+		totalOverdue += balance
+
+		overdue = append(overdue, gin.H{
+			"installment_id": id,
+			"payment_id":     paymentID,
+			"student_id":     studentID,
+			"student_name":   name,
+			"semester":       semester,
+			"school_year":    schoolYear,
+			"term":           term,
+			"amount":         amount,
+			"amount_paid":    amountPaid,
+			"balance":        balance,
+			"due_date":       dueDate,
+			"days_overdue":   daysOverdue,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"installments":  overdue,
+		"total":         len(overdue),
+		"total_balance": roundCents(totalOverdue),
+	})
+}
@@ -0,0 +1,770 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/gateway"
+	"student-portal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ===================== ONLINE PAYMENTS =====================
+//
+// Students pay through a hosted checkout. The provider's signed webhook posts
+// the transaction and issues the OR automatically, without cashier approval.
+// Settlement files are reconciled against the sessions we posted by provider
+// reference.
+
+// onlinePaymentsUsername owns the OR series of gateway-posted transactions.
+const onlinePaymentsUsername = "online-payments"
+
+var allowedOnlineMethods = map[string]bool{"gcash": true, "maya": true, "card": true}
+
+// onlinePaymentsUserID returns the system account that posts gateway
+// payments, creating it (inactive, random password) the first time.
+func onlinePaymentsUserID(tx *sql.Tx) (int, error) {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM users WHERE username = ?`, onlinePaymentsUsername).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO users (username, password, role, status)
+		VALUES (?, ?, 'system', 'inactive')
+	`, onlinePaymentsUsername, utils.HashPassword(uuid.New().String()))
+	if err != nil {
+		return 0, err
+	}
+
+	newID, _ := res.LastInsertId()
+	return int(newID), nil
+}
+
+// POST /student/payments/checkout
+func StudentCreateCheckout(c *gin.Context) {
+	var req struct {
+		PaymentID int    `json:"payment_id"`
+		Amount    int    `json:"amount"`
+		Method    string `json:"method"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	req.Method = strings.ToLower(req.Method)
+	if !allowedOnlineMethods[req.Method] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method must be gcash, maya or card"})
+		return
+	}
+
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid amount"})
+		return
+	}
+
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var studentDBID int
+	err := config.DB.QueryRow(`SELECT id FROM students WHERE student_id = ?`, studentStrID).Scan(&studentDBID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "student not found"})
+		return
+	}
+
+	var totalAmount, amountPaid int
+	var semester, schoolYear string
+	err = config.DB.QueryRow(`
+		SELECT total_amount, amount_paid, IFNULL(semester, ''), IFNULL(school_year, '')
+		FROM student_payments
+		WHERE id = ? AND student_id = ?
+	`, req.PaymentID, studentDBID).Scan(&totalAmount, &amountPaid, &semester, &schoolYear)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+		return
+	}
+
+	if amountPaid+req.Amount > totalAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment exceeds remaining balance"})
+		return
+	}
+
+	provider, err := gateway.Default()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "online payments are not available"})
+		return
+	}
+
+	reference := "UMPAY-" + strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", "")[:16])
+
+	session, err := provider.CreateCheckout(gateway.CheckoutRequest{
+		Reference:   reference,
+		Amount:      float64(req.Amount),
+		Currency:    "PHP",
+		Method:      req.Method,
+		Description: fmt.Sprintf("Tuition %s %s - %s", semester, schoolYear, studentStrID),
+	})
+	if err != nil {
+		fmt.Println("❌ Checkout creation error:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to create checkout session"})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		INSERT INTO checkout_sessions
+		(reference, provider, provider_ref, payment_id, student_id, amount, method, status, checkout_url, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 'open', ?, ?)
+	`, reference, provider.Name(), session.ProviderRef, req.PaymentID, studentDBID,
+		req.Amount, req.Method, session.CheckoutURL, session.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save checkout session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"reference":    reference,
+		"checkout_url": session.CheckoutURL,
+		"amount":       req.Amount,
+		"method":       req.Method,
+		"expires_at":   session.ExpiresAt.Format("2006-01-02 15:04:05"),
+		"status":       "open",
+	})
+}
+
+// GET /student/payments/checkout/:reference
+func StudentGetCheckout(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+
+	var (
+		amount                float64
+		method, status, orNum string
+	)
+	err := config.DB.QueryRow(`
+		SELECT cs.amount, IFNULL(cs.method, ''), cs.status, IFNULL(r.or_number, '')
+		FROM checkout_sessions cs
+		INNER JOIN students st ON st.id = cs.student_id
+		LEFT JOIN official_receipts r ON r.transaction_id = cs.transaction_id
+		WHERE cs.reference = ? AND st.student_id = ?
+	`, c.Param("reference"), studentStrID).Scan(&amount, &method, &status, &orNum)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "checkout not found"})
+		return
+	}
+
+	resp := gin.H{
+		"reference": c.Param("reference"),
+		"amount":    amount,
+		"method":    method,
+		"status":    status,
+	}
+	if orNum != "" {
+		resp["or_number"] = orNum
+		resp["download_url"] = "/student/payments/receipts/" + orNum
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// POST /payments/webhook/:provider
+// Public endpoint called by the provider. Authenticity comes from the
+// signature, and duplicates are dropped by event id.
+func PaymentGatewayWebhook(c *gin.Context) {
+	provider, err := gateway.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown provider"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+
+	status, resp := handleGatewayWebhook(provider, c.Request.Header, body)
+	c.JSON(status, resp)
+}
+
+func handleGatewayWebhook(provider gateway.Provider, header http.Header, body []byte) (int, gin.H) {
+	event, err := provider.VerifyWebhook(header, body)
+	if err != nil {
+		fmt.Println("❌ Webhook rejected:", err)
+		if errors.Is(err, gateway.ErrInvalidSignature) {
+			return http.StatusUnauthorized, gin.H{"error": "invalid signature"}
+		}
+		return http.StatusBadRequest, gin.H{"error": "invalid payload"}
+	}
+
+	res, err := config.DB.Exec(`
+		INSERT IGNORE INTO gateway_webhook_events (provider, event_id, event_type, provider_ref, payload)
+		VALUES (?, ?, ?, ?, ?)
+	`, provider.Name(), event.EventID, event.Type, event.ProviderRef, string(body))
+	if err != nil {
+		return http.StatusInternalServerError, gin.H{"error": "failed to record event"}
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return http.StatusOK, gin.H{"status": "duplicate"}
+	}
+
+	receipts, err := processGatewayEvent(provider.Name(), event)
+	if errors.Is(err, errCheckoutNotFound) {
+		config.DB.Exec(`
+			UPDATE gateway_webhook_events SET status = 'ignored', error = ?
+			WHERE provider = ? AND event_id = ?
+		`, err.Error(), provider.Name(), event.EventID)
+		return http.StatusOK, gin.H{"status": "ignored"}
+	}
+	if err != nil {
+		fmt.Printf("❌ Webhook %s failed: %v\n", event.EventID, err)
+
+		// Forget the event so the provider's retry is processed again
+		config.DB.Exec(`DELETE FROM gateway_webhook_events WHERE provider = ? AND event_id = ?`,
+			provider.Name(), event.EventID)
+		return http.StatusInternalServerError, gin.H{"error": "failed to process event"}
+	}
+
+	config.DB.Exec(`
+		UPDATE gateway_webhook_events SET status = 'processed'
+		WHERE provider = ? AND event_id = ?
+	`, provider.Name(), event.EventID)
+
+	for _, r := range receipts {
+		if _, err := ensureReceiptFile(r); err != nil {
+			fmt.Println("⚠️ Warning: could not generate receipt PDF:", err)
+		}
+	}
+
+	return http.StatusOK, gin.H{"status": "processed"}
+}
+
+var errCheckoutNotFound = errors.New("checkout session not found")
+
+// processGatewayEvent applies a verified event to its checkout session.
+func processGatewayEvent(providerName string, event gateway.WebhookEvent) ([]officialReceipt, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		sessionID, paymentID, studentID int
+		amount                          float64
+		method, status                  string
+	)
+	err = tx.QueryRow(`
+		SELECT id, payment_id, student_id, amount, IFNULL(method, ''), status
+		FROM checkout_sessions
+		WHERE provider = ? AND provider_ref = ?
+		FOR UPDATE
+	`, providerName, event.ProviderRef).Scan(&sessionID, &paymentID, &studentID, &amount, &method, &status)
+	if err == sql.ErrNoRows {
+		return nil, errCheckoutNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch event.Type {
+	case gateway.EventPaymentFailed, gateway.EventPaymentExpired:
+		newStatus := "failed"
+		if event.Type == gateway.EventPaymentExpired {
+			newStatus = "expired"
+		}
+		_, err := tx.Exec(`
+			UPDATE checkout_sessions SET status = ?, completed_at = NOW()
+			WHERE id = ? AND status = 'open'
+		`, newStatus, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit()
+
+	case gateway.EventPaymentSucceeded:
+		// handled below
+
+	default:
+		return nil, tx.Commit()
+	}
+
+	if status == "succeeded" {
+		return nil, tx.Commit()
+	}
+
+	// Never post an amount we did not ask for; the cashier resolves these manually
+	if math.Abs(event.Amount-amount) > 0.005 {
+		fmt.Printf("⚠️ Checkout %s amount mismatch: expected %.2f, got %.2f\n", event.ProviderRef, amount, event.Amount)
+		if _, err := tx.Exec(`UPDATE checkout_sessions SET status = 'amount_mismatch' WHERE id = ?`, sessionID); err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit()
+	}
+
+	var totalAmount, amountPaid int
+	var paymentStatus string
+	err = tx.QueryRow(`
+		SELECT total_amount, amount_paid, IFNULL(status, 'unpaid')
+		FROM student_payments WHERE id = ? FOR UPDATE
+	`, paymentID).Scan(&totalAmount, &amountPaid, &paymentStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.Method != "" {
+		method = event.Method
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO payment_transactions
+		(payment_id, student_id, amount, payment_method, kind, status, submitted_at)
+		VALUES (?, ?, ?, ?, 'payment', 'pending', NOW())
+	`, paymentID, studentID, amount, method)
+	if err != nil {
+		return nil, err
+	}
+	txnID, _ := res.LastInsertId()
+
+	systemUserID, err := onlinePaymentsUserID(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := postTransaction(tx, systemUserID, paymentID, pendingTransaction{
+		ID:            int(txnID),
+		StudentID:     studentID,
+		Amount:        amount,
+		PaymentMethod: method,
+		Kind:          "payment",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newAmountPaid := amountPaid + int(math.Round(amount))
+
+	// Manual submissions still waiting for the cashier keep the payment pending
+	var stillPending int
+	tx.QueryRow(`SELECT COUNT(*) FROM payment_transactions WHERE payment_id = ? AND status = 'pending'`, paymentID).Scan(&stillPending)
+
+	newStatus := "partial"
+	if stillPending > 0 {
+		newStatus = "pending"
+	} else if newAmountPaid >= totalAmount {
+		newStatus = "paid"
+	}
+
+	_, err = tx.Exec(`
+		UPDATE student_payments
+		SET amount_paid = ?, status = ?, payment_method = ?
+		WHERE id = ?
+	`, newAmountPaid, newStatus, method, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if newStatus == "partial" {
+		if err := createInstallmentsFromPlan(tx, paymentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := allocateToInstallments(tx, paymentID, systemUserID, []officialReceipt{receipt}, nil); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE checkout_sessions
+		SET status = 'succeeded', transaction_id = ?, completed_at = NOW()
+		WHERE id = ?
+	`, txnID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("✅ Online payment %s posted: ₱%.2f, OR %s\n", event.ProviderRef, amount, receipt.ORNumber)
+
+	return []officialReceipt{receipt}, nil
+}
+
+// ===================== FAKE PROVIDER (DEV) =====================
+//
+// Only routed when gateway.FakeEnabled (PAYMENT_FAKE_GATEWAY=true).
+
+// GET /payments/gateway/fake/checkout/:provider_ref
+// Stand-in for the provider's hosted page.
+func FakeGatewayCheckoutPage(c *gin.Context) {
+	var reference, status string
+	var amount float64
+	err := config.DB.QueryRow(`
+		SELECT reference, amount, status FROM checkout_sessions
+		WHERE provider = 'fake' AND provider_ref = ?
+	`, c.Param("provider_ref")).Scan(&reference, &amount, &status)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "checkout not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"provider":  "fake",
+		"reference": reference,
+		"amount":    amount,
+		"status":    status,
+		"simulate":  "POST /student/payments/checkout/" + reference + "/simulate {\"result\": \"success|failed|expired\"}",
+	})
+}
+
+// POST /student/payments/checkout/:reference/simulate
+// Makes the fake provider deliver a signed webhook for the session, going
+// through the same verification and posting path as a real provider.
+func StudentSimulateCheckout(c *gin.Context) {
+	var req struct {
+		Result string `json:"result"`
+	}
+	c.ShouldBindJSON(&req)
+
+	eventType := map[string]string{
+		"":        gateway.EventPaymentSucceeded,
+		"success": gateway.EventPaymentSucceeded,
+		"failed":  gateway.EventPaymentFailed,
+		"expired": gateway.EventPaymentExpired,
+	}[req.Result]
+	if eventType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "result must be success, failed or expired"})
+		return
+	}
+
+	var providerName, providerRef, method string
+	var amount float64
+	err := config.DB.QueryRow(`
+		SELECT cs.provider, cs.provider_ref, cs.amount, IFNULL(cs.method, '')
+		FROM checkout_sessions cs
+		INNER JOIN students st ON st.id = cs.student_id
+		WHERE cs.reference = ? AND st.student_id = ?
+	`, c.Param("reference"), c.GetString("student_id")).Scan(&providerName, &providerRef, &amount, &method)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "checkout not found"})
+		return
+	}
+
+	provider, err := gateway.Get(providerName)
+	fake, ok := provider.(*gateway.FakeProvider)
+	if err != nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "simulation is only available for the fake provider"})
+		return
+	}
+
+	body, header, err := fake.BuildWebhook(eventType, providerRef, c.Param("reference"), amount, method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, resp := handleGatewayWebhook(fake, header, body)
+	c.JSON(status, resp)
+}
+
+// ===================== CASHIER SETTLEMENT RECONCILIATION =====================
+
+// settlementWindowDays is how many days after a payment the provider may
+// take to settle it (T+1 and weekends included) before it counts as missing.
+func settlementWindowDays() int {
+	days, err := strconv.Atoi(getSetting("settlement_window_days", "3"))
+	if err != nil || days < 0 {
+		return 3
+	}
+	return days
+}
+
+// POST /cashier/settlements (multipart: provider, file)
+func CashierUploadSettlement(c *gin.Context) {
+	var provider gateway.Provider
+	var err error
+	if name := c.PostForm("provider"); name != "" {
+		provider, err = gateway.Get(name)
+	} else {
+		provider, err = gateway.Default()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "settlement file is required"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer f.Close()
+
+	lines, err := provider.ParseSettlement(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(lines) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "settlement file has no transactions"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO settlement_batches (provider, file_name, line_count, uploaded_by)
+		VALUES (?, ?, ?, ?)
+	`, provider.Name(), file.Filename, len(lines), c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create settlement batch"})
+		return
+	}
+	batchID64, _ := res.LastInsertId()
+	batchID := int(batchID64)
+
+	var (
+		matched, exceptions    int
+		totalAmount, totalFees float64
+		lastSettled            time.Time
+		exceptionLines         = []gin.H{}
+	)
+
+	for _, line := range lines {
+		totalAmount += line.Amount
+		totalFees += line.Fee
+		if line.SettledAt.After(lastSettled) {
+			lastSettled = line.SettledAt
+		}
+
+		var (
+			sessionID      sql.NullInt64
+			sessionAmount  float64
+			sessionStatus  string
+			sessionBatchID sql.NullInt64
+		)
+		err := tx.QueryRow(`
+			SELECT id, amount, status, settlement_batch_id
+			FROM checkout_sessions
+			WHERE provider = ? AND provider_ref = ?
+			FOR UPDATE
+		`, provider.Name(), line.ProviderRef).Scan(&sessionID, &sessionAmount, &sessionStatus, &sessionBatchID)
+
+		status, note := "matched", ""
+		switch {
+		case err == sql.ErrNoRows:
+			status, note = "unknown", "no checkout session with this provider reference"
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		case sessionStatus != "succeeded":
+			status, note = "not_posted", "session status is "+sessionStatus
+		case math.Abs(sessionAmount-line.Amount) > 0.005:
+			status, note = "amount_mismatch", fmt.Sprintf("posted %.2f, settled %.2f", sessionAmount, line.Amount)
+		case sessionBatchID.Valid:
+			status, note = "duplicate", fmt.Sprintf("already settled in batch %d", sessionBatchID.Int64)
+		}
+
+		if status == "matched" {
+			matched++
+			if _, err := tx.Exec(`UPDATE checkout_sessions SET settlement_batch_id = ? WHERE id = ?`, batchID, sessionID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		} else {
+			exceptions++
+			exceptionLines = append(exceptionLines, gin.H{
+				"provider_ref": line.ProviderRef,
+				"reference":    line.Reference,
+				"amount":       line.Amount,
+				"status":       status,
+				"note":         note,
+			})
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO settlement_lines
+			(batch_id, provider_ref, reference, amount, fee, net, settled_at, status, session_id, note)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, batchID, line.ProviderRef, line.Reference, line.Amount, line.Fee, line.Net,
+			line.SettledAt.Format("2006-01-02"), status, sessionID, note)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save settlement line"})
+			return
+		}
+	}
+
+	// Sessions we posted that no settlement has included, although the
+	// provider has settled past their window. Settlement runs days behind
+	// the payment, so payments of the settled dates themselves are not due yet
+	dueBefore := lastSettled.AddDate(0, 0, -settlementWindowDays()+1).Format("2006-01-02")
+	missingRows, err := tx.Query(`
+		SELECT reference, provider_ref, amount, DATE_FORMAT(completed_at, '%Y-%m-%d %H:%i:%s')
+		FROM checkout_sessions
+		WHERE provider = ? AND status = 'succeeded' AND settlement_batch_id IS NULL
+		  AND completed_at < ?
+		ORDER BY completed_at
+	`, provider.Name(), dueBefore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	missing := []gin.H{}
+	for missingRows.Next() {
+		var reference, providerRef, completedAt string
+		var amount float64
+		missingRows.Scan(&reference, &providerRef, &amount, &completedAt)
+		missing = append(missing, gin.H{
+			"reference":    reference,
+			"provider_ref": providerRef,
+			"amount":       amount,
+			"completed_at": completedAt,
+		})
+	}
+	missingRows.Close()
+
+	_, err = tx.Exec(`
+		UPDATE settlement_batches
+		SET matched_count = ?, exception_count = ?, missing_count = ?, total_amount = ?, total_fees = ?
+		WHERE id = ?
+	`, matched, exceptions, len(missing), roundCents(totalAmount), roundCents(totalFees), batchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"batch_id":     batchID,
+		"lines":        len(lines),
+		"matched":      matched,
+		"exceptions":   exceptionLines,
+		"missing":      missing,
+		"total_amount": roundCents(totalAmount),
+		"total_fees":   roundCents(totalFees),
+	})
+}
+
+// GET /cashier/settlements
+func CashierGetSettlements(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT id, provider, IFNULL(file_name, ''), line_count, matched_count, exception_count,
+		       missing_count, total_amount, total_fees, DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s')
+		FROM settlement_batches
+		ORDER BY id DESC
+		LIMIT 100
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch settlements"})
+		return
+	}
+	defer rows.Close()
+
+	batches := []gin.H{}
+	for rows.Next() {
+		var (
+			id, lineCount, matched, exceptions, missing int
+			provider, fileName, createdAt               string
+			totalAmount, totalFees                      float64
+		)
+		if err := rows.Scan(&id, &provider, &fileName, &lineCount, &matched, &exceptions,
+			&missing, &totalAmount, &totalFees, &createdAt); err != nil {
+			continue
+		}
+		batches = append(batches, gin.H{
+			"batch_id":     id,
+			"provider":     provider,
+			"file_name":    fileName,
+			"lines":        lineCount,
+			"matched":      matched,
+			"exceptions":   exceptions,
+			"missing":      missing,
+			"total_amount": totalAmount,
+			"total_fees":   totalFees,
+			"uploaded_at":  createdAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"batches": batches})
+}
+
+// GET /cashier/settlements/:id
+func CashierGetSettlementLines(c *gin.Context) {
+	batchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT IFNULL(provider_ref, ''), IFNULL(reference, ''), amount, fee, net,
+		       DATE_FORMAT(settled_at, '%Y-%m-%d'), status, IFNULL(note, '')
+		FROM settlement_lines
+		WHERE batch_id = ?
+		ORDER BY (status = 'matched'), id
+	`, batchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch settlement lines"})
+		return
+	}
+	defer rows.Close()
+
+	lines := []gin.H{}
+	for rows.Next() {
+		var providerRef, reference, settledAt, status, note string
+		var amount, fee, net float64
+		if err := rows.Scan(&providerRef, &reference, &amount, &fee, &net, &settledAt, &status, &note); err != nil {
+			continue
+		}
+		lines = append(lines, gin.H{
+			"provider_ref": providerRef,
+			"reference":    reference,
+			"amount":       amount,
+			"fee":          fee,
+			"net":          net,
+			"settled_at":   settledAt,
+			"status":       status,
+			"note":         note,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"batch_id": batchID,
+		"lines":    lines,
+	})
+}
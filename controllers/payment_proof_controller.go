@@ -0,0 +1,511 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/filestore"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== PROOF OF PAYMENT =====================
+//
+// Over-the-counter bank payments are backed by a deposit slip or transfer
+// screenshot attached to the pending transaction. Reference numbers and file
+// hashes are compared across all students so a reused slip is flagged to the
+// cashier before approval.
+
+var paymentProofPolicy = uploadPolicy{
+	maxSize:     10 * 1024 * 1024,
+	types:       []string{"image/jpeg", "image/png", "application/pdf"},
+	maxImageDim: maxImageDim,
+}
+
+// normalizeReference strips spacing and punctuation so "FT 123-456" and
+// "ft123456" are treated as the same bank reference.
+func normalizeReference(ref string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(ref) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func cleanUploadPath(p string) string {
+	clean := strings.ReplaceAll(p, "\\", "/")
+	return strings.TrimPrefix(clean, "./")
+}
+
+// findProofDuplicates lists other proofs sharing the reference number or the
+// exact same file.
+func findProofDuplicates(transactionID int, referenceKey, hash string) ([]gin.H, error) {
+	rows, err := config.DB.Query(`
+		SELECT pp.id, pp.transaction_id, pp.payment_id, st.student_id,
+		       CONCAT(st.first_name, ' ', st.last_name), pp.reference_no,
+		       pp.reference_key = ?, pp.sha256 = ?, pp.status
+		FROM payment_proofs pp
+		INNER JOIN students st ON st.id = pp.student_id
+		WHERE pp.transaction_id <> ? AND (pp.reference_key = ? OR pp.sha256 = ?)
+		ORDER BY pp.created_at
+	`, referenceKey, hash, transactionID, referenceKey, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	duplicates := []gin.H{}
+	for rows.Next() {
+		var (
+			proofID, txnID, paymentID    int
+			studentNo, name, ref, status string
+			sameReference, sameFile      bool
+		)
+		if err := rows.Scan(&proofID, &txnID, &paymentID, &studentNo, &name, &ref, &sameReference, &sameFile, &status); err != nil {
+			return nil, err
+		}
+
+		var matches []string
+		if sameReference {
+			matches = append(matches, "reference_no")
+		}
+		if sameFile {
+			matches = append(matches, "file")
+		}
+
+		duplicates = append(duplicates, gin.H{
+			"proof_id":       proofID,
+			"transaction_id": txnID,
+			"payment_id":     paymentID,
+			"student_id":     studentNo,
+			"student_name":   name,
+			"reference_no":   ref,
+			"status":         status,
+			"matched_on":     matches,
+		})
+	}
+
+	return duplicates, nil
+}
+
+// POST /student/payments/transactions/:id/proof
+// multipart: proof (file), reference_no, bank_name, deposit_date (YYYY-MM-DD)
+func StudentUploadPaymentProof(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	transactionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction id"})
+		return
+	}
+
+	referenceNo := strings.TrimSpace(c.PostForm("reference_no"))
+	referenceKey := normalizeReference(referenceNo)
+	if referenceKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reference_no is required"})
+		return
+	}
+
+	var depositDate interface{}
+	if d := strings.TrimSpace(c.PostForm("deposit_date")); d != "" {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "deposit_date must be YYYY-MM-DD"})
+			return
+		}
+		depositDate = d
+	}
+
+	var studentDBID, paymentID int
+	var txnStatus string
+	err = config.DB.QueryRow(`
+		SELECT pt.student_id, pt.payment_id, pt.status
+		FROM payment_transactions pt
+		INNER JOIN students st ON st.id = pt.student_id
+		WHERE pt.id = ? AND st.student_id = ?
+	`, transactionID, studentStrID).Scan(&studentDBID, &paymentID, &txnStatus)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	}
+
+	if txnStatus != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "proof can only be attached to a pending transaction"})
+		return
+	}
+
+	file, err := c.FormFile("proof")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
+		return
+	}
+
+	contentType, err := sniffUpload(formUpload{file}, paymentProofPolicy)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	// Hash the file as sent for duplicate detection; stored images are
+	// re-encoded by the pipeline
+	fileContent, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, fileContent)
+	fileContent.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file content"})
+		return
+	}
+	fileHash := hex.EncodeToString(hasher.Sum(nil))
+
+	uploadsDir := "uploads/payment_proofs"
+
+	newFilename := uploadFileName(fmt.Sprintf("proof_%d_%d", transactionID, time.Now().UnixNano()), contentType, file.Filename)
+	filePath := filepath.ToSlash(filepath.Join(uploadsDir, newFilename))
+
+	upload, err := processUpload(formUpload{file}, paymentProofPolicy, filePath)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	// Re-upload replaces the previous proof of the same transaction
+	var oldPath string
+	config.DB.QueryRow(`SELECT file_path FROM payment_proofs WHERE transaction_id = ?`, transactionID).Scan(&oldPath)
+
+	_, err = config.DB.Exec(`
+		INSERT INTO payment_proofs
+		(transaction_id, payment_id, student_id, reference_no, reference_key, bank_name, deposit_date,
+		 file_name, file_path, file_size, content_type, sha256, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'submitted')
+		ON DUPLICATE KEY UPDATE
+			reference_no = VALUES(reference_no), reference_key = VALUES(reference_key),
+			bank_name = VALUES(bank_name), deposit_date = VALUES(deposit_date),
+			file_name = VALUES(file_name), file_path = VALUES(file_path), file_size = VALUES(file_size),
+			content_type = VALUES(content_type), sha256 = VALUES(sha256),
+			status = 'submitted', review_note = NULL, reviewed_by = NULL, reviewed_at = NULL,
+			created_at = NOW()
+	`, transactionID, paymentID, studentDBID, referenceNo, referenceKey, c.PostForm("bank_name"), depositDate,
+		file.Filename, filePath, upload.Size, upload.ContentType, fileHash)
+	if err != nil {
+		fmt.Println("❌ Database insert error:", err)
+		removeUpload(upload.Path, upload.ThumbnailPath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save proof of payment"})
+		return
+	}
+
+	if oldPath != "" && oldPath != filePath {
+		filestore.Remove(oldPath)
+	}
+
+	duplicates, _ := findProofDuplicates(transactionID, referenceKey, fileHash)
+
+	fmt.Printf("✅ Proof of payment uploaded for transaction %d (ref %s)\n", transactionID, referenceNo)
+
+	resp := gin.H{
+		"message":        "proof of payment uploaded",
+		"transaction_id": transactionID,
+		"reference_no":   referenceNo,
+		"file_path":      cleanUploadPath(filePath),
+	}
+	if len(duplicates) > 0 {
+		// Students are only warned; the matching records are for the cashier
+		resp["warning"] = "this reference number or file was already submitted and will be checked by the cashier"
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /student/payments/transactions?payment_id=
+func StudentGetPaymentTransactions(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT pt.id, pt.payment_id, pt.amount, IFNULL(pt.payment_method, ''), pt.kind, pt.status,
+		       DATE_FORMAT(pt.submitted_at, '%Y-%m-%d %H:%i:%s'),
+		       IFNULL(pp.reference_no, ''), IFNULL(pp.status, ''), IFNULL(pp.review_note, '')
+		FROM payment_transactions pt
+		INNER JOIN students st ON st.id = pt.student_id
+		LEFT JOIN payment_proofs pp ON pp.transaction_id = pt.id
+		WHERE st.student_id = ? AND (? = '' OR pt.payment_id = ?)
+		ORDER BY pt.id DESC
+	`, studentStrID, c.Query("payment_id"), c.Query("payment_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transactions"})
+		return
+	}
+	defer rows.Close()
+
+	transactions := []gin.H{}
+	for rows.Next() {
+		var (
+			id, paymentID                        int
+			amount                               float64
+			method, kind, status, submittedAt    string
+			referenceNo, proofStatus, reviewNote string
+		)
+		if err := rows.Scan(&id, &paymentID, &amount, &method, &kind, &status, &submittedAt,
+			&referenceNo, &proofStatus, &reviewNote); err != nil {
+			continue
+		}
+
+		item := gin.H{
+			"transaction_id": id,
+			"payment_id":     paymentID,
+			"amount":         amount,
+			"payment_method": method,
+			"kind":           kind,
+			"status":         status,
+			"submitted_at":   submittedAt,
+			"proof":          nil,
+		}
+		if proofStatus != "" {
+			item["proof"] = gin.H{
+				"reference_no": referenceNo,
+				"status":       proofStatus,
+				"review_note":  reviewNote,
+			}
+		}
+		transactions = append(transactions, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transactions": transactions})
+}
+
+// loadPendingTransactionProofs returns the pending transactions of a payment
+// with their attached proof and any duplicate matches, for the cashier view.
+func loadPendingTransactionProofs(paymentID int) []gin.H {
+	transactions := []gin.H{}
+
+	rows, err := config.DB.Query(`
+		SELECT pt.id, pt.amount, IFNULL(pt.payment_method, ''), pt.kind,
+		       DATE_FORMAT(pt.submitted_at, '%Y-%m-%d %H:%i:%s'),
+		       IFNULL(pp.id, 0), IFNULL(pp.reference_no, ''), IFNULL(pp.reference_key, ''),
+		       IFNULL(pp.bank_name, ''), IFNULL(DATE_FORMAT(pp.deposit_date, '%Y-%m-%d'), ''),
+		       IFNULL(pp.file_path, ''), IFNULL(pp.content_type, ''), IFNULL(pp.sha256, ''),
+		       IFNULL(pp.status, '')
+		FROM payment_transactions pt
+		LEFT JOIN payment_proofs pp ON pp.transaction_id = pt.id
+		WHERE pt.payment_id = ? AND pt.status = 'pending'
+		ORDER BY pt.id
+	`, paymentID)
+	if err != nil {
+		fmt.Println("❌ Pending transaction query error:", err)
+		return transactions
+	}
+
+	type proofRow struct {
+		item               gin.H
+		proofID, txnID     int
+		referenceKey, hash string
+	}
+	var list []proofRow
+
+	for rows.Next() {
+		var (
+			txnID, proofID                                         int
+			amount                                                 float64
+			method, kind, submittedAt                              string
+			referenceNo, referenceKey, bank, depositDate, filePath string
+			contentType, hash, proofStatus                         string
+		)
+		if err := rows.Scan(&txnID, &amount, &method, &kind, &submittedAt, &proofID, &referenceNo, &referenceKey,
+			&bank, &depositDate, &filePath, &contentType, &hash, &proofStatus); err != nil {
+			continue
+		}
+
+		item := gin.H{
+			"transaction_id": txnID,
+			"amount":         amount,
+			"payment_method": method,
+			"kind":           kind,
+			"submitted_at":   submittedAt,
+			"proof":          nil,
+		}
+		if proofID > 0 {
+			item["proof"] = gin.H{
+				"proof_id":     proofID,
+				"reference_no": referenceNo,
+				"bank_name":    bank,
+				"deposit_date": depositDate,
+				"file_path":    cleanUploadPath(filePath),
+				"content_type": contentType,
+				"status":       proofStatus,
+			}
+		}
+		list = append(list, proofRow{item: item, proofID: proofID, txnID: txnID, referenceKey: referenceKey, hash: hash})
+	}
+	rows.Close()
+
+	for _, row := range list {
+		if row.proofID > 0 {
+			duplicates, err := findProofDuplicates(row.txnID, row.referenceKey, row.hash)
+			if err == nil {
+				proof := row.item["proof"].(gin.H)
+				proof["duplicates"] = duplicates
+				proof["flagged"] = len(duplicates) > 0
+			}
+		}
+		transactions = append(transactions, row.item)
+	}
+
+	return transactions
+}
+
+// POST /cashier/payment-proofs/:id/review
+// decision: verified | rejected. Rejecting with reject_transaction=true also
+// voids the submitted amount so the student can pay again.
+func CashierReviewPaymentProof(c *gin.Context) {
+	proofID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid proof id"})
+		return
+	}
+
+	var req struct {
+		Decision          string `json:"decision" binding:"required"`
+		Note              string `json:"note"`
+		RejectTransaction bool   `json:"reject_transaction"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decision is required"})
+		return
+	}
+
+	if req.Decision != "verified" && req.Decision != "rejected" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decision must be verified or rejected"})
+		return
+	}
+	if req.Decision == "rejected" && strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note is required when rejecting"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var (
+		transactionID, paymentID int
+		amount                   float64
+		kind, txnStatus          string
+	)
+	err = tx.QueryRow(`
+		SELECT pp.transaction_id, pt.payment_id, pt.amount, pt.kind, pt.status
+		FROM payment_proofs pp
+		INNER JOIN payment_transactions pt ON pt.id = pp.transaction_id
+		WHERE pp.id = ?
+		FOR UPDATE
+	`, proofID).Scan(&transactionID, &paymentID, &amount, &kind, &txnStatus)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proof not found"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE payment_proofs
+		SET status = ?, review_note = ?, reviewed_by = ?, reviewed_at = NOW()
+		WHERE id = ?
+	`, req.Decision, req.Note, c.GetInt("user_id"), proofID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review proof"})
+		return
+	}
+
+	transactionVoided := false
+	if req.Decision == "rejected" && req.RejectTransaction {
+		if txnStatus != "pending" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only pending transactions can be rejected"})
+			return
+		}
+
+		if err := voidPendingTransaction(tx, transactionID, paymentID, amount, kind); err != nil {
+			fmt.Println("❌ Transaction void error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reject transaction"})
+			return
+		}
+		transactionVoided = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":              "proof of payment " + req.Decision,
+		"transaction_rejected": transactionVoided,
+	})
+}
+
+// voidPendingTransaction takes back a submitted amount that was never received.
+func voidPendingTransaction(tx *sql.Tx, transactionID, paymentID int, amount float64, kind string) error {
+	if _, err := tx.Exec(`UPDATE payment_transactions SET status = 'rejected' WHERE id = ?`, transactionID); err != nil {
+		return err
+	}
+
+	query := `UPDATE student_payments SET amount_paid = GREATEST(IFNULL(amount_paid, 0) - ?, 0) WHERE id = ?`
+	if kind == "downpayment" {
+		query = `UPDATE student_payments SET amount_paid = GREATEST(IFNULL(amount_paid, 0) - ?, 0), downpayment_amount = 0 WHERE id = ?`
+	}
+	if _, err := tx.Exec(query, amount, paymentID); err != nil {
+		return err
+	}
+
+	// Other submissions still waiting keep the payment in the cashier queue
+	var stillPending int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM payment_transactions WHERE payment_id = ? AND status = 'pending'
+	`, paymentID).Scan(&stillPending); err != nil {
+		return err
+	}
+	if stillPending > 0 {
+		return nil
+	}
+
+	var amountPaid, totalAmount float64
+	var hasInstallments bool
+	if err := tx.QueryRow(`
+		SELECT IFNULL(amount_paid, 0), total_amount,
+		       EXISTS(SELECT 1 FROM student_installments WHERE payment_id = sp.id)
+		FROM student_payments sp
+		WHERE sp.id = ?
+	`, paymentID).Scan(&amountPaid, &totalAmount, &hasInstallments); err != nil {
+		return err
+	}
+
+	status := "unpaid"
+	switch {
+	case amountPaid >= totalAmount && totalAmount > 0:
+		status = "paid"
+	case hasInstallments || amountPaid > 0:
+		status = "partial"
+	}
+
+	_, err := tx.Exec(`UPDATE student_payments SET status = ? WHERE id = ?`, status, paymentID)
+	return err
+}
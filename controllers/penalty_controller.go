@@ -0,0 +1,512 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"student-portal/config"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== LATE PAYMENT PENALTIES =====================
+//
+// Overdue installments get surcharges from the active penalty rules. The job
+// is idempotent: each (installment, rule, period) is assessed at most once, so
+// it can run as often as needed. Penalties are rounded to whole pesos because
+// student_payments.total_amount is stored in pesos.
+
+type penaltyRule struct {
+	ID         int
+	Name       string
+	CalcType   string
+	Value      float64
+	GraceDays  int
+	Recurrence string
+	MaxTotal   sql.NullFloat64
+	IsActive   bool
+}
+
+type overdueInstallment struct {
+	ID          int
+	PaymentID   int
+	Amount      float64
+	DaysPastDue int
+}
+
+func loadPenaltyRules(activeOnly bool) ([]penaltyRule, error) {
+	query := `
+		SELECT id, name, calc_type, value, IFNULL(grace_days, 0), IFNULL(recurrence, 'once'), max_total, is_active
+		FROM penalty_rules`
+	if activeOnly {
+		query += ` WHERE is_active = TRUE`
+	}
+	query += ` ORDER BY id`
+
+	rows, err := config.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []penaltyRule
+	for rows.Next() {
+		var r penaltyRule
+		if err := rows.Scan(&r.ID, &r.Name, &r.CalcType, &r.Value, &r.GraceDays, &r.Recurrence, &r.MaxTotal, &r.IsActive); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// AssessLatePenalties charges every active rule against every overdue
+// installment and returns the number of new penalties.
+func AssessLatePenalties() (int, error) {
+	rules, err := loadPenaltyRules(true)
+	if err != nil {
+		return 0, err
+	}
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT si.id, si.payment_id, si.amount, DATEDIFF(CURDATE(), si.due_date)
+		FROM student_installments si
+		INNER JOIN student_payments sp ON sp.id = si.payment_id
+		WHERE si.status <> 'paid'
+		  AND sp.status <> 'paid'
+		  AND si.due_date IS NOT NULL
+		  AND si.due_date < CURDATE()
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	var overdue []overdueInstallment
+	for rows.Next() {
+		var inst overdueInstallment
+		if err := rows.Scan(&inst.ID, &inst.PaymentID, &inst.Amount, &inst.DaysPastDue); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		overdue = append(overdue, inst)
+	}
+	rows.Close()
+
+	assessed := 0
+	for _, inst := range overdue {
+		for _, rule := range rules {
+			daysLate := inst.DaysPastDue - rule.GraceDays
+			if daysLate <= 0 {
+				continue
+			}
+
+			periods := 1
+			if rule.Recurrence == "monthly" {
+				periods = 1 + (daysLate-1)/30
+			}
+
+			n, err := assessInstallmentPenalty(inst, rule, periods)
+			if err != nil {
+				fmt.Printf("❌ Penalty assessment failed for installment %d: %v\n", inst.ID, err)
+				continue
+			}
+			assessed += n
+		}
+	}
+
+	return assessed, nil
+}
+
+func assessInstallmentPenalty(inst overdueInstallment, rule penaltyRule, periods int) (int, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the installment so concurrent runs cannot double-charge
+	var locked int
+	if err := tx.QueryRow(`SELECT id FROM student_installments WHERE id = ? FOR UPDATE`, inst.ID).Scan(&locked); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT period_no, amount FROM installment_penalties
+		WHERE installment_id = ? AND rule_id = ?
+	`, inst.ID, rule.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	existing := map[int]bool{}
+	var chargedTotal float64
+	for rows.Next() {
+		var period int
+		var amount float64
+		rows.Scan(&period, &amount)
+		existing[period] = true
+		chargedTotal += amount
+	}
+	rows.Close()
+
+	assessed := 0
+	for period := 0; period < periods; period++ {
+		if existing[period] {
+			continue
+		}
+
+		amount := rule.Value
+		if rule.CalcType == "percent" {
+			amount = inst.Amount * rule.Value / 100
+		}
+		amount = math.Round(amount)
+
+		if rule.MaxTotal.Valid {
+			amount = math.Min(amount, math.Floor(rule.MaxTotal.Float64-chargedTotal))
+		}
+		if amount <= 0 {
+			break
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO installment_penalties (installment_id, rule_id, period_no, amount, status, assessed_at)
+			VALUES (?, ?, ?, ?, 'assessed', NOW())
+		`, inst.ID, rule.ID, period, amount)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE student_installments
+			SET penalty_amount = IFNULL(penalty_amount, 0) + ?
+			WHERE id = ?
+		`, amount, inst.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE student_payments
+			SET total_amount = total_amount + ?
+			WHERE id = ?
+		`, amount, inst.PaymentID)
+		if err != nil {
+			return 0, err
+		}
+
+		chargedTotal += amount
+		assessed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return assessed, nil
+}
+
+// StartPenaltyJob runs the penalty assessment immediately and then on every
+// interval. Meant to be started once from main in its own goroutine.
+func StartPenaltyJob(interval time.Duration) {
+	for {
+		n, err := AssessLatePenalties()
+		if err != nil {
+			fmt.Println("❌ Penalty job error:", err)
+		} else if n > 0 {
+			fmt.Printf("✅ Penalty job: %d late payment penalties assessed\n", n)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// ===== ADMIN: PENALTY RULES =====
+
+type penaltyRuleRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	CalcType   string   `json:"calc_type" binding:"required"`
+	Value      float64  `json:"value"`
+	GraceDays  int      `json:"grace_days"`
+	Recurrence string   `json:"recurrence"`
+	MaxTotal   *float64 `json:"max_total"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+func (req *penaltyRuleRequest) validate() error {
+	if req.CalcType != "fixed" && req.CalcType != "percent" {
+		return fmt.Errorf("calc_type must be 'fixed' or 'percent'")
+	}
+	if req.Value <= 0 {
+		return fmt.Errorf("value must be positive")
+	}
+	if req.CalcType == "percent" && req.Value > 100 {
+		return fmt.Errorf("percent value cannot exceed 100")
+	}
+	if req.GraceDays < 0 {
+		return fmt.Errorf("grace_days cannot be negative")
+	}
+	if req.Recurrence == "" {
+		req.Recurrence = "once"
+	}
+	if req.Recurrence != "once" && req.Recurrence != "monthly" {
+		return fmt.Errorf("recurrence must be 'once' or 'monthly'")
+	}
+	if req.MaxTotal != nil && *req.MaxTotal <= 0 {
+		return fmt.Errorf("max_total must be positive")
+	}
+	return nil
+}
+
+// GET /admin/penalty-rules
+func AdminGetPenaltyRules(c *gin.Context) {
+	rules, err := loadPenaltyRules(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch penalty rules"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, r := range rules {
+		item := gin.H{
+			"id":         r.ID,
+			"name":       r.Name,
+			"calc_type":  r.CalcType,
+			"value":      r.Value,
+			"grace_days": r.GraceDays,
+			"recurrence": r.Recurrence,
+			"max_total":  nil,
+			"is_active":  r.IsActive,
+		}
+		if r.MaxTotal.Valid {
+			item["max_total"] = r.MaxTotal.Float64
+		}
+		result = append(result, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": result})
+}
+
+// POST /admin/penalty-rules
+func AdminCreatePenaltyRule(c *gin.Context) {
+	var req penaltyRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	res, err := config.DB.Exec(`
+		INSERT INTO penalty_rules (name, calc_type, value, grace_days, recurrence, max_total, is_active)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, req.Name, req.CalcType, req.Value, req.GraceDays, req.Recurrence, req.MaxTotal, isActive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create penalty rule"})
+		return
+	}
+
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"message": "Penalty rule created", "id": id})
+}
+
+// PUT /admin/penalty-rules/:id
+// Changes apply to future assessments only; penalties already charged stay.
+func AdminUpdatePenaltyRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	var req penaltyRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	res, err := config.DB.Exec(`
+		UPDATE penalty_rules
+		SET name = ?, calc_type = ?, value = ?, grace_days = ?, recurrence = ?, max_total = ?, is_active = ?
+		WHERE id = ?
+	`, req.Name, req.CalcType, req.Value, req.GraceDays, req.Recurrence, req.MaxTotal, isActive, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update penalty rule"})
+		return
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		config.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM penalty_rules WHERE id = ?)`, id).Scan(&exists)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "penalty rule not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Penalty rule updated"})
+}
+
+// POST /admin/penalty-rules/run
+// Runs the assessment now instead of waiting for the scheduled job.
+func AdminRunPenaltyAssessment(c *gin.Context) {
+	n, err := AssessLatePenalties()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assess penalties"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Penalty assessment completed",
+		"assessed": n,
+	})
+}
+
+// ===================== CASHIER PENALTIES =====================
+
+// GET /cashier/penalties?payment_id=
+func CashierGetPenalties(c *gin.Context) {
+	paymentID := c.Query("payment_id")
+	if paymentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment_id is required"})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT ip.id, si.term, pr.name, ip.period_no, ip.amount, ip.status,
+		       IFNULL(ip.waive_reason, ''),
+		       DATE_FORMAT(ip.assessed_at, '%Y-%m-%d %H:%i:%s')
+		FROM installment_penalties ip
+		INNER JOIN student_installments si ON si.id = ip.installment_id
+		INNER JOIN penalty_rules pr ON pr.id = ip.rule_id
+		WHERE si.payment_id = ?
+		ORDER BY si.sequence_no, ip.assessed_at
+	`, paymentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch penalties"})
+		return
+	}
+	defer rows.Close()
+
+	penalties := []gin.H{}
+	for rows.Next() {
+		var (
+			id, periodNo                               int
+			term, ruleName, status, reason, assessedAt string
+			amount                                     float64
+		)
+		if err := rows.Scan(&id, &term, &ruleName, &periodNo, &amount, &status, &reason, &assessedAt); err != nil {
+			continue
+		}
+		penalties = append(penalties, gin.H{
+			"penalty_id":   id,
+			"term":         term,
+			"rule":         ruleName,
+			"period_no":    periodNo,
+			"amount":       amount,
+			"status":       status,
+			"waive_reason": reason,
+			"assessed_at":  assessedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"penalties": penalties})
+}
+
+// POST /cashier/penalties/:id/waive
+func CashierWaivePenalty(c *gin.Context) {
+	penaltyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid penalty id"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var installmentID, paymentID int
+	var amount float64
+	err = tx.QueryRow(`
+		SELECT ip.installment_id, si.payment_id, ip.amount
+		FROM installment_penalties ip
+		INNER JOIN student_installments si ON si.id = ip.installment_id
+		WHERE ip.id = ? AND ip.status = 'assessed'
+		FOR UPDATE
+	`, penaltyID).Scan(&installmentID, &paymentID, &amount)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "penalty not found or already waived"})
+		return
+	}
+
+	queries := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE installment_penalties
+		  SET status = 'waived', waived_by = ?, waive_reason = ?, waived_at = NOW()
+		  WHERE id = ?`, []interface{}{c.GetInt("user_id"), req.Reason, penaltyID}},
+		{`UPDATE student_installments
+		  SET penalty_amount = GREATEST(IFNULL(penalty_amount, 0) - ?, 0)
+		  WHERE id = ?`, []interface{}{amount, installmentID}},
+		{`UPDATE student_installments
+		  SET status = 'paid', paid_at = IFNULL(paid_at, NOW())
+		  WHERE id = ? AND amount + penalty_amount - amount_paid <= 0.005`, []interface{}{installmentID}},
+		{`UPDATE student_payments
+		  SET total_amount = total_amount - ?
+		  WHERE id = ?`, []interface{}{amount, paymentID}},
+		{`UPDATE student_payments
+		  SET status = 'paid'
+		  WHERE id = ? AND status = 'partial' AND amount_paid >= total_amount`, []interface{}{paymentID}},
+	}
+
+	for _, q := range queries {
+		if _, err := tx.Exec(q.query, q.args...); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to waive penalty"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Penalty waived",
+		"amount":  amount,
+	})
+}
@@ -0,0 +1,799 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"student-portal/config"
+	"student-portal/filestore"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ===================== OFFICIAL RECEIPTS =====================
+//
+// Every transaction posted by a cashier gets exactly one official receipt (OR).
+// OR numbers come from the cashier's active series and are allocated inside the
+// same DB transaction that posts the payment, so a failed posting never burns a
+// number and the sequence stays gap-free.
+
+var errORSeriesExhausted = errors.New("official receipt series exhausted")
+
+type officialReceipt struct {
+	ID            int
+	ORNumber      string
+	SeriesCode    string
+	TransactionID int
+	PaymentID     int
+	StudentID     int
+	StudentNumber string
+	ReceivedFrom  string
+	CashierID     int
+	CashierName   string
+	Amount        float64
+	PaymentMethod string
+	Kind          string
+	Semester      string
+	SchoolYear    string
+	FilePath      string
+	ReprintCount  int
+	IssuedAt      time.Time
+}
+
+type pendingTransaction struct {
+	ID            int
+	StudentID     int
+	Amount        float64
+	PaymentMethod string
+	Kind          string
+}
+
+// postPendingTransactions marks every pending transaction of a payment as
+// posted and issues an official receipt for each. Payments submitted before
+// transactions were recorded get a single transaction for the unreceipted
+// difference so they still produce a receipt. The caller owns tx so the
+// posting commits together with the rest of the approval.
+func postPendingTransactions(tx *sql.Tx, paymentID, cashierID int) ([]officialReceipt, error) {
+	rows, err := tx.Query(`
+		SELECT id, student_id, amount, IFNULL(payment_method, ''), IFNULL(kind, 'payment')
+		FROM payment_transactions
+		WHERE payment_id = ? AND status = 'pending'
+		ORDER BY id
+		FOR UPDATE
+	`, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []pendingTransaction
+	for rows.Next() {
+		var t pendingTransaction
+		if err := rows.Scan(&t.ID, &t.StudentID, &t.Amount, &t.PaymentMethod, &t.Kind); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pending = append(pending, t)
+	}
+	rows.Close()
+
+	// Legacy submissions (before payment_transactions existed)
+	if len(pending) == 0 {
+		var (
+			studentID          int
+			amountPaid         float64
+			downpaymentAmount  float64
+			method             string
+			postedTotal        float64
+			postedTransactions int
+		)
+
+		err := tx.QueryRow(`
+			SELECT student_id, amount_paid, IFNULL(downpayment_amount, 0), IFNULL(payment_method, '')
+			FROM student_payments
+			WHERE id = ?
+		`, paymentID).Scan(&studentID, &amountPaid, &downpaymentAmount, &method)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.QueryRow(`
+			SELECT IFNULL(SUM(amount), 0), COUNT(*)
+			FROM payment_transactions
+			WHERE payment_id = ? AND status = 'posted' AND IFNULL(kind, 'payment') <> 'document_fee'
+		`, paymentID).Scan(&postedTotal, &postedTransactions)
+		if err != nil {
+			return nil, err
+		}
+
+		delta := amountPaid - postedTotal
+		if delta > 0 {
+			kind := "payment"
+			if postedTransactions == 0 && downpaymentAmount > 0 {
+				kind = "downpayment"
+			}
+
+			res, err := tx.Exec(`
+				INSERT INTO payment_transactions
+				(payment_id, student_id, amount, payment_method, kind, status, submitted_at)
+				VALUES (?, ?, ?, ?, ?, 'pending', NOW())
+			`, paymentID, studentID, delta, method, kind)
+			if err != nil {
+				return nil, err
+			}
+
+			newID, _ := res.LastInsertId()
+			pending = append(pending, pendingTransaction{
+				ID:            int(newID),
+				StudentID:     studentID,
+				Amount:        delta,
+				PaymentMethod: method,
+				Kind:          kind,
+			})
+		}
+	}
+
+	var receipts []officialReceipt
+	for _, t := range pending {
+		receipt, err := postTransaction(tx, cashierID, paymentID, t)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, nil
+}
+
+// postTransaction posts a single transaction and issues its receipt.
+func postTransaction(tx *sql.Tx, cashierID, paymentID int, t pendingTransaction) (officialReceipt, error) {
+	_, err := tx.Exec(`
+		UPDATE payment_transactions
+		SET status = 'posted', posted_at = NOW(), posted_by = ?
+		WHERE id = ?
+	`, cashierID, t.ID)
+	if err != nil {
+		return officialReceipt{}, err
+	}
+
+	return issueOfficialReceipt(tx, cashierID, paymentID, t)
+}
+
+// issueOfficialReceipt allocates the next OR number from the cashier's active
+// series (locking the series row) and records the receipt.
+func issueOfficialReceipt(tx *sql.Tx, cashierID, paymentID int, t pendingTransaction) (officialReceipt, error) {
+	var (
+		seriesID   int
+		seriesCode string
+		prefix     string
+		nextNumber int
+		endNumber  sql.NullInt64
+	)
+
+	err := tx.QueryRow(`
+		SELECT id, series_code, IFNULL(prefix, ''), next_number, end_number
+		FROM or_series
+		WHERE cashier_id = ? AND is_active = TRUE
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE
+	`, cashierID).Scan(&seriesID, &seriesCode, &prefix, &nextNumber, &endNumber)
+
+	if err == sql.ErrNoRows {
+		// First receipt of a cashier without a registered series
+		var seriesCount int
+		tx.QueryRow(`SELECT COUNT(*) FROM or_series WHERE cashier_id = ?`, cashierID).Scan(&seriesCount)
+		if seriesCount > 0 {
+			return officialReceipt{}, errORSeriesExhausted
+		}
+
+		seriesCode = fmt.Sprintf("CSH%03d", cashierID)
+		prefix = fmt.Sprintf("%03d-", cashierID)
+		res, err := tx.Exec(`
+			INSERT INTO or_series (series_code, cashier_id, prefix, start_number, next_number, is_active)
+			VALUES (?, ?, ?, 1, 1, TRUE)
+		`, seriesCode, cashierID, prefix)
+		if err != nil {
+			return officialReceipt{}, err
+		}
+		newID, _ := res.LastInsertId()
+		seriesID = int(newID)
+		nextNumber = 1
+	} else if err != nil {
+		return officialReceipt{}, err
+	}
+
+	if endNumber.Valid && int64(nextNumber) > endNumber.Int64 {
+		return officialReceipt{}, errORSeriesExhausted
+	}
+
+	var studentNumber, firstName, middleName, lastName string
+	err = tx.QueryRow(`
+		SELECT IFNULL(student_id, ''), IFNULL(first_name, ''), IFNULL(middle_name, ''), IFNULL(last_name, '')
+		FROM students WHERE id = ?
+	`, t.StudentID).Scan(&studentNumber, &firstName, &middleName, &lastName)
+	if err != nil {
+		return officialReceipt{}, err
+	}
+
+	receivedFrom := strings.Join(strings.Fields(firstName+" "+middleName+" "+lastName), " ")
+	orNumber := fmt.Sprintf("%s%07d", prefix, nextNumber)
+
+	res, err := tx.Exec(`
+		INSERT INTO official_receipts
+		(or_number, series_id, sequence_no, transaction_id, payment_id, student_id,
+		 student_number, received_from, cashier_id, amount, payment_method, issued_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	`, orNumber, seriesID, nextNumber, t.ID, paymentID, t.StudentID,
+		studentNumber, receivedFrom, cashierID, t.Amount, t.PaymentMethod)
+	if err != nil {
+		return officialReceipt{}, err
+	}
+
+	_, err = tx.Exec(`UPDATE or_series SET next_number = ? WHERE id = ?`, nextNumber+1, seriesID)
+	if err != nil {
+		return officialReceipt{}, err
+	}
+
+	receiptID, _ := res.LastInsertId()
+
+	return officialReceipt{
+		ID:            int(receiptID),
+		ORNumber:      orNumber,
+		SeriesCode:    seriesCode,
+		TransactionID: t.ID,
+		PaymentID:     paymentID,
+		StudentID:     t.StudentID,
+		StudentNumber: studentNumber,
+		ReceivedFrom:  receivedFrom,
+		CashierID:     cashierID,
+		Amount:        t.Amount,
+		PaymentMethod: t.PaymentMethod,
+		Kind:          t.Kind,
+		IssuedAt:      time.Now(),
+	}, nil
+}
+
+const receiptSelect = `
+	SELECT
+		r.id, r.or_number, s.series_code, r.transaction_id, r.payment_id, r.student_id,
+		IFNULL(r.student_number, ''), IFNULL(r.received_from, ''),
+		r.cashier_id, IFNULL(u.username, ''),
+		r.amount, IFNULL(r.payment_method, ''), IFNULL(pt.kind, 'payment'),
+		IFNULL(sp.semester, ''), IFNULL(sp.school_year, ''),
+		IFNULL(r.file_path, ''), r.reprint_count, r.issued_at
+	FROM official_receipts r
+	INNER JOIN or_series s ON s.id = r.series_id
+	LEFT JOIN users u ON u.id = r.cashier_id
+	LEFT JOIN payment_transactions pt ON pt.id = r.transaction_id
+	LEFT JOIN student_payments sp ON sp.id = r.payment_id
+`
+
+func scanReceipt(scanner interface{ Scan(...interface{}) error }) (officialReceipt, error) {
+	var r officialReceipt
+	err := scanner.Scan(
+		&r.ID, &r.ORNumber, &r.SeriesCode, &r.TransactionID, &r.PaymentID, &r.StudentID,
+		&r.StudentNumber, &r.ReceivedFrom, &r.CashierID, &r.CashierName,
+		&r.Amount, &r.PaymentMethod, &r.Kind, &r.Semester, &r.SchoolYear,
+		&r.FilePath, &r.ReprintCount, &r.IssuedAt,
+	)
+	return r, err
+}
+
+func loadReceiptByNumber(orNumber string) (officialReceipt, error) {
+	return scanReceipt(config.DB.QueryRow(receiptSelect+` WHERE r.or_number = ?`, orNumber))
+}
+
+// ensureReceiptFile returns the stored PDF of an original receipt,
+// regenerating it when the file was never written or has been lost.
+func ensureReceiptFile(r officialReceipt) (string, error) {
+	if r.FilePath != "" {
+		if _, err := filestore.Stat(r.FilePath); err == nil {
+			return r.FilePath, nil
+		}
+	}
+
+	uploadsDir := "uploads/receipts"
+
+	fileName := fmt.Sprintf("OR_%s_%s.pdf", r.ORNumber, strings.ReplaceAll(uuid.New().String(), "-", ""))
+	filePath := filepath.Join(uploadsDir, fileName)
+
+	if err := generateOfficialReceipt(r, filePath, false); err != nil {
+		return "", err
+	}
+
+	_, err := config.DB.Exec(`UPDATE official_receipts SET file_path = ? WHERE id = ?`, filePath, r.ID)
+	if err != nil {
+		return "", err
+	}
+
+	return filePath, nil
+}
+
+func receiptPurpose(r officialReceipt) string {
+	if r.Kind == "document_fee" {
+		return "Document request fee"
+	}
+	purpose := "Tuition and school fees"
+	if r.Kind == "downpayment" {
+		purpose += " (downpayment)"
+	}
+	if r.Semester != "" {
+		purpose += " - " + r.Semester + " Semester"
+	}
+	if r.SchoolYear != "" {
+		purpose += ", SY " + r.SchoolYear
+	}
+	return purpose
+}
+
+func generateOfficialReceipt(r officialReceipt, outputPath string, reprint bool) error {
+	pdf := gofpdf.New("L", "mm", "A5", "")
+	pdf.AddPage()
+
+	writeUniversityHeader(pdf)
+
+	if tin := os.Getenv("SCHOOL_TIN"); tin != "" {
+		pdf.SetFont("Arial", "", 9)
+		pdf.SetY(pdf.GetY() - 6)
+		pdf.Cell(0, 4, "Non-VAT Reg. TIN: "+tin)
+		pdf.Ln(6)
+	}
+
+	// Title + OR number
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(110, 8, "OFFICIAL RECEIPT")
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(20, 8, "No.")
+	pdf.SetFont("Arial", "B", 13)
+	pdf.SetTextColor(190, 30, 45)
+	pdf.Cell(0, 8, r.ORNumber)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(10)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(110, 6, "")
+	pdf.Cell(20, 6, "Date:")
+	pdf.SetFont("Arial", "B", 10)
+	pdf.Cell(0, 6, r.IssuedAt.Format("January 02, 2006"))
+	pdf.Ln(9)
+
+	line := func(label, value string) {
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(40, 7, label)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(0, 7, value, "B", 1, "L", false, 0, "")
+		pdf.Ln(1)
+	}
+
+	line("Received from:", r.ReceivedFrom)
+	line("Student Number:", r.StudentNumber)
+	line("The sum of:", amountInWords(r.Amount))
+	line("Amount:", "PHP "+formatPeso(r.Amount))
+	line("In payment of:", receiptPurpose(r))
+	line("Payment method:", r.PaymentMethod)
+	pdf.Ln(6)
+
+	// Cashier signature
+	pdf.SetFont("Arial", "", 10)
+	pdf.SetX(130)
+	pdf.Cell(0, 5, "______________________________")
+	pdf.Ln(5)
+	pdf.SetX(130)
+	pdf.SetFont("Arial", "B", 9)
+	pdf.Cell(0, 5, r.CashierName)
+	pdf.Ln(4)
+	pdf.SetX(130)
+	pdf.SetFont("Arial", "", 8)
+	pdf.Cell(0, 4, "Cashier / Authorized Representative")
+	pdf.Ln(8)
+
+	pdf.SetFont("Arial", "I", 7)
+	pdf.SetTextColor(100, 100, 100)
+	pdf.Cell(0, 4, fmt.Sprintf("Series %s | Transaction #%d | Issued %s",
+		r.SeriesCode, r.TransactionID, r.IssuedAt.Format("2006-01-02 15:04:05")))
+
+	if reprint {
+		pdf.Ln(4)
+		pdf.Cell(0, 4, fmt.Sprintf("REPRINT #%d generated on %s - not valid as a new receipt.",
+			r.ReprintCount, time.Now().Format("January 02, 2006 at 3:04 PM")))
+
+		// Diagonal watermark
+		pdf.SetFont("Arial", "B", 60)
+		pdf.SetTextColor(220, 220, 220)
+		pdf.TransformBegin()
+		pdf.TransformRotate(20, 105, 80)
+		pdf.Text(55, 95, "REPRINT")
+		pdf.TransformEnd()
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return err
+	}
+	_, err := filestore.SaveBytes(outputPath, buf.Bytes(), "application/pdf")
+	return err
+}
+
+// ===================== AMOUNT HELPERS =====================
+
+func formatPeso(amount float64) string {
+	cents := int64(math.Round(amount * 100))
+	whole := cents / 100
+	frac := cents % 100
+
+	digits := fmt.Sprintf("%d", whole)
+	var grouped []string
+	for len(digits) > 3 {
+		grouped = append([]string{digits[len(digits)-3:]}, grouped...)
+		digits = digits[:len(digits)-3]
+	}
+	grouped = append([]string{digits}, grouped...)
+
+	return fmt.Sprintf("%s.%02d", strings.Join(grouped, ","), frac)
+}
+
+var (
+	wordOnes = []string{"", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine",
+		"Ten", "Eleven", "Twelve", "Thirteen", "Fourteen", "Fifteen", "Sixteen", "Seventeen", "Eighteen", "Nineteen"}
+	wordTens = []string{"", "", "Twenty", "Thirty", "Forty", "Fifty", "Sixty", "Seventy", "Eighty", "Ninety"}
+)
+
+func hundredsInWords(n int64) string {
+	var parts []string
+	if n >= 100 {
+		parts = append(parts, wordOnes[n/100]+" Hundred")
+		n %= 100
+	}
+	if n >= 20 {
+		word := wordTens[n/10]
+		if n%10 != 0 {
+			word += "-" + wordOnes[n%10]
+		}
+		parts = append(parts, word)
+	} else if n > 0 {
+		parts = append(parts, wordOnes[n])
+	}
+	return strings.Join(parts, " ")
+}
+
+// amountInWords spells out a peso amount the way it is written on receipts,
+// e.g. 1250.50 -> "One Thousand Two Hundred Fifty Pesos and 50/100".
+func amountInWords(amount float64) string {
+	cents := int64(math.Round(amount * 100))
+	whole := cents / 100
+	frac := cents % 100
+
+	if whole == 0 {
+		return fmt.Sprintf("Zero Pesos and %02d/100", frac)
+	}
+
+	scales := []string{"", "Thousand", "Million", "Billion"}
+	var parts []string
+	for i := 0; whole > 0 && i < len(scales); i++ {
+		chunk := whole % 1000
+		if chunk > 0 {
+			word := hundredsInWords(chunk)
+			if scales[i] != "" {
+				word += " " + scales[i]
+			}
+			parts = append([]string{word}, parts...)
+		}
+		whole /= 1000
+	}
+
+	return fmt.Sprintf("%s Pesos and %02d/100", strings.Join(parts, " "), frac)
+}
+
+// ===================== STUDENT RECEIPTS =====================
+
+// loadPaymentReceipts lists the receipts of a payment for the student payment view.
+func loadPaymentReceipts(paymentID int) []gin.H {
+	receipts := []gin.H{}
+
+	rows, err := config.DB.Query(receiptSelect+` WHERE r.payment_id = ? ORDER BY r.issued_at, r.id`, paymentID)
+	if err != nil {
+		fmt.Println("❌ Receipt query error:", err)
+		return receipts
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanReceipt(rows)
+		if err != nil {
+			fmt.Println("❌ Receipt scan error:", err)
+			continue
+		}
+		receipts = append(receipts, gin.H{
+			"or_number":      r.ORNumber,
+			"amount":         r.Amount,
+			"payment_method": r.PaymentMethod,
+			"issued_at":      r.IssuedAt.Format("2006-01-02 15:04:05"),
+			"download_url":   "/student/payments/receipts/" + r.ORNumber,
+		})
+	}
+
+	return receipts
+}
+
+// GET /student/payments/receipts/:or_number
+func StudentDownloadReceipt(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	var studentDBID int
+	err := config.DB.QueryRow(`SELECT id FROM students WHERE student_id = ?`, studentStrID).Scan(&studentDBID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+		return
+	}
+
+	r, err := loadReceiptByNumber(c.Param("or_number"))
+	if err != nil || r.StudentID != studentDBID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "receipt not found"})
+		return
+	}
+
+	filePath, err := ensureReceiptFile(r)
+	if err != nil {
+		fmt.Println("❌ Receipt generation error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate receipt"})
+		return
+	}
+
+	sendStoredFile(c, filePath, fmt.Sprintf("OR_%s.pdf", r.ORNumber), cachePrivate, true)
+}
+
+// ===================== CASHIER RECEIPTS =====================
+
+// GET /cashier/receipts?student_id=&date=YYYY-MM-DD
+func CashierGetReceipts(c *gin.Context) {
+	studentID := c.Query("student_id")
+	date := c.Query("date")
+
+	query := receiptSelect + ` WHERE 1=1`
+	args := []interface{}{}
+
+	if studentID != "" {
+		query += " AND r.student_number = ?"
+		args = append(args, studentID)
+	}
+	if date != "" {
+		query += " AND DATE(r.issued_at) = ?"
+		args = append(args, date)
+	}
+
+	query += " ORDER BY r.issued_at DESC, r.id DESC LIMIT 200"
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch receipts"})
+		return
+	}
+	defer rows.Close()
+
+	receipts := []gin.H{}
+	for rows.Next() {
+		r, err := scanReceipt(rows)
+		if err != nil {
+			continue
+		}
+		receipts = append(receipts, gin.H{
+			"or_number":      r.ORNumber,
+			"series_code":    r.SeriesCode,
+			"payment_id":     r.PaymentID,
+			"student_number": r.StudentNumber,
+			"received_from":  r.ReceivedFrom,
+			"cashier":        r.CashierName,
+			"amount":         r.Amount,
+			"payment_method": r.PaymentMethod,
+			"reprint_count":  r.ReprintCount,
+			"issued_at":      r.IssuedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"receipts": receipts,
+		"total":    len(receipts),
+	})
+}
+
+// POST /cashier/receipts/:or_number/reprint
+// Reprints keep the original OR number and are watermarked "REPRINT".
+func CashierReprintReceipt(c *gin.Context) {
+	r, err := loadReceiptByNumber(c.Param("or_number"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "receipt not found"})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		UPDATE official_receipts
+		SET reprint_count = reprint_count + 1, last_reprinted_at = NOW()
+		WHERE id = ?
+	`, r.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record reprint"})
+		return
+	}
+	r.ReprintCount++
+
+	uploadsDir := "uploads/receipts"
+
+	fileName := fmt.Sprintf("OR_%s_reprint%d_%s.pdf", r.ORNumber, r.ReprintCount,
+		strings.ReplaceAll(uuid.New().String(), "-", ""))
+	filePath := filepath.Join(uploadsDir, fileName)
+
+	if err := generateOfficialReceipt(r, filePath, true); err != nil {
+		fmt.Println("❌ Receipt reprint error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate receipt"})
+		return
+	}
+
+	fmt.Printf("🖨️ Receipt %s reprinted by cashier %d (reprint #%d)\n", r.ORNumber, c.GetInt("user_id"), r.ReprintCount)
+
+	sendStoredFile(c, filePath, fmt.Sprintf("OR_%s_REPRINT.pdf", r.ORNumber), cachePrivate, true)
+}
+
+// GET /cashier/or-series
+func CashierGetORSeries(c *gin.Context) {
+	cashierID := c.GetInt("user_id")
+
+	rows, err := config.DB.Query(`
+		SELECT id, series_code, IFNULL(prefix, ''), start_number, end_number, next_number, is_active,
+		       DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s')
+		FROM or_series
+		WHERE cashier_id = ?
+		ORDER BY id DESC
+	`, cashierID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch OR series"})
+		return
+	}
+	defer rows.Close()
+
+	series := []gin.H{}
+	for rows.Next() {
+		var (
+			id, startNumber, nextNumber int
+			code, prefix, createdAt     string
+			endNumber                   sql.NullInt64
+			isActive                    bool
+		)
+		if err := rows.Scan(&id, &code, &prefix, &startNumber, &endNumber, &nextNumber, &isActive, &createdAt); err != nil {
+			continue
+		}
+
+		item := gin.H{
+			"series_id":    id,
+			"series_code":  code,
+			"prefix":       prefix,
+			"start_number": startNumber,
+			"end_number":   nil,
+			"next_or":      fmt.Sprintf("%s%07d", prefix, nextNumber),
+			"is_active":    isActive,
+			"created_at":   createdAt,
+		}
+		if endNumber.Valid {
+			item["end_number"] = endNumber.Int64
+			item["remaining"] = endNumber.Int64 - int64(nextNumber) + 1
+		}
+		series = append(series, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"series": series})
+}
+
+// orPrefixPattern is a registered series prefix: letters and digits ending
+// in a dash, so an OR number can only be read one way. All-digit prefixes
+// such as "001-" are kept for the series made automatically per cashier.
+var orPrefixPattern = regexp.MustCompile(`^[A-Z0-9]{1,15}-$`)
+var autoORPrefixPattern = regexp.MustCompile(`^[0-9]+-$`)
+
+// POST /cashier/or-series
+// Registers a new booklet/series for the cashier and makes it the active one.
+// The prefix and number range may not overlap any other cashier's series,
+// since OR numbers are unique across the school.
+func CashierCreateORSeries(c *gin.Context) {
+	cashierID := c.GetInt("user_id")
+
+	var req struct {
+		SeriesCode  string `json:"series_code" binding:"required"`
+		Prefix      string `json:"prefix"`
+		StartNumber int    `json:"start_number"`
+		EndNumber   *int   `json:"end_number"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	req.Prefix = strings.ToUpper(strings.TrimSpace(req.Prefix))
+	if req.Prefix != "" && !orPrefixPattern.MatchString(req.Prefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prefix must be up to 15 letters or digits followed by a dash, e.g. \"MAIN-\""})
+		return
+	}
+	if autoORPrefixPattern.MatchString(req.Prefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prefixes of only digits are reserved for automatic series"})
+		return
+	}
+	if req.StartNumber <= 0 {
+		req.StartNumber = 1
+	}
+	if req.EndNumber != nil && *req.EndNumber < req.StartNumber {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_number must not be lower than start_number"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the prefix's series keeps two registrations from overlapping
+	// each other; an open-ended series (no end_number) runs forever
+	var overlapping string
+	err = tx.QueryRow(`
+		SELECT series_code FROM or_series
+		WHERE IFNULL(prefix, '') = ?
+		  AND (end_number IS NULL OR end_number >= ?)
+		  AND (? IS NULL OR start_number <= ?)
+		LIMIT 1
+		FOR UPDATE
+	`, req.Prefix, req.StartNumber, req.EndNumber, req.EndNumber).Scan(&overlapping)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("OR numbers overlap series %s", overlapping)})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check OR series"})
+		return
+	}
+
+	if _, err := tx.Exec(`UPDATE or_series SET is_active = FALSE WHERE cashier_id = ?`, cashierID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update OR series"})
+		return
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO or_series (series_code, cashier_id, prefix, start_number, end_number, next_number, is_active)
+		VALUES (?, ?, ?, ?, ?, ?, TRUE)
+	`, req.SeriesCode, cashierID, req.Prefix, req.StartNumber, req.EndNumber, req.StartNumber)
+	if err != nil {
+		if dup, _ := handleDuplicateEntryError(err); dup {
+			c.JSON(http.StatusConflict, gin.H{"error": "series code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create OR series"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	seriesID, _ := res.LastInsertId()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "OR series registered",
+		"series_id":   seriesID,
+		"series_code": req.SeriesCode,
+		"next_or":     fmt.Sprintf("%s%07d", req.Prefix, req.StartNumber),
+	})
+}
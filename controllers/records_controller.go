@@ -1,14 +1,15 @@
 package controllers
 
 import (
+	"bytes"
 	"database/sql"
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"student-portal/config"
+	"student-portal/filestore"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -134,6 +135,7 @@ func RecordsGetDocumentRequests(c *gin.Context) {
 			"processed_at":   processedAt,
 			"notes":          notes,
 			"document_path":  docPath,
+			"fulfillment":    loadRequestFulfillment(id),
 		})
 	}
 
@@ -146,8 +148,16 @@ func RecordsGetDocumentRequests(c *gin.Context) {
 
 // ===================== AUTO-GENERATE DOCUMENT FUNCTIONS =====================
 
+// writeUniversityHeader prints the university letterhead on cashier and
+// billing PDFs; records documents take theirs from the document template.
+func writeUniversityHeader(pdf *gofpdf.Fpdf) {
+	name, address, contact := schoolSettings()
+	writeLetterhead(pdf, name+"\n"+address+"\n"+contact)
+}
+
 type StudentDocumentData struct {
 	RequestID         int
+	StudentDBID       int
 	StudentNumber     string
 	FirstName         string
 	MiddleName        string
@@ -160,344 +170,7 @@ type StudentDocumentData struct {
 	ScholarshipStatus string
 	Purpose           string
 	DateRequested     string
-}
-
-func generateTranscriptOfRecords(data StudentDocumentData, outputPath string) error {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-
-	// Official Header with better formatting
-	pdf.SetFont("Arial", "B", 18)
-	pdf.Cell(0, 8, "THE UNIVERSITY OF MANILA")
-	pdf.Ln(7)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 5, "Sampaloc District in Manila, Philippines.")
-	pdf.Ln(4)
-	pdf.SetFont("Arial", "", 9)
-	pdf.Cell(0, 4, "Tel: 287355085 | https://www.facebook.com/UMOFFICIAL1913/")
-	pdf.Ln(2)
-	pdf.SetDrawColor(40, 145, 108)
-	pdf.SetLineWidth(0.5)
-	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
-	pdf.Ln(8)
-
-	// Office designation
-	pdf.SetFont("Arial", "B", 12)
-	pdf.Cell(0, 6, "OFFICE OF THE UNIVERSITY REGISTRAR")
-	pdf.Ln(12)
-
-	// Document Title
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(0, 10, "TRANSCRIPT OF RECORDS")
-	pdf.Ln(15)
-
-	// Student Information Section
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(0, 6, "STUDENT INFORMATION")
-	pdf.Ln(1)
-	pdf.SetDrawColor(200, 200, 200)
-	pdf.SetLineWidth(0.3)
-	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
-	pdf.Ln(5)
-
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(45, 6, "Student Number:")
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(0, 6, data.StudentNumber)
-	pdf.Ln(5)
-
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(45, 6, "Name:")
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(0, 6, fmt.Sprintf("%s %s %s", data.FirstName, data.MiddleName, data.LastName))
-	pdf.Ln(5)
-
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(45, 6, "Course/Program:")
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(0, 6, data.Course)
-	pdf.Ln(5)
-
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(45, 6, "Year Level:")
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(0, 6, data.YearLevel)
-	pdf.Ln(10)
-
-	// Academic Record Section
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(0, 6, "ACADEMIC RECORD")
-	pdf.Ln(1)
-	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
-	pdf.Ln(4)
-
-	// Grades table header
-	pdf.SetFont("Arial", "B", 9)
-	pdf.SetFillColor(40, 145, 108)
-	pdf.SetTextColor(255, 255, 255)
-	pdf.CellFormat(85, 8, "SUBJECT/COURSE", "1", 0, "L", true, 0, "")
-	pdf.CellFormat(25, 8, "UNITS", "1", 0, "C", true, 0, "")
-	pdf.CellFormat(30, 8, "GRADE", "1", 0, "C", true, 0, "")
-	pdf.CellFormat(40, 8, "REMARKS", "1", 1, "C", true, 0, "")
-
-	// Reset text color
-	pdf.SetTextColor(0, 0, 0)
-
-	// Fetch grades from database
-	gradesQuery := `
-		SELECT 
-			s.subject_name,
-			s.code as subject_code,
-			CASE 
-				WHEN g.prelim IS NOT NULL AND g.midterm IS NOT NULL AND g.finals IS NOT NULL 
-				THEN ROUND((g.prelim + g.midterm + g.finals) / 3, 2)
-				ELSE NULL
-			END as average,
-			IFNULL(g.remarks, 'INC') as remarks
-		FROM grades g
-		INNER JOIN subjects s ON g.subject_id = s.id
-		INNER JOIN students st ON g.student_id = st.id
-		WHERE st.student_id = ? AND g.is_released = TRUE
-		ORDER BY s.subject_name
-	`
-
-	rows, err := config.DB.Query(gradesQuery, data.StudentNumber)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	pdf.SetFont("Arial", "", 9)
-	pdf.SetFillColor(245, 245, 245)
-	hasGrades := false
-	rowCount := 0
-
-	for rows.Next() {
-		var subjectName, subjectCode, remarks string
-		var average *float64
-
-		err := rows.Scan(&subjectName, &subjectCode, &average, &remarks)
-		if err != nil {
-			continue
-		}
-
-		hasGrades = true
-		gradeStr := "INC"
-		if average != nil {
-			gradeStr = fmt.Sprintf("%.2f", *average)
-		}
-
-		defaultUnits := "3.0"
-
-		// Alternate row colors
-		fill := rowCount%2 == 0
-		pdf.CellFormat(85, 7, subjectName, "1", 0, "L", fill, 0, "")
-		pdf.CellFormat(25, 7, defaultUnits, "1", 0, "C", fill, 0, "")
-		pdf.CellFormat(30, 7, gradeStr, "1", 0, "C", fill, 0, "")
-		pdf.CellFormat(40, 7, remarks, "1", 1, "C", fill, 0, "")
-		rowCount++
-	}
-
-	if !hasGrades {
-		pdf.SetFont("Arial", "I", 10)
-		pdf.Cell(0, 10, "No grades available or released at this time.")
-		pdf.Ln(10)
-	}
-
-	// Footer section
-	pdf.Ln(10)
-	pdf.SetFont("Arial", "I", 8)
-	pdf.SetTextColor(100, 100, 100)
-	pdf.Cell(0, 5, fmt.Sprintf("Document generated on: %s", time.Now().Format("January 02, 2006 at 3:04 PM")))
-	pdf.Ln(4)
-	pdf.Cell(0, 5, "*** This is an official computer-generated document. No signature required. ***")
-	pdf.Ln(12)
-
-	// Registrar signature line
-	pdf.SetTextColor(0, 0, 0)
-	pdf.SetFont("Arial", "", 9)
-	pdf.Cell(0, 5, "_________________________________________")
-	pdf.Ln(5)
-	pdf.SetFont("Arial", "B", 9)
-	pdf.Cell(0, 5, "University Registrar")
-	pdf.Ln(3)
-	pdf.SetFont("Arial", "", 8)
-	pdf.Cell(0, 4, "Office of the University Registrar")
-
-	return pdf.OutputFileAndClose(outputPath)
-}
-
-func generateCertificateOfEnrollment(data StudentDocumentData, outputPath string) error {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-
-	pdf.SetFont("Arial", "B", 18)
-	pdf.Cell(0, 8, "THE UNIVERSITY OF MANILA")
-	pdf.Ln(7)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 5, "Sampaloc District in Manila, Philippines.")
-	pdf.Ln(4)
-	pdf.SetFont("Arial", "", 9)
-	pdf.Cell(0, 4, "Tel: 287355085 | https://www.facebook.com/UMOFFICIAL1913/")
-	pdf.Ln(2)
-	pdf.SetDrawColor(40, 145, 108)
-	pdf.SetLineWidth(0.5)
-	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
-	pdf.Ln(8)
-
-	// Footer
-	pdf.SetFont("Arial", "I", 8)
-	pdf.SetTextColor(100, 100, 100)
-	pdf.Cell(0, 4, "*** This is an official computer-generated document. No signature required. ***")
-
-	return pdf.OutputFileAndClose(outputPath)
-}
-
-func generateGoodMoralCertificate(data StudentDocumentData, outputPath string) error {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-
-	pdf.SetFont("Arial", "B", 18)
-	pdf.Cell(0, 8, "THE UNIVERSITY OF MANILA")
-	pdf.Ln(7)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 5, "Sampaloc District in Manila, Philippines.")
-	pdf.Ln(4)
-	pdf.SetFont("Arial", "", 9)
-	pdf.Cell(0, 4, "Tel: 287355085 | https://www.facebook.com/UMOFFICIAL1913/")
-	pdf.Ln(2)
-	pdf.SetDrawColor(40, 145, 108)
-	pdf.SetLineWidth(0.5)
-	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
-	pdf.Ln(8)
-
-	pdf.SetFont("Arial", "B", 12)
-	pdf.Cell(0, 6, "OFFICE OF STUDENT AFFAIRS AND SERVICES")
-	pdf.Ln(15)
-
-	// Document Title
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(0, 10, "CERTIFICATE OF GOOD MORAL CHARACTER")
-	pdf.Ln(18)
-
-	// Body
-	pdf.SetFont("Arial", "B", 11)
-	pdf.MultiCell(0, 6, "TO WHOM IT MAY CONCERN:", "", "L", false)
-	pdf.Ln(8)
-
-	pdf.SetFont("Arial", "", 11)
-	certText := fmt.Sprintf("This is to certify that %s %s %s, Student Number %s, "+
-		"a %s Year student of the %s program, has demonstrated GOOD MORAL CHARACTER "+
-		"during their enrollment at The University of Manila.",
-		data.FirstName, data.MiddleName, data.LastName, data.StudentNumber,
-		data.YearLevel, data.Course)
-
-	pdf.MultiCell(0, 7, certText, "", "J", false)
-	pdf.Ln(8)
-
-	pdf.MultiCell(0, 7, "Based on our records, the above-named student has no pending disciplinary cases "+
-		"and has not violated any university rules, regulations, or policies.", "", "J", false)
-	pdf.Ln(8)
-
-	pdf.MultiCell(0, 7, fmt.Sprintf("This certification is being issued upon the request of the student for %s.",
-		strings.ToLower(data.Purpose)), "", "J", false)
-	pdf.Ln(15)
-
-	// Date issued
-	pdf.SetFont("Arial", "", 11)
-	pdf.Cell(0, 6, fmt.Sprintf("Issued this %s.", time.Now().Format("2nd day of January, 2006")))
-	pdf.Ln(20)
-
-	// Signature section
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 5, "_________________________________________")
-	pdf.Ln(6)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(0, 5, "Director, Office of Student Affairs and Services")
-	pdf.Ln(4)
-	pdf.SetFont("Arial", "", 9)
-	pdf.Cell(0, 4, "The University of Manila")
-	pdf.Ln(15)
-
-	// Footer
-	pdf.SetFont("Arial", "I", 8)
-	pdf.SetTextColor(100, 100, 100)
-	pdf.Cell(0, 4, "*** This is an official computer-generated document. No signature required. ***")
-
-	return pdf.OutputFileAndClose(outputPath)
-}
-
-func generateHonorableDismissal(data StudentDocumentData, outputPath string) error {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-
-	pdf.SetFont("Arial", "B", 18)
-	pdf.Cell(0, 8, "THE UNIVERSITY OF MANILA")
-	pdf.Ln(7)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 5, "Sampaloc District in Manila, Philippines.")
-	pdf.Ln(4)
-	pdf.SetFont("Arial", "", 9)
-	pdf.Cell(0, 4, "Tel: 287355085 | https://www.facebook.com/UMOFFICIAL1913/")
-	pdf.Ln(2)
-	pdf.SetDrawColor(40, 145, 108)
-	pdf.SetLineWidth(0.5)
-	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
-	pdf.Ln(8)
-
-	pdf.SetFont("Arial", "B", 12)
-	pdf.Cell(0, 6, "OFFICE OF THE UNIVERSITY REGISTRAR")
-	pdf.Ln(15)
-
-	// Document Title
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(0, 10, "CERTIFICATE OF HONORABLE DISMISSAL")
-	pdf.Ln(18)
-
-	// Body
-	pdf.SetFont("Arial", "B", 11)
-	pdf.MultiCell(0, 6, "TO WHOM IT MAY CONCERN:", "", "L", false)
-	pdf.Ln(8)
-
-	pdf.SetFont("Arial", "", 11)
-	certText := fmt.Sprintf("This is to certify that %s %s %s, bearing Student Number %s, "+
-		"was a bona fide student of The University of Manila, enrolled in the %s program.",
-		data.FirstName, data.MiddleName, data.LastName, data.StudentNumber, data.Course)
-
-	pdf.MultiCell(0, 7, certText, "", "J", false)
-	pdf.Ln(8)
-
-	pdf.MultiCell(0, 7, "The above-named student is hereby granted HONORABLE DISMISSAL from The University of Manila. "+
-		"The student has settled all financial obligations, returned all university property, and has no pending "+
-		"accountabilities with the university. The student is eligible for transfer to another institution of higher learning.", "", "J", false)
-	pdf.Ln(8)
-
-	pdf.MultiCell(0, 7, fmt.Sprintf("This certificate is issued upon request for %s.",
-		strings.ToLower(data.Purpose)), "", "J", false)
-	pdf.Ln(15)
-
-	// Date issued
-	pdf.SetFont("Arial", "", 11)
-	pdf.Cell(0, 6, fmt.Sprintf("Issued this %s.", time.Now().Format("2nd day of January, 2006")))
-	pdf.Ln(20)
-
-	// Signature section
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 5, "_________________________________________")
-	pdf.Ln(6)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(0, 5, "University Registrar")
-	pdf.Ln(4)
-	pdf.SetFont("Arial", "", 9)
-	pdf.Cell(0, 4, "Office of the University Registrar")
-	pdf.Ln(15)
-
-	// Footer
-	pdf.SetFont("Arial", "I", 8)
-	pdf.SetTextColor(100, 100, 100)
-	pdf.Cell(0, 4, "*** This is an official computer-generated document. No signature required. ***")
-
-	return pdf.OutputFileAndClose(outputPath)
+	Serial            string // printed with the verification QR code
 }
 
 // ===================== PROCESS DOCUMENT REQUEST (AUTO-GENERATE) =====================
@@ -534,34 +207,29 @@ func RecordsProcessDocumentRequest(c *gin.Context) {
 		return
 	}
 
+	// The request row stays locked until it is updated, so two clerks
+	// cannot both generate a document for it
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to process request",
+		})
+		return
+	}
+	defer tx.Rollback()
+
 	// Get request details
 	var (
-		currentStatus, documentType, purpose           string
-		studentID                                      int
-		studentNumber, firstName, middleName, lastName string
-		course, yearLevel, email, address, semester    string
-		scholarshipStatus                              string
+		currentStatus, documentType, purpose string
+		studentID, feeAmount                 int
 	)
 
-	err = config.DB.QueryRow(`
-		SELECT 
-			dr.status, dr.document_type, dr.purpose, dr.student_id,
-			s.student_id, s.first_name, IFNULL(s.middle_name, ''), s.last_name,
-			s.email, s.address,
-			IFNULL(c.course_name, 'N/A'),
-			IFNULL(sa.year_level, '1'),
-			IFNULL(sa.semester, '1st'),
-			IFNULL(sa.scholarship_status, 'non-scholar')
+	err = tx.QueryRow(`
+		SELECT dr.status, dr.document_type, IFNULL(dr.purpose, ''), dr.student_id, IFNULL(dr.fee_amount, 0)
 		FROM document_requests dr
-		INNER JOIN students s ON dr.student_id = s.id
-		LEFT JOIN student_academic sa ON s.id = sa.student_id
-		LEFT JOIN courses c ON sa.course = c.id
 		WHERE dr.id = ?
-	`, requestID).Scan(
-		&currentStatus, &documentType, &purpose, &studentID,
-		&studentNumber, &firstName, &middleName, &lastName,
-		&email, &address, &course, &yearLevel, &semester, &scholarshipStatus,
-	)
+		FOR UPDATE
+	`, requestID).Scan(&currentStatus, &documentType, &purpose, &studentID, &feeAmount)
 
 	if err != nil {
 		fmt.Println("❌ Query error:", err)
@@ -569,70 +237,98 @@ func RecordsProcessDocumentRequest(c *gin.Context) {
 		return
 	}
 
-	if currentStatus != "pending" {
+	if currentStatus != requestPending && currentStatus != requestPaid {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "request already processed",
 		})
 		return
 	}
 
-	var documentPath string
+	// Billed requests are processed once the cashier has posted the fee
+	if req.Status == "approved" && currentStatus == requestPending && feeAmount > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "the document fee has not been paid yet",
+		})
+		return
+	}
 
-	// If approved, auto-generate document
+	// Documents are not released while the student is on hold
 	if req.Status == "approved" {
-		// Create uploads directory if not exists
-		uploadsDir := "./uploads/documents"
-		if err := os.MkdirAll(uploadsDir, os.ModePerm); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "failed to create upload directory",
+		refreshFinancialHold(studentID)
+		holds, err := activeHolds(tx, studentID, holdScopeDocuments)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check student holds"})
+			return
+		}
+		if len(holds) > 0 {
+			list := []gin.H{}
+			for _, h := range holds {
+				list = append(list, h.toJSON())
+			}
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "student has an active hold, the document cannot be released",
+				"holds": list,
 			})
 			return
 		}
+	}
 
-		// Prepare student data
-		studentData := StudentDocumentData{
-			RequestID:         requestID,
-			StudentNumber:     studentNumber,
-			FirstName:         firstName,
-			MiddleName:        middleName,
-			LastName:          lastName,
-			Course:            course,
-			YearLevel:         yearLevel,
-			Email:             email,
-			Address:           address,
-			Semester:          semester,
-			ScholarshipStatus: scholarshipStatus,
-			Purpose:           purpose,
-			DateRequested:     time.Now().Format("2006-01-02"),
+	var documentPath, documentSerial string
+	var templateID *int
+
+	// If approved, auto-generate document
+	if req.Status == "approved" {
+		// Unknown types are refused rather than printed as a COE
+		docType, err := findDocumentType(documentType)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": fmt.Sprintf("%q is not a registered document type", documentType),
+			})
+			return
 		}
+		tpl, err := publishedDocumentTemplate(docType.ID)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": fmt.Sprintf("%s has no published template", docType.Name),
+			})
+			return
+		}
+		templateID = &tpl.ID
 
-		// Generate filename
-		timestamp := time.Now().Unix()
-		filename := fmt.Sprintf("%s_%s_%d.pdf",
-			strings.ReplaceAll(strings.ToLower(documentType), " ", "_"),
-			studentNumber,
-			timestamp,
-		)
-		documentPath = filepath.Join(uploadsDir, filename)
-
-		// Generate document based on type
-		var genErr error
-		switch strings.ToLower(documentType) {
-		case "transcript of records", "tor":
-			genErr = generateTranscriptOfRecords(studentData, documentPath)
-		case "certificate of enrollment", "coe":
-			genErr = generateCertificateOfEnrollment(studentData, documentPath)
-		case "good moral certificate", "good moral":
-			genErr = generateGoodMoralCertificate(studentData, documentPath)
-		case "honorable dismissal":
-			genErr = generateHonorableDismissal(studentData, documentPath)
-		default:
-			// For unknown document types, generate a generic certificate
-			genErr = generateCertificateOfEnrollment(studentData, documentPath)
+		// Types with signatories wait for each of them; the document is
+		// generated after the last approval
+		chain, err := documentSignatoryChain(docType.ID)
+		if err != nil {
+			fmt.Println("❌ Signatory chain error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load signatories"})
+			return
+		}
+		if len(chain) > 0 {
+			err = startApprovalChain(tx, requestID, tpl.ID, chain, req.Notes)
+			if err == nil {
+				err = tx.Commit()
+			}
+			if err != nil {
+				fmt.Println("❌ Approval chain error:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start the approval chain"})
+				return
+			}
+			var studentName string
+			config.DB.QueryRow(`SELECT CONCAT(first_name, ' ', last_name) FROM students WHERE id = ?`, studentID).Scan(&studentName)
+			notifySignatory(chain[0].UserID, documentType, studentName)
+
+			c.JSON(http.StatusOK, gin.H{
+				"message":     "request sent for signature",
+				"request_id":  requestID,
+				"status":      requestForSignature,
+				"signatories": chain,
+			})
+			return
 		}
 
-		if genErr != nil {
-			fmt.Println("❌ Document generation error:", genErr)
+		documentPath, documentSerial, err = generateRequestDocument(tx, requestID, studentID, documentType, purpose, docType, tpl, c.GetInt("user_id"))
+		if err != nil {
+			fmt.Println("❌ Document generation error:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "failed to generate document",
 			})
@@ -640,19 +336,37 @@ func RecordsProcessDocumentRequest(c *gin.Context) {
 		}
 	}
 
+	// A generated document is printed and released from "processing"
+	newStatus := requestRejected
+	if req.Status == "approved" {
+		newStatus = requestProcessing
+	}
+
 	// Update request
-	_, err = config.DB.Exec(`
+	res, err := tx.Exec(`
 		UPDATE document_requests
 		SET 
 			status = ?,
 			processed_at = NOW(),
 			notes = ?,
-			document_file = ?
-		WHERE id = ?
-	`, req.Status, req.Notes, documentPath, requestID)
+			document_file = ?,
+			template_id = ?
+		WHERE id = ? AND status IN ('pending', 'paid')
+	`, newStatus, req.Notes, documentPath, templateID, requestID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n != 1 {
+			err = fmt.Errorf("request %d is no longer awaiting processing", requestID)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
 		fmt.Println("❌ Update error:", err)
+		if documentPath != "" {
+			filestore.Remove(documentPath)
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to process request",
 		})
@@ -662,11 +376,60 @@ func RecordsProcessDocumentRequest(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "request processed successfully",
 		"request_id":    requestID,
-		"status":        req.Status,
+		"status":        newStatus,
 		"document_path": documentPath,
+		"serial":        documentSerial,
 	})
 }
 
+// generateRequestDocument renders a request's document from tpl, saves it
+// under uploads/documents and signs it, recording it through exec. It returns
+// the file path and serial.
+func generateRequestDocument(exec interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, requestID, studentDBID int, documentType, purpose string, docType *documentType, tpl *documentTemplate, issuedBy int) (string, string, error) {
+	studentData, err := studentDocumentData(studentDBID, purpose)
+	if err != nil {
+		return "", "", fmt.Errorf("load student: %w", err)
+	}
+	studentData.RequestID = requestID
+
+	uploadsDir := "uploads/documents"
+
+	// Generate filename
+	filename := fmt.Sprintf("%s_%s_%d.pdf",
+		strings.ReplaceAll(strings.ToLower(documentType), " ", "_"),
+		studentData.StudentNumber,
+		time.Now().Unix(),
+	)
+	documentPath := filepath.Join(uploadsDir, filename)
+
+	serial, err := newDocumentSerial(docType.SerialPrefix)
+	if err != nil {
+		return "", "", fmt.Errorf("generate serial: %w", err)
+	}
+	studentData.Serial = serial
+
+	pdf, err := renderDocument(tpl, studentData, "")
+	if err != nil {
+		return "", "", fmt.Errorf("render: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return "", "", fmt.Errorf("render: %w", err)
+	}
+	if _, err := filestore.SaveBytes(documentPath, buf.Bytes(), "application/pdf"); err != nil {
+		return "", "", fmt.Errorf("save: %w", err)
+	}
+
+	// Sign the finished file; an unsigned document is not released
+	if err := issueDocument(exec, serial, requestID, studentDBID, documentType, documentPath, issuedBy); err != nil {
+		filestore.Remove(documentPath)
+		return "", "", fmt.Errorf("sign: %w", err)
+	}
+	return documentPath, serial, nil
+}
+
 // ===================== GET SINGLE DOCUMENT REQUEST DETAILS =====================
 func RecordsGetDocumentRequestDetails(c *gin.Context) {
 	role := c.GetString("role")
@@ -744,6 +507,7 @@ func RecordsGetDocumentRequestDetails(c *gin.Context) {
 		"processed_at":   processedAt,
 		"notes":          notes,
 		"document_path":  docPath,
+		"fulfillment":    loadRequestFulfillment(id),
 	})
 }
 
@@ -786,6 +550,7 @@ func RecordsGetAllGrades(c *gin.Context) {
 			END as average,
 			IFNULL(g.remarks, '') as remarks,
 			IFNULL(g.is_released, FALSE) as is_released,
+			IFNULL(g.status, 'draft') as status,
 			DATE_FORMAT(g.created_at, '%Y-%m-%d %H:%i:%s') as submitted_at,
 			DATE_FORMAT(g.updated_at, '%Y-%m-%d %H:%i:%s') as updated_at,
 			DATE_FORMAT(g.released_at, '%Y-%m-%d %H:%i:%s') as released_at
@@ -838,7 +603,7 @@ func RecordsGetAllGrades(c *gin.Context) {
 			studentNumber, firstName, lastName, email    string
 			course, courseCode, subjectName, subjectCode string
 			prelim, midterm, finals, average             *float64
-			remarks, submittedAt, updatedAt              string
+			remarks, submittedAt, updatedAt, status      string
 			isReleased                                   bool
 			releasedAt                                   *string
 		)
@@ -846,7 +611,7 @@ func RecordsGetAllGrades(c *gin.Context) {
 		err := rows.Scan(
 			&gradeID, &studentDBID, &studentNumber, &firstName, &lastName, &email,
 			&course, &courseCode, &yearLevel, &subjectName, &subjectCode, &teacherID,
-			&prelim, &midterm, &finals, &average, &remarks, &isReleased,
+			&prelim, &midterm, &finals, &average, &remarks, &isReleased, &status,
 			&submittedAt, &updatedAt, &releasedAt,
 		)
 
@@ -887,6 +652,7 @@ func RecordsGetAllGrades(c *gin.Context) {
 			"average":        average,
 			"remarks":        remarks,
 			"is_released":    isReleased,
+			"status":         status,
 			"submitted_at":   submittedAt,
 			"updated_at":     updatedAt,
 			"released_at":    releasedAt,
@@ -937,13 +703,23 @@ func RecordsReleaseGrade(c *gin.Context) {
 	}
 
 	// Check if grade exists
-	var exists bool
-	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM grades WHERE id = ?)", gradeID).Scan(&exists)
-	if err != nil || !exists {
+	var status string
+	err = config.DB.QueryRow("SELECT IFNULL(status, 'draft') FROM grades WHERE id = ?", gradeID).Scan(&status)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "grade not found"})
 		return
 	}
 
+	// Only verified grades can go out; holding a released grade keeps it locked
+	if input.Action == "release" && status != gradeStatusVerified && status != gradeStatusReleased {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("grade is %s; it must be submitted by the teacher and verified before release", status)})
+		return
+	}
+	if input.Action == "hold" && status != gradeStatusReleased && status != gradeStatusVerified {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("grade is %s and has not been released", status)})
+		return
+	}
+
 	// Update the is_released status
 	isReleased := input.Action == "release"
 
@@ -952,14 +728,16 @@ func RecordsReleaseGrade(c *gin.Context) {
 		_, err2 = config.DB.Exec(`
 			UPDATE grades 
 			SET is_released = ?,
-			    released_at = NOW()
+			    released_at = NOW(),
+			    status = 'released'
 			WHERE id = ?
 		`, isReleased, gradeID)
 	} else {
 		_, err2 = config.DB.Exec(`
 			UPDATE grades 
 			SET is_released = ?,
-			    released_at = NULL
+			    released_at = NULL,
+			    status = 'verified'
 			WHERE id = ?
 		`, isReleased, gradeID)
 	}
@@ -972,6 +750,10 @@ func RecordsReleaseGrade(c *gin.Context) {
 		return
 	}
 
+	if isReleased && status != gradeStatusReleased {
+		notifyGradesReleased([]int{gradeID})
+	}
+
 	message := "Grade released successfully"
 	if input.Action == "hold" {
 		message = "Grade put on hold successfully"
@@ -1174,71 +956,43 @@ func RecordsPostAnnouncement(c *gin.Context) {
 	}
 
 	// Handle optional image upload
-	var imageName, imagePath sql.NullString
+	var imageName, imagePath, imageThumb sql.NullString
 	var imageSize sql.NullInt64
 
 	file, err := c.FormFile("image")
 	if err == nil {
-		// Image was provided — validate it
-		allowedTypes := []string{"image/jpeg", "image/jpg", "image/png", "image/gif", "image/webp"}
-
-		fileHeader, err := file.Open()
+		contentType, err := sniffUpload(formUpload{file}, announcementImagePolicy)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read image"})
+			respondUploadError(c, err)
 			return
 		}
-		defer fileHeader.Close()
 
-		buffer := make([]byte, 512)
-		fileHeader.Read(buffer)
-		contentType := http.DetectContentType(buffer)
+		uploadDir := "uploads/records_announcements"
 
-		valid := false
-		for _, t := range allowedTypes {
-			if t == contentType {
-				valid = true
-				break
-			}
-		}
-		if !valid {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "image must be JPEG, PNG, GIF, or WebP"})
-			return
-		}
+		fileName := uploadFileName(fmt.Sprintf("%d_%d_%s", recordsOfficerID, time.Now().Unix(),
+			strings.ReplaceAll(uuid.New().String(), "-", "")), contentType, file.Filename)
+		savedPath := filepath.ToSlash(filepath.Join(uploadDir, fileName))
 
-		// 10MB max
-		if file.Size > int64(10*1024*1024) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "image too large. Max 10MB"})
-			return
-		}
-
-		uploadDir := "./uploads/records_announcements"
-		if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create upload directory"})
-			return
-		}
-
-		fileExt := filepath.Ext(file.Filename)
-		fileName := fmt.Sprintf("%d_%d_%s%s", recordsOfficerID, time.Now().Unix(),
-			strings.ReplaceAll(uuid.New().String(), "-", ""), fileExt)
-		savedPath := filepath.Join(uploadDir, fileName)
-
-		if err := c.SaveUploadedFile(file, savedPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save image"})
+		upload, err := processUpload(formUpload{file}, announcementImagePolicy, savedPath)
+		if err != nil {
+			respondUploadError(c, err)
 			return
 		}
 
 		imageName = sql.NullString{String: file.Filename, Valid: true}
 		imagePath = sql.NullString{String: savedPath, Valid: true}
-		imageSize = sql.NullInt64{Int64: file.Size, Valid: true}
+		imageSize = sql.NullInt64{Int64: upload.Size, Valid: true}
+		imageThumb = nullString(upload.ThumbnailPath)
 	}
 
 	result, err := config.DB.Exec(`
 		INSERT INTO records_announcements 
-		(records_officer_id, title, content, image_name, image_path, image_size, priority, target_audience, is_active, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, TRUE, NOW(), NOW())
-	`, recordsOfficerID, title, content, imageName, imagePath, imageSize, priority, targetAudience)
+		(records_officer_id, title, content, image_name, image_path, image_size, image_thumbnail_path, priority, target_audience, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE, NOW(), NOW())
+	`, recordsOfficerID, title, content, imageName, imagePath, imageSize, imageThumb, priority, targetAudience)
 
 	if err != nil {
+		removeUpload(imagePath.String, imageThumb.String)
 		fmt.Println("❌ Insert error:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to post announcement"})
 		return
@@ -1261,6 +1015,9 @@ func RecordsPostAnnouncement(c *gin.Context) {
 		}
 		response["image_url"] = "/" + cleanPath
 	}
+	if imageThumb.Valid {
+		response["image_thumbnail_url"] = "/" + imageThumb.String
+	}
 
 	c.JSON(http.StatusCreated, response)
 }
@@ -1289,6 +1046,7 @@ func RecordsGetAnnouncements(c *gin.Context) {
 			ra.image_name,
 			ra.image_path,
 			ra.image_size,
+			ra.image_thumbnail_path,
 			ra.priority,
 			ra.target_audience,
 			ra.is_active,
@@ -1330,18 +1088,18 @@ func RecordsGetAnnouncements(c *gin.Context) {
 
 	for rows.Next() {
 		var (
-			id, recordsOfficerID        int
-			officerName, title, content string
-			imageName, imagePath        sql.NullString
-			imageSize                   sql.NullInt64
-			priority, targetAudience    string
-			isActive                    bool
-			createdAt, updatedAt        string
+			id, recordsOfficerID             int
+			officerName, title, content      string
+			imageName, imagePath, imageThumb sql.NullString
+			imageSize                        sql.NullInt64
+			priority, targetAudience         string
+			isActive                         bool
+			createdAt, updatedAt             string
 		)
 
 		err := rows.Scan(
 			&id, &recordsOfficerID, &officerName, &title, &content,
-			&imageName, &imagePath, &imageSize, &priority, &targetAudience,
+			&imageName, &imagePath, &imageSize, &imageThumb, &priority, &targetAudience,
 			&isActive, &createdAt, &updatedAt,
 		)
 
@@ -1373,6 +1131,9 @@ func RecordsGetAnnouncements(c *gin.Context) {
 			announcement["image_url"] = "/" + cleanPath
 			announcement["image_size"] = imageSize.Int64
 		}
+		if imageThumb.Valid {
+			announcement["image_thumbnail_url"] = "/" + imageThumb.String
+		}
 
 		announcements = append(announcements, announcement)
 	}
@@ -1399,13 +1160,13 @@ func RecordsGetAnnouncementDetails(c *gin.Context) {
 	announcementID := c.Param("id")
 
 	var (
-		id, recordsOfficerID        int
-		officerName, title, content string
-		imageName, imagePath        sql.NullString
-		imageSize                   sql.NullInt64
-		priority, targetAudience    string
-		isActive                    bool
-		createdAt, updatedAt        string
+		id, recordsOfficerID             int
+		officerName, title, content      string
+		imageName, imagePath, imageThumb sql.NullString
+		imageSize                        sql.NullInt64
+		priority, targetAudience         string
+		isActive                         bool
+		createdAt, updatedAt             string
 	)
 
 	err := config.DB.QueryRow(`
@@ -1418,6 +1179,7 @@ func RecordsGetAnnouncementDetails(c *gin.Context) {
 			ra.image_name,
 			ra.image_path,
 			ra.image_size,
+			ra.image_thumbnail_path,
 			ra.priority,
 			ra.target_audience,
 			ra.is_active,
@@ -1428,7 +1190,7 @@ func RecordsGetAnnouncementDetails(c *gin.Context) {
 		WHERE ra.id = ?
 	`, announcementID).Scan(
 		&id, &recordsOfficerID, &officerName, &title, &content,
-		&imageName, &imagePath, &imageSize, &priority, &targetAudience,
+		&imageName, &imagePath, &imageSize, &imageThumb, &priority, &targetAudience,
 		&isActive, &createdAt, &updatedAt,
 	)
 
@@ -1460,6 +1222,9 @@ func RecordsGetAnnouncementDetails(c *gin.Context) {
 		response["image_url"] = "/" + cleanPath
 		response["image_size"] = imageSize.Int64
 	}
+	if imageThumb.Valid {
+		response["image_thumbnail_url"] = "/" + imageThumb.String
+	}
 
 	c.JSON(http.StatusOK, response)
 }
@@ -1595,11 +1360,11 @@ func RecordsDeleteAnnouncement(c *gin.Context) {
 
 	announcementID := c.Param("id")
 
-	var filePath sql.NullString
+	var filePath, thumbPath sql.NullString
 	err := config.DB.QueryRow(`
-		SELECT image_path FROM records_announcements 
+		SELECT image_path, image_thumbnail_path FROM records_announcements 
 		WHERE id = ? AND records_officer_id = ?
-	`, announcementID, recordsOfficerID).Scan(&filePath)
+	`, announcementID, recordsOfficerID).Scan(&filePath, &thumbPath)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "announcement not found or not owned by you"})
@@ -1618,9 +1383,7 @@ func RecordsDeleteAnnouncement(c *gin.Context) {
 	}
 
 	// Delete image file if exists
-	if filePath.Valid {
-		os.Remove(filePath.String)
-	}
+	removeUpload(filePath.String, thumbPath.String)
 
 	c.JSON(http.StatusOK, gin.H{"message": "announcement deleted successfully"})
 }
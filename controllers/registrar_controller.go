@@ -7,7 +7,6 @@ import (
 	"log"
 	"math/big"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -20,6 +19,12 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Tuition rates in pesos per enrolled unit
+const (
+	tuitionPerUnit        = 800
+	scholarTuitionPerUnit = 500
+)
+
 /* =======================
 STUDENT STRUCTS
 ======================= */
@@ -284,9 +289,9 @@ func RegistrarApproveWithAssessment(c *gin.Context) {
 	}
 
 	// ===== COMPUTE TUITION =====
-	tuition := 800 * totalUnits
+	tuition := tuitionPerUnit * totalUnits
 	if strings.ToLower(strings.TrimSpace(scholarshipStatus)) == "scholar" {
-		tuition = 500 * totalUnits
+		tuition = scholarTuitionPerUnit * totalUnits
 	}
 
 	// ===== CREATE PAYMENT RECORD =====
@@ -640,67 +645,43 @@ func RegistrarPostAnnouncement(c *gin.Context) {
 		return
 	}
 
-	var imageName, imagePath sql.NullString
+	var imageName, imagePath, imageThumb sql.NullString
 	var imageSize sql.NullInt64
 
 	file, err := c.FormFile("image")
 	if err == nil {
-		allowedTypes := []string{"image/jpeg", "image/jpg", "image/png", "image/gif", "image/webp"}
-		fileHeader, err := file.Open()
+		contentType, err := sniffUpload(formUpload{file}, announcementImagePolicy)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
-			return
-		}
-		defer fileHeader.Close()
-
-		buffer := make([]byte, 512)
-		fileHeader.Read(buffer)
-		contentType := http.DetectContentType(buffer)
-
-		valid := false
-		for _, t := range allowedTypes {
-			if t == contentType {
-				valid = true
-				break
-			}
-		}
-		if !valid {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Image must be JPEG, PNG, GIF, or WebP"})
-			return
-		}
-		if file.Size > int64(10*1024*1024) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Image too large. Max 10MB"})
+			respondUploadError(c, err)
 			return
 		}
 
-		uploadDir := "./uploads/registrar-announcements"
-		if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
-			return
-		}
+		uploadDir := "uploads/registrar-announcements"
 
-		fileExt := filepath.Ext(file.Filename)
-		fileName := fmt.Sprintf("%d_%d_%s%s", registrarID, time.Now().Unix(),
-			strings.ReplaceAll(uuid.New().String(), "-", ""), fileExt)
-		savedPath := filepath.Join(uploadDir, fileName)
+		fileName := uploadFileName(fmt.Sprintf("%d_%d_%s", registrarID, time.Now().Unix(),
+			strings.ReplaceAll(uuid.New().String(), "-", "")), contentType, file.Filename)
+		savedPath := filepath.ToSlash(filepath.Join(uploadDir, fileName))
 
-		if err := c.SaveUploadedFile(file, savedPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		upload, err := processUpload(formUpload{file}, announcementImagePolicy, savedPath)
+		if err != nil {
+			respondUploadError(c, err)
 			return
 		}
 
 		imageName = sql.NullString{String: file.Filename, Valid: true}
 		imagePath = sql.NullString{String: savedPath, Valid: true}
-		imageSize = sql.NullInt64{Int64: file.Size, Valid: true}
+		imageSize = sql.NullInt64{Int64: upload.Size, Valid: true}
+		imageThumb = nullString(upload.ThumbnailPath)
 	}
 
 	result, err := config.DB.Exec(`
         INSERT INTO registrar_announcements
-            (registrar_id, title, content, target_audience, image_name, image_path, image_size, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
-    `, registrarID, title, content, targetAudience, imageName, imagePath, imageSize)
+            (registrar_id, title, content, target_audience, image_name, image_path, image_size, image_thumbnail_path, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+    `, registrarID, title, content, targetAudience, imageName, imagePath, imageSize, imageThumb)
 
 	if err != nil {
+		removeUpload(imagePath.String, imageThumb.String)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post announcement: " + err.Error()})
 		return
 	}
@@ -720,6 +701,9 @@ func RegistrarPostAnnouncement(c *gin.Context) {
 		}
 		response["image_url"] = "/" + cleanPath
 	}
+	if imageThumb.Valid {
+		response["image_thumbnail_url"] = "/" + imageThumb.String
+	}
 
 	c.JSON(http.StatusCreated, response)
 }
@@ -734,7 +718,7 @@ func RegistrarGetAnnouncements(c *gin.Context) {
 
 	targetAudience := c.Query("target_audience")
 	query := `
-        SELECT id, title, content, target_audience, image_name, image_path, image_size, created_at
+        SELECT id, title, content, target_audience, image_name, image_path, image_size, image_thumbnail_path, created_at
         FROM registrar_announcements
     `
 	args := []interface{}{}
@@ -760,10 +744,11 @@ func RegistrarGetAnnouncements(c *gin.Context) {
 			targetAudience string
 			imageName      sql.NullString
 			imagePath      sql.NullString
+			imageThumb     sql.NullString
 			imageSize      sql.NullInt64
 			createdAt      string
 		)
-		if err := rows.Scan(&id, &title, &content, &targetAudience, &imageName, &imagePath, &imageSize, &createdAt); err != nil {
+		if err := rows.Scan(&id, &title, &content, &targetAudience, &imageName, &imagePath, &imageSize, &imageThumb, &createdAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -785,6 +770,9 @@ func RegistrarGetAnnouncements(c *gin.Context) {
 			item["image_url"] = "/" + cleanPath
 			item["image_size"] = imageSize.Int64
 		}
+		if imageThumb.Valid {
+			item["image_thumbnail_url"] = "/" + imageThumb.String
+		}
 		announcements = append(announcements, item)
 	}
 
@@ -809,10 +797,10 @@ func RegistrarDeleteAnnouncement(c *gin.Context) {
 		return
 	}
 
-	var filePath sql.NullString
+	var filePath, thumbPath sql.NullString
 	err := config.DB.QueryRow(`
-        SELECT image_path FROM registrar_announcements WHERE id = ? AND registrar_id = ?
-    `, announcementID, registrarID).Scan(&filePath)
+        SELECT image_path, image_thumbnail_path FROM registrar_announcements WHERE id = ? AND registrar_id = ?
+    `, announcementID, registrarID).Scan(&filePath, &thumbPath)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Announcement not found or not owned by you"})
@@ -829,9 +817,7 @@ func RegistrarDeleteAnnouncement(c *gin.Context) {
 		return
 	}
 
-	if filePath.Valid {
-		os.Remove(filePath.String)
-	}
+	removeUpload(filePath.String, thumbPath.String)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Announcement deleted successfully"})
 }
@@ -1012,9 +998,9 @@ func RegistrarApproveEnrollmentApplication(c *gin.Context) {
 	}
 
 	// Compute tuition
-	tuition := 800 * totalUnits
+	tuition := tuitionPerUnit * totalUnits
 	if strings.ToLower(scholarshipStatus) == "scholar" {
-		tuition = 500 * totalUnits
+		tuition = scholarTuitionPerUnit * totalUnits
 	}
 
 	// Create payment
@@ -0,0 +1,586 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ===================== RESUMABLE UPLOADS =====================
+//
+// Large lesson files and submissions can be sent in chunks, tus-style, so a
+// dropped connection only loses the chunk in flight:
+//
+//	POST   /resumable-uploads       {"purpose", "file_name", "size", "sha256"}
+//	HEAD   /resumable-uploads/:id   Upload-Offset says where to resume
+//	PATCH  /resumable-uploads/:id   Upload-Offset + application/offset+octet-stream
+//	                                body, optional Upload-Checksum "sha256 <base64>"
+//	DELETE /resumable-uploads/:id   cancel
+//
+// Once every byte is in and the whole file matches the declared SHA-256, the
+// upload is finished by posting the usual lesson or submission form with
+// upload_id instead of file; it then goes through the upload pipeline like
+// any other file. Partial files live on this server's disk
+// (RESUMABLE_UPLOAD_DIR) and are removed when abandoned for a day.
+
+const (
+	uploadPurposeLesson     = "lesson"
+	uploadPurposeSubmission = "submission"
+
+	resumableUploadTTL      = 24 * time.Hour
+	maxOpenResumableUploads = 5
+
+	// tus answers checksum failures with 460
+	statusChecksumMismatch = 460
+	tusVersion             = "1.0.0"
+)
+
+// resumablePurposes is who may upload for what, and the largest file each
+// purpose accepts (the per-type limits are checked again when finishing).
+var resumablePurposes = map[string]struct {
+	role    string
+	maxSize int64
+}{
+	uploadPurposeLesson:     {"teacher", 100 * 1024 * 1024},
+	uploadPurposeSubmission: {"student", 10 * 1024 * 1024},
+}
+
+var (
+	uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	sha256Pattern   = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+	// One chunk at a time per upload
+	resumableLocks sync.Map
+	// Uploads a handler of this process has claimed and not finished yet
+	heldUploads sync.Map
+)
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+func resumableUploadDir() string {
+	if dir := os.Getenv("RESUMABLE_UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("storage", "partial-uploads")
+}
+
+func partialUploadPath(id string) string {
+	return filepath.Join(resumableUploadDir(), id+".part")
+}
+
+type resumableUpload struct {
+	ID        string
+	OwnerRole string
+	OwnerID   string
+	Purpose   string
+	FileName  string
+	Length    int64
+	Offset    int64
+	SHA256    string
+	Status    string
+	ExpiresAt time.Time
+}
+
+func (u *resumableUpload) fileName() string { return u.FileName }
+func (u *resumableUpload) fileSize() int64  { return u.Length }
+
+func (u *resumableUpload) open() (io.ReadCloser, error) {
+	return os.Open(partialUploadPath(u.ID))
+}
+
+// finish drops a stored upload, or hands it back so finishing can be retried.
+func (u *resumableUpload) finish(consumed bool) {
+	defer heldUploads.Delete(u.ID)
+	if consumed {
+		deleteResumableUpload(u.ID)
+		return
+	}
+	if _, err := config.DB.Exec(`
+		UPDATE resumable_uploads SET status = 'complete' WHERE id = ? AND status = 'finalizing'
+	`, u.ID); err != nil {
+		fmt.Println("❌ Resumable upload release error:", err)
+	}
+}
+
+// uploadOwner identifies the caller: the student number for students, the
+// user id for staff.
+func uploadOwner(c *gin.Context) (role, id string) {
+	role = c.GetString("role")
+	if role == "student" {
+		return role, c.GetString("student_id")
+	}
+	return role, strconv.Itoa(c.GetInt("user_id"))
+}
+
+func loadResumableUpload(id string) (*resumableUpload, error) {
+	u := &resumableUpload{}
+	err := config.DB.QueryRow(`
+		SELECT id, owner_role, owner_id, purpose, file_name, upload_length, upload_offset, sha256, status, expires_at
+		FROM resumable_uploads WHERE id = ?
+	`, id).Scan(&u.ID, &u.OwnerRole, &u.OwnerID, &u.Purpose, &u.FileName, &u.Length, &u.Offset, &u.SHA256, &u.Status, &u.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ownedResumableUpload loads the upload in the :id parameter if it belongs to
+// the caller, answering 404 otherwise.
+func ownedResumableUpload(c *gin.Context) (*resumableUpload, bool) {
+	id := c.Param("id")
+	role, owner := uploadOwner(c)
+	if !uploadIDPattern.MatchString(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return nil, false
+	}
+	u, err := loadResumableUpload(id)
+	if err == sql.ErrNoRows || (err == nil && (u.OwnerRole != role || u.OwnerID != owner)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return nil, false
+	}
+	if err != nil {
+		fmt.Println("❌ Resumable upload lookup error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load upload"})
+		return nil, false
+	}
+	return u, true
+}
+
+func setUploadHeaders(c *gin.Context, u *resumableUpload) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(u.Length, 10))
+	c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-store")
+}
+
+func resumableUploadJSON(u *resumableUpload) gin.H {
+	return gin.H{
+		"upload_id":  u.ID,
+		"upload_url": "/resumable-uploads/" + u.ID,
+		"purpose":    u.Purpose,
+		"file_name":  u.FileName,
+		"offset":     u.Offset,
+		"length":     u.Length,
+		"status":     u.Status,
+		"expires_at": u.ExpiresAt,
+	}
+}
+
+func deleteResumableUpload(id string) {
+	if _, err := config.DB.Exec(`DELETE FROM resumable_uploads WHERE id = ?`, id); err != nil {
+		fmt.Println("❌ Resumable upload delete error:", err)
+	}
+	removePartialUpload(id)
+}
+
+// removePartialUpload drops the file and chunk lock of a deleted upload.
+func removePartialUpload(id string) {
+	resumableLocks.Delete(id)
+	if err := os.Remove(partialUploadPath(id)); err != nil && !os.IsNotExist(err) {
+		fmt.Println("⚠️ Warning: failed to remove partial upload:", err)
+	}
+}
+
+// POST /resumable-uploads
+func CreateResumableUpload(c *gin.Context) {
+	var req struct {
+		Purpose  string `json:"purpose" binding:"required"`
+		FileName string `json:"file_name" binding:"required"`
+		Size     int64  `json:"size" binding:"required"`
+		SHA256   string `json:"sha256" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "purpose, file_name, size and sha256 are required"})
+		return
+	}
+
+	role, owner := uploadOwner(c)
+	purpose, ok := resumablePurposes[req.Purpose]
+	if !ok || purpose.role != role {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot upload files for this purpose"})
+		return
+	}
+	if req.Size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be positive"})
+		return
+	}
+	if req.Size > purpose.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File too large. Max size: %dMB", purpose.maxSize/(1024*1024))})
+		return
+	}
+	req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+	if !sha256Pattern.MatchString(req.SHA256) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sha256 must be the hex SHA-256 of the whole file"})
+		return
+	}
+	name := filepath.Base(strings.ReplaceAll(req.FileName, "\\", "/"))
+	if name == "." || name == "/" || len(name) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file_name"})
+		return
+	}
+
+	var open int
+	config.DB.QueryRow(`
+		SELECT COUNT(*) FROM resumable_uploads WHERE owner_role = ? AND owner_id = ? AND status <> 'finalizing'
+	`, role, owner).Scan(&open)
+	if open >= maxOpenResumableUploads {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many unfinished uploads; finish or cancel one first"})
+		return
+	}
+
+	u := &resumableUpload{
+		ID:        strings.ReplaceAll(uuid.New().String(), "-", ""),
+		OwnerRole: role,
+		OwnerID:   owner,
+		Purpose:   req.Purpose,
+		FileName:  name,
+		Length:    req.Size,
+		SHA256:    req.SHA256,
+		Status:    "uploading",
+		ExpiresAt: time.Now().Add(resumableUploadTTL),
+	}
+
+	if err := os.MkdirAll(resumableUploadDir(), 0755); err != nil {
+		fmt.Println("❌ Resumable upload dir error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start upload"})
+		return
+	}
+	f, err := os.OpenFile(partialUploadPath(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Println("❌ Resumable upload create error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start upload"})
+		return
+	}
+	f.Close()
+
+	_, err = config.DB.Exec(`
+		INSERT INTO resumable_uploads (id, owner_role, owner_id, purpose, file_name, upload_length, sha256, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, u.ID, u.OwnerRole, u.OwnerID, u.Purpose, u.FileName, u.Length, u.SHA256, u.ExpiresAt)
+	if err != nil {
+		os.Remove(partialUploadPath(u.ID))
+		fmt.Println("❌ Resumable upload insert error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start upload"})
+		return
+	}
+
+	setUploadHeaders(c, u)
+	c.Header("Location", "/resumable-uploads/"+u.ID)
+	c.JSON(http.StatusCreated, resumableUploadJSON(u))
+}
+
+// HEAD|GET /resumable-uploads/:id
+func GetResumableUpload(c *gin.Context) {
+	u, ok := ownedResumableUpload(c)
+	if !ok {
+		return
+	}
+	setUploadHeaders(c, u)
+	c.JSON(http.StatusOK, resumableUploadJSON(u))
+}
+
+// parseUploadChecksum reads a tus Upload-Checksum header: "<algorithm> <base64 digest>".
+func parseUploadChecksum(header string) (hash.Hash, []byte, error) {
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	newHash, known := checksumAlgorithms[strings.ToLower(algorithm)]
+	if !ok || !known {
+		return nil, nil, fmt.Errorf("Upload-Checksum must be \"sha256|sha1|md5 <base64 digest>\"")
+	}
+	want, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Upload-Checksum digest is not valid base64")
+	}
+	return newHash(), want, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PATCH /resumable-uploads/:id
+// Appends the body at Upload-Offset, which must be where the upload stands.
+// Without a chunk checksum, whatever arrived before a dropped connection is
+// kept; with one, the chunk is all or nothing.
+func PatchResumableUpload(c *gin.Context) {
+	u, ok := ownedResumableUpload(c)
+	if !ok {
+		return
+	}
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset header is required"})
+		return
+	}
+	var verify hash.Hash
+	var want []byte
+	if header := c.GetHeader("Upload-Checksum"); header != "" {
+		if verify, want, err = parseUploadChecksum(header); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	lock, _ := resumableLocks.LoadOrStore(u.ID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	if !mu.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "another chunk of this upload is still being received"})
+		return
+	}
+	defer mu.Unlock()
+
+	// Re-read now that no other chunk can move the offset
+	if u, err = loadResumableUpload(u.ID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return
+	}
+	if u.Status != "uploading" {
+		setUploadHeaders(c, u)
+		c.JSON(http.StatusConflict, gin.H{"error": "upload is already complete"})
+		return
+	}
+	if offset != u.Offset {
+		setUploadHeaders(c, u)
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Upload-Offset %d does not match the upload offset %d", offset, u.Offset)})
+		return
+	}
+
+	path := partialUploadPath(u.ID)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Println("❌ Partial upload open error:", err)
+		c.JSON(http.StatusGone, gin.H{"error": "upload data is no longer available, start again"})
+		return
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write chunk"})
+		return
+	}
+
+	var w io.Writer = f
+	if verify != nil {
+		w = io.MultiWriter(f, verify)
+	}
+	written, copyErr := io.Copy(w, io.LimitReader(c.Request.Body, u.Length-offset))
+	tooLong := false
+	if copyErr == nil {
+		var extra [1]byte
+		n, _ := c.Request.Body.Read(extra[:])
+		tooLong = n > 0
+	}
+
+	rejectChunk := func(status int, message string) {
+		f.Truncate(offset)
+		setUploadHeaders(c, u)
+		c.JSON(status, gin.H{"error": message})
+	}
+	switch {
+	case tooLong:
+		rejectChunk(http.StatusRequestEntityTooLarge, "chunk runs past the declared upload size")
+		return
+	case verify != nil && copyErr != nil:
+		rejectChunk(http.StatusBadRequest, "chunk was interrupted, send it again")
+		return
+	case verify != nil && !bytes.Equal(verify.Sum(nil), want):
+		rejectChunk(statusChecksumMismatch, "chunk checksum does not match")
+		return
+	}
+	if err := f.Sync(); err != nil {
+		rejectChunk(http.StatusInternalServerError, "failed to write chunk")
+		return
+	}
+
+	u.Offset = offset + written
+	u.ExpiresAt = time.Now().Add(resumableUploadTTL)
+	if _, err := config.DB.Exec(`
+		UPDATE resumable_uploads SET upload_offset = ?, expires_at = ? WHERE id = ? AND upload_offset = ?
+	`, u.Offset, u.ExpiresAt, u.ID, offset); err != nil {
+		fmt.Println("❌ Resumable upload update error:", err)
+		rejectChunk(http.StatusInternalServerError, "failed to record chunk")
+		return
+	}
+	if copyErr != nil {
+		// The client is most likely gone; it resumes from the new offset
+		setUploadHeaders(c, u)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "chunk was interrupted, resume from Upload-Offset"})
+		return
+	}
+
+	if u.Offset == u.Length {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			fmt.Println("❌ Partial upload hash error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify upload"})
+			return
+		}
+		if sum != u.SHA256 {
+			deleteResumableUpload(u.ID)
+			c.JSON(statusChecksumMismatch, gin.H{"error": "file checksum does not match; the upload was discarded, start again"})
+			return
+		}
+		u.Status = "complete"
+		if _, err := config.DB.Exec(`UPDATE resumable_uploads SET status = 'complete' WHERE id = ?`, u.ID); err != nil {
+			fmt.Println("❌ Resumable upload update error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record upload"})
+			return
+		}
+	}
+
+	setUploadHeaders(c, u)
+	c.Status(http.StatusNoContent)
+}
+
+// DELETE /resumable-uploads/:id
+func CancelResumableUpload(c *gin.Context) {
+	u, ok := ownedResumableUpload(c)
+	if !ok {
+		return
+	}
+	if u.Status == "finalizing" {
+		c.JSON(http.StatusConflict, gin.H{"error": "upload is being saved"})
+		return
+	}
+	deleteResumableUpload(u.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "upload cancelled"})
+}
+
+// claimResumableUpload reserves a finished upload of the caller for one
+// handler; finish releases it.
+func claimResumableUpload(c *gin.Context, id, purpose string) (*resumableUpload, error) {
+	role, owner := uploadOwner(c)
+	if !uploadIDPattern.MatchString(id) {
+		return nil, rejectUpload(http.StatusNotFound, "upload not found")
+	}
+	res, err := config.DB.Exec(`
+		UPDATE resumable_uploads SET status = 'finalizing', expires_at = ?
+		WHERE id = ? AND owner_role = ? AND owner_id = ? AND purpose = ? AND status = 'complete'
+	`, time.Now().Add(resumableUploadTTL), id, role, owner, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		u, err := loadResumableUpload(id)
+		switch {
+		case err != nil || u.OwnerRole != role || u.OwnerID != owner || u.Purpose != purpose:
+			return nil, rejectUpload(http.StatusNotFound, "upload not found")
+		case u.Status == "uploading":
+			return nil, rejectUpload(http.StatusConflict, "upload is not complete (%d of %d bytes)", u.Offset, u.Length)
+		default:
+			return nil, rejectUpload(http.StatusConflict, "upload is already being saved")
+		}
+	}
+	u, err := loadResumableUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	heldUploads.Store(id, true)
+	return u, nil
+}
+
+// CleanupResumableUploads deletes uploads left unfinished past their expiry,
+// and partial files no upload refers to. Uploads a handler is still saving
+// are left alone, however long it takes.
+func CleanupResumableUploads() (int, error) {
+	now := time.Now()
+	rows, err := config.DB.Query(`SELECT id FROM resumable_uploads WHERE expires_at < ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	var expired []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			expired = append(expired, id)
+		}
+	}
+	rows.Close()
+
+	removed := 0
+	for _, id := range expired {
+		if _, held := heldUploads.Load(id); held {
+			continue
+		}
+		// Still expired: a claim since the listing moved the expiry on
+		res, err := config.DB.Exec(`DELETE FROM resumable_uploads WHERE id = ? AND expires_at < ?`, id, now)
+		if err != nil {
+			return removed, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			removePartialUpload(id)
+			removed++
+		}
+	}
+	entries, err := os.ReadDir(resumableUploadDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return removed, nil
+		}
+		return removed, err
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".part")
+		info, err := e.Info()
+		if !ok || err != nil || time.Since(info.ModTime()) < resumableUploadTTL {
+			continue
+		}
+		var exists bool
+		if err := config.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM resumable_uploads WHERE id = ?)`, id).Scan(&exists); err != nil || exists {
+			continue
+		}
+		if os.Remove(filepath.Join(resumableUploadDir(), e.Name())) == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// StartResumableUploadJob removes abandoned uploads every interval.
+func StartResumableUploadJob(interval time.Duration) {
+	for {
+		if n, err := CleanupResumableUploads(); err != nil {
+			fmt.Println("❌ Resumable upload cleanup error:", err)
+		} else if n > 0 {
+			fmt.Printf("🗑️ Removed %d abandoned uploads\n", n)
+		}
+		time.Sleep(interval)
+	}
+}
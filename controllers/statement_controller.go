@@ -0,0 +1,691 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ===================== STATEMENT OF ACCOUNT =====================
+//
+// A statement is built per billing record (one per term) or cumulatively over
+// every term of the student. Only posted transactions reduce the balance;
+// submissions still waiting for the cashier are listed separately.
+
+type statementLine struct {
+	Description string
+	Amount      float64
+}
+
+type statementPayment struct {
+	Date     time.Time
+	ORNumber string
+	Method   string
+	Amount   float64
+}
+
+type statementInstallment struct {
+	Term       string
+	DueDate    string
+	Amount     float64
+	Penalty    float64
+	Paid       float64
+	Balance    float64
+	RunningDue float64
+	Status     string
+}
+
+type ledgerEntry struct {
+	Date        time.Time
+	Description string
+	Reference   string
+	Charge      float64
+	Credit      float64
+	Balance     float64
+}
+
+type termStatement struct {
+	PaymentID       int
+	Semester        string
+	SchoolYear      string
+	Status          string
+	Assessment      []statementLine
+	TotalAssessment float64
+	Payments        []statementPayment
+	PendingPayments []statementPayment
+	Installments    []statementInstallment
+	Ledger          []ledgerEntry
+	TotalPaid       float64
+	Balance         float64
+}
+
+type studentStatement struct {
+	Scope         string
+	StudentNumber string
+	Name          string
+	Email         string
+	GuardianEmail string
+	Course        string
+	YearLevel     string
+	Scholar       bool
+	Terms         []termStatement
+	TotalAssessed float64
+	TotalPaid     float64
+	Balance       float64
+	GeneratedAt   time.Time
+}
+
+func (t termStatement) label() string {
+	label := strings.TrimSpace(t.Semester + " Semester")
+	if t.Semester == "" {
+		label = "Billing #" + strconv.Itoa(t.PaymentID)
+	}
+	if t.SchoolYear != "" {
+		label += ", SY " + t.SchoolYear
+	}
+	return label
+}
+
+func buildTermStatement(paymentID int, scholar bool) (termStatement, error) {
+	t := termStatement{PaymentID: paymentID}
+
+	var amountPaid float64
+	var createdAt time.Time
+	err := config.DB.QueryRow(`
+		SELECT IFNULL(semester, ''), IFNULL(school_year, ''), IFNULL(status, 'unpaid'),
+		       IFNULL(total_amount, 0), IFNULL(amount_paid, 0), created_at
+		FROM student_payments
+		WHERE id = ?
+	`, paymentID).Scan(&t.Semester, &t.SchoolYear, &t.Status, &t.TotalAssessment, &amountPaid, &createdAt)
+	if err != nil {
+		return t, err
+	}
+
+	// ----- Assessment -----
+	var feeTotal float64
+	var fees []statementLine
+	feeRows, err := config.DB.Query(`SELECT fee_name, amount FROM payment_fees WHERE payment_id = ? ORDER BY id`, paymentID)
+	if err != nil {
+		return t, err
+	}
+	for feeRows.Next() {
+		var l statementLine
+		if err := feeRows.Scan(&l.Description, &l.Amount); err != nil {
+			feeRows.Close()
+			return t, err
+		}
+		feeTotal += l.Amount
+		fees = append(fees, l)
+	}
+	feeRows.Close()
+
+	var penaltyTotal float64
+	var penalties []ledgerEntry
+	penRows, err := config.DB.Query(`
+		SELECT ip.amount, ip.assessed_at, si.term
+		FROM installment_penalties ip
+		INNER JOIN student_installments si ON si.id = ip.installment_id
+		WHERE si.payment_id = ? AND ip.status = 'assessed'
+		ORDER BY ip.assessed_at, ip.id
+	`, paymentID)
+	if err != nil {
+		return t, err
+	}
+	for penRows.Next() {
+		var e ledgerEntry
+		var term string
+		if err := penRows.Scan(&e.Charge, &e.Date, &term); err != nil {
+			penRows.Close()
+			return t, err
+		}
+		e.Description = "Late payment surcharge (" + term + ")"
+		penaltyTotal += e.Charge
+		penalties = append(penalties, e)
+	}
+	penRows.Close()
+
+	// Tuition is whatever the registrar assessed beyond the itemized fees
+	tuition := roundCents(t.TotalAssessment - feeTotal - penaltyTotal)
+	if scholar && tuition > 0 {
+		discount := roundCents(tuition / scholarTuitionPerUnit * (tuitionPerUnit - scholarTuitionPerUnit))
+		t.Assessment = append(t.Assessment,
+			statementLine{"Tuition fee", tuition + discount},
+			statementLine{"Less: scholarship discount", -discount})
+	} else {
+		t.Assessment = append(t.Assessment, statementLine{"Tuition fee", tuition})
+	}
+	t.Assessment = append(t.Assessment, fees...)
+	if penaltyTotal > 0 {
+		t.Assessment = append(t.Assessment, statementLine{"Late payment surcharges", roundCents(penaltyTotal)})
+	}
+
+	// ----- Payments -----
+	txnRows, err := config.DB.Query(`
+		SELECT pt.status, IFNULL(pt.posted_at, pt.submitted_at), IFNULL(r.or_number, ''),
+		       IFNULL(pt.payment_method, ''), pt.amount
+		FROM payment_transactions pt
+		LEFT JOIN official_receipts r ON r.transaction_id = pt.id
+		WHERE pt.payment_id = ? AND pt.status IN ('posted', 'pending')
+		  AND IFNULL(pt.kind, 'payment') <> 'document_fee'
+		ORDER BY IFNULL(pt.posted_at, pt.submitted_at), pt.id
+	`, paymentID)
+	if err != nil {
+		return t, err
+	}
+	for txnRows.Next() {
+		var p statementPayment
+		var status string
+		if err := txnRows.Scan(&status, &p.Date, &p.ORNumber, &p.Method, &p.Amount); err != nil {
+			txnRows.Close()
+			return t, err
+		}
+		if status == "posted" {
+			t.Payments = append(t.Payments, p)
+			t.TotalPaid += p.Amount
+		} else {
+			t.PendingPayments = append(t.PendingPayments, p)
+		}
+	}
+	txnRows.Close()
+
+	// Approved before transactions were recorded individually
+	if len(t.Payments) == 0 && amountPaid > 0 && (t.Status == "paid" || t.Status == "partial") {
+		t.Payments = append(t.Payments, statementPayment{Date: createdAt, Method: "Payment on record", Amount: amountPaid})
+		t.TotalPaid = amountPaid
+	}
+
+	t.TotalPaid = roundCents(t.TotalPaid)
+	t.Balance = roundCents(t.TotalAssessment - t.TotalPaid)
+
+	// ----- Ledger with running balance -----
+	ledger := []ledgerEntry{{
+		Date:        createdAt,
+		Description: "Assessment - " + t.label(),
+		Charge:      roundCents(t.TotalAssessment - penaltyTotal),
+	}}
+	ledger = append(ledger, penalties...)
+	for _, p := range t.Payments {
+		ledger = append(ledger, ledgerEntry{
+			Date:        p.Date,
+			Description: "Payment - " + p.Method,
+			Reference:   p.ORNumber,
+			Credit:      p.Amount,
+		})
+	}
+	sort.SliceStable(ledger, func(i, j int) bool { return ledger[i].Date.Before(ledger[j].Date) })
+
+	var running float64
+	for i := range ledger {
+		running = roundCents(running + ledger[i].Charge - ledger[i].Credit)
+		ledger[i].Balance = running
+	}
+	t.Ledger = ledger
+
+	// ----- Installment schedule -----
+	instRows, err := config.DB.Query(`
+		SELECT term, IFNULL(DATE_FORMAT(due_date, '%Y-%m-%d'), ''), amount,
+		       IFNULL(penalty_amount, 0), IFNULL(amount_paid, 0), status
+		FROM student_installments
+		WHERE payment_id = ?
+		ORDER BY sequence_no, id
+	`, paymentID)
+	if err != nil {
+		return t, err
+	}
+	defer instRows.Close()
+
+	var cumulative float64
+	for instRows.Next() {
+		var in statementInstallment
+		if err := instRows.Scan(&in.Term, &in.DueDate, &in.Amount, &in.Penalty, &in.Paid, &in.Status); err != nil {
+			return t, err
+		}
+		in.Balance = roundCents(in.Amount + in.Penalty - in.Paid)
+		if in.Balance > 0 {
+			cumulative += in.Balance
+		}
+		in.RunningDue = roundCents(cumulative)
+		t.Installments = append(t.Installments, in)
+	}
+
+	return t, nil
+}
+
+// loadStudentStatement builds the statement of one term (paymentID, or the
+// latest billing when 0) or of every term when scope is "cumulative".
+func loadStudentStatement(studentStrID string, paymentID int, scope string) (studentStatement, error) {
+	s := studentStatement{Scope: scope, GeneratedAt: time.Now()}
+
+	var studentDBID int
+	var scholarship string
+	err := config.DB.QueryRow(`
+		SELECT st.id, st.student_id, CONCAT(st.first_name, ' ', st.last_name), IFNULL(st.email, ''),
+		       IFNULL(co.code, ''), IFNULL(sa.year_level, ''), IFNULL(sa.scholarship_status, '')
+		FROM students st
+		LEFT JOIN student_academic sa ON sa.student_id = st.id
+		LEFT JOIN courses co ON co.id = sa.course
+		WHERE st.student_id = ?
+		LIMIT 1
+	`, studentStrID).Scan(&studentDBID, &s.StudentNumber, &s.Name, &s.Email, &s.Course, &s.YearLevel, &scholarship)
+	if err != nil {
+		return s, err
+	}
+	s.Scholar = strings.ToLower(strings.TrimSpace(scholarship)) == "scholar"
+
+	config.DB.QueryRow(`
+		SELECT IFNULL(guardian_email, '') FROM student_family WHERE student_id = ? LIMIT 1
+	`, studentDBID).Scan(&s.GuardianEmail)
+
+	var paymentIDs []int
+	switch {
+	case scope == "cumulative":
+		rows, err := config.DB.Query(`SELECT id FROM student_payments WHERE student_id = ? ORDER BY id`, studentDBID)
+		if err != nil {
+			return s, err
+		}
+		for rows.Next() {
+			var id int
+			rows.Scan(&id)
+			paymentIDs = append(paymentIDs, id)
+		}
+		rows.Close()
+	case paymentID > 0:
+		var owned bool
+		config.DB.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM student_payments WHERE id = ? AND student_id = ?)
+		`, paymentID, studentDBID).Scan(&owned)
+		if !owned {
+			return s, sql.ErrNoRows
+		}
+		paymentIDs = []int{paymentID}
+	default:
+		latest, _, err := latestStudentPayment(studentDBID)
+		if err != nil {
+			return s, err
+		}
+		paymentIDs = []int{latest}
+	}
+
+	if len(paymentIDs) == 0 {
+		return s, sql.ErrNoRows
+	}
+
+	for _, id := range paymentIDs {
+		t, err := buildTermStatement(id, s.Scholar)
+		if err != nil {
+			return s, err
+		}
+		s.Terms = append(s.Terms, t)
+		s.TotalAssessed += t.TotalAssessment
+		s.TotalPaid += t.TotalPaid
+	}
+	s.TotalAssessed = roundCents(s.TotalAssessed)
+	s.TotalPaid = roundCents(s.TotalPaid)
+	s.Balance = roundCents(s.TotalAssessed - s.TotalPaid)
+
+	return s, nil
+}
+
+func (t termStatement) toJSON() gin.H {
+	assessment := []gin.H{}
+	for _, l := range t.Assessment {
+		assessment = append(assessment, gin.H{"description": l.Description, "amount": l.Amount})
+	}
+
+	paymentsJSON := func(list []statementPayment) []gin.H {
+		result := []gin.H{}
+		for _, p := range list {
+			result = append(result, gin.H{
+				"date":      p.Date.Format("2006-01-02 15:04:05"),
+				"or_number": p.ORNumber,
+				"method":    p.Method,
+				"amount":    p.Amount,
+			})
+		}
+		return result
+	}
+
+	installments := []gin.H{}
+	for _, in := range t.Installments {
+		item := gin.H{
+			"term":        in.Term,
+			"due_date":    nil,
+			"amount":      in.Amount,
+			"penalty":     in.Penalty,
+			"amount_paid": in.Paid,
+			"balance":     in.Balance,
+			"running_due": in.RunningDue,
+			"status":      in.Status,
+		}
+		if in.DueDate != "" {
+			item["due_date"] = in.DueDate
+		}
+		installments = append(installments, item)
+	}
+
+	ledger := []gin.H{}
+	for _, e := range t.Ledger {
+		ledger = append(ledger, gin.H{
+			"date":        e.Date.Format("2006-01-02"),
+			"description": e.Description,
+			"reference":   e.Reference,
+			"charge":      e.Charge,
+			"credit":      e.Credit,
+			"balance":     e.Balance,
+		})
+	}
+
+	return gin.H{
+		"payment_id":       t.PaymentID,
+		"semester":         t.Semester,
+		"school_year":      t.SchoolYear,
+		"status":           t.Status,
+		"assessment":       assessment,
+		"total_assessment": t.TotalAssessment,
+		"payments":         paymentsJSON(t.Payments),
+		"pending_payments": paymentsJSON(t.PendingPayments),
+		"installments":     installments,
+		"ledger":           ledger,
+		"total_paid":       t.TotalPaid,
+		"balance":          t.Balance,
+	}
+}
+
+func generateStatementPDF(s studentStatement) *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+
+	writeUniversityHeader(pdf)
+
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(0, 8, "STATEMENT OF ACCOUNT", "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 9)
+	scope := "Current term"
+	if s.Scope == "cumulative" {
+		scope = "All terms"
+	}
+	pdf.CellFormat(0, 5, scope+" | as of "+s.GeneratedAt.Format("January 02, 2006"), "", 1, "C", false, 0, "")
+	pdf.Ln(5)
+
+	info := func(label, value string) {
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(35, 6, label)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(0, 6, value)
+		pdf.Ln(6)
+	}
+	info("Student Name:", s.Name)
+	info("Student Number:", s.StudentNumber)
+	info("Course / Year:", strings.Trim(s.Course+" - "+s.YearLevel, " -"))
+	pdf.Ln(4)
+
+	for _, t := range s.Terms {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.SetTextColor(40, 145, 108)
+		pdf.Cell(0, 7, t.label())
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(9)
+
+		reportSection(pdf, "Assessment")
+		var rows [][]string
+		for _, l := range t.Assessment {
+			rows = append(rows, []string{l.Description, formatPeso(l.Amount)})
+		}
+		rows = append(rows, []string{"TOTAL ASSESSMENT", formatPeso(t.TotalAssessment)})
+		reportTable(pdf, []float64{130, 40}, []string{"Particulars", "Amount"}, rows, 1)
+
+		reportSection(pdf, "Account Ledger")
+		rows = nil
+		for _, e := range t.Ledger {
+			charge, credit := "", ""
+			if e.Charge != 0 {
+				charge = formatPeso(e.Charge)
+			}
+			if e.Credit != 0 {
+				credit = formatPeso(e.Credit)
+			}
+			rows = append(rows, []string{e.Date.Format("2006-01-02"), e.Description, e.Reference,
+				charge, credit, formatPeso(e.Balance)})
+		}
+		reportTable(pdf, []float64{22, 58, 25, 22, 22, 22},
+			[]string{"Date", "Description", "OR No.", "Charges", "Payments", "Balance"}, rows, 3)
+
+		if len(t.PendingPayments) > 0 {
+			reportSection(pdf, "Payments Awaiting Verification")
+			rows = nil
+			for _, p := range t.PendingPayments {
+				rows = append(rows, []string{p.Date.Format("2006-01-02"), p.Method, formatPeso(p.Amount)})
+			}
+			reportTable(pdf, []float64{40, 90, 40}, []string{"Submitted", "Method", "Amount"}, rows, 2)
+		}
+
+		if len(t.Installments) > 0 {
+			reportSection(pdf, "Installment Schedule")
+			rows = nil
+			for _, in := range t.Installments {
+				due := in.DueDate
+				if due == "" {
+					due = "-"
+				}
+				rows = append(rows, []string{in.Term, due, formatPeso(in.Amount), formatPeso(in.Penalty),
+					formatPeso(in.Paid), formatPeso(in.Balance), formatPeso(in.RunningDue)})
+			}
+			reportTable(pdf, []float64{25, 25, 24, 24, 24, 24, 24},
+				[]string{"Term", "Due Date", "Amount", "Surcharge", "Paid", "Balance", "Total Due"}, rows, 2)
+		}
+
+		pdf.SetFont("Arial", "B", 11)
+		pdf.CellFormat(130, 7, "BALANCE FOR THIS TERM", "", 0, "R", false, 0, "")
+		pdf.CellFormat(40, 7, "PHP "+formatPeso(t.Balance), "", 1, "R", false, 0, "")
+		pdf.Ln(6)
+	}
+
+	if len(s.Terms) > 1 {
+		reportSection(pdf, "Summary of Account")
+		var rows [][]string
+		for _, t := range s.Terms {
+			rows = append(rows, []string{t.label(), formatPeso(t.TotalAssessment), formatPeso(t.TotalPaid), formatPeso(t.Balance)})
+		}
+		rows = append(rows, []string{"TOTAL", formatPeso(s.TotalAssessed), formatPeso(s.TotalPaid), formatPeso(s.Balance)})
+		reportTable(pdf, []float64{70, 35, 35, 30}, []string{"Term", "Assessed", "Paid", "Balance"}, rows, 1)
+	}
+
+	pdf.SetFont("Arial", "I", 8)
+	pdf.SetTextColor(100, 100, 100)
+	pdf.MultiCell(0, 4, "Payments submitted but not yet verified by the Cashier's Office are not deducted from the balance. "+
+		"Please present this statement when settling your account.", "", "L", false)
+
+	return pdf
+}
+
+func statementParams(c *gin.Context) (int, string, bool) {
+	scope := c.DefaultQuery("scope", "term")
+	if scope != "term" && scope != "cumulative" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be term or cumulative"})
+		return 0, "", false
+	}
+	paymentID := 0
+	if v := c.Query("payment_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment_id"})
+			return 0, "", false
+		}
+		paymentID = id
+	}
+	return paymentID, scope, true
+}
+
+func respondStatementError(c *gin.Context, err error) {
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no billing record found"})
+		return
+	}
+	fmt.Println("❌ Statement error:", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build statement"})
+}
+
+// GET /student/statement?payment_id=&scope=term|cumulative
+func StudentGetStatement(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	paymentID, scope, ok := statementParams(c)
+	if !ok {
+		return
+	}
+
+	s, err := loadStudentStatement(studentStrID, paymentID, scope)
+	if err != nil {
+		respondStatementError(c, err)
+		return
+	}
+
+	terms := []gin.H{}
+	for _, t := range s.Terms {
+		terms = append(terms, t.toJSON())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scope":          s.Scope,
+		"student_id":     s.StudentNumber,
+		"student_name":   s.Name,
+		"course":         s.Course,
+		"year_level":     s.YearLevel,
+		"terms":          terms,
+		"total_assessed": s.TotalAssessed,
+		"total_paid":     s.TotalPaid,
+		"balance":        s.Balance,
+		"generated_at":   s.GeneratedAt.Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GET /student/statement/pdf?payment_id=&scope=term|cumulative
+func StudentDownloadStatement(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	paymentID, scope, ok := statementParams(c)
+	if !ok {
+		return
+	}
+
+	s, err := loadStudentStatement(studentStrID, paymentID, scope)
+	if err != nil {
+		respondStatementError(c, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := generateStatementPDF(s).Output(&buf); err != nil {
+		fmt.Println("❌ Statement PDF error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate statement"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=SOA_%s_%s.pdf", s.StudentNumber, s.GeneratedAt.Format("20060102")))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// POST /student/statement/email
+// Sends the PDF to the student and, unless include_guardian is false, to the
+// guardian email on file.
+func StudentEmailStatement(c *gin.Context) {
+	studentStrID := c.GetString("student_id")
+	if studentStrID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	var req struct {
+		PaymentID       int    `json:"payment_id"`
+		Scope           string `json:"scope"`
+		IncludeGuardian *bool  `json:"include_guardian"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = "term"
+	}
+	if req.Scope != "term" && req.Scope != "cumulative" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be term or cumulative"})
+		return
+	}
+
+	s, err := loadStudentStatement(studentStrID, req.PaymentID, req.Scope)
+	if err != nil {
+		respondStatementError(c, err)
+		return
+	}
+
+	recipients := []string{}
+	if s.Email != "" {
+		recipients = append(recipients, s.Email)
+	}
+	if (req.IncludeGuardian == nil || *req.IncludeGuardian) && s.GuardianEmail != "" && s.GuardianEmail != s.Email {
+		recipients = append(recipients, s.GuardianEmail)
+	}
+	if len(recipients) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no email address on file"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := generateStatementPDF(s).Output(&buf); err != nil {
+		fmt.Println("❌ Statement PDF error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate statement"})
+		return
+	}
+
+	body := fmt.Sprintf(`
+<div style="font-family:'Segoe UI',Arial,sans-serif;color:#333;max-width:600px;">
+	<div style="background:#1b4332;color:#ffffff;padding:20px 24px;border-radius:8px 8px 0 0;">
+		<h2 style="margin:0;">Statement of Account</h2>
+	</div>
+	<div style="padding:20px 24px;border:1px solid #e5e7eb;border-top:none;border-radius:0 0 8px 8px;">
+		<p>Attached is the statement of account of <strong>%s</strong> (%s) as of %s.</p>
+		<p>Total assessed: <strong>&#8369;%s</strong><br>
+		   Total paid: <strong>&#8369;%s</strong><br>
+		   Outstanding balance: <strong>&#8369;%s</strong></p>
+		<p style="font-size:12px;color:#6b7280;">Payments awaiting verification are not yet deducted.
+		For questions, please visit the Cashier's Office.</p>
+	</div>
+</div>`, s.Name, s.StudentNumber, s.GeneratedAt.Format("January 02, 2006"),
+		formatPeso(s.TotalAssessed), formatPeso(s.TotalPaid), formatPeso(s.Balance))
+
+	filename := fmt.Sprintf("SOA_%s_%s.pdf", s.StudentNumber, s.GeneratedAt.Format("20060102"))
+	sent := []string{}
+	for _, to := range recipients {
+		if err := utils.SendEmailWithAttachment(to, "Statement of Account - University of Manila", body,
+			filename, "application/pdf", buf.Bytes()); err != nil {
+			fmt.Println("⚠️ Warning: could not email statement to", to, ":", err)
+			continue
+		}
+		sent = append(sent, to)
+	}
+
+	if len(sent) == 0 {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send statement email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Statement of account sent", "sent_to": sent})
+}
@@ -2,14 +2,15 @@ package controllers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"student-portal/config"
+	"student-portal/filestore"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +33,7 @@ func StudentGetPaymentsMe(c *gin.Context) {
 		firstName         string
 		lastName          string
 		profilePicture    string // ⬅️ NEW
+		profileThumbnail  string
 		yearLevel         int
 		totalUnits        int
 		scholarshipStatus string
@@ -44,6 +46,7 @@ func StudentGetPaymentsMe(c *gin.Context) {
 			st.first_name,
 			st.last_name,
 			IFNULL(st.profile_picture, ''),    -- ⬅️ NEW
+			IFNULL(st.profile_thumbnail, ''),
 			IFNULL(sa.year_level, 0),
 			IFNULL(sa.total_units, 0),
 			IFNULL(sa.scholarship_status, ''),
@@ -56,6 +59,7 @@ func StudentGetPaymentsMe(c *gin.Context) {
 		&firstName,
 		&lastName,
 		&profilePicture, // ⬅️ NEW
+		&profileThumbnail,
 		&yearLevel,
 		&totalUnits,
 		&scholarshipStatus,
@@ -150,6 +154,7 @@ func StudentGetPaymentsMe(c *gin.Context) {
 			"remaining":    totalAmount - amountPaid,
 			"status":       status,
 			"other_fees":   fees,
+			"receipts":     loadPaymentReceipts(id),
 		})
 	}
 
@@ -163,6 +168,7 @@ func StudentGetPaymentsMe(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"student_name":       studentName,
 		"profile_picture":    cleanProfilePic, // ⬅️ NEW
+		"profile_thumbnail":  profileThumbnail,
 		"student_id":         studentStrID,
 		"year_level":         yearLevel,
 		"total_units":        totalUnits,
@@ -226,8 +232,15 @@ func StudentPayBill(c *gin.Context) {
 		return
 	}
 
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit payment"})
+		return
+	}
+	defer tx.Rollback()
+
 	// SET STATUS TO PENDING FOR CASHIER APPROVAL
-	_, err = config.DB.Exec(`
+	_, err = tx.Exec(`
 		UPDATE student_payments
 		SET
 			amount_paid = ?,
@@ -241,11 +254,27 @@ func StudentPayBill(c *gin.Context) {
 		return
 	}
 
+	// Each submission is its own transaction so the cashier can issue one OR per payment
+	txnRes, err := tx.Exec(`
+		INSERT INTO payment_transactions
+		(payment_id, student_id, amount, payment_method, kind, status, submitted_at)
+		VALUES (?, ?, ?, ?, 'payment', 'pending', NOW())
+	`, req.PaymentID, studentDBID, req.Amount, req.PaymentMethod)
+
+	if err != nil || tx.Commit() != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit payment"})
+		return
+	}
+
+	transactionID, _ := txnRes.LastInsertId()
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "payment submitted, waiting for cashier approval",
-		"amount_paid": newAmountPaid,
-		"remaining":   totalAmount - newAmountPaid,
-		"status":      "pending",
+		"message":          "payment submitted, waiting for cashier approval",
+		"transaction_id":   transactionID,
+		"upload_proof_url": fmt.Sprintf("/student/payments/transactions/%d/proof", transactionID),
+		"amount_paid":      newAmountPaid,
+		"remaining":        totalAmount - newAmountPaid,
+		"status":           "pending",
 	})
 }
 
@@ -256,6 +285,7 @@ func StudentDownPayment(c *gin.Context) {
 		PaymentID     int    `json:"payment_id"`
 		DownPayment   int    `json:"down_payment"`
 		PaymentMethod string `json:"payment_method"`
+		PlanID        int    `json:"plan_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -314,32 +344,87 @@ func StudentDownPayment(c *gin.Context) {
 		return
 	}
 
+	// ===== INSTALLMENT PLAN + MINIMUM DOWNPAYMENT
+	planID := req.PlanID
+	if planID == 0 {
+		config.DB.QueryRow(`
+			SELECT IFNULL(
+				(SELECT plan_id FROM student_payments WHERE id = ?),
+				(SELECT id FROM installment_plans WHERE is_default = TRUE AND is_active = TRUE ORDER BY id LIMIT 1)
+			)
+		`, req.PaymentID).Scan(&planID)
+	}
+
+	if planID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no installment plan available"})
+		return
+	}
+
+	plan, err := loadInstallmentPlan(config.DB, planID)
+	if err != nil || !plan.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid installment plan"})
+		return
+	}
+
+	minDownpayment := math.Ceil(float64(totalAmount) * plan.MinDownpaymentPercent / 100)
+	if float64(req.DownPayment) < minDownpayment {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":           fmt.Sprintf("minimum downpayment for %s is %.0f%%", plan.Name, plan.MinDownpaymentPercent),
+			"min_downpayment": minDownpayment,
+		})
+		return
+	}
+
 	remaining := totalAmount - req.DownPayment
 
 	// ⭐ SET TO PENDING - WAIT FOR CASHIER APPROVAL
 	// ✅ FIX: Also save downpayment_amount so MarkPaidInstallments
 	//         can correctly exclude it from installment calculations.
-	_, err = config.DB.Exec(`
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit downpayment"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		UPDATE student_payments
 		SET
 			amount_paid = ?,
 			status = 'pending',
 			payment_method = ?,
-			downpayment_amount = ?
+			downpayment_amount = ?,
+			plan_id = ?
 		WHERE id = ? AND student_id = ?
-	`, req.DownPayment, req.PaymentMethod, req.DownPayment, req.PaymentID, studentDBID)
+	`, req.DownPayment, req.PaymentMethod, req.DownPayment, plan.ID, req.PaymentID, studentDBID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit downpayment"})
 		return
 	}
 
+	txnRes, err := tx.Exec(`
+		INSERT INTO payment_transactions
+		(payment_id, student_id, amount, payment_method, kind, status, submitted_at)
+		VALUES (?, ?, ?, ?, 'downpayment', 'pending', NOW())
+	`, req.PaymentID, studentDBID, req.DownPayment, req.PaymentMethod)
+
+	if err != nil || tx.Commit() != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit downpayment"})
+		return
+	}
+
+	transactionID, _ := txnRes.LastInsertId()
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":      "downpayment submitted, waiting for cashier approval",
-		"total_amount": totalAmount,
-		"paid":         req.DownPayment,
-		"remaining":    remaining,
-		"status":       "pending",
+		"message":          "downpayment submitted, waiting for cashier approval",
+		"transaction_id":   transactionID,
+		"upload_proof_url": fmt.Sprintf("/student/payments/transactions/%d/proof", transactionID),
+		"total_amount":     totalAmount,
+		"paid":             req.DownPayment,
+		"remaining":        remaining,
+		"plan":             plan.Name,
+		"status":           "pending",
 	})
 }
 
@@ -460,35 +545,18 @@ func StudentGetInstallments(c *gin.Context) {
 	}
 
 	// ================= INSTALLMENTS =================
-	instRows, err := config.DB.Query(`
-		SELECT term, amount, status, paid_at
-		FROM student_installments
-		WHERE payment_id = ?
-		ORDER BY FIELD(term,'prelim','midterm','finals')
-	`, paymentID)
-
+	installments, err := loadInstallmentSchedule(paymentID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load installments"})
 		return
 	}
-	defer instRows.Close()
-
-	installments := []gin.H{}
-
-	for instRows.Next() {
-		var term, instStatus string
-		var amount float64
-		var paidAt *string
 
-		instRows.Scan(&term, &amount, &instStatus, &paidAt)
-
-		installments = append(installments, gin.H{
-			"term":    term,
-			"amount":  amount,
-			"status":  instStatus,
-			"paid_at": paidAt,
-		})
-	}
+	var planName string
+	config.DB.QueryRow(`
+		SELECT ip.name FROM student_payments sp
+		INNER JOIN installment_plans ip ON ip.id = sp.plan_id
+		WHERE sp.id = ?
+	`, paymentID).Scan(&planName)
 
 	// ================= RESPONSE =================
 	c.JSON(http.StatusOK, gin.H{
@@ -499,6 +567,7 @@ func StudentGetInstallments(c *gin.Context) {
 		"status":       status,
 		"total_units":  totalUnits,
 		"subjects":     subjects,
+		"plan":         planName,
 		"installments": installments,
 	})
 }
@@ -607,6 +676,10 @@ func StudentRequestDocument(c *gin.Context) {
 		DocumentType string `json:"document_type"`
 		Purpose      string `json:"purpose"`
 		Copies       int    `json:"copies"`
+		Rush         bool   `json:"rush"`
+		// pickup (default) or mail
+		ReleaseMethod  string `json:"release_method"`
+		MailingAddress string `json:"mailing_address"`
 	}
 
 	// Parse JSON body
@@ -638,10 +711,18 @@ func StudentRequestDocument(c *gin.Context) {
 		req.Copies = 1
 	}
 
-	// Validate copies range
-	if req.Copies > 10 {
+	if req.ReleaseMethod == "" {
+		req.ReleaseMethod = releasePickup
+	}
+	if req.ReleaseMethod != releasePickup && req.ReleaseMethod != releaseMail {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "release_method must be 'pickup' or 'mail'",
+		})
+		return
+	}
+	if req.ReleaseMethod == releaseMail && strings.TrimSpace(req.MailingAddress) == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Maximum 10 copies allowed per request",
+			"error": "mailing_address is required for mailed documents",
 		})
 		return
 	}
@@ -672,15 +753,69 @@ func StudentRequestDocument(c *gin.Context) {
 		return
 	}
 
+	if blockedByHolds(c, studentDBID, holdScopeDocuments, "request documents") {
+		return
+	}
+
+	// Only registered, requestable types with a published template
+	docType, err := findDocumentType(req.DocumentType)
+	if err == nil && (!docType.Requestable || !docType.IsActive) {
+		err = sql.ErrNoRows
+	}
+	if err == nil {
+		_, err = publishedDocumentTemplate(docType.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "this document type cannot be requested; see /student/document-types",
+		})
+		return
+	}
+	req.DocumentType = docType.Name
+
+	// Validate copies range
+	if req.Copies > docType.MaxCopies {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Maximum %d copies allowed per request", docType.MaxCopies),
+		})
+		return
+	}
+	if req.Rush && docType.RushFee == 0 && docType.RushDays >= docType.ProcessingDays {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": docType.Name + " has no rush processing",
+		})
+		return
+	}
+	if req.ReleaseMethod == releasePickup {
+		req.MailingAddress = ""
+	}
+
 	fmt.Printf("✅ Student DB ID: %d\n", studentDBID)
 	fmt.Printf("📋 Request: Type=%s, Purpose=%s, Copies=%d\n",
 		req.DocumentType, req.Purpose, req.Copies)
 
+	fee := documentFee(docType, req.Copies, req.Rush)
+	processingDays := docType.ProcessingDays
+	if req.Rush {
+		processingDays = docType.RushDays
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to submit request. Please try again later.",
+		})
+		return
+	}
+	defer tx.Rollback()
+
 	// Insert document request
-	result, err := config.DB.Exec(`
-        INSERT INTO document_requests (student_id, document_type, purpose, copies, status, requested_at)
-        VALUES (?, ?, ?, ?, 'pending', NOW())
-    `, studentDBID, req.DocumentType, req.Purpose, req.Copies)
+	result, err := tx.Exec(`
+        INSERT INTO document_requests
+        (student_id, document_type, purpose, copies, rush, release_method, mailing_address, fee_amount, status, requested_at)
+        VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, 'pending', NOW())
+    `, studentDBID, req.DocumentType, req.Purpose, req.Copies, req.Rush, req.ReleaseMethod,
+		strings.TrimSpace(req.MailingAddress), fee)
 
 	if err != nil {
 		fmt.Println("❌ Database insert error:", err)
@@ -692,13 +827,50 @@ func StudentRequestDocument(c *gin.Context) {
 
 	requestID, _ := result.LastInsertId()
 
-	fmt.Printf("✅ Request created with ID: %d\n", requestID)
-
-	c.JSON(http.StatusOK, gin.H{
+	// Paid requests are billed now; free ones get their claim stub now
+	response := gin.H{
 		"message":    "document request submitted successfully",
 		"request_id": requestID,
-		"status":     "pending",
-	})
+		"status":     requestPending,
+		"fee_amount": fee,
+	}
+	if fee > 0 {
+		if err := billDocumentRequest(tx, int(requestID), studentDBID); err != nil {
+			if errors.Is(err, errNoBillingAccount) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error": "you have no student account to bill this fee to; please see the cashier",
+				})
+				return
+			}
+			fmt.Println("❌ Document billing error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to submit request. Please try again later.",
+			})
+			return
+		}
+		response["message"] = "document request submitted; pay the fee at the cashier to start processing"
+	} else {
+		stub, pickup, err := assignClaimStub(tx, int(requestID), processingDays)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to submit request. Please try again later.",
+			})
+			return
+		}
+		response["claim_stub"] = stub
+		response["pickup_date"] = pickup.Format("2006-01-02")
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to submit request. Please try again later.",
+		})
+		return
+	}
+
+	fmt.Printf("✅ Request created with ID: %d\n", requestID)
+
+	c.JSON(http.StatusOK, response)
 }
 
 // ===================== STUDENT VIEW DOCUMENT REQUESTS =====================
@@ -774,6 +946,7 @@ func StudentGetDocumentRequests(c *gin.Context) {
 			"processed_at":  processedAt,
 			"notes":         notes,
 			"document_path": cleanPath,
+			"fulfillment":   loadRequestFulfillment(id),
 		})
 	}
 
@@ -800,6 +973,10 @@ func StudentGetGrades(c *gin.Context) {
 		return
 	}
 
+	if blockedByHolds(c, studentDBID, holdScopeGrades, "view your grades") {
+		return
+	}
+
 	// Get student info
 	var firstName, lastName, email string
 	var course, courseCode string
@@ -824,11 +1001,8 @@ func StudentGetGrades(c *gin.Context) {
 		return
 	}
 
-	// ── UPDATED QUERY ──
-	// LEFT JOIN student_payments so each grade carries its semester + school_year.
-	// If a student has multiple payment records, the LIMIT 1 sub-select picks the
-	// most recent one; adjust the join condition if your schema links grades to a
-	// specific payment_id directly.
+	// Each grade carries the term it was given in; grades whose term is not
+	// recorded are listed as unassigned.
 	rows, err := config.DB.Query(`
 		SELECT 
 			g.id AS grade_id,
@@ -838,24 +1012,23 @@ func StudentGetGrades(c *gin.Context) {
 			g.prelim,
 			g.midterm,
 			g.finals,
-			CASE 
+			COALESCE(g.final_grade, CASE 
 				WHEN g.prelim IS NOT NULL AND g.midterm IS NOT NULL AND g.finals IS NOT NULL 
 				THEN ROUND((g.prelim + g.midterm + g.finals) / 3, 2)
 				ELSE NULL
-			END AS average,
+			END) AS average,
 			IFNULL(g.remarks, '') AS remarks,
+			IFNULL(g.final_remark, '') AS final_remark,
 			DATE_FORMAT(g.released_at, '%Y-%m-%d %H:%i:%s') AS released_at,
-			IFNULL(sp.semester, 'N/A')    AS semester,
-			IFNULL(sp.school_year, 'N/A') AS school_year
+			IFNULL(g.semester, ?)    AS semester,
+			IFNULL(g.school_year, ?) AS school_year
 		FROM grades g
 		INNER JOIN subjects s ON g.subject_id = s.id
 		INNER JOIN users u   ON g.teacher_id  = u.id
-		LEFT JOIN student_payments sp
-			ON sp.student_id = g.student_id
 		WHERE g.student_id  = ?
 		  AND g.is_released = TRUE
-		ORDER BY sp.school_year DESC, sp.semester ASC, s.subject_name ASC
-	`, studentDBID)
+		ORDER BY g.school_year IS NULL, school_year DESC, semester ASC, s.subject_name ASC
+	`, unassignedTerm, unassignedTerm, studentDBID)
 
 	if err != nil {
 		fmt.Println("❌ Database query error:", err)
@@ -871,7 +1044,7 @@ func StudentGetGrades(c *gin.Context) {
 			gradeID                               int
 			subjectName, subjectCode, teacherName string
 			prelim, midterm, finals, average      *float64
-			remarks                               string
+			remarks, finalRemark                  string
 			releasedAt                            *string
 			semester, schoolYear                  string // ← NEW
 		)
@@ -879,7 +1052,7 @@ func StudentGetGrades(c *gin.Context) {
 		err := rows.Scan(
 			&gradeID, &subjectName, &subjectCode, &teacherName,
 			&prelim, &midterm, &finals, &average,
-			&remarks, &releasedAt,
+			&remarks, &finalRemark, &releasedAt,
 			&semester, &schoolYear, // ← NEW
 		)
 
@@ -898,6 +1071,7 @@ func StudentGetGrades(c *gin.Context) {
 			"finals":       finals,
 			"average":      average,
 			"remarks":      remarks,
+			"final_remark": finalRemark,
 			"released_at":  releasedAt,
 			"semester":     semester,   // ← NEW
 			"school_year":  schoolYear, // ← NEW
@@ -908,19 +1082,18 @@ func StudentGetGrades(c *gin.Context) {
 		grades = []gin.H{}
 	}
 
-	// Calculate overall GPA
-	var totalGradePoints float64
-	var gradeCount int
-	for _, grade := range grades {
-		if avg, ok := grade["average"].(*float64); ok && avg != nil {
-			totalGradePoints += *avg
-			gradeCount++
-		}
+	// "gpa" is the cumulative GWA weighted by units; the full breakdown is
+	// in academic_standing
+	standing, err := computeAcademicStanding(studentDBID)
+	if err != nil {
+		fmt.Println("❌ GWA error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute GWA"})
+		return
 	}
 
 	var gpa float64
-	if gradeCount > 0 {
-		gpa = totalGradePoints / float64(gradeCount)
+	if standing.GWA != nil {
+		gpa = *standing.GWA
 	}
 
 	fmt.Printf("✅ Found %d released grades for student %s\n", len(grades), studentStrID)
@@ -934,9 +1107,10 @@ func StudentGetGrades(c *gin.Context) {
 			"course_code": courseCode,
 			"year_level":  yearLevel,
 		},
-		"grades":       grades,
-		"total_grades": len(grades),
-		"gpa":          gpa,
+		"grades":            grades,
+		"total_grades":      len(grades),
+		"gpa":               gpa,
+		"academic_standing": standing,
 	})
 }
 func StudentGetProfile(c *gin.Context) {
@@ -948,7 +1122,7 @@ func StudentGetProfile(c *gin.Context) {
 
 	// Get student DB ID and basic info (UPDATED: Added profile_picture)
 	var studentDBID int
-	var firstName, lastName, email, contactNumber, address, profilePicture string
+	var firstName, lastName, email, contactNumber, address, profilePicture, profileThumbnail string
 
 	err := config.DB.QueryRow(`
 		SELECT 
@@ -958,7 +1132,8 @@ func StudentGetProfile(c *gin.Context) {
 			IFNULL(email, ''),
 			IFNULL(contact_number, ''),
 			IFNULL(address, ''),
-			IFNULL(profile_picture, '')
+			IFNULL(profile_picture, ''),
+			IFNULL(profile_thumbnail, '')
 		FROM students
 		WHERE student_id = ?
 	`, studentStrID).Scan(
@@ -969,6 +1144,7 @@ func StudentGetProfile(c *gin.Context) {
 		&contactNumber,
 		&address,
 		&profilePicture,
+		&profileThumbnail,
 	)
 
 	if err != nil {
@@ -999,6 +1175,11 @@ func StudentGetProfile(c *gin.Context) {
 		yearLevel = 0
 	}
 
+	var guardianEmail string
+	config.DB.QueryRow(`
+		SELECT IFNULL(guardian_email, '') FROM student_family WHERE student_id = ? LIMIT 1
+	`, studentDBID).Scan(&guardianEmail)
+
 	// Normalize profile picture path
 	cleanProfilePic := strings.ReplaceAll(profilePicture, "\\", "/")
 	if strings.HasPrefix(cleanProfilePic, "./") {
@@ -1006,17 +1187,19 @@ func StudentGetProfile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"student_id":      studentStrID,
-		"student_name":    firstName + " " + lastName,
-		"first_name":      firstName,
-		"last_name":       lastName,
-		"email":           email,
-		"contact_number":  contactNumber,
-		"address":         address,
-		"profile_picture": cleanProfilePic,
-		"course":          course,
-		"course_code":     courseCode,
-		"year_level":      yearLevel,
+		"student_id":        studentStrID,
+		"student_name":      firstName + " " + lastName,
+		"first_name":        firstName,
+		"last_name":         lastName,
+		"email":             email,
+		"contact_number":    contactNumber,
+		"address":           address,
+		"profile_picture":   cleanProfilePic,
+		"profile_thumbnail": profileThumbnail,
+		"course":            course,
+		"course_code":       courseCode,
+		"year_level":        yearLevel,
+		"guardian_email":    guardianEmail,
 	})
 }
 
@@ -1029,10 +1212,10 @@ func StudentUploadProfilePicture(c *gin.Context) {
 
 	// Get student DB ID
 	var studentDBID int
-	var oldProfilePic string
+	var oldProfilePic, oldThumbnail string
 	err := config.DB.QueryRow(`
-		SELECT id, IFNULL(profile_picture, '') FROM students WHERE student_id = ?
-	`, studentStrID).Scan(&studentDBID, &oldProfilePic)
+		SELECT id, IFNULL(profile_picture, ''), IFNULL(profile_thumbnail, '') FROM students WHERE student_id = ?
+	`, studentStrID).Scan(&studentDBID, &oldProfilePic, &oldThumbnail)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
@@ -1046,84 +1229,49 @@ func StudentUploadProfilePicture(c *gin.Context) {
 		return
 	}
 
-	// Validate file size (max 5MB)
-	if file.Size > 5*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "file too large (max 5MB)"})
-		return
-	}
-
-	// Validate file type
-	allowedTypes := map[string]bool{
-		"image/jpeg": true,
-		"image/jpg":  true,
-		"image/png":  true,
-		"image/gif":  true,
+	policy := uploadPolicy{
+		maxSize:     5 * 1024 * 1024,
+		types:       []string{"image/jpeg", "image/png", "image/gif"},
+		maxImageDim: 1024,
+		thumbnail:   true,
 	}
-
-	// Open file to check content type
-	fileContent, err := file.Open()
+	contentType, err := sniffUpload(formUpload{file}, policy)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		respondUploadError(c, err)
 		return
 	}
-	defer fileContent.Close()
 
-	// Read first 512 bytes to detect content type
-	buffer := make([]byte, 512)
-	_, err = fileContent.Read(buffer)
-	if err != nil && err != io.EOF {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file content"})
-		return
-	}
-
-	contentType := http.DetectContentType(buffer)
-	if !allowedTypes[contentType] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file type (only jpg, png, gif allowed)"})
-		return
-	}
-
-	// Create uploads directory if it doesn't exist
-	uploadsDir := "./uploads/profile_pictures"
-	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		fmt.Println("❌ Error creating directory:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create upload directory"})
-		return
-	}
+	uploadsDir := "uploads/profile_pictures"
 
 	// Generate unique filename
-	ext := filepath.Ext(file.Filename)
-	newFilename := fmt.Sprintf("student_%s_%d%s", studentStrID, time.Now().Unix(), ext)
-	filePath := filepath.Join(uploadsDir, newFilename)
+	newFilename := uploadFileName(fmt.Sprintf("student_%s_%d", studentStrID, time.Now().Unix()), contentType, file.Filename)
+	filePath := filepath.ToSlash(filepath.Join(uploadsDir, newFilename))
 
-	// Save the file
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		fmt.Println("❌ Error saving file:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save file"})
+	upload, err := processUpload(formUpload{file}, policy, filePath)
+	if err != nil {
+		respondUploadError(c, err)
 		return
 	}
 
-	// Delete old profile picture if exists
-	if oldProfilePic != "" {
-		oldPath := "./" + oldProfilePic
-		if _, err := os.Stat(oldPath); err == nil {
-			os.Remove(oldPath)
-			fmt.Printf("🗑️ Deleted old profile picture: %s\n", oldPath)
-		}
-	}
-
 	// Update database
 	_, err = config.DB.Exec(`
-		UPDATE students SET profile_picture = ? WHERE id = ?
-	`, filePath, studentDBID)
+		UPDATE students SET profile_picture = ?, profile_thumbnail = ? WHERE id = ?
+	`, filePath, nullString(upload.ThumbnailPath), studentDBID)
 
 	if err != nil {
 		fmt.Println("❌ Database update error:", err)
 		// Clean up uploaded file if DB update fails
-		os.Remove(filePath)
+		removeUpload(upload.Path, upload.ThumbnailPath)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update profile"})
 		return
 	}
 
+	// Delete old profile picture if exists
+	if oldProfilePic != "" && oldProfilePic != filePath {
+		removeUpload(oldProfilePic, oldThumbnail)
+		fmt.Printf("🗑️ Deleted old profile picture: %s\n", oldProfilePic)
+	}
+
 	// Normalize path for response
 	cleanPath := strings.ReplaceAll(filePath, "\\", "/")
 	if strings.HasPrefix(cleanPath, "./") {
@@ -1133,8 +1281,9 @@ func StudentUploadProfilePicture(c *gin.Context) {
 	fmt.Printf("✅ Profile picture uploaded for student %s: %s\n", studentStrID, cleanPath)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":         "profile picture uploaded successfully",
-		"profile_picture": cleanPath,
+		"message":           "profile picture uploaded successfully",
+		"profile_picture":   cleanPath,
+		"profile_thumbnail": upload.ThumbnailPath,
 	})
 }
 
@@ -1146,11 +1295,12 @@ func StudentUpdateProfile(c *gin.Context) {
 	}
 
 	var req struct {
-		FirstName     string `json:"first_name"`
-		LastName      string `json:"last_name"`
-		Email         string `json:"email"`
-		ContactNumber string `json:"contact_number"`
-		Address       string `json:"address"`
+		FirstName     string  `json:"first_name"`
+		LastName      string  `json:"last_name"`
+		Email         string  `json:"email"`
+		ContactNumber string  `json:"contact_number"`
+		Address       string  `json:"address"`
+		GuardianEmail *string `json:"guardian_email"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1175,6 +1325,15 @@ func StudentUpdateProfile(c *gin.Context) {
 		return
 	}
 
+	if req.GuardianEmail != nil {
+		g := strings.TrimSpace(*req.GuardianEmail)
+		if g != "" && !strings.Contains(g, "@") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid guardian email format"})
+			return
+		}
+		req.GuardianEmail = &g
+	}
+
 	// Get student DB ID
 	var studentDBID int
 	err := config.DB.QueryRow(`
@@ -1205,6 +1364,18 @@ func StudentUpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// Guardian email is only touched when sent
+	if req.GuardianEmail != nil {
+		_, err = config.DB.Exec(`
+			UPDATE student_family SET guardian_email = ? WHERE student_id = ?
+		`, *req.GuardianEmail, studentDBID)
+		if err != nil {
+			fmt.Println("❌ Guardian email update error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update guardian email"})
+			return
+		}
+	}
+
 	fmt.Printf("✅ Profile updated for student %s\n", studentStrID)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -1329,7 +1500,13 @@ func StudentGetLessons(c *gin.Context) {
 			lm.type as file_type,
 			DATE_FORMAT(lm.created_at, '%Y-%m-%d %H:%i:%s') as uploaded_at,
 			u.username as teacher_name,
-			lm.due_date
+			lm.due_date,
+			lm.page_count,
+			lm.thumbnail_path,
+			lm.preview_text,
+			lm.video_status,
+			lm.video_duration,
+			lm.poster_path
 		FROM lesson_materials lm
 		INNER JOIN subjects s ON lm.subject_id = s.id
 		INNER JOIN users u ON lm.teacher_id = u.id
@@ -1352,12 +1529,18 @@ func StudentGetLessons(c *gin.Context) {
 			subjectName, subjectCode, title, description string
 			filePath, fileType, uploadedAt, teacherName  string
 			dueDate                                      *string
+			pageCount                                    sql.NullInt64
+			thumbPath, previewText                       sql.NullString
+			videoStatus, posterPath                      sql.NullString
+			videoDuration                                sql.NullInt64
 		)
 
 		err := rows.Scan(
 			&id, &subjectName, &subjectCode, &title,
 			&description, &filePath, &fileType,
 			&uploadedAt, &teacherName, &dueDate,
+			&pageCount, &thumbPath, &previewText,
+			&videoStatus, &videoDuration, &posterPath,
 		)
 
 		if err != nil {
@@ -1381,6 +1564,8 @@ func StudentGetLessons(c *gin.Context) {
 			"uploaded_at":  uploadedAt,
 			"teacher_name": teacherName,
 		}
+		addMaterialPreview(lesson, pageCount, thumbPath, previewText)
+		addVideoDetails(lesson, id, videoStatus, videoDuration, posterPath)
 
 		if dueDate != nil {
 			lesson["due_date"] = *dueDate
@@ -1489,57 +1674,44 @@ func StudentUploadSubmission(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("file")
-	if err != nil {
+	// Either a multipart file or a finished resumable upload (upload_id)
+	file, err := uploadFromRequest(c, "file", uploadPurposeSubmission)
+	if errors.Is(err, http.ErrMissingFile) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
 		return
 	}
-
-	if file.Size > 10*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "file too large (max 10MB)"})
+	if err != nil {
+		respondUploadError(c, err)
 		return
 	}
+	consumed := false
+	defer func() { file.finish(consumed) }()
 
-	allowedTypes := map[string]bool{
-		"application/pdf": true,
-		"image/jpeg":      true,
-		"image/png":       true,
-		"text/plain":      true,
+	policy := uploadPolicy{
+		maxSize:     10 * 1024 * 1024,
+		types:       []string{"application/pdf", "image/jpeg", "image/png", "text/plain"},
+		maxImageDim: maxImageDim,
 	}
-
-	fileContent, err := file.Open()
+	contentType, err := sniffUpload(file, policy)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot read file"})
+		respondUploadError(c, err)
 		return
 	}
-	defer fileContent.Close()
-
-	buffer := make([]byte, 512)
-	fileContent.Read(buffer)
-
-	contentType := http.DetectContentType(buffer)
 
-	if !allowedTypes[contentType] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file type"})
-		return
-	}
+	uploadDir := "uploads/student_submissions"
 
-	uploadDir := "./uploads/student_submissions"
-	os.MkdirAll(uploadDir, os.ModePerm)
-
-	ext := filepath.Ext(file.Filename)
-	newFilename := fmt.Sprintf(
-		"submission_%s_%d_%d%s",
+	newFilename := uploadFileName(fmt.Sprintf(
+		"submission_%s_%d_%d",
 		studentStrID,
 		materialID,
 		time.Now().Unix(),
-		ext,
-	)
+	), contentType, file.fileName())
 
-	filePath := filepath.Join(uploadDir, newFilename)
+	filePath := filepath.ToSlash(filepath.Join(uploadDir, newFilename))
 
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "file save failed"})
+	upload, err := processUpload(file, policy, filePath)
+	if err != nil {
+		respondUploadError(c, err)
 		return
 	}
 
@@ -1555,16 +1727,17 @@ func StudentUploadSubmission(c *gin.Context) {
 
 	result, err := config.DB.Exec(`
 		INSERT INTO student_submissions
-		(student_id, material_id, file_name, file_path, file_size, submitted_at, status, remarks)
-		VALUES (?, ?, ?, ?, ?, NOW(), ?, ?)
-	`, studentDBID, materialID, file.Filename, filePath, file.Size, status, remarks)
+		(student_id, material_id, file_name, file_path, file_size, page_count, submitted_at, status, remarks)
+		VALUES (?, ?, ?, ?, ?, ?, NOW(), ?, ?)
+	`, studentDBID, materialID, file.fileName(), filePath, upload.Size, nullInt(upload.Pages), status, remarks)
 
 	if err != nil {
-		os.Remove(filePath)
+		filestore.Remove(filePath)
 		fmt.Println("DB insert error:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "submission save failed"})
 		return
 	}
+	consumed = true
 
 	submissionID, _ := result.LastInsertId()
 
@@ -1580,6 +1753,7 @@ func StudentUploadSubmission(c *gin.Context) {
 		"submission_id": submissionID,
 		"title":         title,
 		"file_path":     cleanPath,
+		"page_count":    upload.Pages,
 		"status":        status,
 	})
 }
@@ -1618,7 +1792,8 @@ func StudentGetSubmissions(c *gin.Context) {
 				IFNULL(ss.remarks, ''),
 				ss.file_path,
 				ss.status,
-				DATE_FORMAT(ss.submitted_at, '%Y-%m-%d %H:%i:%s') as submitted_at
+				DATE_FORMAT(ss.submitted_at, '%Y-%m-%d %H:%i:%s') as submitted_at,
+				ss.score
 			FROM student_submissions ss
 			INNER JOIN lesson_materials lm ON ss.material_id = lm.id
 			INNER JOIN subjects s ON lm.subject_id = s.id
@@ -1636,7 +1811,8 @@ func StudentGetSubmissions(c *gin.Context) {
 				IFNULL(ss.remarks, ''),
 				ss.file_path,
 				ss.status,
-				DATE_FORMAT(ss.submitted_at, '%Y-%m-%d %H:%i:%s') as submitted_at
+				DATE_FORMAT(ss.submitted_at, '%Y-%m-%d %H:%i:%s') as submitted_at,
+				ss.score
 			FROM student_submissions ss
 			INNER JOIN lesson_materials lm ON ss.material_id = lm.id
 			INNER JOIN subjects s ON lm.subject_id = s.id
@@ -1661,12 +1837,13 @@ func StudentGetSubmissions(c *gin.Context) {
 			id                                           int
 			lessonTitle, subjectName, title, description string
 			filePath, status, submittedAt                string
+			score                                        *float64
 		)
 
 		err := rows.Scan(
 			&id, &lessonTitle, &subjectName, &title,
 			&description, &filePath, &status,
-			&submittedAt,
+			&submittedAt, &score,
 		)
 
 		if err != nil {
@@ -1688,6 +1865,7 @@ func StudentGetSubmissions(c *gin.Context) {
 			"file_path":     cleanPath,
 			"status":        status,
 			"submitted_at":  submittedAt,
+			"score":         score,
 		})
 	}
 
@@ -1760,6 +1938,7 @@ func StudentGetAnnouncements(c *gin.Context) {
 				a.image_name,
 				a.image_path,
 				a.image_size,
+				a.image_thumbnail_path,
 				DATE_FORMAT(a.created_at, '%Y-%m-%d %H:%i:%s') as created_at,
 				'teacher' as source
 			FROM announcements a
@@ -1779,7 +1958,7 @@ func StudentGetAnnouncements(c *gin.Context) {
 					id                                   int
 					title, content, subject, subjectCode string
 					teacherName                          string
-					imageName, imagePath                 sql.NullString
+					imageName, imagePath, imageThumb     sql.NullString
 					imageSize                            sql.NullInt64
 					createdAt                            string
 					source                               string
@@ -1787,7 +1966,7 @@ func StudentGetAnnouncements(c *gin.Context) {
 
 				err := rows.Scan(
 					&id, &title, &content, &subject, &subjectCode,
-					&teacherName, &imageName, &imagePath, &imageSize, &createdAt, &source,
+					&teacherName, &imageName, &imagePath, &imageSize, &imageThumb, &createdAt, &source,
 				)
 
 				if err != nil {
@@ -1817,6 +1996,9 @@ func StudentGetAnnouncements(c *gin.Context) {
 					announcement["image_url"] = "/" + cleanPath
 					announcement["image_size"] = imageSize.Int64
 				}
+				if imageThumb.Valid {
+					announcement["image_thumbnail_url"] = "/" + imageThumb.String
+				}
 
 				allAnnouncements = append(allAnnouncements, announcement)
 			}
@@ -1833,6 +2015,7 @@ func StudentGetAnnouncements(c *gin.Context) {
 			image_name,
 			image_path,
 			image_size,
+			image_thumbnail_path,
 			DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s') as created_at
 		FROM registrar_announcements
 		WHERE target_audience = 'all' OR target_audience = ?
@@ -1851,16 +2034,16 @@ func StudentGetAnnouncements(c *gin.Context) {
 
 		for rows.Next() {
 			var (
-				id                        int
-				title, content, targetAud string
-				imageName, imagePath      sql.NullString
-				imageSize                 sql.NullInt64
-				createdAt                 string
+				id                               int
+				title, content, targetAud        string
+				imageName, imagePath, imageThumb sql.NullString
+				imageSize                        sql.NullInt64
+				createdAt                        string
 			)
 
 			err := rows.Scan(
 				&id, &title, &content, &targetAud,
-				&imageName, &imagePath, &imageSize, &createdAt,
+				&imageName, &imagePath, &imageSize, &imageThumb, &createdAt,
 			)
 
 			if err != nil {
@@ -1891,6 +2074,9 @@ func StudentGetAnnouncements(c *gin.Context) {
 				announcement["image_url"] = "/" + cleanPath
 				announcement["image_size"] = imageSize.Int64
 			}
+			if imageThumb.Valid {
+				announcement["image_thumbnail_url"] = "/" + imageThumb.String
+			}
 
 			allAnnouncements = append(allAnnouncements, announcement)
 		}
@@ -1907,6 +2093,7 @@ func StudentGetAnnouncements(c *gin.Context) {
 			image_name,
 			image_path,
 			image_size,
+			image_thumbnail_path,
 			DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s') as created_at
 		FROM records_announcements
 		WHERE is_active = TRUE 
@@ -1923,14 +2110,14 @@ func StudentGetAnnouncements(c *gin.Context) {
 			var (
 				id                                  int
 				title, content, priority, targetAud string
-				imageName, imagePath                sql.NullString
+				imageName, imagePath, imageThumb    sql.NullString
 				imageSize                           sql.NullInt64
 				createdAt                           string
 			)
 
 			err := recordsRows.Scan(
 				&id, &title, &content, &priority, &targetAud,
-				&imageName, &imagePath, &imageSize, &createdAt,
+				&imageName, &imagePath, &imageSize, &imageThumb, &createdAt,
 			)
 
 			if err != nil {
@@ -1962,6 +2149,9 @@ func StudentGetAnnouncements(c *gin.Context) {
 				announcement["image_url"] = "/" + cleanPath
 				announcement["image_size"] = imageSize.Int64
 			}
+			if imageThumb.Valid {
+				announcement["image_thumbnail_url"] = "/" + imageThumb.String
+			}
 
 			allAnnouncements = append(allAnnouncements, announcement)
 		}
@@ -2037,6 +2227,10 @@ func StudentSubmitEnrollment(c *gin.Context) {
 		return
 	}
 
+	if blockedByHolds(c, studentDBID, holdScopeEnrollment, "enroll") {
+		return
+	}
+
 	var existingID int
 	err = config.DB.QueryRow(`
         SELECT id FROM enrollment_applications
@@ -2,13 +2,14 @@ package controllers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"student-portal/config"
+	"student-portal/filestore"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -278,6 +279,14 @@ func TeacherSubmitGrade(c *gin.Context) {
 		return
 	}
 
+	// Classes with a grading scheme compute their grades from raw scores
+	var hasScheme bool
+	config.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM grading_schemes WHERE class_id = ?)`, request.ClassID).Scan(&hasScheme)
+	if hasScheme {
+		c.JSON(http.StatusConflict, gin.H{"error": "This class uses a grading scheme; enter raw scores and grades are computed automatically"})
+		return
+	}
+
 	// FIX: Validate that the student is approved AND enrolled in this class
 	var studentEnrolled bool
 	err = config.DB.QueryRow(`
@@ -311,10 +320,33 @@ func TeacherSubmitGrade(c *gin.Context) {
 
 	// Check if grade already exists
 	var gradeID int
+	var status string
+	var oldPrelim, oldMidterm, oldFinals *float64
 	err = config.DB.QueryRow(`
-		SELECT id FROM grades 
+		SELECT id, IFNULL(status, 'draft'), prelim, midterm, finals FROM grades 
 		WHERE student_id = ? AND subject_id = ? AND teacher_id = ?
-	`, request.StudentID, subjectID, teacherID).Scan(&gradeID)
+	`, request.StudentID, subjectID, teacherID).Scan(&gradeID, &status, &oldPrelim, &oldMidterm, &oldFinals)
+
+	if err == nil && !gradeEditable(status) {
+		c.JSON(http.StatusConflict, gin.H{"error": gradeLockedMessage(status)})
+		return
+	}
+
+	// Only terms whose value actually changes need an open grading window
+	changed := map[string]bool{
+		"prelim":  !sameGrade(request.Prelim, oldPrelim),
+		"midterm": !sameGrade(request.Midterm, oldMidterm),
+		"finals":  !sameGrade(request.Finals, oldFinals),
+	}
+	for _, term := range gradingTerms {
+		if !changed[term] {
+			continue
+		}
+		if werr := checkGradingWindow(term); werr != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Grading window closed: " + werr.Error()})
+			return
+		}
+	}
 
 	if err == nil {
 		// Update existing grade
@@ -337,10 +369,11 @@ func TeacherSubmitGrade(c *gin.Context) {
 		})
 	} else {
 		// Insert new grade
+		schoolYear, semester := gradeTermArgs()
 		result, err := config.DB.Exec(`
-			INSERT INTO grades (student_id, subject_id, teacher_id, prelim, midterm, finals, remarks, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
-		`, request.StudentID, subjectID, teacherID, request.Prelim, request.Midterm, request.Finals, request.Remarks)
+			INSERT INTO grades (student_id, subject_id, teacher_id, prelim, midterm, finals, remarks, school_year, semester, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+		`, request.StudentID, subjectID, teacherID, request.Prelim, request.Midterm, request.Finals, request.Remarks, schoolYear, semester)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit grade"})
@@ -391,6 +424,7 @@ func TeacherGetGrades(c *gin.Context) {
 	query := `
 		SELECT 
 			st.id,
+			IFNULL(st.student_id, ''),
 			st.first_name,
 			st.last_name,
 			st.email,
@@ -398,7 +432,12 @@ func TeacherGetGrades(c *gin.Context) {
 			g.prelim,
 			g.midterm,
 			g.finals,
-			IFNULL(g.remarks, '')
+			IFNULL(g.remarks, ''),
+			g.final_grade,
+			IFNULL(g.final_remark, ''),
+			IFNULL(g.mark, ''),
+			IFNULL(g.status, ''),
+			IFNULL(g.review_note, '')
 		FROM student_academic sa
 		INNER JOIN students st ON st.id = sa.student_id
 		LEFT JOIN grades g 
@@ -418,10 +457,12 @@ func TeacherGetGrades(c *gin.Context) {
 	defer rows.Close()
 
 	var students []gin.H
+	sheet := [][]string{gradeSheetHeader}
 
 	for rows.Next() {
 		var (
 			id        int
+			studentNo string
 			first     string
 			last      string
 			email     string
@@ -430,29 +471,49 @@ func TeacherGetGrades(c *gin.Context) {
 			midterm   *float64
 			finals    *float64
 			remarks   string
+			final     *float64
+			remark    string
+			mark      string
+			status    string
+			note      string
 		)
 
-		if err := rows.Scan(&id, &first, &last, &email, &yearLevel, &prelim, &midterm, &finals, &remarks); err != nil {
+		if err := rows.Scan(&id, &studentNo, &first, &last, &email, &yearLevel, &prelim, &midterm, &finals, &remarks, &final, &remark, &mark, &status, &note); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
 		students = append(students, gin.H{
-			"student_id": id,
-			"full_name":  first + " " + last,
-			"email":      email,
-			"year_level": yearLevel,
-			"prelim":     prelim,
-			"midterm":    midterm,
-			"finals":     finals,
-			"remarks":    remarks,
+			"student_id":     id,
+			"student_number": studentNo,
+			"full_name":      first + " " + last,
+			"email":          email,
+			"year_level":     yearLevel,
+			"prelim":         prelim,
+			"midterm":        midterm,
+			"finals":         finals,
+			"remarks":        remarks,
+			"final_grade":    final,
+			"final_remark":   remark,
+			"mark":           mark,
+			"status":         status,
+			"review_note":    note,
 		})
+		sheet = append(sheet, gradeSheetRow(id, studentNo, last, first, prelim, midterm, finals, remarks))
 	}
 
 	if students == nil {
 		students = []gin.H{}
 	}
 
+	// ?format=csv|xlsx downloads the same list as a grade sheet that can be
+	// filled in and sent back to /teacher/grades/import
+	if format := c.Query("format"); format == "csv" || format == "xlsx" {
+		classNum, _ := strconv.Atoi(classID)
+		writeGradeSheet(c, format, fmt.Sprintf("grades_class_%d", classNum), sheet)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"students": students,
 		"total":    len(students),
@@ -517,82 +578,55 @@ func TeacherUploadLesson(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("file")
-	if err != nil {
+	// Either a multipart file or a finished resumable upload (upload_id)
+	file, err := uploadFromRequest(c, "file", uploadPurposeLesson)
+	if errors.Is(err, http.ErrMissingFile) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
 		return
 	}
-
-	allowedTypes := map[string][]string{
-		"image":    {"image/jpeg", "image/jpg", "image/png", "image/gif"},
-		"video":    {"video/mp4", "video/mpeg", "video/quicktime", "video/x-msvideo", "video/webm"},
-		"document": {"application/pdf"},
-	}
-
-	fileHeader, err := file.Open()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
-		return
-	}
-	defer fileHeader.Close()
-
-	buffer := make([]byte, 512)
-	_, err = fileHeader.Read(buffer)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file content"})
+		respondUploadError(c, err)
 		return
 	}
+	consumed := false
+	defer func() { file.finish(consumed) }()
 
-	contentType := http.DetectContentType(buffer)
-
-	validType := false
-	for _, t := range allowedTypes[materialType] {
-		if t == contentType {
-			validType = true
-			break
-		}
-	}
-	if !validType {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Invalid file type. Detected: %s, allowed: %v", contentType, allowedTypes[materialType]),
-		})
-		return
-	}
-
-	maxSize := int64(10 * 1024 * 1024)
-	if materialType == "video" {
-		maxSize = int64(100 * 1024 * 1024)
-	}
-	if file.Size > maxSize {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File too large. Max size: %dMB", maxSize/(1024*1024))})
-		return
+	policies := map[string]uploadPolicy{
+		"image":    {maxSize: 10 * 1024 * 1024, types: []string{"image/jpeg", "image/png", "image/gif"}, maxImageDim: maxImageDim},
+		"video":    {maxSize: 100 * 1024 * 1024, types: []string{"video/mp4", "video/mpeg", "video/quicktime", "video/x-msvideo", "video/webm"}},
+		"document": {maxSize: 10 * 1024 * 1024, types: []string{"application/pdf"}, thumbnail: true},
 	}
+	policy := policies[materialType]
 
-	uploadDir := "./uploads/lessons"
-	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+	contentType, err := sniffUpload(file, policy)
+	if err != nil {
+		respondUploadError(c, err)
 		return
 	}
 
-	fileExt := filepath.Ext(file.Filename)
-	fileName := fmt.Sprintf("%d_%d_%s%s", teacherID, time.Now().Unix(), strings.ReplaceAll(uuid.New().String(), "-", ""), fileExt)
-	filePath := filepath.Join(uploadDir, fileName)
+	uploadDir := "uploads/lessons"
+	fileName := uploadFileName(fmt.Sprintf("%d_%d_%s", teacherID, time.Now().Unix(), strings.ReplaceAll(uuid.New().String(), "-", "")), contentType, file.fileName())
+	filePath := filepath.ToSlash(filepath.Join(uploadDir, fileName))
 
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	upload, err := processUpload(file, policy, filePath)
+	if err != nil {
+		respondUploadError(c, err)
 		return
 	}
 
 	result, err := config.DB.Exec(`
 		INSERT INTO lesson_materials
-		(teacher_id, subject_id, class_id, title, description, type, file_name, file_path, file_size, due_date, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
-	`, teacherID, subjectID, classID, title, description, materialType, fileName, filePath, file.Size, dueDate)
+		(teacher_id, subject_id, class_id, title, description, type, file_name, file_path, file_size, due_date,
+		 page_count, thumbnail_path, preview_text, video_status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+	`, teacherID, subjectID, classID, title, description, materialType, fileName, filePath, upload.Size, dueDate,
+		nullInt(upload.Pages), nullString(upload.ThumbnailPath), nullString(upload.PreviewText), videoStatusFor(materialType))
 	if err != nil {
-		os.Remove(filePath)
+		removeUpload(upload.Path, upload.ThumbnailPath)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save lesson material: " + err.Error()})
 		return
 	}
+	consumed = true
 
 	materialID, _ := result.LastInsertId()
 
@@ -602,7 +636,16 @@ func TeacherUploadLesson(c *gin.Context) {
 		"title":       title,
 		"type":        materialType,
 		"file_name":   fileName,
-		"file_size":   file.Size,
+		"file_size":   upload.Size,
+	}
+	if upload.Pages > 0 {
+		response["page_count"] = upload.Pages
+	}
+	if upload.ThumbnailPath != "" {
+		response["thumbnail_url"] = "/" + upload.ThumbnailPath
+	}
+	if materialType == "video" {
+		response["video_status"] = "pending"
 	}
 	if dueDate.Valid {
 		response["due_date"] = dueDate.Time.Format("2006-01-02 15:04:05")
@@ -646,6 +689,12 @@ func TeacherGetLessonMaterials(c *gin.Context) {
 			lm.file_name,
 			lm.file_path,
 			lm.file_size,
+			lm.page_count,
+			lm.thumbnail_path,
+			lm.preview_text,
+			lm.video_status,
+			lm.video_duration,
+			lm.poster_path,
 			lm.due_date,
 			lm.created_at,
 			COUNT(DISTINCT ss.id) as total_submissions,
@@ -675,6 +724,12 @@ func TeacherGetLessonMaterials(c *gin.Context) {
 			fileName         string
 			filePath         string
 			fileSize         int64
+			pageCount        sql.NullInt64
+			thumbPath        sql.NullString
+			previewText      sql.NullString
+			videoStatus      sql.NullString
+			videoDuration    sql.NullInt64
+			posterPath       sql.NullString
 			dueDate          sql.NullString
 			createdAt        string
 			totalSubmissions int
@@ -682,6 +737,7 @@ func TeacherGetLessonMaterials(c *gin.Context) {
 		)
 
 		if err := rows.Scan(&id, &title, &description, &matType, &fileName, &filePath, &fileSize,
+			&pageCount, &thumbPath, &previewText, &videoStatus, &videoDuration, &posterPath,
 			&dueDate, &createdAt, &totalSubmissions, &lateSubmissions); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -699,6 +755,8 @@ func TeacherGetLessonMaterials(c *gin.Context) {
 			"late_submissions":  lateSubmissions,
 			"download_url":      "/api/lessons/download/" + strconv.Itoa(id),
 		}
+		addMaterialPreview(material, pageCount, thumbPath, previewText)
+		addVideoDetails(material, id, videoStatus, videoDuration, posterPath)
 
 		if dueDate.Valid {
 			parsedTime, err := time.Parse("2006-01-02 15:04:05", dueDate.String)
@@ -760,6 +818,7 @@ func TeacherGetSubmissions(c *gin.Context) {
 			ss.file_name,
 			ss.file_path,
 			ss.file_size,
+			ss.page_count,
 			ss.submitted_at,
 			ss.status,
 			IFNULL(ss.remarks, '')
@@ -789,13 +848,14 @@ func TeacherGetSubmissions(c *gin.Context) {
 			fileName  string
 			filePath  string
 			fileSize  int64
+			pageCount sql.NullInt64
 			submitted string
 			status    string
 			remarks   sql.NullString
 		)
 
 		if err := rows.Scan(&id, &studentID, &firstName, &lastName, &email,
-			&fileName, &filePath, &fileSize, &submitted, &status, &remarks); err != nil {
+			&fileName, &filePath, &fileSize, &pageCount, &submitted, &status, &remarks); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -819,6 +879,7 @@ func TeacherGetSubmissions(c *gin.Context) {
 			"file_path":    cleanPath,
 			"download_url": cleanPath,
 			"file_size":    fileSize,
+			"page_count":   pageCount.Int64,
 			"submitted_at": submitted,
 			"status":       status,
 			"remarks":      remarksValue,
@@ -846,11 +907,13 @@ func TeacherDeleteLessonMaterial(c *gin.Context) {
 		return
 	}
 
+	var id int
 	var filePath string
+	var thumbPath sql.NullString
 	err := config.DB.QueryRow(`
-		SELECT file_path FROM lesson_materials 
+		SELECT id, file_path, thumbnail_path FROM lesson_materials 
 		WHERE id = ? AND teacher_id = ?
-	`, materialID, teacherID).Scan(&filePath)
+	`, materialID, teacherID).Scan(&id, &filePath, &thumbPath)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found or not owned by you"})
@@ -873,15 +936,19 @@ func TeacherDeleteLessonMaterial(c *gin.Context) {
 		}
 	}
 
+	// Keep the gradebook column and its scores; only the lesson goes away
+	config.DB.Exec(`UPDATE grade_items SET material_id = NULL WHERE material_id = ?`, materialID)
+
 	_, err = config.DB.Exec(`DELETE FROM lesson_materials WHERE id = ?`, materialID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete material"})
 		return
 	}
 
-	os.Remove(filePath)
+	removeUpload(filePath, thumbPath.String)
+	removeVideoOutputs(id)
 	for _, path := range submissionPaths {
-		os.Remove(path)
+		filestore.Remove(path)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Lesson material deleted successfully"})
@@ -986,11 +1053,6 @@ func TeacherReviewSubmission(c *gin.Context) {
 		return
 	}
 
-	if request.Grade != nil && (*request.Grade < 0 || *request.Grade > 100) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Grade must be between 0 and 100"})
-		return
-	}
-
 	// FIX: Also verify that the submitting student is approved
 	var materialID int
 	var studentID int
@@ -1015,37 +1077,42 @@ func TeacherReviewSubmission(c *gin.Context) {
 		return
 	}
 
+	// Lessons linked to the gradebook are scored out of the item's max points;
+	// unlinked ones keep the old 0-100 scale.
+	maxScore := 100.0
+	config.DB.QueryRow(`SELECT max_score FROM grade_items WHERE material_id = ?`, materialID).Scan(&maxScore)
+
+	if request.Grade != nil && (*request.Grade < 0 || *request.Grade > maxScore) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Grade must be between 0 and %g", maxScore)})
+		return
+	}
+
+	// A rejected submission loses its score; an accepted one without a grade keeps it
+	var score *float64
+	if request.Status == "accepted" {
+		score = request.Grade
+	}
+
 	_, err = config.DB.Exec(`
 		UPDATE student_submissions
-		SET status = ?, remarks = ?, reviewed_at = NOW()
+		SET status = ?, remarks = ?, reviewed_at = NOW(),
+		    score = CASE WHEN ? = 'accepted' THEN COALESCE(?, score) ELSE NULL END
 		WHERE id = ?
-	`, request.Status, request.Remarks, submissionID)
+	`, request.Status, request.Remarks, request.Status, score, submissionID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update submission"})
 		return
 	}
 
-	if request.Grade != nil && request.Status == "accepted" {
-		var subjectID int
-		err = config.DB.QueryRow(`
-			SELECT subject_id FROM lesson_materials WHERE id = ?
-		`, materialID).Scan(&subjectID)
-
-		if err == nil {
-			var gradeID int
-			err = config.DB.QueryRow(`
-				SELECT id FROM grades 
-				WHERE student_id = ? AND subject_id = ? AND teacher_id = ?
-			`, studentID, subjectID, teacherID).Scan(&gradeID)
-
-			if err == nil {
-				_, _ = config.DB.Exec(`
-					UPDATE grades 
-					SET remarks = CONCAT(IFNULL(remarks, ''), '\nSubmission Grade: ', ?), updated_at = NOW()
-					WHERE id = ?
-				`, *request.Grade, gradeID)
-			}
+	// Rejecting a resubmission falls back to the score of an earlier
+	// accepted one rather than clearing it
+	if score != nil || request.Status == "rejected" {
+		classID, subjectID, linked, err := syncSubmissionScore(materialID, studentID)
+		if err != nil {
+			fmt.Println("⚠️ Warning: could not record submission score in gradebook:", err)
+		} else if linked {
+			recomputeAfterChange(classID, teacherID, subjectID)
 		}
 	}
 
@@ -1228,67 +1295,42 @@ func TeacherPostAnnouncement(c *gin.Context) {
 		return
 	}
 
-	var imageName, imagePath sql.NullString
+	var imageName, imagePath, imageThumb sql.NullString
 	var imageSize sql.NullInt64
 
 	file, err := c.FormFile("image")
 	if err == nil {
-		allowedTypes := []string{"image/jpeg", "image/jpg", "image/png", "image/gif", "image/webp"}
-
-		fileHeader, err := file.Open()
+		contentType, err := sniffUpload(formUpload{file}, announcementImagePolicy)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+			respondUploadError(c, err)
 			return
 		}
-		defer fileHeader.Close()
 
-		buffer := make([]byte, 512)
-		fileHeader.Read(buffer)
-		contentType := http.DetectContentType(buffer)
+		uploadDir := "uploads/announcements"
 
-		valid := false
-		for _, t := range allowedTypes {
-			if t == contentType {
-				valid = true
-				break
-			}
-		}
-		if !valid {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Image must be JPEG, PNG, GIF, or WebP"})
-			return
-		}
-		if file.Size > int64(10*1024*1024) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Image too large. Max 10MB"})
-			return
-		}
+		fileName := uploadFileName(fmt.Sprintf("%d_%d_%s", teacherID, time.Now().Unix(),
+			strings.ReplaceAll(uuid.New().String(), "-", "")), contentType, file.Filename)
+		savedPath := filepath.ToSlash(filepath.Join(uploadDir, fileName))
 
-		uploadDir := "./uploads/announcements"
-		if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
-			return
-		}
-
-		fileExt := filepath.Ext(file.Filename)
-		fileName := fmt.Sprintf("%d_%d_%s%s", teacherID, time.Now().Unix(),
-			strings.ReplaceAll(uuid.New().String(), "-", ""), fileExt)
-		savedPath := filepath.Join(uploadDir, fileName)
-
-		if err := c.SaveUploadedFile(file, savedPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		upload, err := processUpload(formUpload{file}, announcementImagePolicy, savedPath)
+		if err != nil {
+			respondUploadError(c, err)
 			return
 		}
 
 		imageName = sql.NullString{String: file.Filename, Valid: true}
 		imagePath = sql.NullString{String: savedPath, Valid: true}
-		imageSize = sql.NullInt64{Int64: file.Size, Valid: true}
+		imageSize = sql.NullInt64{Int64: upload.Size, Valid: true}
+		imageThumb = nullString(upload.ThumbnailPath)
 	}
 
 	result, err := config.DB.Exec(`
-        INSERT INTO announcements (teacher_id, class_id, subject_id, title, content, image_name, image_path, image_size, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
-    `, teacherID, classID, subjectID, title, content, imageName, imagePath, imageSize)
+        INSERT INTO announcements (teacher_id, class_id, subject_id, title, content, image_name, image_path, image_size, image_thumbnail_path, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+    `, teacherID, classID, subjectID, title, content, imageName, imagePath, imageSize, imageThumb)
 
 	if err != nil {
+		removeUpload(imagePath.String, imageThumb.String)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post announcement: " + err.Error()})
 		return
 	}
@@ -1301,7 +1343,10 @@ func TeacherPostAnnouncement(c *gin.Context) {
 		"title":           title,
 	}
 	if imagePath.Valid {
-		response["image_url"] = "/" + strings.ReplaceAll(imagePath.String, "\\", "/")[2:]
+		response["image_url"] = "/" + imagePath.String
+	}
+	if imageThumb.Valid {
+		response["image_thumbnail_url"] = "/" + imageThumb.String
 	}
 
 	c.JSON(http.StatusCreated, response)
@@ -1332,7 +1377,7 @@ func TeacherGetAnnouncements(c *gin.Context) {
 	}
 
 	rows, err := config.DB.Query(`
-        SELECT id, title, content, image_name, image_path, image_size, created_at
+        SELECT id, title, content, image_name, image_path, image_size, image_thumbnail_path, created_at
         FROM announcements
         WHERE teacher_id = ? AND class_id = ?
         ORDER BY created_at DESC
@@ -1346,15 +1391,16 @@ func TeacherGetAnnouncements(c *gin.Context) {
 	var announcements []gin.H
 	for rows.Next() {
 		var (
-			id        int
-			title     string
-			content   string
-			imageName sql.NullString
-			imagePath sql.NullString
-			imageSize sql.NullInt64
-			createdAt string
+			id         int
+			title      string
+			content    string
+			imageName  sql.NullString
+			imagePath  sql.NullString
+			imageSize  sql.NullInt64
+			imageThumb sql.NullString
+			createdAt  string
 		)
-		if err := rows.Scan(&id, &title, &content, &imageName, &imagePath, &imageSize, &createdAt); err != nil {
+		if err := rows.Scan(&id, &title, &content, &imageName, &imagePath, &imageSize, &imageThumb, &createdAt); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -1375,6 +1421,9 @@ func TeacherGetAnnouncements(c *gin.Context) {
 			item["image_url"] = "/" + cleanPath
 			item["image_size"] = imageSize.Int64
 		}
+		if imageThumb.Valid {
+			item["image_thumbnail_url"] = "/" + imageThumb.String
+		}
 		announcements = append(announcements, item)
 	}
 
@@ -1399,10 +1448,10 @@ func TeacherDeleteAnnouncement(c *gin.Context) {
 		return
 	}
 
-	var filePath sql.NullString
+	var filePath, thumbPath sql.NullString
 	err := config.DB.QueryRow(`
-        SELECT image_path FROM announcements WHERE id = ? AND teacher_id = ?
-    `, announcementID, teacherID).Scan(&filePath)
+        SELECT image_path, image_thumbnail_path FROM announcements WHERE id = ? AND teacher_id = ?
+    `, announcementID, teacherID).Scan(&filePath, &thumbPath)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Announcement not found or not owned by you"})
@@ -1419,9 +1468,7 @@ func TeacherDeleteAnnouncement(c *gin.Context) {
 		return
 	}
 
-	if filePath.Valid {
-		os.Remove(filePath.String)
-	}
+	removeUpload(filePath.String, thumbPath.String)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Announcement deleted successfully"})
 }
@@ -0,0 +1,426 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"student-portal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ===================== TRANSCRIPT OF RECORDS =====================
+//
+// Blocks used by the transcript template: entrance data, the resident record
+// grouped by term, subjects credited from other schools, the grading legend
+// and the closing marker. Each record row is counted against its page so the
+// page footer can state how many entries the page carries.
+
+type creditedSubject struct {
+	ID           int     `json:"id"`
+	SubjectCode  string  `json:"subject_code"`
+	SubjectName  string  `json:"subject_name"`
+	Units        float64 `json:"units"`
+	Grade        string  `json:"grade"`
+	SourceSchool string  `json:"source_school"`
+	SchoolYear   string  `json:"school_year"`
+	Semester     string  `json:"semester"`
+	Remarks      string  `json:"remarks"`
+}
+
+func loadCreditedSubjects(studentDBID int) ([]creditedSubject, error) {
+	rows, err := config.DB.Query(`
+		SELECT id, subject_code, subject_name, units, IFNULL(grade, ''), source_school,
+		       IFNULL(school_year, ''), IFNULL(semester, ''), IFNULL(remarks, '')
+		FROM credited_subjects
+		WHERE student_id = ?
+		ORDER BY source_school, school_year, semester, subject_code
+	`, studentDBID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []creditedSubject{}
+	for rows.Next() {
+		var cs creditedSubject
+		if err := rows.Scan(&cs.ID, &cs.SubjectCode, &cs.SubjectName, &cs.Units, &cs.Grade, &cs.SourceSchool,
+			&cs.SchoolYear, &cs.Semester, &cs.Remarks); err != nil {
+			return nil, err
+		}
+		credits = append(credits, cs)
+	}
+	return credits, rows.Err()
+}
+
+// transcriptRowHeight is the height of one record row; rows never split
+// across pages.
+const transcriptRowHeight = 7.0
+
+// ensureRoom starts a new page when h more millimetres would run into the
+// footer. It reports whether a page was added.
+func ensureRoom(pdf *gofpdf.Fpdf, h float64) bool {
+	_, pageH := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+h <= pageH-bottom {
+		return false
+	}
+	pdf.AddPage()
+	return true
+}
+
+func writeRecordHeader(pdf *gofpdf.Fpdf, columns []string, widths []float64) {
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(40, 145, 108)
+	pdf.SetTextColor(255, 255, 255)
+	for i, col := range columns {
+		align := "C"
+		if i == 0 {
+			align = "L"
+		}
+		ln := 0
+		if i == len(columns)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(widths[i], 8, col, "1", ln, align, true, 0, "")
+	}
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Arial", "", 9)
+	pdf.SetFillColor(245, 245, 245)
+}
+
+// writeRecordRow prints one counted row, repeating the caption and column
+// header at the top of a new page.
+func writeRecordRow(ctx *documentContext, caption string, columns []string, widths []float64, cells []string, fill bool) {
+	pdf := ctx.pdf
+	if ensureRoom(pdf, transcriptRowHeight) {
+		pdf.SetFont("Arial", "B", 9)
+		pdf.Cell(0, 7, caption+" (continued)")
+		pdf.Ln(7)
+		writeRecordHeader(pdf, columns, widths)
+	}
+	for i, cell := range cells {
+		align := "C"
+		if i == 0 {
+			align = "L"
+		}
+		ln := 0
+		if i == len(cells)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(widths[i], transcriptRowHeight, cell, "1", ln, align, fill, 0, "")
+	}
+	ctx.entries[pdf.PageNo()]++
+}
+
+func writeEntranceData(ctx *documentContext) error {
+	var lastSchool, lastSchoolYear, admitted string
+	if ctx.data.StudentDBID > 0 {
+		config.DB.QueryRow(`
+			SELECT IFNULL(sa.last_school_attended, ''), IFNULL(sa.last_school_year, ''),
+			       DATE_FORMAT(IFNULL(sa.created_at, s.created_at), '%M %d, %Y')
+			FROM students s
+			LEFT JOIN student_academic sa ON sa.student_id = s.id
+			WHERE s.id = ?
+			LIMIT 1
+		`, ctx.data.StudentDBID).Scan(&lastSchool, &lastSchoolYear, &admitted)
+	}
+	if lastSchool == "" {
+		lastSchool = "-"
+	}
+	if lastSchoolYear != "" {
+		lastSchool += " (S.Y. " + lastSchoolYear + ")"
+	}
+	if admitted == "" {
+		admitted = "-"
+	}
+
+	writeDocumentSection(ctx.pdf, "ENTRANCE DATA")
+	writeDocumentFields(ctx.pdf, [][2]string{
+		{"School Last Attended:", lastSchool},
+		{"Date Admitted:", admitted},
+		{"Program Admitted To:", ctx.data.Course},
+	})
+	return nil
+}
+
+// writeGradesTable prints released grades grouped by school year and
+// semester with each term's GWA, then the cumulative GWA and latin honors.
+func writeGradesTable(ctx *documentContext) error {
+	standing, err := ctx.academicStanding()
+	if err != nil {
+		return err
+	}
+	pdf := ctx.pdf
+
+	columns := []string{"SUBJECT/COURSE", "UNITS", "GRADE", "REMARKS"}
+	widths := []float64{85, 25, 30, 40}
+
+	rowCount := 0
+	for _, term := range standing.Terms {
+		caption := fmt.Sprintf("%s, S.Y. %s", term.Semester, term.SchoolYear)
+		if term.unassigned() {
+			caption = "Term not recorded"
+		}
+
+		// Keep the caption, header and first row together
+		ensureRoom(pdf, 15+transcriptRowHeight)
+		pdf.SetFont("Arial", "B", 9)
+		pdf.Cell(0, 7, caption)
+		pdf.Ln(7)
+		writeRecordHeader(pdf, columns, widths)
+
+		for _, subj := range term.Subjects {
+			// Without a numeric grade the mark (DRP, INC...) is shown
+			gradeStr := "-"
+			if subj.Grade != nil {
+				gradeStr = fmt.Sprintf("%.2f", *subj.Grade)
+			} else if subj.Mark != "" {
+				gradeStr = subj.Mark
+			}
+			units := fmt.Sprintf("%.1f", subj.Units)
+			if !subj.Counted {
+				// Non-credit subjects are shown in parentheses
+				units = "(" + units + ")"
+			}
+
+			// Alternate row colors
+			writeRecordRow(ctx, caption, columns, widths,
+				[]string{subj.SubjectCode + " - " + subj.SubjectName, units, gradeStr, subj.Remark}, rowCount%2 == 0)
+			rowCount++
+		}
+
+		termGWA := "-"
+		if term.GWA != nil {
+			termGWA = fmt.Sprintf("%.2f", *term.GWA)
+		}
+		deansList := ""
+		if term.DeansList {
+			deansList = "Dean's List"
+		}
+		ensureRoom(pdf, transcriptRowHeight)
+		pdf.SetFont("Arial", "B", 9)
+		pdf.CellFormat(85, 7, "Term GWA", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(25, 7, fmt.Sprintf("%.1f", term.UnitsCounted), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(30, 7, termGWA, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(40, 7, deansList, "1", 1, "C", false, 0, "")
+		pdf.Ln(4)
+	}
+
+	if rowCount == 0 {
+		pdf.SetFont("Arial", "I", 10)
+		pdf.Cell(0, 10, "No grades available or released at this time.")
+		pdf.Ln(10)
+		return nil
+	}
+
+	ensureRoom(pdf, 25)
+	fields := [][2]string{{"Cumulative GWA:", "-"}}
+	if standing.GWA != nil {
+		fields[0][1] = fmt.Sprintf("%.2f (%.1f units)", *standing.GWA, standing.UnitsCounted)
+	}
+	fields = append(fields, [2]string{"Units Earned:", fmt.Sprintf("%.1f", standing.UnitsEarned+standing.CreditedUnits)})
+	if standing.LatinHonors != "" {
+		fields = append(fields, [2]string{"Academic Honors:", standing.LatinHonors})
+	}
+	writeDocumentFields(pdf, fields)
+	return nil
+}
+
+// writeCreditedSubjects lists subjects credited from other schools. They
+// count toward units earned but not toward the GWA.
+func writeCreditedSubjects(ctx *documentContext) error {
+	if ctx.data.StudentDBID == 0 {
+		return nil
+	}
+	credits, err := loadCreditedSubjects(ctx.data.StudentDBID)
+	if err != nil || len(credits) == 0 {
+		return err
+	}
+	pdf := ctx.pdf
+
+	ensureRoom(pdf, 30)
+	writeDocumentSection(pdf, "CREDITED / TRANSFERRED SUBJECTS")
+
+	columns := []string{"SUBJECT/COURSE", "UNITS", "GRADE", "CREDITED FROM"}
+	widths := []float64{85, 25, 30, 40}
+	caption := "Credited / transferred subjects"
+	writeRecordHeader(pdf, columns, widths)
+
+	for i, cs := range credits {
+		source := cs.SourceSchool
+		if cs.SchoolYear != "" {
+			source += " " + cs.SchoolYear
+		}
+		grade := cs.Grade
+		if grade == "" {
+			grade = "CR"
+		}
+		writeRecordRow(ctx, caption, columns, widths,
+			[]string{cs.SubjectCode + " - " + cs.SubjectName, fmt.Sprintf("%.1f", cs.Units), grade, truncatePDF(pdf, source, 38)}, i%2 == 0)
+	}
+	pdf.Ln(6)
+	return nil
+}
+
+// truncatePDF shortens s with "..." until it fits in width millimetres at the
+// current font.
+func truncatePDF(pdf *gofpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	for len(s) > 0 && pdf.GetStringWidth(s+"...") > width {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
+
+func writeGradingLegend(ctx *documentContext) error {
+	pdf := ctx.pdf
+
+	var legend []string
+	if rows, err := loadTransmutationRows(0); err == nil {
+		upper := 100.0
+		for _, r := range rows {
+			legend = append(legend, fmt.Sprintf("%.2f = %g-%g%%", r.Grade, r.MinPercent, upper))
+			upper = r.MinPercent - 1
+		}
+	}
+	legend = append(legend,
+		fmt.Sprintf("%.2f = Failed", failingGrade),
+		"INC = Incomplete",
+		"DRP = Dropped",
+		"CR = Credited",
+		"(units) = not credited toward GWA")
+
+	ensureRoom(pdf, 30)
+	writeDocumentSection(pdf, "GRADING SYSTEM")
+	pdf.SetFont("Arial", "", 8)
+
+	// Four columns
+	const colW = 45.0
+	left, _, _, _ := pdf.GetMargins()
+	for i, item := range legend {
+		if i%4 == 0 && i > 0 {
+			pdf.Ln(4)
+		}
+		pdf.SetX(left + float64(i%4)*colW)
+		pdf.Cell(colW, 4, item)
+	}
+	pdf.Ln(6)
+	pdf.MultiCell(0, 4, fmt.Sprintf("Passing grade is %.2f. The General Weighted Average (GWA) is computed "+
+		"as the sum of grade x units divided by the total units of credited subjects.", passingGrade), "", "L", false)
+	pdf.Ln(6)
+	return nil
+}
+
+// writeEndOfRecord closes the record with the total entry count so rows
+// cannot be appended after it.
+func writeEndOfRecord(ctx *documentContext) error {
+	total := 0
+	for _, n := range ctx.entries {
+		total += n
+	}
+
+	pdf := ctx.pdf
+	ensureRoom(pdf, 12)
+	pdf.SetFont("Arial", "B", 9)
+	pdf.CellFormat(0, 6, fmt.Sprintf("*** NOTHING FOLLOWS - %d %s ***", total, pluralize(total, "entry", "entries")),
+		"TB", 1, "C", false, 0, "")
+	pdf.Ln(6)
+	return nil
+}
+
+func pluralize(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// ===================== CREDITED SUBJECTS =====================
+
+// GET /records/grades/student/:student_id/credits
+func RecordsGetCreditedSubjects(c *gin.Context) {
+	studentID, err := strconv.Atoi(c.Param("student_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student_id"})
+		return
+	}
+
+	credits, err := loadCreditedSubjects(studentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load credited subjects"})
+		return
+	}
+
+	var units float64
+	for _, cs := range credits {
+		units += cs.Units
+	}
+	c.JSON(http.StatusOK, gin.H{"credits": credits, "total_units": units})
+}
+
+// POST /records/grades/student/:student_id/credits
+func RecordsAddCreditedSubject(c *gin.Context) {
+	studentID, err := strconv.Atoi(c.Param("student_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student_id"})
+		return
+	}
+
+	var req creditedSubject
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.SubjectCode = strings.TrimSpace(req.SubjectCode)
+	req.SubjectName = strings.TrimSpace(req.SubjectName)
+	req.SourceSchool = strings.TrimSpace(req.SourceSchool)
+	if req.SubjectCode == "" || req.SubjectName == "" || req.SourceSchool == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subject_code, subject_name and source_school are required"})
+		return
+	}
+	if req.Units <= 0 || req.Units > 10 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "units must be between 0 and 10"})
+		return
+	}
+
+	var exists int
+	config.DB.QueryRow(`SELECT COUNT(*) FROM students WHERE id = ?`, studentID).Scan(&exists)
+	if exists == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "student not found"})
+		return
+	}
+
+	res, err := config.DB.Exec(`
+		INSERT INTO credited_subjects
+		(student_id, subject_code, subject_name, units, grade, source_school, school_year, semester, remarks, credited_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, studentID, req.SubjectCode, req.SubjectName, req.Units, strings.TrimSpace(req.Grade), req.SourceSchool,
+		req.SchoolYear, req.Semester, req.Remarks, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save credited subject"})
+		return
+	}
+
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"message": "Subject credited", "id": id})
+}
+
+// DELETE /records/credits/:id
+func RecordsDeleteCreditedSubject(c *gin.Context) {
+	res, err := config.DB.Exec(`DELETE FROM credited_subjects WHERE id = ?`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete credited subject"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "credited subject not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Credited subject removed"})
+}
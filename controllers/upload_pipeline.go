@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"student-portal/antivirus"
+	"student-portal/filestore"
+	"student-portal/media"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== UPLOAD PIPELINE =====================
+//
+// Every user upload goes through processUpload: size cap, content sniffing
+// checked against both the allowed types and the file name's extension, a
+// virus scan (VIRUS_SCANNER: clamav, or fake in development), then per type:
+//   - images are re-encoded without EXIF/XMP, scaled down to maxImageDim and,
+//     when the policy asks, get a thumbnail under <dir>/thumbs/
+//   - PDFs are parsed for their page count and a text preview; files with
+//     JavaScript, launch actions or embedded files are refused. A preview
+//     image of the first page is made when pdftoppm is installed
+//   - anything else (video, text) is stored as uploaded
+
+const (
+	thumbnailDim   = 320
+	maxImageDim    = 2560
+	pdfPreviewSize = 640
+)
+
+type uploadPolicy struct {
+	maxSize     int64
+	types       []string // allowed sniffed content types
+	maxImageDim int      // 0 keeps the original size
+	thumbnail   bool     // images and PDFs get a thumbnail
+}
+
+func (p uploadPolicy) allows(contentType string) bool {
+	for _, t := range p.types {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// announcementImagePolicy covers the optional image on teacher, records and
+// registrar announcements. WebP is left out: it cannot be decoded here, so
+// it would get no thumbnail.
+var announcementImagePolicy = uploadPolicy{
+	maxSize:     10 * 1024 * 1024,
+	types:       []string{"image/jpeg", "image/png", "image/gif"},
+	maxImageDim: maxImageDim,
+	thumbnail:   true,
+}
+
+// processedUpload is what was stored for one upload.
+type processedUpload struct {
+	Path          string
+	ThumbnailPath string // empty when there is none
+	ContentType   string
+	Size          int64
+	Width         int
+	Height        int
+	Pages         int
+	PreviewText   string
+}
+
+// uploadError is a rejection to report to the uploader as it is.
+type uploadError struct {
+	status  int
+	message string
+}
+
+func (e *uploadError) Error() string { return e.message }
+
+func rejectUpload(status int, format string, args ...interface{}) *uploadError {
+	return &uploadError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// respondUploadError answers with the rejection, or a 500 for storage errors.
+func respondUploadError(c *gin.Context, err error) {
+	var ue *uploadError
+	if errors.As(err, &ue) {
+		c.JSON(ue.status, gin.H{"error": ue.message})
+		return
+	}
+	fmt.Println("❌ Error saving upload:", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+}
+
+// uploadFileName builds the stored name for an upload: the base chosen by the
+// handler and the extension of the detected type, not the one sent.
+func uploadFileName(base, contentType, original string) string {
+	ext := media.Extension(contentType)
+	if ext == "" || media.ExtensionMatches(contentType, filepath.Ext(original)) {
+		ext = strings.ToLower(filepath.Ext(original))
+	}
+	return base + ext
+}
+
+// thumbnailPath is where the thumbnail of the file at p is kept.
+func thumbnailPath(p, contentType string) string {
+	dir, name := path.Split(p)
+	base := strings.TrimSuffix(name, path.Ext(name))
+	return path.Join(dir, "thumbs", base+"_thumb"+media.Extension(contentType))
+}
+
+// nullString and nullInt store the optional upload details as NULL when
+// they do not apply.
+func nullString(s string) sql.NullString { return sql.NullString{String: s, Valid: s != ""} }
+
+func nullInt(n int) sql.NullInt64 { return sql.NullInt64{Int64: int64(n), Valid: n > 0} }
+
+// addMaterialPreview adds what the pipeline learned about a lesson file to
+// its list entry.
+func addMaterialPreview(m gin.H, pages sql.NullInt64, thumb, text sql.NullString) {
+	m["page_count"] = nil
+	if pages.Valid {
+		m["page_count"] = pages.Int64
+	}
+	m["thumbnail_url"] = nil
+	if thumb.Valid {
+		m["thumbnail_url"] = "/" + thumb.String
+	}
+	m["preview_text"] = text.String
+}
+
+// removeUpload deletes a stored upload and its thumbnail.
+func removeUpload(p, thumb string) {
+	if p != "" {
+		filestore.Remove(p)
+	}
+	if thumb != "" {
+		filestore.Remove(thumb)
+	}
+}
+
+// uploadSource is a file to run through the pipeline: a multipart form file
+// or a finished resumable upload.
+type uploadSource interface {
+	fileName() string
+	fileSize() int64
+	open() (io.ReadCloser, error)
+	// finish is called when the handler is done with the file; consumed
+	// reports whether it was stored for good.
+	finish(consumed bool)
+}
+
+type formUpload struct {
+	*multipart.FileHeader
+}
+
+func (f formUpload) fileName() string             { return f.Filename }
+func (f formUpload) fileSize() int64              { return f.Size }
+func (f formUpload) open() (io.ReadCloser, error) { return f.Open() }
+func (f formUpload) finish(consumed bool)         {}
+
+// uploadFromRequest returns the file sent in field, or the finished
+// resumable upload named by the upload_id form value. http.ErrMissingFile
+// means neither was sent.
+func uploadFromRequest(c *gin.Context, field, purpose string) (uploadSource, error) {
+	if id := c.PostForm("upload_id"); id != "" {
+		return claimResumableUpload(c, id, purpose)
+	}
+	file, err := c.FormFile(field)
+	if err != nil {
+		return nil, http.ErrMissingFile
+	}
+	return formUpload{file}, nil
+}
+
+// sniffUpload reads the head of an upload and validates its type.
+func sniffUpload(file uploadSource, policy uploadPolicy) (string, error) {
+	if file.fileSize() <= 0 {
+		return "", rejectUpload(http.StatusBadRequest, "File is empty")
+	}
+	if policy.maxSize > 0 && file.fileSize() > policy.maxSize {
+		return "", rejectUpload(http.StatusBadRequest, "File too large. Max size: %dMB", policy.maxSize/(1024*1024))
+	}
+
+	f, err := file.open()
+	if err != nil {
+		return "", rejectUpload(http.StatusInternalServerError, "Failed to read file")
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", rejectUpload(http.StatusInternalServerError, "Failed to read file content")
+	}
+
+	contentType := media.Sniff(head[:n])
+	if !policy.allows(contentType) {
+		return "", rejectUpload(http.StatusBadRequest, "Invalid file type. Detected: %s, allowed: %v", contentType, policy.types)
+	}
+	if !media.ExtensionMatches(contentType, filepath.Ext(file.fileName())) {
+		return "", rejectUpload(http.StatusBadRequest, "File extension %q does not match its content (%s)", filepath.Ext(file.fileName()), contentType)
+	}
+	return contentType, nil
+}
+
+// scanUpload runs the configured virus scanner over the whole upload.
+func scanUpload(file uploadSource) error {
+	scanner, err := antivirus.Default()
+	if err != nil {
+		fmt.Println("❌ Virus scanner:", err)
+		return rejectUpload(http.StatusServiceUnavailable, "Uploads are unavailable: virus scanning is not configured")
+	}
+	f, err := file.open()
+	if err != nil {
+		return rejectUpload(http.StatusInternalServerError, "Failed to read file")
+	}
+	defer f.Close()
+
+	result, err := scanner.Scan(f)
+	if err != nil {
+		fmt.Println("❌ Virus scan error:", err)
+		return rejectUpload(http.StatusServiceUnavailable, "File could not be scanned for viruses, try again later")
+	}
+	if result.Infected {
+		fmt.Printf("⚠️ Warning: rejected infected upload %q (%s)\n", file.fileName(), result.Signature)
+		return rejectUpload(http.StatusUnprocessableEntity, "File was rejected by the virus scanner")
+	}
+	return nil
+}
+
+// processUpload validates, scans and stores an upload at p (whose extension
+// should come from uploadFileName).
+func processUpload(file uploadSource, policy uploadPolicy, p string) (processedUpload, error) {
+	contentType, err := sniffUpload(file, policy)
+	if err != nil {
+		return processedUpload{}, err
+	}
+	if err := scanUpload(file); err != nil {
+		return processedUpload{}, err
+	}
+
+	switch {
+	case media.IsImage(contentType):
+		return storeImage(file, policy, p, contentType)
+	case contentType == "application/pdf":
+		return storePDF(file, policy, p)
+	}
+
+	f, err := file.open()
+	if err != nil {
+		return processedUpload{}, err
+	}
+	defer f.Close()
+	stored, err := filestore.Save(p, f, contentType)
+	if err != nil {
+		return processedUpload{}, err
+	}
+	return processedUpload{Path: p, ContentType: contentType, Size: stored.Size}, nil
+}
+
+func readUpload(file uploadSource) ([]byte, error) {
+	f, err := file.open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func storeImage(file uploadSource, policy uploadPolicy, p, contentType string) (processedUpload, error) {
+	data, err := readUpload(file)
+	if err != nil {
+		return processedUpload{}, err
+	}
+	img, err := media.NormalizeImage(data, contentType, policy.maxImageDim)
+	if errors.Is(err, media.ErrImageTooBig) {
+		return processedUpload{}, rejectUpload(http.StatusBadRequest, "Image dimensions are too large")
+	}
+	if err != nil {
+		return processedUpload{}, rejectUpload(http.StatusBadRequest, "File is not a valid image")
+	}
+
+	if _, err := filestore.SaveBytes(p, img.Data, img.ContentType); err != nil {
+		return processedUpload{}, err
+	}
+	out := processedUpload{Path: p, ContentType: img.ContentType, Size: int64(len(img.Data)), Width: img.Width, Height: img.Height}
+
+	if policy.thumbnail {
+		// WebP cannot be decoded here; those images are shown as they are
+		if thumb, err := media.Thumbnail(img, thumbnailDim); err == nil {
+			out.ThumbnailPath = saveThumbnail(p, thumb)
+		} else if !errors.Is(err, media.ErrNoPixels) {
+			fmt.Println("⚠️ Warning: thumbnail failed:", err)
+		}
+	}
+	return out, nil
+}
+
+func storePDF(file uploadSource, policy uploadPolicy, p string) (processedUpload, error) {
+	data, err := readUpload(file)
+	if err != nil {
+		return processedUpload{}, err
+	}
+	info, err := media.InspectPDF(data)
+	if err != nil {
+		return processedUpload{}, rejectUpload(http.StatusBadRequest, "File is not a valid PDF")
+	}
+	if len(info.ActiveContent) > 0 {
+		return processedUpload{}, rejectUpload(http.StatusBadRequest, "PDFs with %s are not accepted", strings.Join(info.ActiveContent, ", "))
+	}
+
+	if _, err := filestore.Save(p, bytes.NewReader(data), "application/pdf"); err != nil {
+		return processedUpload{}, err
+	}
+	out := processedUpload{Path: p, ContentType: "application/pdf", Size: int64(len(data)), Pages: info.Pages, PreviewText: info.Text}
+
+	if policy.thumbnail && !info.Encrypted {
+		out.ThumbnailPath = pdfThumbnail(p, data)
+	}
+	return out, nil
+}
+
+func pdfThumbnail(p string, data []byte) string {
+	rendered, err := media.RenderPDFPage(data, pdfPreviewSize)
+	if err != nil {
+		if !errors.Is(err, media.ErrNoRenderer) {
+			fmt.Println("⚠️ Warning: PDF preview failed:", err)
+		}
+		return ""
+	}
+	page, err := media.DecodeImage(rendered)
+	if err != nil {
+		fmt.Println("⚠️ Warning: PDF preview failed:", err)
+		return ""
+	}
+	thumb, err := media.Thumbnail(page, thumbnailDim)
+	if err != nil {
+		fmt.Println("⚠️ Warning: PDF preview failed:", err)
+		return ""
+	}
+	return saveThumbnail(p, thumb)
+}
+
+// saveThumbnail stores a thumbnail next to p and returns its path, or ""
+// when it could not be saved (the upload itself is still kept).
+func saveThumbnail(p string, thumb media.Image) string {
+	tp := thumbnailPath(p, thumb.ContentType)
+	if _, err := filestore.SaveBytes(tp, thumb.Data, thumb.ContentType); err != nil {
+		fmt.Println("⚠️ Warning: failed to save thumbnail:", err)
+		return ""
+	}
+	return tp
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"student-portal/antivirus"
+)
+
+// memoryUpload is an uploadSource held in memory.
+type memoryUpload struct {
+	name string
+	data []byte
+}
+
+func (m memoryUpload) fileName() string { return m.name }
+func (m memoryUpload) fileSize() int64  { return int64(len(m.data)) }
+func (m memoryUpload) open() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(m.data)), nil
+}
+func (m memoryUpload) finish(consumed bool) {}
+
+func TestProcessUploadVirusScan(t *testing.T) {
+	pdfPolicy := uploadPolicy{maxSize: 1 << 20, types: []string{"application/pdf"}}
+	textPolicy := uploadPolicy{maxSize: 1 << 20, types: []string{"text/plain"}}
+	infectedPDF := []byte("%PDF-1.4\n1 0 obj << /Type /Page >> endobj\n% " + antivirus.EICAR + "\n%%EOF\n")
+
+	tests := []struct {
+		name    string
+		scanner string
+		file    memoryUpload
+		policy  uploadPolicy
+		status  int
+	}{
+		{"EICAR test file", "fake", memoryUpload{"eicar.txt", []byte(antivirus.EICAR)}, textPolicy, http.StatusUnprocessableEntity},
+		{"EICAR inside a PDF", "fake", memoryUpload{"slip.pdf", infectedPDF}, pdfPolicy, http.StatusUnprocessableEntity},
+		{"EICAR past the first read", "fake", memoryUpload{"notes.txt", append(bytes.Repeat([]byte("lorem ipsum "), 10000), antivirus.EICAR...)}, textPolicy, http.StatusUnprocessableEntity},
+		{"unknown scanner", "no-such-scanner", memoryUpload{"slip.pdf", infectedPDF}, pdfPolicy, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VIRUS_SCANNER", tt.scanner)
+			p := "uploads/test/" + tt.file.name
+
+			_, err := processUpload(tt.file, tt.policy, p)
+			var ue *uploadError
+			if !errors.As(err, &ue) {
+				t.Fatalf("processUpload error = %v, want a rejection", err)
+			}
+			if ue.status != tt.status {
+				t.Errorf("status = %d (%s), want %d", ue.status, ue.message, tt.status)
+			}
+			if _, err := os.Stat(p); !os.IsNotExist(err) {
+				t.Errorf("rejected upload was stored at %s", p)
+			}
+		})
+	}
+}
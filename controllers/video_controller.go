@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"student-portal/config"
+	"student-portal/filestore"
+	"student-portal/media"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===================== LESSON VIDEOS =====================
+//
+// Lesson videos are kept as uploaded and queued for transcoding
+// (video_status 'pending'). A background job turns each one, with the local
+// ffmpeg (FFMPEG_PATH / FFPROBE_PATH), into:
+//   - a 720p H.264/AAC MP4 with its index up front, for plain <video> playback
+//   - HLS levels (360p, 720p, 1080p up to the source's height) with a master
+//     playlist, so players drop to a lower level on slow connections
+//   - a poster frame
+//
+// stored under uploads/lessons/video/<id>/. Students enrolled in the subject
+// and staff watch through:
+//
+//	GET /lessons/:id/video      status and links
+//	GET /lessons/:id/stream     the MP4 (the original until it is ready), with Range
+//	GET /lessons/:id/hls/:file  playlists and segments
+//	GET /lessons/:id/poster
+//
+// A video that fails is retried maxVideoAttempts times, then marked 'failed'
+// and still plays as uploaded; the teacher can queue it again.
+
+const (
+	videoOutputRoot  = "uploads/lessons/video"
+	videoJobTimeout  = 2 * time.Hour
+	videoRetryDelay  = 10 * time.Minute
+	maxVideoAttempts = 3
+)
+
+var (
+	hlsFilePattern = regexp.MustCompile(`^[A-Za-z0-9_]+\.(m3u8|ts)$`)
+	ffmpegWarning  sync.Once
+)
+
+// videoStatusFor is the initial video_status of a new lesson material.
+func videoStatusFor(materialType string) sql.NullString {
+	if materialType != "video" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: "pending", Valid: true}
+}
+
+func videoOutputDir(materialID int) string {
+	return videoOutputRoot + "/" + strconv.Itoa(materialID)
+}
+
+// videoContentType is the type each transcoder output is served as.
+func videoContentType(name string) string {
+	switch path.Ext(name) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	case ".mp4":
+		return "video/mp4"
+	case ".jpg":
+		return "image/jpeg"
+	}
+	return "application/octet-stream"
+}
+
+// cleanStoredPath turns a file_path column into the "uploads/..." form.
+func cleanStoredPath(p string) string {
+	return strings.TrimPrefix(strings.ReplaceAll(p, "\\", "/"), "./")
+}
+
+// removeVideoOutputs deletes everything transcoded for a lesson.
+func removeVideoOutputs(materialID int) {
+	files, err := filestore.List(videoOutputDir(materialID))
+	if err != nil {
+		fmt.Println("⚠️ Warning: could not list video files of lesson", materialID, err)
+		return
+	}
+	for _, f := range files {
+		filestore.Remove(f.Path)
+	}
+}
+
+// addVideoDetails adds the playback links of a video lesson to its list
+// entry.
+func addVideoDetails(m gin.H, id int, status sql.NullString, duration sql.NullInt64, poster sql.NullString) {
+	if !status.Valid {
+		return
+	}
+	base := "/lessons/" + strconv.Itoa(id)
+	m["video_status"] = status.String
+	m["stream_url"] = base + "/stream"
+	m["hls_url"] = nil
+	if status.String == "ready" {
+		m["hls_url"] = base + "/hls/" + media.HLSPlaylistFile
+	}
+	m["poster_url"] = nil
+	if poster.Valid {
+		m["poster_url"] = base + "/poster"
+	}
+	m["duration_seconds"] = nil
+	if duration.Valid {
+		m["duration_seconds"] = duration.Int64
+	}
+}
+
+// ===================== TRANSCODING JOB =====================
+
+func StartVideoTranscodeJob(interval time.Duration) {
+	for {
+		for transcodeNextVideo() {
+		}
+		time.Sleep(interval)
+	}
+}
+
+// transcodeNextVideo handles one queued video and reports whether there may
+// be more to do.
+func transcodeNextVideo() bool {
+	if !media.FFmpegAvailable() {
+		ffmpegWarning.Do(func() {
+			fmt.Println("⚠️ Warning: ffmpeg not found, lesson videos are served as uploaded")
+		})
+		return false
+	}
+
+	// A run that outlived its timeout died with the server; queue it again
+	if _, err := config.DB.Exec(`
+		UPDATE lesson_materials
+		SET video_status = IF(video_attempts >= ?, 'failed', 'pending'),
+		    video_error = IF(video_attempts >= ?, 'transcoding did not finish', video_error)
+		WHERE video_status = 'processing' AND video_started_at < ?
+	`, maxVideoAttempts, maxVideoAttempts, time.Now().Add(-videoJobTimeout-videoRetryDelay)); err != nil {
+		fmt.Println("❌ Video queue error:", err)
+		return false
+	}
+
+	var id int
+	var filePath string
+	err := config.DB.QueryRow(`
+		SELECT id, file_path FROM lesson_materials
+		WHERE video_status = 'pending' AND (video_started_at IS NULL OR video_started_at < ?)
+		ORDER BY id
+		LIMIT 1
+	`, time.Now().Add(-videoRetryDelay)).Scan(&id, &filePath)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		fmt.Println("❌ Video queue error:", err)
+		return false
+	}
+
+	// Claim it; another server may have got there first
+	res, err := config.DB.Exec(`
+		UPDATE lesson_materials
+		SET video_status = 'processing', video_attempts = video_attempts + 1, video_started_at = ?
+		WHERE id = ? AND video_status = 'pending'
+	`, time.Now(), id)
+	if err != nil {
+		fmt.Println("❌ Video queue error:", err)
+		return false
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return true
+	}
+
+	started := time.Now()
+	if terr := transcodeLessonVideo(id, filePath); terr != nil {
+		fmt.Printf("❌ Transcoding lesson %d failed: %v\n", id, terr)
+		// A file ffmpeg cannot read will not get better by retrying
+		attempts := maxVideoAttempts
+		if errors.Is(terr, media.ErrInvalidVideo) || errors.Is(terr, filestore.ErrNotExist) {
+			attempts = 0
+		}
+		if _, err := config.DB.Exec(`
+			UPDATE lesson_materials
+			SET video_status = IF(video_attempts >= ?, 'failed', 'pending'), video_error = ?
+			WHERE id = ? AND video_status = 'processing'
+		`, attempts, terr.Error(), id); err != nil {
+			fmt.Println("❌ Video queue error:", err)
+		}
+		return true
+	}
+	fmt.Printf("🎬 Transcoded lesson %d in %s\n", id, time.Since(started).Round(time.Second))
+	return true
+}
+
+// transcodeLessonVideo runs ffmpeg over a lesson's file and stores the
+// results.
+func transcodeLessonVideo(id int, filePath string) error {
+	dir, err := os.MkdirTemp("", "lessonvideo-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	stored := cleanStoredPath(filePath)
+	source := filepath.Join(dir, "source"+path.Ext(stored))
+	if err := copyStoredFile(stored, source); err != nil {
+		return err
+	}
+	outDir := filepath.Join(dir, "out")
+	if err := os.Mkdir(outDir, 0700); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), videoJobTimeout)
+	defer cancel()
+	result, err := media.TranscodeVideo(ctx, source, outDir)
+	if err != nil {
+		return err
+	}
+
+	prefix := videoOutputDir(id)
+	previous, err := filestore.List(prefix)
+	if err != nil {
+		return err
+	}
+	saved := map[string]bool{}
+	for _, name := range result.Files {
+		p := prefix + "/" + name
+		f, err := os.Open(filepath.Join(outDir, name))
+		if err != nil {
+			return err
+		}
+		_, err = filestore.Save(p, f, videoContentType(name))
+		f.Close()
+		if err != nil {
+			return err
+		}
+		saved[p] = true
+	}
+
+	poster := sql.NullString{}
+	if result.Poster != "" {
+		poster = sql.NullString{String: prefix + "/" + result.Poster, Valid: true}
+	} else {
+		fmt.Printf("⚠️ Warning: no poster frame for lesson %d\n", id)
+	}
+	res, err := config.DB.Exec(`
+		UPDATE lesson_materials
+		SET video_status = 'ready', video_error = NULL, video_duration = ?,
+		    stream_path = ?, hls_path = ?, poster_path = ?, updated_at = NOW()
+		WHERE id = ? AND video_status = 'processing'
+	`, int(result.Info.Duration+0.5), prefix+"/"+result.WebVideo, prefix+"/"+result.Playlist, poster, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Deleted while transcoding: nothing refers to the files any more
+		if !existsQuery(`SELECT 1 FROM lesson_materials WHERE id = ?`, id) {
+			removeVideoOutputs(id)
+		}
+		return nil
+	}
+
+	// Levels from an earlier run that this one did not make again
+	for _, f := range previous {
+		if !saved[f.Path] {
+			filestore.Remove(f.Path)
+		}
+	}
+	return nil
+}
+
+func copyStoredFile(stored, dest string) error {
+	obj, err := filestore.Open(stored)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, obj); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ===================== PLAYBACK =====================
+
+type lessonVideo struct {
+	id         int
+	teacherID  int
+	fileName   string
+	filePath   string
+	status     sql.NullString
+	streamPath sql.NullString
+	hlsPath    sql.NullString
+	posterPath sql.NullString
+	duration   sql.NullInt64
+	videoError sql.NullString
+}
+
+// loadLessonVideo reads the video lesson in :id and checks the caller may
+// watch it: staff, or a student enrolled in its subject. It has answered
+// the request when ok is false.
+func loadLessonVideo(c *gin.Context) (v lessonVideo, ok bool) {
+	err := config.DB.QueryRow(`
+		SELECT id, teacher_id, file_name, file_path, video_status, stream_path, hls_path, poster_path,
+		       video_duration, video_error
+		FROM lesson_materials
+		WHERE id = ? AND type = 'video'
+	`, c.Param("id")).Scan(&v.id, &v.teacherID, &v.fileName, &v.filePath, &v.status, &v.streamPath,
+		&v.hlsPath, &v.posterPath, &v.duration, &v.videoError)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "video not found"})
+		return v, false
+	}
+	if err != nil {
+		fmt.Println("❌ Error loading lesson video:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load video"})
+		return v, false
+	}
+
+	viewer := viewerFromContext(c)
+	allowed := viewer.is("teacher", "faculty", "admin") || (viewer.is("student") && existsQuery(`
+		SELECT 1 FROM lesson_materials lm
+		INNER JOIN student_academic sa ON FIND_IN_SET(lm.subject_id, sa.subjects) > 0
+		INNER JOIN students s ON s.id = sa.student_id
+		WHERE s.student_id = ? AND lm.id = ?`, viewer.studentID, v.id))
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot access this video"})
+		return v, false
+	}
+	return v, true
+}
+
+func (v lessonVideo) ready() bool { return v.status.String == "ready" }
+
+// GET /lessons/:id/video
+func GetLessonVideo(c *gin.Context) {
+	v, ok := loadLessonVideo(c)
+	if !ok {
+		return
+	}
+	response := gin.H{"lesson_id": v.id, "file_name": v.fileName}
+	status := v.status
+	if !status.Valid {
+		status = sql.NullString{String: "pending", Valid: true}
+	}
+	addVideoDetails(response, v.id, status, v.duration, v.posterPath)
+	if status.String == "failed" && viewerFromContext(c).is("teacher", "faculty", "admin") {
+		response["error"] = v.videoError.String
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GET /lessons/:id/stream
+// The transcoded MP4 once it is ready, the uploaded file until then.
+// Range requests are answered, so players can seek and resume.
+func StreamLessonVideo(c *gin.Context) {
+	v, ok := loadLessonVideo(c)
+	if !ok {
+		return
+	}
+	if v.ready() && v.streamPath.Valid {
+		name := strings.TrimSuffix(v.fileName, filepath.Ext(v.fileName)) + ".mp4"
+		sendStoredFile(c, v.streamPath.String, name, cacheShared, false)
+		return
+	}
+	sendStoredFile(c, cleanStoredPath(v.filePath), v.fileName, cacheShared, false)
+}
+
+// GET /lessons/:id/hls/:file
+func ServeLessonHLS(c *gin.Context) {
+	v, ok := loadLessonVideo(c)
+	if !ok {
+		return
+	}
+	file := c.Param("file")
+	if !v.ready() || !v.hlsPath.Valid || !hlsFilePattern.MatchString(file) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	sendStoredFile(c, path.Dir(v.hlsPath.String)+"/"+file, file, cacheShared, false)
+}
+
+// GET /lessons/:id/poster
+func ServeLessonPoster(c *gin.Context) {
+	v, ok := loadLessonVideo(c)
+	if !ok {
+		return
+	}
+	if !v.posterPath.Valid {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	sendStoredFile(c, v.posterPath.String, media.PosterFile, cacheShared, false)
+}
+
+// POST /teacher/lessons/:id/transcode
+// Queues a video lesson again, such as one that failed.
+func TeacherRetranscodeLesson(c *gin.Context) {
+	teacherID := c.GetInt("user_id")
+
+	var status sql.NullString
+	err := config.DB.QueryRow(`
+		SELECT video_status FROM lesson_materials
+		WHERE id = ? AND teacher_id = ? AND type = 'video'
+	`, c.Param("id"), teacherID).Scan(&status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video lesson not found or not owned by you"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status.String == "processing" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Video is being transcoded"})
+		return
+	}
+
+	if _, err := config.DB.Exec(`
+		UPDATE lesson_materials
+		SET video_status = 'pending', video_attempts = 0, video_started_at = NULL, video_error = NULL
+		WHERE id = ? AND teacher_id = ? AND (video_status IS NULL OR video_status <> 'processing')
+	`, c.Param("id"), teacherID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue video"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Video queued for transcoding", "video_status": "pending"})
+}
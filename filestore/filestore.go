@@ -0,0 +1,125 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend is where file contents live: a directory on local disk or a bucket
+// in an S3-compatible object store. Keys are slash-separated relative paths.
+type Backend interface {
+	Name() string
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Open(key string) (Object, error)
+	Stat(key string) (ObjectInfo, error)
+	Delete(key string) error
+	// List calls fn for every object whose key starts with prefix.
+	List(prefix string, fn func(ObjectInfo) error) error
+}
+
+// Object is an open file. Reads are streamed; Seek lets http.ServeContent
+// answer Range requests without loading the whole file.
+type Object interface {
+	io.ReadSeekCloser
+	Info() ObjectInfo
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+var (
+	ErrNotExist       = errors.New("file does not exist")
+	ErrInvalidKey     = errors.New("invalid storage key")
+	ErrUnknownBackend = errors.New("unknown storage backend")
+)
+
+// Factory builds a backend from its environment configuration.
+type Factory func() (Backend, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a backend available under name.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = f
+}
+
+// NewBackend builds the backend registered under name.
+func NewBackend(name string) (Backend, error) {
+	mu.RLock()
+	f, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
+	}
+	return f()
+}
+
+// Backends lists the registered backend names.
+func Backends() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultBackend returns the backend selected by STORAGE_BACKEND (local when
+// unset).
+func DefaultBackend() (Backend, error) {
+	name := os.Getenv("STORAGE_BACKEND")
+	if name == "" {
+		name = "local"
+	}
+	return NewBackend(name)
+}
+
+// CleanKey normalizes a stored path ("./uploads/x", "uploads\\x", "/uploads/x")
+// to the key form "uploads/x" and rejects anything escaping the root.
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(strings.TrimSpace(key), "\\", "/")
+	for strings.HasPrefix(key, "./") {
+		key = key[2:]
+	}
+	key = strings.TrimLeft(key, "/")
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	clean := path.Clean(key)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", ErrInvalidKey
+	}
+	return clean, nil
+}
+
+func init() {
+	Register("local", func() (Backend, error) {
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "./storage/objects"
+		}
+		return NewLocalBackend(dir)
+	})
+	Register("s3", func() (Backend, error) {
+		return NewS3BackendFromEnv()
+	})
+}
@@ -0,0 +1,236 @@
+package filestore
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StandIn is a small S3-compatible server for development, in the spirit of
+// MinIO: path-style buckets, SigV4-authenticated PUT/GET/HEAD/DELETE on
+// objects (with Range), ListObjectsV2 and bucket creation, stored as plain
+// files under a directory. Run it with `go run ./cmd/storagectl standin`.
+type StandIn struct {
+	dir       string
+	accessKey string
+	secretKey string
+	region    string
+}
+
+// maxClockSkew is how far a request's X-Amz-Date may be from the server clock.
+const maxClockSkew = 15 * time.Minute
+
+func NewStandIn(dir, accessKey, secretKey, region string) *StandIn {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &StandIn{dir: dir, accessKey: accessKey, secretKey: secretKey, region: region}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, message)
+}
+
+// authorize checks the SigV4 Authorization header against the stand-in's
+// credentials.
+func (s *StandIn) authorize(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, sigAlgorithm+" ") {
+		return false
+	}
+	var credential, signedHeaders, signature string
+	for _, part := range strings.Split(strings.TrimPrefix(auth, sigAlgorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Credential":
+			credential = kv[1]
+		case "SignedHeaders":
+			signedHeaders = kv[1]
+		case "Signature":
+			signature = kv[1]
+		}
+	}
+
+	scope := strings.SplitN(credential, "/", 2)
+	if len(scope) != 2 || scope[0] != s.accessKey || signedHeaders == "" {
+		return false
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	t, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil || time.Since(t) > maxClockSkew || time.Until(t) > maxClockSkew {
+		return false
+	}
+
+	want, wantScope := v4Signature(r, s.secretKey, s.region, amzDate, strings.Split(signedHeaders, ";"))
+	return scope[1] == wantScope && signature == want
+}
+
+// split returns the bucket and object key of a path-style request.
+func (s *StandIn) split(r *http.Request) (bucket, key string, ok bool) {
+	p := strings.TrimPrefix(r.URL.Path, "/")
+	parts := strings.SplitN(p, "/", 2)
+	bucket = parts[0]
+	if bucket == "" || bucket == "." || bucket == ".." || strings.ContainsAny(bucket, `\`) {
+		return "", "", false
+	}
+	if len(parts) == 2 && parts[1] != "" {
+		clean, err := CleanKey(parts[1])
+		if err != nil {
+			return "", "", false
+		}
+		key = clean
+	}
+	return bucket, key, true
+}
+
+func (s *StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(r) {
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch", "request signature does not match")
+		return
+	}
+	bucket, key, ok := s.split(r)
+	if !ok {
+		writeS3Error(w, http.StatusBadRequest, "InvalidURI", "invalid bucket or key")
+		return
+	}
+	bucketDir := filepath.Join(s.dir, bucket)
+
+	if key == "" {
+		s.serveBucket(w, r, bucket, bucketDir)
+		return
+	}
+
+	if st, err := os.Stat(bucketDir); err != nil || !st.IsDir() {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	backend := &LocalBackend{root: bucketDir}
+
+	switch r.Method {
+	case http.MethodPut:
+		if err := backend.Put(key, r.Body, r.ContentLength, r.Header.Get("Content-Type")); err != nil {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		obj, err := backend.Open(key)
+		if errors.Is(err, ErrNotExist) {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", "object does not exist")
+			return
+		}
+		if err != nil {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		defer obj.Close()
+		info := obj.Info()
+		if info.ContentType == "" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		http.ServeContent(w, r, key, info.ModTime, obj)
+	case http.MethodDelete:
+		if err := backend.Delete(key); err != nil {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+	}
+}
+
+type standInObject struct {
+	Key          string `xml:"Key"`
+	Size         int64  `xml:"Size"`
+	LastModified string `xml:"LastModified"`
+}
+
+type standInListing struct {
+	XMLName               xml.Name        `xml:"ListBucketResult"`
+	Name                  string          `xml:"Name"`
+	Prefix                string          `xml:"Prefix"`
+	KeyCount              int             `xml:"KeyCount"`
+	MaxKeys               int             `xml:"MaxKeys"`
+	IsTruncated           bool            `xml:"IsTruncated"`
+	NextContinuationToken string          `xml:"NextContinuationToken,omitempty"`
+	Contents              []standInObject `xml:"Contents"`
+}
+
+const standInMaxKeys = 1000
+
+func (s *StandIn) serveBucket(w http.ResponseWriter, r *http.Request, bucket, bucketDir string) {
+	switch r.Method {
+	case http.MethodHead:
+		if st, err := os.Stat(bucketDir); err != nil || !st.IsDir() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		if err := os.MkdirAll(bucketDir, 0755); err != nil {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		if r.URL.Query().Get("list-type") != "2" {
+			writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 is supported")
+			return
+		}
+		if st, err := os.Stat(bucketDir); err != nil || !st.IsDir() {
+			writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+			return
+		}
+
+		prefix := r.URL.Query().Get("prefix")
+		after := r.URL.Query().Get("continuation-token")
+		var all []ObjectInfo
+		err := (&LocalBackend{root: bucketDir}).List(prefix, func(info ObjectInfo) error {
+			if info.Key > after {
+				all = append(all, info)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+
+		listing := standInListing{Name: bucket, Prefix: prefix, MaxKeys: standInMaxKeys}
+		if len(all) > standInMaxKeys {
+			all = all[:standInMaxKeys]
+			listing.IsTruncated = true
+			listing.NextContinuationToken = all[len(all)-1].Key
+		}
+		for _, info := range all {
+			listing.Contents = append(listing.Contents, standInObject{
+				Key:          info.Key,
+				Size:         info.Size,
+				LastModified: info.ModTime.UTC().Format(time.RFC3339),
+			})
+		}
+		listing.KeyCount = len(listing.Contents)
+
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, xml.Header)
+		xml.NewEncoder(w).Encode(listing)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+	}
+}
@@ -0,0 +1,569 @@
+package filestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is what the rest of the portal uses. Files keep the paths they always
+// had ("uploads/lessons/x.pdf"), but their contents are kept once per SHA-256
+// in the backend ("sha256/ab/abcdef...") and the stored_files table maps each
+// path to its hash. Uploading the same file twice stores it once; deleting a
+// path removes the contents only when no other path still points at them.
+//
+// Paths that are not in stored_files yet are read from the local disk, so
+// files written before the store existed keep working until
+// `storagectl migrate` copies them in.
+type Store struct {
+	backend Backend
+	db      *sql.DB
+}
+
+// File is a path saved in the store.
+type File struct {
+	Path        string
+	SHA256      string
+	Size        int64
+	ContentType string
+	SavedAt     time.Time
+}
+
+var ErrNotConfigured = errors.New("file storage is not configured")
+
+func NewStore(backend Backend, db *sql.DB) *Store {
+	return &Store{backend: backend, db: db}
+}
+
+func (s *Store) Backend() Backend { return s.backend }
+
+// legacyRoots are the local directories files were written to before the
+// store existed. Only paths under them fall back to the disk.
+var legacyRoots = []string{"uploads/", "storage/signatures/"}
+
+func legacyPath(key string) (string, bool) {
+	for _, root := range legacyRoots {
+		if strings.HasPrefix(key, root) {
+			return filepath.FromSlash(key), true
+		}
+	}
+	return "", false
+}
+
+func blobKey(sum string) string {
+	return "sha256/" + sum[:2] + "/" + sum
+}
+
+func detectContentType(p string) string {
+	ct := mime.TypeByExtension(strings.ToLower(path.Ext(p)))
+	if ct == "" {
+		return "application/octet-stream"
+	}
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	return ct
+}
+
+func sniffContentType(head []byte) string {
+	ct := http.DetectContentType(head)
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	return ct
+}
+
+// Save streams r into the store under p, replacing whatever p held before.
+// The content is spooled to a temporary file while it is hashed, so memory
+// use does not grow with the file size. contentType defaults to the one of
+// the file extension.
+func (s *Store) Save(p string, r io.Reader, contentType string) (File, error) {
+	key, err := CleanKey(p)
+	if err != nil {
+		return File{}, err
+	}
+	if contentType == "" {
+		contentType = detectContentType(key)
+	}
+
+	tmp, err := os.CreateTemp("", "filestore-*")
+	if err != nil {
+		return File{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return File{}, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	file := File{Path: key, SHA256: sum, Size: size, ContentType: contentType, SavedAt: time.Now()}
+
+	var previous string
+	err = s.db.QueryRow(`SELECT sha256 FROM stored_files WHERE path = ?`, key).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return File{}, err
+	}
+
+	// The row goes in before the contents are checked: a collect of the same
+	// hash running concurrently either sees the row or has already removed
+	// the blob, in which case it is uploaded again below.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return File{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO stored_files (path, sha256, size, content_type)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE sha256 = VALUES(sha256), size = VALUES(size),
+			content_type = VALUES(content_type), created_at = NOW()
+	`, key, sum, size, contentType)
+	if err != nil {
+		return File{}, err
+	}
+
+	if _, err := s.backend.Stat(blobKey(sum)); errors.Is(err, ErrNotExist) {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return File{}, err
+		}
+		if err := s.backend.Put(blobKey(sum), tmp, size, contentType); err != nil {
+			return File{}, fmt.Errorf("store %s: %w", key, err)
+		}
+	} else if err != nil {
+		return File{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return File{}, err
+	}
+
+	if previous != "" && previous != sum {
+		if err := s.collect(previous); err != nil {
+			fmt.Println("⚠️ Warning: could not remove replaced file contents:", err)
+		}
+	}
+	return file, nil
+}
+
+// SaveBytes stores an in-memory file, such as a rendered PDF.
+func (s *Store) SaveBytes(p string, data []byte, contentType string) (File, error) {
+	return s.Save(p, bytes.NewReader(data), contentType)
+}
+
+// SaveUpload stores a multipart upload. The content type follows the file
+// extension and is sniffed from the first bytes when the extension says
+// nothing; the client's Content-Type header is never trusted.
+func (s *Store) SaveUpload(p string, fh *multipart.FileHeader) (File, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return File{}, err
+	}
+	contentType := ""
+	if byExt := detectContentType(p); byExt != "application/octet-stream" {
+		contentType = byExt
+	} else if n > 0 {
+		contentType = sniffContentType(head[:n])
+	}
+	return s.Save(p, io.MultiReader(bytes.NewReader(head[:n]), f), contentType)
+}
+
+// collect removes the contents of sum once no path refers to them. The
+// count locks the hash's index range, so a Save of the same contents waits
+// for the removal and then uploads them again.
+func (s *Store) collect(sum string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var refs int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM stored_files WHERE sha256 = ? FOR UPDATE`, sum).Scan(&refs); err != nil {
+		return err
+	}
+	if refs == 0 {
+		if err := s.backend.Delete(blobKey(sum)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) lookup(key string) (File, bool, error) {
+	f := File{Path: key}
+	err := s.db.QueryRow(`SELECT sha256, size, content_type, created_at FROM stored_files WHERE path = ?`, key).
+		Scan(&f.SHA256, &f.Size, &f.ContentType, &f.SavedAt)
+	if err == sql.ErrNoRows {
+		return File{}, false, nil
+	}
+	if err != nil {
+		return File{}, false, err
+	}
+	return f, true, nil
+}
+
+// indexedObject reports the stored path and type instead of the blob's.
+type indexedObject struct {
+	Object
+	info ObjectInfo
+}
+
+func (o *indexedObject) Info() ObjectInfo { return o.info }
+
+// Open streams the file stored under p.
+func (s *Store) Open(p string) (Object, error) {
+	key, err := CleanKey(p)
+	if err != nil {
+		return nil, err
+	}
+	f, ok, err := s.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		local, legacy := legacyPath(key)
+		if !legacy {
+			return nil, ErrNotExist
+		}
+		return openLocalFile(local, key)
+	}
+
+	obj, err := s.backend.Open(blobKey(f.SHA256))
+	if err != nil {
+		return nil, err
+	}
+	info := obj.Info()
+	info.Key = key
+	info.ContentType = f.ContentType
+	info.ModTime = f.SavedAt
+	return &indexedObject{Object: obj, info: info}, nil
+}
+
+// ReadAll loads a whole file, for small files such as signature images.
+func (s *Store) ReadAll(p string) ([]byte, error) {
+	obj, err := s.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// Stat describes the file stored under p.
+func (s *Store) Stat(p string) (File, error) {
+	key, err := CleanKey(p)
+	if err != nil {
+		return File{}, err
+	}
+	f, ok, err := s.lookup(key)
+	if err != nil {
+		return File{}, err
+	}
+	if ok {
+		return f, nil
+	}
+
+	local, legacy := legacyPath(key)
+	if !legacy {
+		return File{}, ErrNotExist
+	}
+	st, err := os.Stat(local)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && !st.Mode().IsRegular()) {
+		return File{}, ErrNotExist
+	}
+	if err != nil {
+		return File{}, err
+	}
+	return File{Path: key, Size: st.Size(), ContentType: detectContentType(key), SavedAt: st.ModTime()}, nil
+}
+
+// List returns the stored files whose path starts with the directory
+// prefix, such as every output made for one video.
+func (s *Store) List(prefix string) ([]File, error) {
+	key, err := CleanKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(key) + "/%"
+	rows, err := s.db.Query(`SELECT path, sha256, size, content_type, created_at FROM stored_files WHERE path LIKE ? ORDER BY path`, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []File
+	for rows.Next() {
+		var f File
+		if err := rows.Scan(&f.Path, &f.SHA256, &f.Size, &f.ContentType, &f.SavedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// Delete removes p, and its contents once nothing else refers to them. A
+// file still on the local disk from before the store is removed too.
+func (s *Store) Delete(p string) error {
+	key, err := CleanKey(p)
+	if err != nil {
+		return err
+	}
+
+	var sum string
+	err = s.db.QueryRow(`SELECT sha256 FROM stored_files WHERE path = ?`, key).Scan(&sum)
+	if err == sql.ErrNoRows {
+		local, legacy := legacyPath(key)
+		if !legacy {
+			return nil
+		}
+		if err := os.Remove(local); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM stored_files WHERE path = ? AND sha256 = ?`, key, sum); err != nil {
+		return err
+	}
+	return s.collect(sum)
+}
+
+// ===================== MIGRATION =====================
+
+// ImportResult counts what an import or copy did.
+type ImportResult struct {
+	Imported int
+	Skipped  int
+	Bytes    int64
+}
+
+// ImportDir copies every file under dir (a path relative to the working
+// directory, such as "uploads") into the store under the same path the
+// database already refers to. Paths already in the store are skipped, so it
+// can be re-run. With remove, each local file is deleted once stored.
+func (s *Store) ImportDir(dir string, remove bool, logf func(format string, args ...interface{})) (ImportResult, error) {
+	var res ImportResult
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		key, err := CleanKey(filepath.ToSlash(p))
+		if err != nil {
+			return err
+		}
+
+		if _, ok, err := s.lookup(key); err != nil {
+			return err
+		} else if ok {
+			res.Skipped++
+		} else {
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			stored, err := s.Save(key, f, "")
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			res.Imported++
+			res.Bytes += stored.Size
+			logf("imported %s (%d bytes, %s)", key, stored.Size, stored.SHA256[:12])
+		}
+
+		if remove {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// CopyBlobs copies every stored content from src to dst, for moving the store
+// to another backend. Contents already present in dst are skipped.
+func CopyBlobs(src, dst Backend, logf func(format string, args ...interface{})) (ImportResult, error) {
+	var res ImportResult
+	err := src.List("sha256/", func(info ObjectInfo) error {
+		if _, err := dst.Stat(info.Key); err == nil {
+			res.Skipped++
+			return nil
+		} else if !errors.Is(err, ErrNotExist) {
+			return err
+		}
+
+		obj, err := src.Open(info.Key)
+		if err != nil {
+			return err
+		}
+		defer obj.Close()
+		if err := dst.Put(info.Key, obj, obj.Info().Size, obj.Info().ContentType); err != nil {
+			return fmt.Errorf("%s: %w", info.Key, err)
+		}
+		res.Imported++
+		res.Bytes += obj.Info().Size
+		logf("copied %s (%d bytes)", info.Key, obj.Info().Size)
+		return nil
+	})
+	return res, err
+}
+
+// Verify checks that every path in stored_files has its contents in the
+// backend and returns the paths that do not.
+func (s *Store) Verify() ([]string, error) {
+	rows, err := s.db.Query(`SELECT path, sha256 FROM stored_files ORDER BY path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var key, sum string
+		if err := rows.Scan(&key, &sum); err != nil {
+			return nil, err
+		}
+		if _, err := s.backend.Stat(blobKey(sum)); errors.Is(err, ErrNotExist) {
+			missing = append(missing, key)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return missing, rows.Err()
+}
+
+// ===================== DEFAULT STORE =====================
+
+var (
+	defaultMu    sync.RWMutex
+	defaultStore *Store
+)
+
+// Init builds the backend chosen by STORAGE_BACKEND and makes it the store
+// used by Save, Open and the other package functions.
+func Init(db *sql.DB) error {
+	backend, err := DefaultBackend()
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defaultStore = NewStore(backend, db)
+	defaultMu.Unlock()
+	return nil
+}
+
+// Default returns the store set up by Init.
+func Default() (*Store, error) {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	if defaultStore == nil {
+		return nil, ErrNotConfigured
+	}
+	return defaultStore, nil
+}
+
+func Save(p string, r io.Reader, contentType string) (File, error) {
+	s, err := Default()
+	if err != nil {
+		return File{}, err
+	}
+	return s.Save(p, r, contentType)
+}
+
+func SaveBytes(p string, data []byte, contentType string) (File, error) {
+	s, err := Default()
+	if err != nil {
+		return File{}, err
+	}
+	return s.SaveBytes(p, data, contentType)
+}
+
+func SaveUpload(p string, fh *multipart.FileHeader) (File, error) {
+	s, err := Default()
+	if err != nil {
+		return File{}, err
+	}
+	return s.SaveUpload(p, fh)
+}
+
+func Open(p string) (Object, error) {
+	s, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return s.Open(p)
+}
+
+func ReadAll(p string) ([]byte, error) {
+	s, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return s.ReadAll(p)
+}
+
+func Stat(p string) (File, error) {
+	s, err := Default()
+	if err != nil {
+		return File{}, err
+	}
+	return s.Stat(p)
+}
+
+func Delete(p string) error {
+	s, err := Default()
+	if err != nil {
+		return err
+	}
+	return s.Delete(p)
+}
+
+func List(prefix string) ([]File, error) {
+	s, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return s.List(prefix)
+}
+
+// Remove deletes p and only logs a failure, for clean-up paths where the
+// caller has nothing better to do with the error.
+func Remove(p string) {
+	if p == "" {
+		return
+	}
+	if err := Delete(p); err != nil && !errors.Is(err, ErrNotExist) {
+		fmt.Println("⚠️ Warning: could not delete file", p+":", err)
+	}
+}
@@ -0,0 +1,216 @@
+package filestore
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDB is an in-memory stored_files table behind database/sql, answering
+// exactly the statements Store runs. Transactions apply immediately; the
+// tests do not run anything concurrently.
+type fakeDB struct {
+	mu    sync.Mutex
+	files map[string]fakeFile // by path
+}
+
+type fakeFile struct {
+	sha256, contentType string
+	size                int64
+	createdAt           time.Time
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("filestore-fake", fakeDriver{})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	db, ok := fakeDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("no fake database %q", name)
+	}
+	return &fakeConn{db: db}, nil
+}
+
+func newFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	fake := &fakeDB{files: map[string]fakeFile{}}
+	fakeDBsMu.Lock()
+	fakeDBs[t.Name()] = fake
+	fakeDBsMu.Unlock()
+
+	db, err := sql.Open("filestore-fake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fake
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: strings.Join(strings.Fields(query), " ")}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO stored_files"):
+		s.db.files[args[0].(string)] = fakeFile{
+			sha256: args[1].(string), size: args[2].(int64), contentType: args[3].(string), createdAt: time.Now(),
+		}
+		return driver.RowsAffected(1), nil
+	case s.query == "DELETE FROM stored_files WHERE path = ? AND sha256 = ?":
+		if f, ok := s.db.files[args[0].(string)]; ok && f.sha256 == args[1].(string) {
+			delete(s.db.files, args[0].(string))
+			return driver.RowsAffected(1), nil
+		}
+		return driver.RowsAffected(0), nil
+	}
+	return nil, fmt.Errorf("fake db: unexpected exec %q", s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	switch s.query {
+	case "SELECT sha256 FROM stored_files WHERE path = ?":
+		rows := &fakeRows{cols: []string{"sha256"}}
+		if f, ok := s.db.files[args[0].(string)]; ok {
+			rows.data = append(rows.data, []driver.Value{f.sha256})
+		}
+		return rows, nil
+	case "SELECT sha256, size, content_type, created_at FROM stored_files WHERE path = ?":
+		rows := &fakeRows{cols: []string{"sha256", "size", "content_type", "created_at"}}
+		if f, ok := s.db.files[args[0].(string)]; ok {
+			rows.data = append(rows.data, []driver.Value{f.sha256, f.size, f.contentType, f.createdAt})
+		}
+		return rows, nil
+	case "SELECT COUNT(*) FROM stored_files WHERE sha256 = ? FOR UPDATE":
+		var n int64
+		for _, f := range s.db.files {
+			if f.sha256 == args[0].(string) {
+				n++
+			}
+		}
+		return &fakeRows{cols: []string{"count"}, data: [][]driver.Value{{n}}}, nil
+	}
+	return nil, fmt.Errorf("fake db: unexpected query %q", s.query)
+}
+
+type fakeRows struct {
+	cols []string
+	data [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.data[0])
+	r.data = r.data[1:]
+	return nil
+}
+
+func newTestStore(t *testing.T) (*Store, *LocalBackend, *fakeDB) {
+	backend, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, fake := newFakeDB(t)
+	return NewStore(backend, db), backend, fake
+}
+
+func TestStoreSharedContentSurvivesDelete(t *testing.T) {
+	store, backend, _ := newTestStore(t)
+	content := []byte("same lesson handout")
+
+	first, err := store.SaveBytes("lessons/a/handout.txt", content, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.SaveBytes("lessons/b/handout-copy.txt", content, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.SHA256 != second.SHA256 {
+		t.Fatalf("same contents hashed differently: %s vs %s", first.SHA256, second.SHA256)
+	}
+
+	if err := store.Delete("lessons/a/handout.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Stat("lessons/a/handout.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("deleted path still stored: %v", err)
+	}
+	got, err := store.ReadAll("lessons/b/handout-copy.txt")
+	if err != nil {
+		t.Fatalf("the other path lost its contents: %v", err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("read %q, want %q", got, content)
+	}
+
+	// The contents go with the last path that refers to them
+	if err := store.Delete("lessons/b/handout-copy.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Stat(blobKey(first.SHA256)); !errors.Is(err, ErrNotExist) {
+		t.Errorf("contents kept after the last path was deleted: %v", err)
+	}
+}
+
+func TestStoreReplaceCollectsOldContent(t *testing.T) {
+	store, backend, fake := newTestStore(t)
+
+	old, err := store.SaveBytes("documents/tor.pdf", []byte("first version"), "application/pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SaveBytes("documents/tor.pdf", []byte("second version"), "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Stat(blobKey(old.SHA256)); !errors.Is(err, ErrNotExist) {
+		t.Errorf("replaced contents kept: %v", err)
+	}
+	if n := len(fake.files); n != 1 {
+		t.Errorf("%d stored_files rows, want 1", n)
+	}
+	got, err := store.ReadAll("documents/tor.pdf")
+	if err != nil || string(got) != "second version" {
+		t.Errorf("read %q, %v", got, err)
+	}
+}
@@ -0,0 +1,185 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SignatureHeader carries "t=<unix>,v1=<hex hmac>" where the HMAC-SHA256 is
+// computed over "<t>.<raw body>" with the shared webhook secret.
+const SignatureHeader = "X-Signature"
+
+// signatureTolerance rejects replays of old webhooks.
+const signatureTolerance = 5 * time.Minute
+
+// FakeProvider behaves like a hosted-checkout provider without talking to
+// anything. Used in development and for testing the webhook/settlement flow.
+type FakeProvider struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: []byte(secret), now: time.Now}
+}
+
+func (p *FakeProvider) Name() string { return "fake" }
+
+func (p *FakeProvider) CreateCheckout(req CheckoutRequest) (CheckoutSession, error) {
+	if req.Amount <= 0 {
+		return CheckoutSession{}, fmt.Errorf("amount must be positive")
+	}
+
+	ref := "fake_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	return CheckoutSession{
+		ProviderRef: ref,
+		CheckoutURL: "/payments/gateway/fake/checkout/" + ref,
+		ExpiresAt:   p.now().Add(30 * time.Minute),
+	}, nil
+}
+
+type fakeWebhookPayload struct {
+	ID          string  `json:"id"`
+	Type        string  `json:"type"`
+	ProviderRef string  `json:"provider_ref"`
+	Reference   string  `json:"reference"`
+	Amount      float64 `json:"amount"`
+	Method      string  `json:"method"`
+	Created     int64   `json:"created"`
+}
+
+func (p *FakeProvider) sign(timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BuildWebhook produces the body and headers the fake provider would POST for
+// an event, so the regular webhook endpoint can be exercised end to end.
+func (p *FakeProvider) BuildWebhook(eventType, providerRef, reference string, amount float64, method string) ([]byte, http.Header, error) {
+	now := p.now()
+	body, err := json.Marshal(fakeWebhookPayload{
+		ID:          "evt_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Type:        eventType,
+		ProviderRef: providerRef,
+		Reference:   reference,
+		Amount:      amount,
+		Method:      method,
+		Created:     now.Unix(),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", now.Unix(), p.sign(now.Unix(), body)))
+
+	return body, header, nil
+}
+
+func (p *FakeProvider) VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error) {
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(header.Get(SignatureHeader), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp, _ = strconv.ParseInt(kv[1], 10, 64)
+		case "v1":
+			signature = kv[1]
+		}
+	}
+
+	if timestamp == 0 || signature == "" {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	expected := p.sign(timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	age := p.now().Sub(time.Unix(timestamp, 0))
+	if math.Abs(age.Seconds()) > signatureTolerance.Seconds() {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var payload fakeWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return WebhookEvent{}, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+
+	return WebhookEvent{
+		EventID:     payload.ID,
+		Type:        payload.Type,
+		ProviderRef: payload.ProviderRef,
+		Reference:   payload.Reference,
+		Amount:      payload.Amount,
+		Method:      payload.Method,
+		OccurredAt:  time.Unix(payload.Created, 0),
+	}, nil
+}
+
+// ParseSettlement reads the fake provider's CSV settlement format:
+//
+//	provider_ref,reference,amount,fee,net,settled_at
+//
+// with settled_at as YYYY-MM-DD. A header row is optional.
+func (p *FakeProvider) ParseSettlement(r io.Reader) ([]SettlementLine, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []SettlementLine
+	for i, rec := range records {
+		if len(rec) < 6 {
+			return nil, fmt.Errorf("line %d: expected 6 columns, got %d", i+1, len(rec))
+		}
+		if i == 0 && strings.EqualFold(rec[0], "provider_ref") {
+			continue
+		}
+
+		var line SettlementLine
+		line.ProviderRef = rec[0]
+		line.Reference = rec[1]
+
+		nums := []*float64{&line.Amount, &line.Fee, &line.Net}
+		for j, n := range nums {
+			v, err := strconv.ParseFloat(strings.TrimSpace(rec[2+j]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid number %q", i+1, rec[2+j])
+			}
+			*n = v
+		}
+
+		line.SettledAt, err = time.Parse("2006-01-02", strings.TrimSpace(rec[5]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid settled_at %q", i+1, rec[5])
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Provider is an online payment channel (GCash, Maya, card acquirer...).
+// Each implementation creates hosted checkout sessions, verifies the signed
+// webhooks the provider sends back and parses its daily settlement files.
+type Provider interface {
+	Name() string
+	CreateCheckout(req CheckoutRequest) (CheckoutSession, error)
+	VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error)
+	ParseSettlement(r io.Reader) ([]SettlementLine, error)
+}
+
+// CheckoutRequest describes what the student is about to pay.
+type CheckoutRequest struct {
+	Reference   string // our own unique reference, echoed back in webhooks
+	Amount      float64
+	Currency    string
+	Method      string // gcash, maya, card
+	Description string
+	SuccessURL  string
+	CancelURL   string
+}
+
+// CheckoutSession is the hosted payment page returned by the provider.
+type CheckoutSession struct {
+	ProviderRef string
+	CheckoutURL string
+	ExpiresAt   time.Time
+}
+
+// Webhook event types
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventPaymentExpired   = "payment.expired"
+)
+
+// WebhookEvent is a verified notification from the provider.
+type WebhookEvent struct {
+	EventID     string
+	Type        string
+	ProviderRef string
+	Reference   string
+	Amount      float64
+	Method      string
+	OccurredAt  time.Time
+}
+
+// SettlementLine is one transaction of a provider settlement file.
+type SettlementLine struct {
+	ProviderRef string
+	Reference   string
+	Amount      float64
+	Fee         float64
+	Net         float64
+	SettledAt   time.Time
+}
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnknownProvider  = errors.New("unknown payment provider")
+	ErrNotConfigured    = errors.New("online payments are not configured")
+)
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{}
+	// configured is set by Init once webhooks can be verified
+	configured bool
+)
+
+// Register makes a provider available under its name.
+func Register(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name()] = p
+}
+
+// Get returns a registered provider.
+func Get(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return p, nil
+}
+
+// FakeEnabled reports whether the fake provider and its simulate and
+// checkout pages are switched on. They let anyone mark a checkout as paid,
+// so only development setups enable them, with PAYMENT_FAKE_GATEWAY=true.
+func FakeEnabled() bool {
+	return os.Getenv("PAYMENT_FAKE_GATEWAY") == "true"
+}
+
+// Init registers the providers. Without PAYMENT_WEBHOOK_SECRET, which is all
+// that stands between the public webhook endpoint and posted payments, online
+// payments stay off; the rest of the portal runs as usual.
+func Init() {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Println("⚠️ Warning: PAYMENT_WEBHOOK_SECRET is not set, online payments are disabled")
+		return
+	}
+	if FakeEnabled() {
+		Register(NewFakeProvider(secret))
+	}
+	mu.Lock()
+	configured = true
+	mu.Unlock()
+	if _, err := Default(); err != nil {
+		log.Println("⚠️ Warning: no payment provider is available, online payments are disabled:", err)
+	}
+}
+
+// Default returns the provider selected by PAYMENT_PROVIDER. Only with the
+// fake gateway enabled does an unset PAYMENT_PROVIDER mean the fake one.
+func Default() (Provider, error) {
+	mu.RLock()
+	ok := configured
+	mu.RUnlock()
+	if !ok {
+		return nil, ErrNotConfigured
+	}
+	name := os.Getenv("PAYMENT_PROVIDER")
+	if name == "" {
+		if !FakeEnabled() {
+			return nil, ErrNotConfigured
+		}
+		name = "fake"
+	}
+	return Get(name)
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"log"
 	"os"
 	"student-portal/config"
 	"student-portal/controllers"
+	"student-portal/filestore"
+	"student-portal/gateway"
 	"student-portal/middleware"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +19,21 @@ func main() {
 	config.MigrateDB()
 	config.CreateDefaultUsers()
 
+	// ---------------- FILE STORAGE ----------------
+	if err := filestore.Init(config.DB); err != nil {
+		log.Fatal("❌ File storage: ", err)
+	}
+
+	// ---------------- PAYMENT GATEWAY ----------------
+	gateway.Init()
+
+	// ---------------- BACKGROUND JOBS ----------------
+	go controllers.StartPenaltyJob(time.Hour)
+	go controllers.StartHoldJob(time.Hour)
+	go controllers.StartGradeReleaseJob(time.Minute)
+	go controllers.StartResumableUploadJob(time.Hour)
+	go controllers.StartVideoTranscodeJob(time.Minute)
+
 	// ---------------- CREATE GIN ROUTER ----------------
 	r := gin.Default()
 
@@ -27,8 +46,9 @@ func main() {
 		}
 
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range, Upload-Offset, Upload-Checksum, Tus-Resumable")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Location, Accept-Ranges, Content-Range, Content-Length, Upload-Offset, Upload-Length, Upload-Expires, Tus-Resumable")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -39,7 +59,9 @@ func main() {
 
 	// ---------------- STATIC FILES ----------------
 	r.Static("/assets", "./frontend")
-	r.Static("/uploads", "./uploads")
+
+	// Uploaded files go through authorized handlers, see controllers/file_controller.go
+	r.GET("/files/*filepath", controllers.ServeSignedUpload)
 
 	// ---------------- PUBLIC PAGES ----------------
 	r.GET("/", func(c *gin.Context) { c.File("./frontend/login.html") })
@@ -58,9 +80,39 @@ func main() {
 		c.File("./frontend/reset_password.html")
 	})
 
+	// ---------------- PAYMENT GATEWAY (provider callbacks) ----------------
+	r.POST("/payments/webhook/:provider", controllers.PaymentGatewayWebhook)
+	if gateway.FakeEnabled() {
+		r.GET("/payments/gateway/fake/checkout/:provider_ref", controllers.FakeGatewayCheckoutPage)
+	}
+
+	// ---------------- DOCUMENT VERIFICATION (public) ----------------
+	r.GET("/verify/public-key", controllers.GetDocumentSigningKey)
+	r.GET("/verify/:serial", controllers.VerifyDocument)
+	r.POST("/verify/:serial", controllers.VerifyDocumentFile)
+
 	// ---------------- PROTECTED ROUTES ----------------
 	protected := r.Group("/")
 	protected.Use(middleware.AuthMiddleware())
+	protected.GET("/uploads/*filepath", controllers.ServeUpload)
+	protected.POST("/uploads/sign", controllers.SignUploadURL)
+
+	// Chunked uploads for lessons and submissions, see controllers/resumable_upload_controller.go
+	resumable := protected.Group("/resumable-uploads")
+	resumable.Use(middleware.RoleOnly("teacher", "student"))
+	resumable.POST("", controllers.CreateResumableUpload)
+	resumable.HEAD("/:id", controllers.GetResumableUpload)
+	resumable.GET("/:id", controllers.GetResumableUpload)
+	resumable.PATCH("/:id", controllers.PatchResumableUpload)
+	resumable.DELETE("/:id", controllers.CancelResumableUpload)
+
+	// Lesson video playback, see controllers/video_controller.go
+	lessons := protected.Group("/lessons")
+	lessons.Use(middleware.RoleOnly("teacher", "student", "faculty", "admin"))
+	lessons.GET("/:id/video", controllers.GetLessonVideo)
+	lessons.GET("/:id/stream", controllers.StreamLessonVideo)
+	lessons.GET("/:id/hls/:file", controllers.ServeLessonHLS)
+	lessons.GET("/:id/poster", controllers.ServeLessonPoster)
 
 	// ✅ FIXED: was using r.GET (unprotected), now correctly uses protected.GET
 	r.GET("/public/courses", controllers.FacultyGetCourses)
@@ -90,6 +142,24 @@ func main() {
 	admin.POST("/students/:id/reset-password", controllers.AdminResetStudentPassword)
 	admin.GET("/students/search", controllers.AdminSearchStudents)
 	admin.DELETE("/students/:id", controllers.AdminDeleteStudent)
+	admin.GET("/installment-plans", controllers.AdminGetInstallmentPlans)
+	admin.POST("/installment-plans", controllers.AdminCreateInstallmentPlan)
+	admin.PUT("/installment-plans/:id", controllers.AdminUpdateInstallmentPlan)
+	admin.GET("/term-calendar", controllers.AdminGetTermCalendar)
+	admin.POST("/term-calendar", controllers.AdminSaveTermCalendar)
+	admin.GET("/penalty-rules", controllers.AdminGetPenaltyRules)
+	admin.POST("/penalty-rules", controllers.AdminCreatePenaltyRule)
+	admin.PUT("/penalty-rules/:id", controllers.AdminUpdatePenaltyRule)
+	admin.POST("/penalty-rules/run", controllers.AdminRunPenaltyAssessment)
+	admin.GET("/settings/holds", controllers.AdminGetHoldSettings)
+	admin.PUT("/settings/holds", controllers.AdminUpdateHoldSettings)
+	admin.GET("/settings/honors", controllers.AdminGetHonorsSettings)
+	admin.PUT("/settings/honors", controllers.AdminUpdateHonorsSettings)
+	admin.GET("/holds", controllers.StaffGetHolds)
+	admin.POST("/holds", controllers.StaffPlaceHold)
+	admin.POST("/holds/:id/lift", controllers.StaffLiftHold)
+	admin.GET("/grading-windows", controllers.GetGradingWindows)
+	admin.POST("/grading-windows", controllers.SaveGradingWindow)
 
 	// ---------------- TEACHER ROUTES ----------------
 	teacher := protected.Group("/teacher")
@@ -99,11 +169,30 @@ func main() {
 	teacher.GET("/students", controllers.TeacherGetStudents)
 	teacher.POST("/grades", controllers.TeacherSubmitGrade)
 	teacher.GET("/grades", controllers.TeacherGetGrades)
+	teacher.POST("/grades/import", controllers.TeacherImportGrades)
+	teacher.GET("/grading-windows", controllers.GetGradingWindows)
+	teacher.POST("/classes/:id/submit-grades", controllers.TeacherSubmitGradesForVerification)
+	teacher.POST("/grades/:grade_id/change-requests", controllers.TeacherRequestGradeChange)
+	teacher.GET("/grade-change-requests", controllers.TeacherGetGradeChangeRequests)
+	teacher.GET("/classes/:id/grading-scheme", controllers.TeacherGetGradingScheme)
+	teacher.PUT("/classes/:id/grading-scheme", controllers.TeacherSaveGradingScheme)
+	teacher.GET("/transmutation-tables", controllers.TeacherGetTransmutationTables)
+	teacher.POST("/transmutation-tables", controllers.TeacherCreateTransmutationTable)
+	teacher.GET("/classes/:id/grade-items", controllers.TeacherGetGradeItems)
+	teacher.POST("/classes/:id/grade-items", controllers.TeacherCreateGradeItem)
+	teacher.DELETE("/grade-items/:id", controllers.TeacherDeleteGradeItem)
+	teacher.POST("/grade-items/:id/scores", controllers.TeacherSaveScores)
+	teacher.POST("/classes/:id/grade-marks", controllers.TeacherSetGradeMark)
+	teacher.POST("/classes/:id/compute-grades", controllers.TeacherComputeGrades)
+	teacher.GET("/classes/:id/gradebook", controllers.TeacherGetGradebook)
+	teacher.POST("/lessons/:id/grade-item", controllers.TeacherLinkLessonGradeItem)
+	teacher.GET("/exam-permits", controllers.TeacherGetExamPermits)
 	teacher.POST("/lessons", controllers.TeacherUploadLesson)
 	teacher.GET("/lessons", controllers.TeacherGetLessonMaterials)
 	teacher.GET("/lessons/:id/submissions", controllers.TeacherGetSubmissions)
 	teacher.PUT("/lessons/:id", controllers.TeacherUpdateLesson)
 	teacher.DELETE("/lessons/:id", controllers.TeacherDeleteLessonMaterial)
+	teacher.POST("/lessons/:id/transcode", controllers.TeacherRetranscodeLesson)
 	teacher.GET("/submissions/pending", controllers.TeacherGetPendingSubmissions)
 	teacher.POST("/submissions/:id/review", controllers.TeacherReviewSubmission)
 	teacher.POST("/announcements", controllers.TeacherPostAnnouncement)
@@ -118,16 +207,34 @@ func main() {
 		c.File("./frontend/student.html")
 	})
 	student.GET("/payments/me", controllers.StudentGetPaymentsMe)
+	student.GET("/payments/receipts/:or_number", controllers.StudentDownloadReceipt)
+	student.GET("/statement", controllers.StudentGetStatement)
+	student.GET("/statement/pdf", controllers.StudentDownloadStatement)
+	student.POST("/statement/email", controllers.StudentEmailStatement)
+	student.GET("/installment-plans", controllers.StudentGetInstallmentPlans)
 	student.POST("/pay", controllers.StudentPayBill)
 	student.POST("/payments/downpayment", controllers.StudentDownPayment)
+	student.GET("/payments/transactions", controllers.StudentGetPaymentTransactions)
+	student.POST("/payments/transactions/:id/proof", controllers.StudentUploadPaymentProof)
+	student.POST("/payments/checkout", controllers.StudentCreateCheckout)
+	student.GET("/payments/checkout/:reference", controllers.StudentGetCheckout)
+	if gateway.FakeEnabled() {
+		student.POST("/payments/checkout/:reference/simulate", controllers.StudentSimulateCheckout)
+	}
 	student.GET("/installments", controllers.StudentGetInstallments)
+	student.GET("/exam-permits", controllers.StudentGetExamPermits)
+	student.GET("/holds", controllers.StudentGetHolds)
 	student.GET("/schedule", controllers.StudentGetSchedule)
+	student.GET("/document-types", controllers.StudentGetDocumentTypes)
 	student.POST("/documents/request", controllers.StudentRequestDocument)
 	student.GET("/lessons", controllers.StudentGetLessons)
 	student.POST("/submissions/upload", controllers.StudentUploadSubmission)
 	student.GET("/submissions", controllers.StudentGetSubmissions)
 	student.GET("/documents/requests", controllers.StudentGetDocumentRequests)
+	student.GET("/documents/requests/:id/claim-stub", controllers.StudentDownloadClaimStub)
 	student.GET("/grades", controllers.StudentGetGrades)
+	student.GET("/gradebook", controllers.StudentGetGradebook)
+	student.GET("/gwa", controllers.StudentGetGWA)
 	student.GET("/profile", controllers.StudentGetProfile)
 	student.PUT("/profile/update", controllers.StudentUpdateProfile)
 	student.POST("/profile/change-password", controllers.StudentChangePassword)
@@ -153,6 +260,9 @@ func main() {
 
 	// ✅ FIXED: was using r.POST (unprotected), now correctly uses registrar group
 	registrar.POST("/student/status", controllers.RegistrarUpdateStudentStatus)
+	registrar.GET("/holds", controllers.StaffGetHolds)
+	registrar.POST("/holds", controllers.StaffPlaceHold)
+	registrar.POST("/holds/:id/lift", controllers.StaffLiftHold)
 
 	// ---------------- CASHIER ROUTES ----------------
 	cashier := protected.Group("/cashier")
@@ -161,7 +271,34 @@ func main() {
 		c.File("./frontend/cashier.html")
 	})
 	cashier.GET("/pending-payments", controllers.CashierGetPendingPayments)
+	cashier.GET("/queue", controllers.CashierGetQueue)
+	cashier.POST("/queue/:payment_id/claim", controllers.CashierClaimPayment)
+	cashier.DELETE("/queue/:payment_id/claim", controllers.CashierReleasePayment)
 	cashier.POST("/approve-payment", controllers.CashierApprovePayment)
+	cashier.POST("/payment-proofs/:id/review", controllers.CashierReviewPaymentProof)
+	cashier.GET("/receipts", controllers.CashierGetReceipts)
+	cashier.POST("/receipts/:or_number/reprint", controllers.CashierReprintReceipt)
+	cashier.GET("/or-series", controllers.CashierGetORSeries)
+	cashier.POST("/or-series", controllers.CashierCreateORSeries)
+	cashier.GET("/overdue-installments", controllers.CashierGetOverdueInstallments)
+	cashier.GET("/penalties", controllers.CashierGetPenalties)
+	cashier.POST("/penalties/:id/waive", controllers.CashierWaivePenalty)
+	cashier.POST("/exam-permits/override", controllers.CashierOverrideExamPermit)
+	cashier.GET("/document-requests", controllers.CashierGetUnpaidDocumentRequests)
+	cashier.POST("/document-requests/:id/pay", controllers.CashierPayDocumentRequest)
+	cashier.DELETE("/exam-permits/override", controllers.CashierRevokeExamPermitOverride)
+	cashier.POST("/settlements", controllers.CashierUploadSettlement)
+	cashier.GET("/settlements", controllers.CashierGetSettlements)
+	cashier.GET("/settlements/:id", controllers.CashierGetSettlementLines)
+	cashier.POST("/shifts/open", controllers.CashierOpenShift)
+	cashier.GET("/shifts/current", controllers.CashierGetCurrentShift)
+	cashier.POST("/shifts/close", controllers.CashierCloseShift)
+	cashier.GET("/shifts", controllers.CashierGetShifts)
+	cashier.GET("/reports/daily", controllers.CashierDailyCollectionReport)
+	cashier.GET("/reports/term", controllers.CashierTermCollectionReport)
+	cashier.GET("/holds", controllers.StaffGetHolds)
+	cashier.POST("/holds", controllers.StaffPlaceHold)
+	cashier.POST("/holds/:id/lift", controllers.StaffLiftHold)
 
 	// ---------------- RECORDS ROUTES ----------------
 	records := protected.Group("/records")
@@ -175,13 +312,55 @@ func main() {
 	records.POST("/document-requests/:id", controllers.RecordsProcessDocumentRequest)
 	records.GET("/grades", controllers.RecordsGetAllGrades)
 	records.GET("/grades/student/:student_id", controllers.RecordsGetStudentGrades)
+	records.GET("/grades/student/:student_id/gwa", controllers.RecordsGetStudentGWA)
+	records.GET("/grades/student/:student_id/credits", controllers.RecordsGetCreditedSubjects)
+	records.POST("/grades/student/:student_id/credits", controllers.RecordsAddCreditedSubject)
+	records.DELETE("/credits/:id", controllers.RecordsDeleteCreditedSubject)
 	records.POST("/grades/:grade_id/release", controllers.RecordsReleaseGrade)
+	records.GET("/grade-releases/preview", controllers.RecordsPreviewGradeRelease)
+	records.POST("/grade-releases", controllers.RecordsBatchReleaseGrades)
+	records.GET("/grade-releases", controllers.RecordsGetGradeReleaseBatches)
+	records.DELETE("/grade-releases/:id", controllers.RecordsCancelGradeRelease)
+	records.GET("/grading-windows", controllers.GetGradingWindows)
+	records.POST("/grading-windows", controllers.SaveGradingWindow)
+	records.GET("/grade-submissions", controllers.StaffGetGradeSubmissions)
+	records.POST("/grade-submissions/:class_id/review", controllers.StaffReviewGradeSubmission)
+	records.GET("/grade-change-requests", controllers.StaffGetGradeChangeRequests)
+	records.POST("/grade-change-requests/:id/review", controllers.StaffReviewGradeChangeRequest)
 	records.GET("/announcements", controllers.RecordsGetAnnouncements)
 	records.GET("/announcements/:id", controllers.RecordsGetAnnouncementDetails)
 	records.POST("/announcements", controllers.RecordsPostAnnouncement)
 	records.PUT("/announcements/:id", controllers.RecordsUpdateAnnouncement)
 	records.POST("/announcements/:id/toggle", controllers.RecordsToggleAnnouncement)
 	records.DELETE("/announcements/:id", controllers.RecordsDeleteAnnouncement)
+	records.GET("/holds", controllers.StaffGetHolds)
+	records.POST("/holds", controllers.StaffPlaceHold)
+	records.POST("/holds/:id/lift", controllers.StaffLiftHold)
+	records.POST("/issued-documents/:serial/revoke", controllers.RecordsRevokeIssuedDocument)
+	records.GET("/document-types", controllers.RecordsGetDocumentTypes)
+	records.POST("/document-types", controllers.RecordsCreateDocumentType)
+	records.PUT("/document-types/:id", controllers.RecordsUpdateDocumentType)
+	records.GET("/document-types/:id/templates", controllers.RecordsGetDocumentTemplates)
+	records.POST("/document-types/:id/templates", controllers.RecordsCreateDocumentTemplate)
+	records.PUT("/document-templates/:id", controllers.RecordsUpdateDocumentTemplate)
+	records.POST("/document-templates/:id/publish", controllers.RecordsPublishDocumentTemplate)
+	records.GET("/document-templates/:id/preview", controllers.RecordsPreviewDocumentTemplate)
+	records.GET("/document-types/:id/signatories", controllers.RecordsGetDocumentSignatories)
+	records.PUT("/document-types/:id/signatories", controllers.RecordsSetDocumentSignatories)
+	records.GET("/document-requests/:id/approvals", controllers.RecordsGetDocumentApprovals)
+	records.POST("/document-requests/:id/ready", controllers.RecordsMarkDocumentReady)
+	records.POST("/document-requests/:id/release", controllers.RecordsReleaseDocument)
+	records.GET("/document-releases", controllers.RecordsGetDocumentReleases)
+
+	// ---------------- SIGNATORY ROUTES ----------------
+	// Any staff account listed in a document type's signatory chain
+	signatory := protected.Group("/signatory")
+	signatory.Use(middleware.RoleOnly("admin", "registrar", "records", "faculty", "teacher"))
+	signatory.GET("/signature", controllers.SignatoryGetSignature)
+	signatory.GET("/signature/image", controllers.SignatoryGetSignatureImage)
+	signatory.POST("/signature", controllers.SignatoryUploadSignature)
+	signatory.GET("/approvals", controllers.SignatoryGetApprovals)
+	signatory.POST("/approvals/:id", controllers.SignatoryActOnApproval)
 
 	// ---------------- FACULTY ROUTES ----------------
 	faculty := protected.Group("/faculty")
@@ -195,6 +374,10 @@ func main() {
 	faculty.GET("/subjects", controllers.FacultyGetSubjects)
 	faculty.POST("/subjects", controllers.FacultyCreateSubject)
 	faculty.POST("/school-year", controllers.FacultySetSchoolYear)
+	faculty.GET("/grade-submissions", controllers.StaffGetGradeSubmissions)
+	faculty.POST("/grade-submissions/:class_id/review", controllers.StaffReviewGradeSubmission)
+	faculty.GET("/grade-change-requests", controllers.StaffGetGradeChangeRequests)
+	faculty.POST("/grade-change-requests/:id/review", controllers.StaffReviewGradeChangeRequest)
 
 	// ---------------- TEST PING ----------------
 	r.GET("/ping", func(c *gin.Context) { c.JSON(200, gin.H{"message": "pong"}) })
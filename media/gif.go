@@ -0,0 +1,68 @@
+package media
+
+// MaxGIFFrames caps the frames of an animated GIF. Together with MaxPixels
+// over the frames' summed area it keeps gif.DecodeAll, which allocates every
+// frame up front, from being used as a decompression bomb.
+const MaxGIFFrames = 500
+
+// checkGIFFrames walks the GIF's blocks without decoding them and refuses
+// files with too many frames or too many pixels across their frames.
+func checkGIFFrames(data []byte) error {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return ErrInvalidImage
+	}
+	pos := 13
+	if data[10]&0x80 != 0 { // global color table
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks returns the position after a run of data sub-blocks
+	skipSubBlocks := func(pos int) int {
+		for pos < len(data) {
+			n := int(data[pos])
+			pos++
+			if n == 0 {
+				return pos
+			}
+			pos += n
+		}
+		return -1
+	}
+
+	frames, area := 0, 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // extension: label, then sub-blocks
+			if pos+2 > len(data) {
+				return ErrInvalidImage
+			}
+			pos = skipSubBlocks(pos + 2)
+		case 0x2C: // image descriptor
+			if pos+10 > len(data) {
+				return ErrInvalidImage
+			}
+			w := int(data[pos+5]) | int(data[pos+6])<<8
+			h := int(data[pos+7]) | int(data[pos+8])<<8
+			flags := data[pos+9]
+			frames++
+			area += w * h
+			if frames > MaxGIFFrames || area > MaxPixels {
+				return ErrImageTooBig
+			}
+			pos += 10
+			if flags&0x80 != 0 { // local color table
+				pos += 3 << (flags&0x07 + 1)
+			}
+			pos = skipSubBlocks(pos + 1) // after the LZW minimum code size
+		case 0x3B: // trailer
+			return nil
+		default:
+			return ErrInvalidImage
+		}
+		if pos < 0 {
+			return ErrInvalidImage
+		}
+	}
+	// The decoder accepts a missing trailer, so this does too
+	return nil
+}
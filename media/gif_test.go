@@ -0,0 +1,62 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// rawGIF builds a GIF of frames w x h without real pixel data; checkGIFFrames
+// only walks the blocks.
+func rawGIF(frames, w, h int, trailer bool) []byte {
+	b := []byte("GIF89a")
+	b = append(b, byte(w), byte(w>>8), byte(h), byte(h>>8), 0, 0, 0)
+	// comment extension, to be skipped
+	b = append(b, 0x21, 0xFE, 3, 'h', 'i', '!', 0)
+	for i := 0; i < frames; i++ {
+		b = append(b, 0x2C, 0, 0, 0, 0, byte(w), byte(w>>8), byte(h), byte(h>>8), 0)
+		b = append(b, 2, 2, 0x4C, 0x01, 0)
+	}
+	if trailer {
+		b = append(b, 0x3B)
+	}
+	return b
+}
+
+func TestCheckGIFFrames(t *testing.T) {
+	var encoded bytes.Buffer
+	pal := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White})
+	if err := gif.Encode(&encoded, pal, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"encoded by image/gif", encoded.Bytes(), nil},
+		{"single frame", rawGIF(1, 16, 16, true), nil},
+		{"frame cap", rawGIF(MaxGIFFrames, 1, 1, true), nil},
+		{"too many frames", rawGIF(MaxGIFFrames+1, 1, 1, true), ErrImageTooBig},
+		{"too many pixels across frames", rawGIF(3, 4000, 4000, true), ErrImageTooBig},
+		{"missing trailer", rawGIF(2, 16, 16, false), nil},
+		{"truncated descriptor", rawGIF(1, 16, 16, false)[:len(rawGIF(0, 16, 16, false))+4], ErrInvalidImage},
+		{"truncated sub-block", append(rawGIF(1, 16, 16, false)[:len(rawGIF(1, 16, 16, false))-4], 0x40, 1, 2), ErrInvalidImage},
+		{"truncated extension", append(rawGIF(0, 16, 16, false), 0x21), ErrInvalidImage},
+		{"unknown block", append(rawGIF(1, 16, 16, false), 0x99), ErrInvalidImage},
+		{"not a GIF", []byte("GIF88a\x01\x00\x01\x00\x00\x00\x00;"), ErrInvalidImage},
+		{"header only", []byte("GIF89a"), ErrInvalidImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkGIFFrames(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("checkGIFFrames = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
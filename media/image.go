@@ -0,0 +1,341 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// MaxPixels refuses images whose decoded size would exhaust memory
+// (decompression bombs) before they are decoded.
+const MaxPixels = 40_000_000
+
+var (
+	ErrInvalidImage = errors.New("file is not a valid image")
+	ErrImageTooBig  = errors.New("image dimensions are too large")
+	ErrNoPixels     = errors.New("image cannot be decoded for a thumbnail")
+)
+
+// Image is a normalized image ready to store.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+
+	pixels image.Image // first frame, for thumbnails; nil for WebP
+}
+
+// NormalizeImage re-encodes an uploaded image so nothing but pixels
+// survives: EXIF (GPS position, camera serial), XMP and comments are
+// dropped, JPEG orientation is applied to the pixels first, and images
+// larger than maxDim on either side (0 = no limit) are scaled down.
+// Animated GIFs keep their frames and size, up to MaxGIFFrames frames and
+// MaxPixels across them. WebP is not decoded by the standard library, so
+// only its metadata chunks are removed.
+func NormalizeImage(data []byte, contentType string, maxDim int) (Image, error) {
+	if contentType == "image/webp" {
+		return normalizeWebP(data)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return Image{}, ErrImageTooBig
+	}
+
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, ErrInvalidImage
+		}
+		out := orient(fit(img, maxDim), jpegOrientation(data))
+		if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: 88}); err != nil {
+			return Image{}, err
+		}
+		return Image{Data: buf.Bytes(), ContentType: contentType, Width: out.Bounds().Dx(), Height: out.Bounds().Dy(), pixels: out}, nil
+
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, ErrInvalidImage
+		}
+		out := fit(img, maxDim)
+		if err := png.Encode(&buf, out); err != nil {
+			return Image{}, err
+		}
+		return Image{Data: buf.Bytes(), ContentType: contentType, Width: out.Bounds().Dx(), Height: out.Bounds().Dy(), pixels: out}, nil
+
+	case "image/gif":
+		if err := checkGIFFrames(data); err != nil {
+			return Image{}, err
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(g.Image) == 0 {
+			return Image{}, ErrInvalidImage
+		}
+		if len(g.Image) == 1 {
+			out := fit(g.Image[0], maxDim)
+			if err := gif.Encode(&buf, out, nil); err != nil {
+				return Image{}, err
+			}
+			return Image{Data: buf.Bytes(), ContentType: contentType, Width: out.Bounds().Dx(), Height: out.Bounds().Dy(), pixels: out}, nil
+		}
+		// Re-encoding the frames drops comment and application extensions
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			return Image{}, err
+		}
+		return Image{Data: buf.Bytes(), ContentType: contentType, Width: g.Config.Width, Height: g.Config.Height, pixels: g.Image[0]}, nil
+	}
+	return Image{}, fmt.Errorf("%w: %s", ErrInvalidImage, contentType)
+}
+
+// Thumbnail scales img to fit in maxDim x maxDim. Opaque images become
+// JPEG, images with transparency PNG.
+func Thumbnail(img Image, maxDim int) (Image, error) {
+	if img.pixels == nil {
+		return Image{}, ErrNoPixels
+	}
+	out := fit(img.pixels, maxDim)
+
+	var buf bytes.Buffer
+	if opaque(out) {
+		if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: 80}); err != nil {
+			return Image{}, err
+		}
+		return Image{Data: buf.Bytes(), ContentType: "image/jpeg", Width: out.Bounds().Dx(), Height: out.Bounds().Dy(), pixels: out}, nil
+	}
+	if err := png.Encode(&buf, out); err != nil {
+		return Image{}, err
+	}
+	return Image{Data: buf.Bytes(), ContentType: "image/png", Width: out.Bounds().Dx(), Height: out.Bounds().Dy(), pixels: out}, nil
+}
+
+// DecodeImage wraps an already rendered image (a PDF page preview) so it can
+// be thumbnailed.
+func DecodeImage(data []byte) (Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return Image{}, ErrImageTooBig
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrInvalidImage
+	}
+	return Image{Data: data, ContentType: "image/" + format, Width: cfg.Width, Height: cfg.Height, pixels: img}, nil
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// fit scales img down to fit in maxDim x maxDim, keeping its aspect ratio.
+// Smaller images are returned as they are.
+func fit(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxDim <= 0 || (w <= maxDim && h <= maxDim) {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxDim/w)
+		w = maxDim
+	} else {
+		w = max(1, w*maxDim/h)
+		h = maxDim
+	}
+	return resize(img, w, h)
+}
+
+// span is one source pixel's share of a destination pixel.
+type span struct {
+	index  int
+	weight float64
+}
+
+// coverage gives, for each destination index, the source indexes it covers
+// and how much of each (area averaging, which is what downscaling needs).
+func coverage(srcLen, dstLen int) [][]span {
+	scale := float64(srcLen) / float64(dstLen)
+	out := make([][]span, dstLen)
+	for i := range out {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < srcLen && float64(j) < end; j++ {
+			lo, hi := max(start, float64(j)), min(end, float64(j+1))
+			if hi > lo {
+				out[i] = append(out[i], span{j, (hi - lo) / scale})
+			}
+		}
+	}
+	return out
+}
+
+// resize downscales with a box filter, a row at a time so memory stays at
+// a couple of destination rows whatever the source size. Colors are averaged
+// premultiplied so transparent edges do not darken.
+func resize(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	cols := coverage(b.Dx(), w)
+	rows := coverage(b.Dy(), h)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	cachedRow, cached := -1, make([]float64, w*4)
+	rowOf := func(sy int) []float64 {
+		if sy == cachedRow {
+			return cached
+		}
+		for x, spans := range cols {
+			var r, g, bl, a float64
+			for _, s := range spans {
+				cr, cg, cb, ca := img.At(b.Min.X+s.index, b.Min.Y+sy).RGBA()
+				r += float64(cr) * s.weight
+				g += float64(cg) * s.weight
+				bl += float64(cb) * s.weight
+				a += float64(ca) * s.weight
+			}
+			cached[x*4], cached[x*4+1], cached[x*4+2], cached[x*4+3] = r, g, bl, a
+		}
+		cachedRow = sy
+		return cached
+	}
+
+	acc := make([]float64, w*4)
+	for y, spans := range rows {
+		for i := range acc {
+			acc[i] = 0
+		}
+		for _, s := range spans {
+			row := rowOf(s.index)
+			for i, v := range row {
+				acc[i] += v * s.weight
+			}
+		}
+		for x := 0; x < w; x++ {
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(min(acc[x*4], 65535)/257 + 0.5),
+				G: uint8(min(acc[x*4+1], 65535)/257 + 0.5),
+				B: uint8(min(acc[x*4+2], 65535)/257 + 0.5),
+				A: uint8(min(acc[x*4+3], 65535)/257 + 0.5),
+			})
+		}
+	}
+	return dst
+}
+
+// orient turns pixels stored sideways or mirrored upright, following the
+// EXIF orientation tag (1-8).
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation reads the EXIF orientation tag of a JPEG, or 1 when there
+// is none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // image data starts: no EXIF
+			return 1
+		}
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var u16 func([]byte) int
+	var u32 func([]byte) int
+	switch string(tiff[:2]) {
+	case "II":
+		u16 = func(b []byte) int { return int(b[0]) | int(b[1])<<8 }
+		u32 = func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 | int(b[3])<<24 }
+	case "MM":
+		u16 = func(b []byte) int { return int(b[1]) | int(b[0])<<8 }
+		u32 = func(b []byte) int { return int(b[3]) | int(b[2])<<8 | int(b[1])<<16 | int(b[0])<<24 }
+	default:
+		return 1
+	}
+	ifd := u32(tiff[4:8])
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := u16(tiff[ifd : ifd+2])
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if u16(tiff[entry:entry+2]) == 0x0112 {
+			v := u16(tiff[entry+8 : entry+10])
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
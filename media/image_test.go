@@ -0,0 +1,67 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// exifJPEG is a JPEG start with an APP1 EXIF segment holding tiff, followed
+// by the start of scan.
+func exifJPEG(tiff []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	n := len(payload) + 2
+	b := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 'J', 'F'} // a JFIF-like APP0 first
+	b = append(b, 0xFF, 0xE1, byte(n>>8), byte(n))
+	b = append(b, payload...)
+	return append(b, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+// orientationTIFF is a TIFF header with one IFD entry: the orientation tag.
+func orientationTIFF(bigEndian bool, value int) []byte {
+	if bigEndian {
+		return []byte{'M', 'M', 0x00, 0x2A, 0, 0, 0, 8,
+			0x00, 0x01,
+			0x01, 0x12, 0x00, 0x03, 0, 0, 0, 1, 0x00, byte(value), 0, 0,
+			0, 0, 0, 0}
+	}
+	return []byte{'I', 'I', 0x2A, 0x00, 8, 0, 0, 0,
+		0x01, 0x00,
+		0x12, 0x01, 0x03, 0x00, 1, 0, 0, 0, byte(value), 0x00, 0, 0,
+		0, 0, 0, 0}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	truncated := exifJPEG(orientationTIFF(false, 6))
+	truncated = truncated[:len(truncated)-12]
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"little-endian rotate 90", exifJPEG(orientationTIFF(false, 6)), 6},
+		{"big-endian rotate 180", exifJPEG(orientationTIFF(true, 3)), 3},
+		{"mirrored", exifJPEG(orientationTIFF(false, 2)), 2},
+		{"out-of-range value", exifJPEG(orientationTIFF(false, 9)), 1},
+		{"unknown byte order", exifJPEG(append([]byte("XX"), orientationTIFF(false, 6)[2:]...)), 1},
+		{"IFD offset past the end", exifJPEG([]byte{'I', 'I', 0x2A, 0x00, 0xFF, 0, 0, 0}), 1},
+		{"segment runs past the end", truncated, 1},
+		{"no EXIF", plain.Bytes(), 1},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"empty", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
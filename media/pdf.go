@@ -0,0 +1,328 @@
+package media
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPDF = errors.New("file is not a valid PDF")
+	ErrNoRenderer = errors.New("no PDF renderer installed")
+)
+
+// PDFInfo is what can be learned about a PDF without rendering it.
+type PDFInfo struct {
+	Pages     int
+	Encrypted bool
+	// ActiveContent lists scripting and launch features found in the file
+	// (JavaScript, Launch, EmbeddedFile...).
+	ActiveContent []string
+	// Text is the start of the text drawn on the pages, when the fonts use
+	// plain encodings. Good enough for a preview line, not for search.
+	Text string
+}
+
+const (
+	maxInflated     = 64 << 20 // total decompressed stream data
+	maxStreamLength = 16 << 20
+	previewTextLen  = 500
+)
+
+var (
+	objectPattern     = regexp.MustCompile(`(?s)(\d+)\s+\d+\s+obj\b(.*?)\bendobj`)
+	pageTypePattern   = regexp.MustCompile(`/Type\s*/Page\b`)
+	objStmPattern     = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	firstPattern      = regexp.MustCompile(`/First\s+(\d+)`)
+	countPattern      = regexp.MustCompile(`/N\s+(\d+)`)
+	namePattern       = regexp.MustCompile(`/[A-Za-z0-9#]+`)
+	textShowPattern   = regexp.MustCompile(`(?s)(\((?:\\.|[^\\)])*\))\s*(?:Tj|'|")|\[((?:\\.|[^\]])*)\]\s*TJ`)
+	literalPattern    = regexp.MustCompile(`\((?:\\.|[^\\)])*\)`)
+	streamStartRegexp = regexp.MustCompile(`stream\r?\n`)
+)
+
+// activeNames are the PDF name objects that make a document run code or
+// carry other files. An attachment shows up as an /EF file specification or
+// an /EmbeddedFile stream; the /EmbeddedFiles name tree alone is not enough,
+// since gofpdf writes an empty one into every file.
+var activeNames = map[string]string{
+	"/JavaScript":   "JavaScript",
+	"/JS":           "JavaScript",
+	"/Launch":       "Launch",
+	"/EF":           "EmbeddedFile",
+	"/EmbeddedFile": "EmbeddedFile",
+	"/RichMedia":    "RichMedia",
+	"/XFA":          "XFA",
+}
+
+// decodeName resolves #xx escapes, which can hide /JavaScript as /J#61vaScript.
+func decodeName(name string) string {
+	if !strings.Contains(name, "#") {
+		return name
+	}
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if v, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				sb.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
+
+type pdfStream struct {
+	dict []byte
+	data []byte // inflated
+}
+
+// streams inflates the FlateDecode streams of a PDF, within the size limits.
+func streams(data []byte) []pdfStream {
+	var out []pdfStream
+	total := 0
+	for _, loc := range streamStartRegexp.FindAllIndex(data, -1) {
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		dictStart := bytes.LastIndex(data[max(0, loc[0]-1024):loc[0]], []byte("obj"))
+		dict := data[max(0, loc[0]-1024):loc[0]]
+		if dictStart >= 0 {
+			dict = dict[dictStart:]
+		}
+		if !bytes.Contains(dict, []byte("/FlateDecode")) {
+			continue
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(data[start : start+end]))
+		if err != nil {
+			continue
+		}
+		inflated, _ := io.ReadAll(io.LimitReader(zr, maxStreamLength))
+		zr.Close()
+		total += len(inflated)
+		if total > maxInflated {
+			break
+		}
+		out = append(out, pdfStream{dict: dict, data: inflated})
+	}
+	return out
+}
+
+// InspectPDF checks that data is a PDF, counts its pages, flags active
+// content and pulls out a line of text.
+func InspectPDF(data []byte) (PDFInfo, error) {
+	header := data[:min(len(data), 1024)]
+	if !bytes.Contains(header, []byte("%PDF-")) {
+		return PDFInfo{}, ErrInvalidPDF
+	}
+	if !bytes.Contains(data[max(0, len(data)-1024):], []byte("%%EOF")) {
+		return PDFInfo{}, ErrInvalidPDF
+	}
+
+	info := PDFInfo{Encrypted: bytes.Contains(data, []byte("/Encrypt"))}
+	decoded := streams(data)
+
+	// Later definitions of an object replace earlier ones (incremental
+	// updates), so pages are counted per object number
+	objects := map[string][]byte{}
+	for _, m := range objectPattern.FindAllSubmatch(data, -1) {
+		objects[string(m[1])] = m[2]
+	}
+	for _, s := range decoded {
+		if !objStmPattern.Match(s.dict) {
+			continue
+		}
+		for num, body := range objectStreamObjects(s) {
+			if _, ok := objects[num]; !ok {
+				objects[num] = body
+			}
+		}
+	}
+	for _, body := range objects {
+		if pageTypePattern.Match(body) {
+			info.Pages++
+		}
+	}
+	if info.Pages == 0 {
+		return PDFInfo{}, ErrInvalidPDF
+	}
+
+	found := map[string]bool{}
+	check := func(b []byte) {
+		for _, n := range namePattern.FindAll(b, -1) {
+			if what, ok := activeNames[decodeName(string(n))]; ok {
+				found[what] = true
+			}
+		}
+	}
+	check(data)
+	for _, s := range decoded {
+		if objStmPattern.Match(s.dict) {
+			check(s.data)
+		}
+	}
+	for what := range found {
+		info.ActiveContent = append(info.ActiveContent, what)
+	}
+	sort.Strings(info.ActiveContent)
+
+	if !info.Encrypted {
+		info.Text = extractText(decoded)
+	}
+	return info, nil
+}
+
+// objectStreamObjects splits a /Type /ObjStm stream into its objects: a
+// header of "number offset" pairs, then the objects from /First on.
+func objectStreamObjects(s pdfStream) map[string][]byte {
+	first, count := 0, 0
+	if m := firstPattern.FindSubmatch(s.dict); m != nil {
+		first, _ = strconv.Atoi(string(m[1]))
+	}
+	if m := countPattern.FindSubmatch(s.dict); m != nil {
+		count, _ = strconv.Atoi(string(m[1]))
+	}
+	if first <= 0 || first > len(s.data) || count <= 0 {
+		return nil
+	}
+
+	fields := strings.Fields(string(s.data[:first]))
+	type entry struct {
+		num    string
+		offset int
+	}
+	var entries []entry
+	for i := 0; i+1 < len(fields) && len(entries) < count; i += 2 {
+		offset, err := strconv.Atoi(fields[i+1])
+		if err != nil || first+offset > len(s.data) {
+			return nil
+		}
+		entries = append(entries, entry{fields[i], first + offset})
+	}
+
+	out := map[string][]byte{}
+	for i, e := range entries {
+		end := len(s.data)
+		if i+1 < len(entries) && entries[i+1].offset >= e.offset {
+			end = entries[i+1].offset
+		}
+		out[e.num] = s.data[e.offset:end]
+	}
+	return out
+}
+
+// extractText collects the literal strings shown by Tj/TJ operators in
+// content streams.
+func extractText(decoded []pdfStream) string {
+	var sb strings.Builder
+	for _, s := range decoded {
+		if objStmPattern.Match(s.dict) || !bytes.Contains(s.data, []byte("BT")) {
+			continue
+		}
+		for _, m := range textShowPattern.FindAllSubmatch(s.data, -1) {
+			var parts [][]byte
+			if m[1] != nil {
+				parts = [][]byte{m[1]}
+			} else {
+				parts = literalPattern.FindAll(m[2], -1)
+			}
+			for _, p := range parts {
+				sb.WriteString(unescapeLiteral(p[1 : len(p)-1]))
+			}
+			sb.WriteByte(' ')
+			if sb.Len() > previewTextLen*2 {
+				break
+			}
+		}
+		if sb.Len() > previewTextLen*2 {
+			break
+		}
+	}
+
+	text := strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, sb.String())), " ")
+	if len(text) > previewTextLen {
+		text = strings.ToValidUTF8(text[:previewTextLen], "")
+	}
+	return text
+}
+
+func unescapeLiteral(b []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\\' || i+1 >= len(b) {
+			sb.WriteByte(b[i])
+			continue
+		}
+		i++
+		switch c := b[i]; c {
+		case 'n', 'r', 't':
+			sb.WriteByte(' ')
+		case '(', ')', '\\':
+			sb.WriteByte(c)
+		default:
+			if c >= '0' && c <= '7' {
+				j := i
+				for j < len(b) && j < i+3 && b[j] >= '0' && b[j] <= '7' {
+					j++
+				}
+				v, _ := strconv.ParseUint(string(b[i:j]), 8, 8)
+				sb.WriteRune(rune(v)) // PDFDocEncoding matches Latin-1 for text
+				i = j - 1
+			}
+		}
+	}
+	return sb.String()
+}
+
+// RenderPDFPage renders the first page as a PNG no larger than maxDim on
+// either side, using poppler's pdftoppm (or the command in PDF_RENDERER).
+// ErrNoRenderer means the tool is not installed and there is no preview.
+func RenderPDFPage(data []byte, maxDim int) ([]byte, error) {
+	renderer := os.Getenv("PDF_RENDERER")
+	if renderer == "" {
+		renderer = "pdftoppm"
+	}
+	bin, err := exec.LookPath(renderer)
+	if err != nil {
+		return nil, ErrNoRenderer
+	}
+
+	dir, err := os.MkdirTemp("", "pdfpreview-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "in.pdf")
+	if err := os.WriteFile(input, data, 0600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, "-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to", strconv.Itoa(maxDim), input, filepath.Join(dir, "page"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, errors.New("pdf render: " + strings.TrimSpace(string(out)) + ": " + err.Error())
+	}
+	return os.ReadFile(filepath.Join(dir, "page.png"))
+}
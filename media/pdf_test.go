@@ -0,0 +1,137 @@
+package media
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// onePagePDF is a minimal single-page PDF with extra added to the catalog
+// and more objects appended before the trailer.
+func onePagePDF(extra, more string) []byte {
+	return []byte(fmt.Sprintf(`%%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R %s >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >> endobj
+%s
+trailer << /Root 1 0 R >>
+%%%%EOF
+`, extra, more))
+}
+
+// objectStream packs objects into a compressed /Type /ObjStm, where a plain
+// byte scan of the file cannot see them.
+func objectStream(t *testing.T, num int, objects ...string) string {
+	t.Helper()
+	var header, body bytes.Buffer
+	for i, o := range objects {
+		fmt.Fprintf(&header, "%d %d ", 10+i, body.Len())
+		body.WriteString(o + " ")
+	}
+	var z bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&z, zlib.BestCompression)
+	zw.Write(append(header.Bytes(), body.Bytes()...))
+	zw.Close()
+	if bytes.Contains(z.Bytes(), []byte("/Type")) {
+		t.Fatal("object stream was stored uncompressed")
+	}
+	return fmt.Sprintf("%d 0 obj << /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream\nendobj",
+		num, len(objects), header.Len(), z.Len(), z.String())
+}
+
+func TestInspectPDF(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		pages  int
+		active []string
+		err    error
+	}{
+		{
+			name:  "plain document",
+			data:  onePagePDF("", ""),
+			pages: 1,
+		},
+		{
+			name:  "empty EmbeddedFiles name tree",
+			data:  onePagePDF("/Names << /EmbeddedFiles << /Names [] >> >>", ""),
+			pages: 1,
+		},
+		{
+			name:   "open action JavaScript",
+			data:   onePagePDF("/OpenAction << /S /JavaScript /JS (app.alert(1)) >>", ""),
+			pages:  1,
+			active: []string{"JavaScript"},
+		},
+		{
+			name:   "JavaScript hidden with #xx escapes",
+			data:   onePagePDF("/OpenAction << /S /J#61vaScript /J#53 (app.alert(1)) >>", ""),
+			pages:  1,
+			active: []string{"JavaScript"},
+		},
+		{
+			name:   "attached file",
+			data:   onePagePDF("", "4 0 obj << /Type /Filespec /F (a.exe) /EF << /F 5 0 R >> >> endobj"),
+			pages:  1,
+			active: []string{"EmbeddedFile"},
+		},
+		{
+			name:   "launch action",
+			data:   onePagePDF("/OpenAction << /S /Launch /F (cmd.exe) >>", ""),
+			pages:  1,
+			active: []string{"Launch"},
+		},
+		{
+			name:   "JavaScript inside an object stream",
+			data:   onePagePDF("", objectStream(t, 6, "<< /S /JavaScript /JS (app.alert(1)) >>")),
+			pages:  1,
+			active: []string{"JavaScript"},
+		},
+		{
+			name:  "pages inside an object stream",
+			data:  onePagePDF("", objectStream(t, 6, "<< /Type /Page /Parent 2 0 R >>", "<< /Type /Page /Parent 2 0 R >>")),
+			pages: 3,
+		},
+		{
+			name:  "page redefined by an incremental update",
+			data:  onePagePDF("", "3 0 obj << /Type /Page /Parent 2 0 R /Rotate 90 >> endobj"),
+			pages: 1,
+		},
+		{
+			name: "no pages",
+			data: []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\n%%EOF\n"),
+			err:  ErrInvalidPDF,
+		},
+		{
+			name: "truncated",
+			data: onePagePDF("", "")[:60],
+			err:  ErrInvalidPDF,
+		},
+		{
+			name: "not a PDF",
+			data: []byte("<html><body>%%EOF</body></html>"),
+			err:  ErrInvalidPDF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := InspectPDF(tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("InspectPDF error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if info.Pages != tt.pages {
+				t.Errorf("pages = %d, want %d", info.Pages, tt.pages)
+			}
+			if !reflect.DeepEqual(info.ActiveContent, tt.active) {
+				t.Errorf("active content = %v, want %v", info.ActiveContent, tt.active)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package media
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Sniff returns the content type of a file from its first bytes (up to 512),
+// without parameters. It extends http.DetectContentType with QuickTime and
+// uses the registered AVI type.
+func Sniff(head []byte) string {
+	ct := http.DetectContentType(head)
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	switch {
+	case ct == "application/octet-stream" && len(head) >= 12 && string(head[4:8]) == "ftyp" && string(head[8:12]) == "qt  ":
+		return "video/quicktime"
+	case ct == "video/avi":
+		return "video/x-msvideo"
+	}
+	return ct
+}
+
+// extensions lists the file name extensions accepted for each content type.
+var extensions = map[string][]string{
+	"image/jpeg":      {".jpg", ".jpeg"},
+	"image/png":       {".png"},
+	"image/gif":       {".gif"},
+	"image/webp":      {".webp"},
+	"application/pdf": {".pdf"},
+	"text/plain":      {".txt"},
+	"video/mp4":       {".mp4", ".m4v"},
+	"video/mpeg":      {".mpeg", ".mpg"},
+	"video/quicktime": {".mov"},
+	"video/x-msvideo": {".avi"},
+	"video/webm":      {".webm"},
+}
+
+// ExtensionMatches reports whether a file named with ext may hold
+// contentType, so "notes.pdf.exe" or a PNG renamed to .pdf is refused.
+func ExtensionMatches(contentType, ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, e := range extensions[contentType] {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Extension returns the usual extension of contentType.
+func Extension(contentType string) string {
+	if exts := extensions[contentType]; len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// IsImage reports whether contentType is an image the pipeline handles.
+func IsImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
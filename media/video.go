@@ -0,0 +1,294 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoFFmpeg     = errors.New("ffmpeg is not installed")
+	ErrInvalidVideo = errors.New("file is not a playable video")
+)
+
+// VideoInfo is what ffprobe reports about a video.
+type VideoInfo struct {
+	Duration float64 // seconds
+	Width    int
+	Height   int
+	HasAudio bool
+}
+
+// Rendition is one HLS quality level.
+type Rendition struct {
+	Name         string
+	Height       int
+	VideoBitrate int // kbit/s
+	AudioBitrate int // kbit/s
+}
+
+// Renditions are the HLS levels made for a video, lowest first. Levels
+// taller than the source are skipped, except the lowest one.
+var Renditions = []Rendition{
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 160},
+}
+
+const (
+	webVideoHeight = 720 // the progressive MP4 is capped at this height
+	hlsSegmentTime = 6   // seconds per HLS segment
+	posterHeight   = 720
+
+	// Names of the files TranscodeVideo writes into its output directory.
+	WebVideoFile    = "web.mp4"
+	HLSPlaylistFile = "master.m3u8"
+	PosterFile      = "poster.jpg"
+)
+
+// TranscodedVideo lists the files TranscodeVideo wrote, as names inside the
+// output directory.
+type TranscodedVideo struct {
+	Info       VideoInfo
+	WebVideo   string
+	Playlist   string
+	Poster     string // empty when no frame could be grabbed
+	Renditions []string
+	Files      []string // every file, playlists and segments included
+}
+
+// tool finds ffmpeg or ffprobe, or the command set in FFMPEG_PATH or
+// FFPROBE_PATH.
+func tool(env, name string) (string, error) {
+	if v := os.Getenv(env); v != "" {
+		name = v
+	}
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		return "", ErrNoFFmpeg
+	}
+	return bin, nil
+}
+
+// FFmpegAvailable reports whether videos can be transcoded here.
+func FFmpegAvailable() bool {
+	if _, err := tool("FFMPEG_PATH", "ffmpeg"); err != nil {
+		return false
+	}
+	_, err := tool("FFPROBE_PATH", "ffprobe")
+	return err == nil
+}
+
+// run executes a tool and returns the tail of its output on failure, which
+// is where ffmpeg says what went wrong.
+func run(ctx context.Context, bin string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(bin), ctx.Err())
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 500 {
+			msg = msg[len(msg)-500:]
+		}
+		return nil, fmt.Errorf("%s: %s: %w", filepath.Base(bin), msg, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// ProbeVideo reads the duration and frame size of the video at input.
+func ProbeVideo(ctx context.Context, input string) (VideoInfo, error) {
+	bin, err := tool("FFPROBE_PATH", "ffprobe")
+	if err != nil {
+		return VideoInfo{}, err
+	}
+	out, err := run(ctx, bin, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", input)
+	if err != nil {
+		if ctx.Err() != nil {
+			return VideoInfo{}, err
+		}
+		return VideoInfo{}, fmt.Errorf("%w: %v", ErrInvalidVideo, err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			CodecType string            `json:"codec_type"`
+			Width     int               `json:"width"`
+			Height    int               `json:"height"`
+			Duration  string            `json:"duration"`
+			Tags      map[string]string `json:"tags"`
+			SideData  []struct {
+				Rotation float64 `json:"rotation"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return VideoInfo{}, fmt.Errorf("ffprobe: %w", err)
+	}
+
+	var info VideoInfo
+	info.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	for _, s := range probe.Streams {
+		switch s.CodecType {
+		case "video":
+			if info.Height > 0 {
+				continue
+			}
+			info.Width, info.Height = s.Width, s.Height
+			// ffmpeg turns phone videos upright, so report them that way
+			rotation := s.Tags["rotate"]
+			for _, sd := range s.SideData {
+				if sd.Rotation != 0 {
+					rotation = strconv.Itoa(int(sd.Rotation))
+				}
+			}
+			if r, _ := strconv.Atoi(rotation); r%180 != 0 {
+				info.Width, info.Height = info.Height, info.Width
+			}
+			if info.Duration == 0 {
+				info.Duration, _ = strconv.ParseFloat(s.Duration, 64)
+			}
+		case "audio":
+			info.HasAudio = true
+		}
+	}
+	if info.Width <= 0 || info.Height <= 0 {
+		return VideoInfo{}, ErrInvalidVideo
+	}
+	return info, nil
+}
+
+// renditionsFor picks the HLS levels for a source of the given height.
+func renditionsFor(height int) []Rendition {
+	out := []Rendition{Renditions[0]}
+	for _, r := range Renditions[1:] {
+		if r.Height <= height {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// scaledWidth is the width of a frame scaled to height, kept even as H.264
+// requires.
+func scaledWidth(info VideoInfo, height int) int {
+	w := int(float64(info.Width)*float64(height)/float64(info.Height) + 0.5)
+	return w + w%2
+}
+
+// h264Args are the encoder settings shared by the MP4 and the HLS levels:
+// H.264 and AAC that every browser and phone plays.
+func h264Args(height int) []string {
+	return []string{
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=-2:'min(%d,trunc(ih/2)*2)'", height),
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "main", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-ac", "2",
+	}
+}
+
+// TranscodeVideo converts the video at input into outDir: a progressive
+// MP4 (H.264/AAC, moov atom first so playback starts before the download
+// ends), HLS levels with a master playlist, and a poster frame.
+func TranscodeVideo(ctx context.Context, input, outDir string) (TranscodedVideo, error) {
+	bin, err := tool("FFMPEG_PATH", "ffmpeg")
+	if err != nil {
+		return TranscodedVideo{}, err
+	}
+	info, err := ProbeVideo(ctx, input)
+	if err != nil {
+		return TranscodedVideo{}, err
+	}
+	out := TranscodedVideo{Info: info}
+
+	web := append([]string{"-y", "-v", "error", "-i", input}, h264Args(webVideoHeight)...)
+	web = append(web, "-crf", "23", "-b:a", "128k", "-movflags", "+faststart", filepath.Join(outDir, WebVideoFile))
+	if _, err := run(ctx, bin, web...); err != nil {
+		return TranscodedVideo{}, err
+	}
+	out.WebVideo = WebVideoFile
+
+	var master strings.Builder
+	master.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range renditionsFor(info.Height) {
+		playlist := r.Name + ".m3u8"
+		args := append([]string{"-y", "-v", "error", "-i", input}, h264Args(r.Height)...)
+		args = append(args,
+			"-b:v", fmt.Sprintf("%dk", r.VideoBitrate),
+			"-maxrate", fmt.Sprintf("%dk", r.VideoBitrate*107/100),
+			"-bufsize", fmt.Sprintf("%dk", r.VideoBitrate*3/2),
+			"-b:a", fmt.Sprintf("%dk", r.AudioBitrate),
+			// a keyframe at every segment start, whatever the frame rate
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsSegmentTime),
+			"-sc_threshold", "0",
+			"-f", "hls", "-hls_time", strconv.Itoa(hlsSegmentTime), "-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(outDir, r.Name+"_%04d.ts"),
+			filepath.Join(outDir, playlist))
+		if _, err := run(ctx, bin, args...); err != nil {
+			return TranscodedVideo{}, err
+		}
+
+		height := min(r.Height, info.Height-info.Height%2)
+		bandwidth := (r.VideoBitrate + r.AudioBitrate) * 1100 // bit/s with container overhead
+		fmt.Fprintf(&master, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n%s\n",
+			bandwidth, scaledWidth(info, height), height, playlist)
+		out.Renditions = append(out.Renditions, playlist)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, HLSPlaylistFile), []byte(master.String()), 0600); err != nil {
+		return TranscodedVideo{}, err
+	}
+	out.Playlist = HLSPlaylistFile
+
+	// Files lists what ended up in outDir, segments included
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return TranscodedVideo{}, err
+	}
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			out.Files = append(out.Files, e.Name())
+		}
+	}
+
+	// The poster comes from a little way in, past black intro frames; a
+	// very short video falls back to its first frame. A video without a
+	// poster still plays, so that is not an error
+	if err := grabPoster(ctx, bin, input, outDir, min(info.Duration*0.1, 5)); err != nil {
+		if err := grabPoster(ctx, bin, input, outDir, 0); err != nil {
+			if ctx.Err() != nil {
+				return TranscodedVideo{}, err
+			}
+			return out, nil
+		}
+	}
+	out.Poster = PosterFile
+	out.Files = append(out.Files, PosterFile)
+	return out, nil
+}
+
+func grabPoster(ctx context.Context, bin, input, outDir string, at float64) error {
+	poster := filepath.Join(outDir, PosterFile)
+	_, err := run(ctx, bin, "-y", "-v", "error", "-ss", strconv.FormatFloat(at, 'f', 2, 64), "-i", input,
+		"-frames:v", "1", "-vf", fmt.Sprintf("scale=-2:'min(%d,trunc(ih/2)*2)'", posterHeight), "-q:v", "3", poster)
+	if err != nil {
+		return err
+	}
+	// ffmpeg exits cleanly without writing a frame when at is past the end
+	if st, err := os.Stat(poster); err != nil || st.Size() == 0 {
+		return errors.New("ffmpeg: no frame at " + strconv.FormatFloat(at, 'f', 2, 64) + "s")
+	}
+	return nil
+}
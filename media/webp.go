@@ -0,0 +1,70 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// normalizeWebP drops the EXIF and XMP chunks of a WebP file and clears
+// their flags in the VP8X header. The image data itself is left untouched.
+func normalizeWebP(data []byte) (Image, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return Image{}, ErrInvalidImage
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	width, height := 0, 0
+	vp8x := -1
+
+	pos := 12
+	for pos+8 <= len(data) {
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size
+		if end > len(data) {
+			return Image{}, ErrInvalidImage
+		}
+		payload := data[pos+8 : end]
+		if size%2 == 1 && end < len(data) {
+			end++ // chunks are padded to an even size
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			pos = end
+			continue
+		case "VP8X":
+			if len(payload) >= 10 {
+				vp8x = len(out) + 8
+				// 24-bit little-endian canvas size minus one
+				width = 1 + (int(payload[4]) | int(payload[5])<<8 | int(payload[6])<<16)
+				height = 1 + (int(payload[7]) | int(payload[8])<<8 | int(payload[9])<<16)
+			}
+		case "VP8 ":
+			if width == 0 && len(payload) >= 10 {
+				width = int(binary.LittleEndian.Uint16(payload[6:8]) & 0x3fff)
+				height = int(binary.LittleEndian.Uint16(payload[8:10]) & 0x3fff)
+			}
+		case "VP8L":
+			if width == 0 && len(payload) >= 5 && payload[0] == 0x2f {
+				bits := binary.LittleEndian.Uint32(payload[1:5])
+				width = int(bits&0x3fff) + 1
+				height = int(bits>>14&0x3fff) + 1
+			}
+		}
+		out = append(out, data[pos:end]...)
+		pos = end
+	}
+
+	if width <= 0 || height <= 0 {
+		return Image{}, ErrInvalidImage
+	}
+	if width*height > MaxPixels {
+		return Image{}, ErrImageTooBig
+	}
+	if vp8x >= 0 {
+		out[vp8x] &^= 0x04 | 0x08 // XMP and EXIF present
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return Image{Data: out, ContentType: "image/webp", Width: width, Height: height}, nil
+}
@@ -0,0 +1,136 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func webpChunk(fourCC string, payload []byte) []byte {
+	b := append([]byte(fourCC), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(payload)))
+	b = append(b, payload...)
+	if len(payload)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func webpFile(chunks ...[]byte) []byte {
+	b := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, c := range chunks {
+		b = append(b, c...)
+	}
+	binary.LittleEndian.PutUint32(b[4:8], uint32(len(b)-8))
+	return b
+}
+
+// vp8x is an extended header with the given flags and canvas size.
+func vp8x(flags byte, w, h int) []byte {
+	w, h = w-1, h-1
+	return webpChunk("VP8X", []byte{flags, 0, 0, 0, byte(w), byte(w >> 8), byte(w >> 16), byte(h), byte(h >> 8), byte(h >> 16)})
+}
+
+// vp8l is a lossless bitstream header of w x h; the pixels are not checked.
+func vp8l(w, h int) []byte {
+	bits := uint32(w-1) | uint32(h-1)<<14
+	p := []byte{0x2f, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(p[1:], bits)
+	return webpChunk("VP8L", p)
+}
+
+// vp8 is a lossy key frame header of w x h.
+func vp8(w, h int) []byte {
+	p := []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(p[6:], uint16(w))
+	binary.LittleEndian.PutUint16(p[8:], uint16(h))
+	return webpChunk("VP8 ", p)
+}
+
+func TestNormalizeWebP(t *testing.T) {
+	const (
+		flagAlpha = 0x10
+		flagEXIF  = 0x08
+		flagXMP   = 0x04
+	)
+	exif := webpChunk("EXIF", []byte("Exif\x00\x00GPS 14.5995N 120.9842E")) // odd length, padded
+	xmp := webpChunk("XMP ", []byte("<x:xmpmeta/>"))
+
+	tests := []struct {
+		name          string
+		data          []byte
+		want          []byte // nil: compare only the size
+		width, height int
+		err           error
+	}{
+		{
+			name:  "EXIF and XMP removed, flags cleared",
+			data:  webpFile(vp8x(flagAlpha|flagEXIF|flagXMP, 640, 480), vp8l(640, 480), exif, xmp),
+			want:  webpFile(vp8x(flagAlpha, 640, 480), vp8l(640, 480)),
+			width: 640, height: 480,
+		},
+		{
+			name:  "metadata before the image data",
+			data:  webpFile(vp8x(flagEXIF, 300, 200), exif, vp8(300, 200)),
+			want:  webpFile(vp8x(0, 300, 200), vp8(300, 200)),
+			width: 300, height: 200,
+		},
+		{
+			name:  "simple lossy file kept as is",
+			data:  webpFile(vp8(120, 90)),
+			want:  webpFile(vp8(120, 90)),
+			width: 120, height: 90,
+		},
+		{
+			name:  "simple lossless file kept as is",
+			data:  webpFile(vp8l(33, 17)),
+			want:  webpFile(vp8l(33, 17)),
+			width: 33, height: 17,
+		},
+		{
+			name: "canvas too large",
+			data: webpFile(vp8x(0, 10000, 10000), vp8l(16, 16)),
+			err:  ErrImageTooBig,
+		},
+		{
+			name: "chunk runs past the end",
+			data: webpFile(vp8x(0, 16, 16), vp8l(16, 16))[:40],
+			err:  ErrInvalidImage,
+		},
+		{
+			name: "no image chunk",
+			data: webpFile(exif),
+			err:  ErrInvalidImage,
+		},
+		{
+			name: "not RIFF",
+			data: []byte("RIFX\x00\x00\x00\x00WEBP"),
+			err:  ErrInvalidImage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := normalizeWebP(tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("normalizeWebP error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !bytes.Equal(img.Data, tt.want) {
+				t.Errorf("normalizeWebP data = %q\nwant %q", img.Data, tt.want)
+			}
+			if bytes.Contains(img.Data, []byte("EXIF")) || bytes.Contains(img.Data, []byte("XMP ")) {
+				t.Error("metadata chunk left in the file")
+			}
+			if img.Width != tt.width || img.Height != tt.height {
+				t.Errorf("size = %dx%d, want %dx%d", img.Width, img.Height, tt.width, tt.height)
+			}
+			if img.ContentType != "image/webp" {
+				t.Errorf("content type = %q", img.ContentType)
+			}
+		})
+	}
+}
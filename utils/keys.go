@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Server keys (document signing, signed file URLs) come from an environment
+// variable in production. Without it a key is generated on first use and
+// kept in KeysDir, which must survive redeploys: documents signed and links
+// handed out with the old key stop verifying once it is lost.
+
+// legacyKeysDir is where generated keys used to be kept, outside the Docker
+// volume.
+const legacyKeysDir = "./keys"
+
+// KeysDir is where generated keys are kept: KEYS_DIR, or storage/keys, which
+// the Docker image keeps on its /app/storage volume.
+func KeysDir() string {
+	if dir := os.Getenv("KEYS_DIR"); dir != "" {
+		return dir
+	}
+	return "./storage/keys"
+}
+
+// readKeyFile reads a generated key, moving one left in the legacy
+// directory by an earlier version into KeysDir.
+func readKeyFile(name string) ([]byte, error) {
+	file := filepath.Join(KeysDir(), name)
+	raw, err := os.ReadFile(file)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return raw, err
+	}
+	raw, err = os.ReadFile(filepath.Join(legacyKeysDir, name))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(KeysDir(), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, raw, 0600); err != nil {
+		return nil, err
+	}
+	log.Println("⚠️ Moved", name, "from", legacyKeysDir, "to", KeysDir())
+	return raw, nil
+}
+
+// loadOrCreateKey returns the base64 key in env, or the one kept in KeysDir
+// under name, generating size random bytes there if there is none yet. what
+// describes a valid key in errors, e.g. "32-byte seed".
+func loadOrCreateKey(env, name, what string, size int, valid func([]byte) bool) ([]byte, error) {
+	if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || !valid(key) {
+			return nil, fmt.Errorf("%s must be a base64 %s", env, what)
+		}
+		return key, nil
+	}
+
+	file := filepath.Join(KeysDir(), name)
+	raw, err := readKeyFile(name)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+		if err != nil || !valid(key) {
+			return nil, fmt.Errorf("%s is not a valid %s", file, what)
+		}
+		return key, nil
+	}
+	// An unreadable key is not replaced, that would orphan what it signed
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(KeysDir(), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		return nil, err
+	}
+	log.Printf("⚠️ Generated a new %s in %s (set %s to choose one)\n", name, file, env)
+	return key, nil
+}
@@ -1,66 +1,140 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"net"
 	"net/smtp"
+	"os"
+	"time"
 )
 
-// SendEmail sends an HTML email using Gmail SMTP port 465 (SSL/TLS)
-// Railway blocks port 587, so we use 465 with direct TLS instead
+// smtpConfig is the mail account, read from the environment:
+// SMTP_USERNAME and SMTP_PASSWORD (for Gmail, an app password), with
+// SMTP_HOST, SMTP_PORT and SMTP_FROM defaulting to Gmail on port 465 and
+// the username.
+type smtpConfig struct {
+	host, port         string
+	username, password string
+	from               string
+}
+
+func loadSMTPConfig() (smtpConfig, error) {
+	cfg := smtpConfig{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     os.Getenv("SMTP_PORT"),
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("SMTP_FROM"),
+	}
+	if cfg.username == "" || cfg.password == "" {
+		return smtpConfig{}, fmt.Errorf("email is not configured: SMTP_USERNAME and SMTP_PASSWORD must be set")
+	}
+	if cfg.host == "" {
+		cfg.host = "smtp.gmail.com"
+	}
+	// Railway blocks port 587, so the default is 465 with direct TLS
+	if cfg.port == "" {
+		cfg.port = "465"
+	}
+	if cfg.from == "" {
+		cfg.from = cfg.username
+	}
+	return cfg, nil
+}
+
+// SendEmail sends an HTML email.
 func SendEmail(to, subject, body string) error {
-	from := "glensssg@gmail.com"
-	password := "ueaejehhkllpvlgv" // Gmail App Password (no spaces)
+	log.Printf("📧 Sending email to: %s | Subject: %s", to, subject)
+	return sendMessage(to, subject, []byte("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n"+body))
+}
+
+// SendEmailWithAttachment sends an HTML email with a single file attached
+// (e.g. a PDF statement).
+func SendEmailWithAttachment(to, subject, body, filename, contentType string, data []byte) error {
+	log.Printf("📧 Sending email with attachment to: %s | Subject: %s | File: %s", to, subject, filename)
 
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "465"
+	boundary := fmt.Sprintf("umboundary%d", time.Now().UnixNano())
 
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=\"%s\"\r\n\r\n", boundary)
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+	buf.WriteString(body)
+	buf.WriteString("\r\n")
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	fmt.Fprintf(&buf, "Content-Type: %s; name=\"%s\"\r\n", contentType, filename)
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	fmt.Fprintf(&buf, "Content-Disposition: attachment; filename=\"%s\"\r\n\r\n", filename)
+
+	// RFC 2045: base64 lines of at most 76 characters
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return sendMessage(to, subject, buf.Bytes())
+}
+
+// sendMessage puts the envelope headers in front of a MIME body (which
+// starts with its own Content-Type header) and delivers it.
+func sendMessage(to, subject string, mimeBody []byte) error {
 	if to == "" {
 		log.Println("❌ SendEmail: recipient email is empty")
 		return fmt.Errorf("recipient email is empty")
 	}
+	cfg, err := loadSMTPConfig()
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return err
+	}
 
-	log.Printf("📧 Sending email to: %s | Subject: %s", to, subject)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: University of Manila <%s>\r\n", cfg.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", subject)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.Write(mimeBody)
 
-	msg := []byte(fmt.Sprintf(
-		"From: University of Manila <%s>\r\n"+
-			"To: %s\r\n"+
-			"Subject: %s\r\n"+
-			"MIME-Version: 1.0\r\n"+
-			"Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n"+
-			"%s",
-		from, to, subject, body,
-	))
+	return deliver(cfg, to, buf.Bytes())
+}
 
+func deliver(cfg smtpConfig, to string, msg []byte) error {
 	// Port 465 = immediate TLS (no STARTTLS)
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: false,
-		ServerName:         smtpHost,
+		ServerName:         cfg.host,
 	}
 
-	conn, err := tls.Dial("tcp", net.JoinHostPort(smtpHost, smtpPort), tlsConfig)
+	conn, err := tls.Dial("tcp", net.JoinHostPort(cfg.host, cfg.port), tlsConfig)
 	if err != nil {
 		log.Printf("❌ TLS dial failed: %v", err)
 		return fmt.Errorf("TLS dial failed: %w", err)
 	}
 	defer conn.Close()
 
-	client, err := smtp.NewClient(conn, smtpHost)
+	client, err := smtp.NewClient(conn, cfg.host)
 	if err != nil {
 		log.Printf("❌ SMTP client failed: %v", err)
 		return fmt.Errorf("SMTP client failed: %w", err)
 	}
 	defer client.Close()
 
-	auth := smtp.PlainAuth("", from, password, smtpHost)
+	auth := smtp.PlainAuth("", cfg.username, cfg.password, cfg.host)
 	if err = client.Auth(auth); err != nil {
 		log.Printf("❌ Auth failed: %v", err)
 		return fmt.Errorf("SMTP auth failed: %w", err)
 	}
 
-	if err = client.Mail(from); err != nil {
+	if err = client.Mail(cfg.from); err != nil {
 		log.Printf("❌ MAIL FROM failed: %v", err)
 		return fmt.Errorf("MAIL FROM failed: %w", err)
 	}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// Document signing key (Ed25519): a base64 32-byte seed in
+// DOCUMENT_SIGNING_KEY, or one generated in KeysDir.
+
+var (
+	signingKey     ed25519.PrivateKey
+	signingKeyErr  error
+	signingKeyOnce sync.Once
+)
+
+func loadSigningKey() {
+	seed, err := loadOrCreateKey("DOCUMENT_SIGNING_KEY", "document_signing.key",
+		fmt.Sprintf("%d-byte seed", ed25519.SeedSize), ed25519.SeedSize,
+		func(k []byte) bool { return len(k) == ed25519.SeedSize })
+	if err != nil {
+		signingKeyErr = err
+		return
+	}
+	signingKey = ed25519.NewKeyFromSeed(seed)
+}
+
+func documentSigningKey() (ed25519.PrivateKey, error) {
+	signingKeyOnce.Do(loadSigningKey)
+	return signingKey, signingKeyErr
+}
+
+// SigningPublicKey returns the public half of the document signing key and
+// its key ID (the first 8 bytes of its SHA-256, hex).
+func SigningPublicKey() (ed25519.PublicKey, string, error) {
+	key, err := documentSigningKey()
+	if err != nil {
+		return nil, "", err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	return pub, SigningKeyID(pub), nil
+}
+
+// SigningKeyID is the ID documents record for the key that signed them.
+func SigningKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SignDocumentHash signs a document's SHA-256 digest and returns the
+// signature and the ID of the key that made it.
+func SignDocumentHash(digest []byte) ([]byte, string, error) {
+	key, err := documentSigningKey()
+	if err != nil {
+		return nil, "", err
+	}
+	_, keyID, err := SigningPublicKey()
+	if err != nil {
+		return nil, "", err
+	}
+	return ed25519.Sign(key, digest), keyID, nil
+}
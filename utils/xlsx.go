@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Minimal single-sheet XLSX support (Office Open XML) built on archive/zip
+// and encoding/xml, enough for grade sheets and report exports. Styles,
+// formulas and multiple sheets are not supported.
+
+// WriteXLSX builds a workbook with one sheet. Cells that look like numbers are
+// written as numbers, everything else as inline strings.
+func WriteXLSX(sheetName string, rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + xmlEscape(sheetTitle(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheetXML(rows)},
+	}
+
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, f.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sheetTitle applies Excel's sheet name rules: max 31 chars, no []:*?/\
+func sheetTitle(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet1"
+	}
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	return name
+}
+
+func sheetXML(rows [][]string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			ref := columnName(j) + strconv.Itoa(i+1)
+			if cell == "" {
+				continue
+			}
+			if isXLSXNumber(cell) {
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, cell)
+			} else {
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(cell))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// isXLSXNumber keeps values like student numbers ("2024-00001") or
+// zero-padded codes ("007") as text.
+func isXLSXNumber(s string) bool {
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return false
+	}
+	if len(s) > 1 && s[0] == '0' && s[1] != '.' {
+		return false
+	}
+	return !strings.ContainsAny(s, "eExX+") && len(s) < 16
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// columnName converts a zero-based index to A, B, ..., Z, AA, AB, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// columnIndex converts the letters of a cell reference ("AB12") to a
+// zero-based column index. Anything past MaxXLSXColumns comes back as
+// MaxXLSXColumns rather than overflowing.
+func columnIndex(ref string) int {
+	idx := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		idx = idx*26 + int(r-'A'+1)
+		if idx > MaxXLSXColumns {
+			return MaxXLSXColumns
+		}
+	}
+	return idx - 1
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+// Limits on what ReadXLSX accepts. Rows and columns are placed by the
+// positions the file declares, so without a cap a few hundred bytes naming
+// cell XFD1048576 would allocate gigabytes.
+const (
+	MaxXLSXRows    = 10000
+	MaxXLSXColumns = 256
+	maxXLSXPart    = 32 << 20 // decompressed size of one XML part
+)
+
+// ReadXLSX returns the cells of the first worksheet as strings. Empty cells
+// in the middle of a row come back as "".
+func ReadXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a valid .xlsx file: %w", err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	readXML := func(name string, v interface{}) error {
+		f, ok := files[name]
+		if !ok {
+			return fmt.Errorf("%s missing from workbook", name)
+		}
+		if f.UncompressedSize64 > maxXLSXPart {
+			return fmt.Errorf("%s is too large", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		// The declared size can lie; a part cut off here fails to decode
+		return xml.NewDecoder(io.LimitReader(rc, maxXLSXPart)).Decode(v)
+	}
+
+	sheetPath, err := firstSheetPath(readXML)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []string
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := readXML("xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		for _, si := range sst.Items {
+			shared = append(shared, si.String())
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				Ref    string   `xml:"r,attr"`
+				Type   string   `xml:"t,attr"`
+				Value  string   `xml:"v"`
+				Inline xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := readXML(sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	if len(sheet.Rows) > MaxXLSXRows {
+		return nil, fmt.Errorf("sheet has more than %d rows", MaxXLSXRows)
+	}
+	for _, r := range sheet.Rows {
+		if r.R > MaxXLSXRows {
+			return nil, fmt.Errorf("row %d is past the %d-row limit", r.R, MaxXLSXRows)
+		}
+		// Fill skipped (completely empty) rows so row numbers stay meaningful
+		for r.R > 0 && len(rows) < r.R-1 {
+			rows = append(rows, nil)
+		}
+
+		var row []string
+		for i, cell := range r.Cells {
+			col := i
+			if cell.Ref != "" {
+				col = columnIndex(cell.Ref)
+			}
+			if col >= MaxXLSXColumns {
+				return nil, fmt.Errorf("cell %s is past the %d-column limit", cell.Ref, MaxXLSXColumns)
+			}
+			for len(row) < col {
+				row = append(row, "")
+			}
+
+			var value string
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("cell %s: bad shared string index", cell.Ref)
+				}
+				value = shared[n]
+			case "inlineStr":
+				value = cell.Inline.String()
+			default:
+				value = cell.Value
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// firstSheetPath follows workbook.xml -> workbook.xml.rels to the first sheet,
+// falling back to the conventional sheet1.xml.
+func firstSheetPath(readXML func(string, interface{}) error) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := readXML("xl/workbook.xml", &wb); err != nil {
+		return "", err
+	}
+
+	var rels struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if len(wb.Sheets) > 0 && readXML("xl/_rels/workbook.xml.rels", &rels) == nil {
+		for _, rel := range rels.Items {
+			if rel.ID != wb.Sheets[0].RID {
+				continue
+			}
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+	}
+
+	return "xl/worksheets/sheet1.xml", nil
+}